@@ -0,0 +1,177 @@
+// Package apperr defines the error codes the API puts in every error
+// response. Codes are part of the public contract: add new ones freely, but
+// never rename or reuse one.
+package apperr
+
+import (
+	"context"
+	"errors"
+)
+
+type Code string
+
+const (
+	ValidationFailed   Code = "VALIDATION_FAILED"
+	Unauthenticated    Code = "UNAUTHENTICATED"
+	Forbidden          Code = "FORBIDDEN"
+	InsufficientScope  Code = "INSUFFICIENT_SCOPE"
+	IPNotAllowed       Code = "IP_NOT_ALLOWED"
+	InvalidSignature   Code = "INVALID_SIGNATURE"
+	NotFound           Code = "NOT_FOUND"
+	PostNotFound       Code = "POST_NOT_FOUND"
+	Conflict           Code = "CONFLICT"
+	LinkExpired        Code = "LINK_EXPIRED"
+	RequestTooLarge    Code = "REQUEST_TOO_LARGE"
+	AttachmentNotFound Code = "ATTACHMENT_NOT_FOUND"
+	HookNotFound       Code = "HOOK_NOT_FOUND"
+	UnsupportedFormat  Code = "UNSUPPORTED_FORMAT"
+	InvalidBackup      Code = "INVALID_BACKUP"
+	InvalidConfig      Code = "INVALID_CONFIG"
+	Internal           Code = "INTERNAL"
+	Overloaded         Code = "OVERLOADED"
+	Timeout            Code = "TIMEOUT"
+)
+
+// Definition describes a code. Status is the HTTP status it is sent with,
+// spelled as a number so that the domain, which returns these errors, stays
+// clear of net/http.
+type Definition struct {
+	Code        Code   `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+var catalog = []Definition{
+	{ValidationFailed, 400, "The request body or parameters are malformed or fail validation."},
+	{Unauthenticated, 401, "No valid credentials were presented."},
+	{Forbidden, 403, "The caller is not allowed to perform this action."},
+	{InsufficientScope, 403, "The token is valid but lacks the scope this endpoint requires."},
+	{IPNotAllowed, 403, "The client address is not permitted by the IP filter."},
+	{InvalidSignature, 403, "The signed URL is missing its signature or it does not match."},
+	{NotFound, 404, "No route matches the request."},
+	{PostNotFound, 404, "The requested post does not exist."},
+	{Conflict, 409, "The request conflicts with the current state of the resource."},
+	{LinkExpired, 410, "The signed URL or one-time token has expired or was already used."},
+	{RequestTooLarge, 413, "The request body exceeds limits.max_body_bytes."},
+	{AttachmentNotFound, 404, "The requested attachment does not exist."},
+	{HookNotFound, 404, "The REST hook subscription does not exist or belongs to another token."},
+	{UnsupportedFormat, 501, "The requested response format is not supported."},
+	{InvalidBackup, 422, "The uploaded backup archive is not valid."},
+	{InvalidConfig, 422, "The reloaded configuration failed validation."},
+	{Internal, 500, "An unexpected error occurred; quote the request_id when reporting it."},
+	{Overloaded, 503, "The server is shedding load; retry after the Retry-After delay."},
+	{Timeout, 504, "The request did not finish within its deadline."},
+}
+
+// Catalog lists every code in a stable order.
+func Catalog() []Definition {
+	return append([]Definition(nil), catalog...)
+}
+
+func (c Code) Status() int {
+	for _, def := range catalog {
+		if def.Code == c {
+			return def.Status
+		}
+	}
+	return 500
+}
+
+// Kind is what went wrong, whichever code says so in detail. Errors with a
+// code match their kind with errors.Is, so errors.Is(err, ErrNotFound)
+// holds for POST_NOT_FOUND and ATTACHMENT_NOT_FOUND alike. Code that has no
+// code of its own to give can wrap a kind instead, as in
+// fmt.Errorf("slug %q is taken: %w", slug, ErrConflict); From gives it the
+// kind's generic code and the message as the detail.
+type Kind struct {
+	name string
+	code Code
+}
+
+func (k *Kind) Error() string { return k.name }
+
+var (
+	ErrNotFound     = &Kind{"not found", NotFound}
+	ErrConflict     = &Kind{"conflict", Conflict}
+	ErrValidation   = &Kind{"validation failed", ValidationFailed}
+	ErrUnauthorized = &Kind{"unauthorized", Unauthenticated}
+)
+
+// Kind classifies c by its status; codes of none of the kinds return nil.
+func (c Code) Kind() *Kind {
+	switch c.Status() {
+	case 404:
+		return ErrNotFound
+	case 409:
+		return ErrConflict
+	case 400, 422:
+		return ErrValidation
+	case 401, 403:
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// Error pairs a code with a detail that is safe to show the client. Err keeps
+// the underlying cause for logs and errors.Is.
+type Error struct {
+	Code   Code
+	Detail string
+	Err    error
+}
+
+func New(code Code, detail string) *Error {
+	return &Error{Code: code, Detail: detail}
+}
+
+// Wrap exposes err's message as the detail; use it only for errors that are
+// meant for the client, such as binding failures.
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Detail: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func (e *Error) Is(target error) bool {
+	kind, ok := target.(*Kind)
+	return ok && e.Code.Kind() == kind
+}
+
+// From classifies any error. Errors without a code become INTERNAL with no
+// detail so internals never leak into responses.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	var kind *Kind
+	switch {
+	case errors.As(err, &kind):
+		return &Error{Code: kind.code, Detail: err.Error(), Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: Timeout, Detail: "request timed out", Err: err}
+	default:
+		return &Error{Code: Internal, Err: err}
+	}
+}
+
+// Invalid classifies a request binding error: one that already has a code,
+// such as the REQUEST_TOO_LARGE the transport reads an oversized body as,
+// keeps it, and anything else is a validation failure.
+func Invalid(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Wrap(ValidationFailed, err)
+}
@@ -0,0 +1,242 @@
+// Package client is a Go client for the gosolid HTTP API. The request and
+// response types are the ones the server binds and renders, so the two
+// can't drift apart.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosolid/apperr"
+)
+
+// Error is a problem response from the server.
+type Error struct {
+	Status    int         `json:"status"`
+	Code      apperr.Code `json:"code"`
+	Detail    string      `json:"detail"`
+	RequestID string      `json:"request_id"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return string(e.Code) + ": " + e.Detail
+	}
+	return fmt.Sprintf("%s (%d)", e.Code, e.Status)
+}
+
+// IsNotFound reports whether err is a POST_NOT_FOUND problem.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == apperr.PostNotFound
+}
+
+// PostClient calls the /posts endpoints. Safe requests (GET and DELETE) are
+// retried up to Retries times on network errors, 429 and 5xx responses,
+// waiting for Retry-After when the server sends one and RetryBackoff doubled
+// per attempt otherwise. Creates and updates are never retried.
+type PostClient struct {
+	BaseURL      string
+	Token        string
+	HTTPClient   *http.Client
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+func NewPostClient(baseURL, token string) *PostClient {
+	return &PostClient{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		Token:        token,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		Retries:      2,
+		RetryBackoff: 200 * time.Millisecond,
+	}
+}
+
+func (c *PostClient) CreatePost(ctx context.Context, req NewPostReq) (NewPostResp, error) {
+	var resp NewPostResp
+	_, err := c.do(ctx, http.MethodPost, "/posts", req, &resp)
+	return resp, err
+}
+
+func (c *PostClient) GetPost(ctx context.Context, id int) (GetPostResp, error) {
+	var resp GetPostResp
+	_, err := c.do(ctx, http.MethodGet, "/posts/"+strconv.Itoa(id), nil, &resp)
+	return resp, err
+}
+
+// ListPosts returns one page and the total number of posts.
+func (c *PostClient) ListPosts(ctx context.Context, opts ListOptions) ([]ListPostDataResp, int, error) {
+	return listPosts[ListPostDataResp](ctx, c, "/posts", url.Values{}, opts)
+}
+
+// ListPostSummaries is ListPosts with excerpts and timestamps in place of
+// whole bodies.
+func (c *PostClient) ListPostSummaries(ctx context.Context, opts ListOptions) ([]PostSummaryResp, int, error) {
+	return listPosts[PostSummaryResp](ctx, c, "/posts", url.Values{"view": {"summary"}}, opts)
+}
+
+// SearchPosts returns one page of the posts whose title or body matches q
+// and the total number of matches.
+func (c *PostClient) SearchPosts(ctx context.Context, q string, opts ListOptions) ([]ListPostDataResp, int, error) {
+	return listPosts[ListPostDataResp](ctx, c, "/posts/search", url.Values{"q": {q}}, opts)
+}
+
+func listPosts[T any](ctx context.Context, c *PostClient, path string, query url.Values, opts ListOptions) ([]T, int, error) {
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.After > 0 {
+		query.Set("after", strconv.Itoa(opts.After))
+	}
+	if opts.Query != "" {
+		query.Set("q", opts.Query)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var posts []T
+	header, err := c.do(ctx, http.MethodGet, path, nil, &posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := strconv.Atoi(header.Get("X-Total-Count"))
+	if err != nil {
+		total = opts.Offset + len(posts)
+	}
+	return posts, total, nil
+}
+
+// AllPosts iterates over every post, fetching pageSize at a time. Iteration
+// stops after the first error, which is yielded with a zero post.
+func (c *PostClient) AllPosts(ctx context.Context, pageSize int) iter.Seq2[ListPostDataResp, error] {
+	return func(yield func(ListPostDataResp, error) bool) {
+		for offset := 0; ; {
+			posts, total, err := c.ListPosts(ctx, ListOptions{Limit: pageSize, Offset: offset})
+			if err != nil {
+				yield(ListPostDataResp{}, err)
+				return
+			}
+			for _, post := range posts {
+				if !yield(post, nil) {
+					return
+				}
+			}
+			offset += len(posts)
+			if len(posts) == 0 || offset >= total {
+				return
+			}
+		}
+	}
+}
+
+// UpdatePost sends only the non-nil fields of req.
+func (c *PostClient) UpdatePost(ctx context.Context, id int, req UpdatePostReq) (UpdatePostResp, error) {
+	body := map[string]string{}
+	if req.Title != nil {
+		body["title"] = *req.Title
+	}
+	if req.Body != nil {
+		body["body"] = *req.Body
+	}
+	var resp UpdatePostResp
+	_, err := c.do(ctx, http.MethodPatch, "/posts/"+strconv.Itoa(id), body, &resp)
+	return resp, err
+}
+
+func (c *PostClient) DeletePost(ctx context.Context, id int) error {
+	_, err := c.do(ctx, http.MethodDelete, "/posts/"+strconv.Itoa(id), nil, nil)
+	return err
+}
+
+func (c *PostClient) do(ctx context.Context, method, path string, body, out any) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	retries := 0
+	if method == http.MethodGet || method == http.MethodDelete {
+		retries = c.Retries
+	}
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= retries {
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			return resp.Header, decodeResponse(resp, out)
+		}
+
+		wait := backoff
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		backoff *= 2
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *PostClient) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	if resp.StatusCode >= 300 {
+		apiErr := &Error{Status: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Code == "" {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
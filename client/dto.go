@@ -0,0 +1,62 @@
+package client
+
+import "time"
+
+type NewPostReq struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type NewPostResp struct {
+	ID    int    `json:"id"`
+	UID   string `json:"uid,omitempty"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type GetPostResp struct {
+	ID    int    `json:"id"`
+	UID   string `json:"uid,omitempty"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type ListPostDataResp struct {
+	ID    int    `json:"id"`
+	UID   string `json:"uid,omitempty"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// PostSummaryResp is a post as GET /posts?view=summary lists it: the body
+// cut to an excerpt, plus timestamps.
+type PostSummaryResp struct {
+	ID        int       `json:"id"`
+	UID       string    `json:"uid,omitempty"`
+	Title     string    `json:"title"`
+	Excerpt   string    `json:"excerpt"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type UpdatePostReq struct {
+	Title *string `json:"title"`
+	Body  *string `json:"body"`
+}
+
+type UpdatePostResp struct {
+	ID    int    `json:"id"`
+	UID   string `json:"uid,omitempty"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// ListOptions pages through GET /posts. A zero Limit returns every post.
+// Sort, After and Query narrow down GET /posts only.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Sort   string // such as "-created_at"; ID order when empty
+	After  int    // only posts past this ID; needs an ID order
+	Query  string
+}
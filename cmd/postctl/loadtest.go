@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gosolid/client"
+)
+
+// loadOp is one kind of request the load test sends, picked in proportion
+// to weight.
+type loadOp struct {
+	name   string
+	weight int
+	run    func(ctx context.Context) error
+}
+
+type loadConfig struct {
+	rps         float64
+	duration    time.Duration
+	concurrency int
+}
+
+// OpReport is the outcome of one kind of request. Latencies are in
+// milliseconds and only count requests that got a response.
+type OpReport struct {
+	Op       string  `json:"op"`
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	P50      float64 `json:"p50_ms"`
+	P90      float64 `json:"p90_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+}
+
+// LoadReport is what loadtest prints. Skipped counts requests that were due
+// while all workers were busy, which means the server, or the
+// concurrency, couldn't keep up with the target rate.
+type LoadReport struct {
+	TargetRPS   float64    `json:"target_rps"`
+	AchievedRPS float64    `json:"achieved_rps"`
+	Seconds     float64    `json:"seconds"`
+	Skipped     int        `json:"skipped"`
+	Ops         []OpReport `json:"ops"`
+}
+
+// parseMix reads --mix, such as "list=3,get=6,create=1".
+func parseMix(mix string, known []string) (map[string]int, error) {
+	weights := map[string]int{}
+	for _, part := range strings.Split(mix, ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("--mix: %q is not op=weight", part)
+		}
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("--mix: unknown op %q; expected %s", name, strings.Join(known, ", "))
+		}
+		weight, err := strconv.Atoi(raw)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("--mix: weight of %s must be a non-negative integer", name)
+		}
+		weights[name] = weight
+	}
+	return weights, nil
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.999999) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// runLoad starts requests at cfg.rps for cfg.duration, open loop: a request
+// is due every 1/rps whether or not earlier ones have finished, up to
+// cfg.concurrency at once.
+func runLoad(ctx context.Context, cfg loadConfig, ops []loadOp) LoadReport {
+	total := 0
+	for _, op := range ops {
+		total += op.weight
+	}
+	pick := func() int {
+		n := rand.IntN(total)
+		for i, op := range ops {
+			if n < op.weight {
+				return i
+			}
+			n -= op.weight
+		}
+		return len(ops) - 1
+	}
+
+	var mu sync.Mutex
+	latencies := make([][]time.Duration, len(ops))
+	errs := make([]int, len(ops))
+	skipped := 0
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+	sem := make(chan struct{}, cfg.concurrency)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.rps))
+	defer ticker.Stop()
+	var wg sync.WaitGroup
+	start := time.Now()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+		select {
+		case sem <- struct{}{}:
+		default:
+			skipped++
+			continue
+		}
+		i := pick()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// Requests still running at the end get their own time to
+			// finish rather than being cut off by ctx.
+			began := time.Now()
+			err := ops[i].run(context.WithoutCancel(ctx))
+			elapsed := time.Since(began)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[i]++
+				return
+			}
+			latencies[i] = append(latencies[i], elapsed)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report := LoadReport{TargetRPS: cfg.rps, Seconds: elapsed.Seconds(), Skipped: skipped}
+	var all []time.Duration
+	requests := 0
+	for i, op := range ops {
+		if op.weight == 0 {
+			continue
+		}
+		report.Ops = append(report.Ops, opReport(op.name, latencies[i], errs[i]))
+		all = append(all, latencies[i]...)
+		requests += len(latencies[i]) + errs[i]
+	}
+	totalErrs := 0
+	for _, n := range errs {
+		totalErrs += n
+	}
+	report.Ops = append(report.Ops, opReport("total", all, totalErrs))
+	report.AchievedRPS = float64(requests) / elapsed.Seconds()
+	return report
+}
+
+func opReport(name string, latencies []time.Duration, errs int) OpReport {
+	slices.Sort(latencies)
+	return OpReport{
+		Op:       name,
+		Requests: len(latencies) + errs,
+		Errors:   errs,
+		P50:      milliseconds(percentile(latencies, 50)),
+		P90:      milliseconds(percentile(latencies, 90)),
+		P99:      milliseconds(percentile(latencies, 99)),
+		Max:      milliseconds(percentile(latencies, 100)),
+	}
+}
+
+func (p *printer) loadReport(r LoadReport) error {
+	if p.json {
+		return p.encode(r)
+	}
+	fmt.Fprintf(p.w, "%.0f req/s over %.1fs (target %.0f), %d skipped\n\n", r.AchievedRPS, r.Seconds, r.TargetRPS, r.Skipped)
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OP\tREQUESTS\tERRORS\tP50 MS\tP90 MS\tP99 MS\tMAX MS\t")
+	for _, op := range r.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t\n", op.Op, op.Requests, op.Errors, op.P50, op.P90, op.P99, op.Max)
+	}
+	return tw.Flush()
+}
+
+// loadOps builds the requests for weights. get picks among the IDs of the
+// first page of posts and the posts created during the run, creating one
+// first if there are none and creates are in the mix.
+func loadOps(ctx context.Context, posts *client.PostClient, weights map[string]int, pageSize int) ([]loadOp, error) {
+	var mu sync.Mutex
+	var ids []int
+	if weights["get"] > 0 {
+		page, _, err := posts.ListPosts(ctx, client.ListOptions{Limit: 100})
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range page {
+			ids = append(ids, post.ID)
+		}
+		if len(ids) == 0 && weights["create"] == 0 {
+			return nil, fmt.Errorf("there are no posts to get; create some or add create to --mix")
+		}
+		if len(ids) == 0 {
+			post, err := posts.CreatePost(ctx, client.NewPostReq{Title: "loadtest 0", Body: "Created by postctl loadtest."})
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, post.ID)
+		}
+	}
+
+	var seq int
+	return []loadOp{
+		{name: "list", weight: weights["list"], run: func(ctx context.Context) error {
+			_, _, err := posts.ListPosts(ctx, client.ListOptions{Limit: pageSize})
+			return err
+		}},
+		{name: "get", weight: weights["get"], run: func(ctx context.Context) error {
+			mu.Lock()
+			id := ids[rand.IntN(len(ids))]
+			mu.Unlock()
+			_, err := posts.GetPost(ctx, id)
+			return err
+		}},
+		{name: "create", weight: weights["create"], run: func(ctx context.Context) error {
+			mu.Lock()
+			seq++
+			title := "loadtest " + strconv.Itoa(seq)
+			mu.Unlock()
+			post, err := posts.CreatePost(ctx, client.NewPostReq{Title: title, Body: "Created by postctl loadtest."})
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			ids = append(ids, post.ID)
+			mu.Unlock()
+			return nil
+		}},
+	}, nil
+}
+
+func newLoadTestCmd(posts *client.PostClient, printer func(*cobra.Command) *printer) *cobra.Command {
+	var cfg loadConfig
+	var mix string
+	var pageSize int
+	cmd := &cobra.Command{
+		Use:   "loadtest",
+		Short: "Send requests at a fixed rate and report latency percentiles",
+		Long: "Send requests at --rps for --duration and report latency percentiles per\n" +
+			"request kind. --mix weights the kinds: list (GET /posts?limit=--page-size),\n" +
+			"get (GET /posts/:id) and create (POST /posts, which leaves posts behind).\n" +
+			"Requests aren't retried, so errors are the server's.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.rps <= 0 || cfg.duration <= 0 || cfg.concurrency <= 0 || pageSize <= 0 {
+				return fmt.Errorf("--rps, --duration, --concurrency and --page-size must be positive")
+			}
+			weights, err := parseMix(mix, []string{"list", "get", "create"})
+			if err != nil {
+				return err
+			}
+			if weights["list"]+weights["get"]+weights["create"] == 0 {
+				return fmt.Errorf("--mix: at least one weight must be positive")
+			}
+
+			load := *posts
+			load.Retries = 0
+			load.HTTPClient = &http.Client{
+				Timeout:   posts.HTTPClient.Timeout,
+				Transport: &http.Transport{MaxIdleConnsPerHost: cfg.concurrency},
+			}
+			ops, err := loadOps(cmd.Context(), &load, weights, pageSize)
+			if err != nil {
+				return err
+			}
+			return printer(cmd).loadReport(runLoad(cmd.Context(), cfg, ops))
+		},
+	}
+	cmd.Flags().Float64Var(&cfg.rps, "rps", 50, "requests per second to start")
+	cmd.Flags().DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to send requests for")
+	cmd.Flags().IntVar(&cfg.concurrency, "concurrency", 32, "most requests in flight at once")
+	cmd.Flags().StringVar(&mix, "mix", "list=1,get=1", "weights of list, get and create requests")
+	cmd.Flags().IntVar(&pageSize, "page-size", 20, "limit of list requests")
+	return cmd
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gosolid/client"
+)
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{{50, 50 * time.Millisecond}, {90, 90 * time.Millisecond}, {99, 99 * time.Millisecond}, {100, 100 * time.Millisecond}, {0, time.Millisecond}} {
+		if got := percentile(sorted, tc.p); got != tc.want {
+			t.Errorf("percentile(%v) = %v, want %v", tc.p, got, tc.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of nothing = %v, want 0", got)
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	known := []string{"list", "get", "create"}
+	weights, err := parseMix("list=3, get=6,create=0", known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights["list"] != 3 || weights["get"] != 6 || weights["create"] != 0 {
+		t.Fatalf("got %v", weights)
+	}
+	for _, bad := range []string{"list", "list=x", "list=-1", "delete=1"} {
+		if _, err := parseMix(bad, known); err == nil {
+			t.Errorf("parseMix(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRunLoad(t *testing.T) {
+	var lists, gets atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/posts":
+			lists.Add(1)
+			w.Header().Set("X-Total-Count", "1")
+			json.NewEncoder(w).Encode([]client.ListPostDataResp{{ID: 1, Title: "t"}})
+		case "/posts/1":
+			gets.Add(1)
+			json.NewEncoder(w).Encode(client.GetPostResp{ID: 1, Title: "t"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	posts := client.NewPostClient(srv.URL, "")
+	posts.Retries = 0
+	ops, err := loadOps(context.Background(), posts, map[string]int{"list": 1, "get": 1}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := runLoad(context.Background(), loadConfig{rps: 200, duration: 500 * time.Millisecond, concurrency: 8}, ops)
+
+	total := report.Ops[len(report.Ops)-1]
+	if total.Op != "total" || total.Requests == 0 || total.Errors != 0 {
+		t.Fatalf("unexpected total %+v", total)
+	}
+	// loadOps lists once itself to find IDs to get.
+	if int64(total.Requests) != lists.Load()-1+gets.Load() {
+		t.Fatalf("report counts %d requests, server saw %d lists and %d gets", total.Requests, lists.Load()-1, gets.Load())
+	}
+	if len(report.Ops) != 3 {
+		t.Fatalf("want list, get and total, got %+v", report.Ops)
+	}
+	if total.P50 > total.P99 || total.P99 > total.Max {
+		t.Fatalf("percentiles out of order: %+v", total)
+	}
+}
+
+func TestRunLoadCountsErrors(t *testing.T) {
+	failing := loadOp{name: "fail", weight: 1, run: func(context.Context) error { return errors.New("boom") }}
+	report := runLoad(context.Background(), loadConfig{rps: 100, duration: 200 * time.Millisecond, concurrency: 4}, []loadOp{failing})
+	op := report.Ops[0]
+	if op.Requests == 0 || op.Errors != op.Requests || op.Max != 0 {
+		t.Fatalf("unexpected report %+v", op)
+	}
+}
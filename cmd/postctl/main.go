@@ -0,0 +1,75 @@
+// Command postctl manages posts on a gosolid server from the command line,
+// and replays the event logs of its events storage backend.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gosolid/client"
+)
+
+// Post is what every command prints. The client's response types all share
+// its fields, so they convert to it directly.
+type Post client.GetPostResp
+
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
+
+func newRootCmd() *cobra.Command {
+	posts := client.NewPostClient("", "")
+	var server string
+	var timeout time.Duration
+	var output string
+
+	root := &cobra.Command{
+		Use:           "postctl",
+		Short:         "Manage posts on a gosolid server",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("--output must be table or json, got %q", output)
+			}
+			posts.BaseURL = strings.TrimSuffix(server, "/")
+			posts.HTTPClient = &http.Client{Timeout: timeout}
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&server, "server", envOr("POSTCTL_SERVER", "http://localhost:8080"), "server URL (POSTCTL_SERVER)")
+	root.PersistentFlags().StringVar(&posts.Token, "token", os.Getenv("API_TOKEN"), "API token (API_TOKEN)")
+	root.PersistentFlags().DurationVar(&timeout, "timeout", 30*time.Second, "request timeout")
+	root.PersistentFlags().IntVar(&posts.Retries, "retries", posts.Retries, "retries for reads and deletes")
+	root.PersistentFlags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+
+	printer := func(cmd *cobra.Command) *printer {
+		return &printer{w: cmd.OutOrStdout(), json: output == "json"}
+	}
+	root.AddCommand(
+		newCreateCmd(posts, printer),
+		newGetCmd(posts, printer),
+		newListCmd(posts, printer),
+		newUpdateCmd(posts, printer),
+		newDeleteCmd(posts),
+		newSearchCmd(posts, printer),
+		newLoadTestCmd(posts, printer),
+		newReplayCmd(printer),
+	)
+	return root
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "postctl:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"gosolid/client"
+)
+
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p *printer) post(post Post) error {
+	if p.json {
+		return p.encode(post)
+	}
+	return p.table([]Post{post})
+}
+
+func (p *printer) posts(posts []Post) error {
+	if p.json {
+		return p.encode(posts)
+	}
+	return p.table(posts)
+}
+
+func (p *printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (p *printer) table(posts []Post) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tBODY")
+	for _, post := range posts {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", post.ID, post.Title, truncate(post.Body, 60))
+	}
+	return tw.Flush()
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+func postID(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("post id must be an integer, got %q", arg)
+	}
+	return id, nil
+}
+
+func newCreateCmd(posts *client.PostClient, printer func(*cobra.Command) *printer) *cobra.Command {
+	var title, body string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a post",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			post, err := posts.CreatePost(cmd.Context(), client.NewPostReq{Title: title, Body: body})
+			if err != nil {
+				return err
+			}
+			return printer(cmd).post(Post(post))
+		},
+	}
+	cmd.Flags().StringVar(&title, "title", "", "post title")
+	cmd.Flags().StringVar(&body, "body", "", "post body")
+	return cmd
+}
+
+func newGetCmd(posts *client.PostClient, printer func(*cobra.Command) *printer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get ID",
+		Short: "Show a post",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := postID(args[0])
+			if err != nil {
+				return err
+			}
+			post, err := posts.GetPost(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+			return printer(cmd).post(Post(post))
+		},
+	}
+}
+
+// allPosts collects every post that keep accepts.
+func allPosts(cmd *cobra.Command, posts *client.PostClient, keep func(Post) bool) ([]Post, error) {
+	all := []Post{}
+	for post, err := range posts.AllPosts(cmd.Context(), 100) {
+		if err != nil {
+			return nil, err
+		}
+		if keep(Post(post)) {
+			all = append(all, Post(post))
+		}
+	}
+	return all, nil
+}
+
+func newListCmd(posts *client.PostClient, printer func(*cobra.Command) *printer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List posts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, err := allPosts(cmd, posts, func(Post) bool { return true })
+			if err != nil {
+				return err
+			}
+			return printer(cmd).posts(all)
+		},
+	}
+}
+
+func newUpdateCmd(posts *client.PostClient, printer func(*cobra.Command) *printer) *cobra.Command {
+	var title, body string
+	cmd := &cobra.Command{
+		Use:   "update ID",
+		Short: "Update a post",
+		Long: "Update a post. Only the flags given are sent; whether omitted fields are\n" +
+			"kept or cleared depends on the server's partial_patch feature flag.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := postID(args[0])
+			if err != nil {
+				return err
+			}
+			var req client.UpdatePostReq
+			if cmd.Flags().Changed("title") {
+				req.Title = &title
+			}
+			if cmd.Flags().Changed("body") {
+				req.Body = &body
+			}
+			post, err := posts.UpdatePost(cmd.Context(), id, req)
+			if err != nil {
+				return err
+			}
+			return printer(cmd).post(Post(post))
+		},
+	}
+	cmd.Flags().StringVar(&title, "title", "", "new title")
+	cmd.Flags().StringVar(&body, "body", "", "new body")
+	return cmd
+}
+
+func newDeleteCmd(posts *client.PostClient) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID...",
+		Short: "Delete posts",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, arg := range args {
+				id, err := postID(arg)
+				if err != nil {
+					return err
+				}
+				if err := posts.DeletePost(cmd.Context(), id); err != nil {
+					return fmt.Errorf("delete %d: %w", id, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newSearchCmd(posts *client.PostClient, printer func(*cobra.Command) *printer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "search QUERY",
+		Short: "List posts whose title or body matches QUERY",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			found, _, err := posts.SearchPosts(cmd.Context(), args[0], client.ListOptions{})
+			if err != nil {
+				return err
+			}
+			matches := make([]Post, 0, len(found))
+			for _, post := range found {
+				matches = append(matches, Post(post))
+			}
+			return printer(cmd).posts(matches)
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gosolid/eventstore"
+)
+
+// errStopReplay ends a replay at --until or --at.
+var errStopReplay = errors.New("stop replay")
+
+func newReplayCmd(printer func(*cobra.Command) *printer) *cobra.Command {
+	var until int64
+	var at string
+	cmd := &cobra.Command{
+		Use:   "replay LOG",
+		Short: "Rebuild the posts of an events backend log",
+		Long: "Rebuild the posts of a log written by the events storage backend, from\n" +
+			"the file itself rather than a server. --until and --at stop the replay\n" +
+			"after a commit or at a time, to see the posts as they were then. The\n" +
+			"replay fails on a log whose events don't fit together. Bodies are as the\n" +
+			"backend stored them, so encrypted if the server encrypts posts.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var stopAt time.Time
+			if at != "" {
+				var err error
+				if stopAt, err = time.Parse(time.RFC3339, at); err != nil {
+					return fmt.Errorf("--at must be an RFC 3339 time, got %q", at)
+				}
+			}
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			projection := eventstore.NewProjection()
+			err = eventstore.Read(f, func(c eventstore.Commit) error {
+				if until > 0 && c.Seq > until {
+					return errStopReplay
+				}
+				for _, e := range c.Events {
+					if !stopAt.IsZero() && e.At.After(stopAt) {
+						return errStopReplay
+					}
+				}
+				return projection.Apply(c)
+			})
+			if err != nil && !errors.Is(err, errStopReplay) {
+				return fmt.Errorf("replay %s: %w", args[0], err)
+			}
+
+			found := projection.List()
+			posts := make([]Post, 0, len(found))
+			for _, post := range found {
+				posts = append(posts, Post{ID: post.ID, UID: post.UID, Title: post.Title, Body: post.Body})
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "replayed %d commits\n", projection.Seq)
+			return printer(cmd).posts(posts)
+		},
+	}
+	cmd.Flags().Int64Var(&until, "until", 0, "stop after this commit")
+	cmd.Flags().StringVar(&at, "at", "", "stop before the first event after this RFC 3339 time")
+	return cmd
+}
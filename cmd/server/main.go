@@ -0,0 +1,33 @@
+// Command server is the gosolid API server: the HTTP and gRPC APIs over the
+// configured storage backend, and the notifiers, mailers and background jobs
+// around them.
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"gosolid/server"
+)
+
+func main() {
+	startedAt := time.Now()
+
+	cfg, err := server.LoadConfig(os.Args[1:])
+	if err != nil {
+		fatal("load config", err)
+	}
+	app, err := server.NewApp(cfg, os.Args[1:], startedAt)
+	if err != nil {
+		fatal("start", err)
+	}
+	if err := app.Run(); err != nil {
+		fatal("stop", err)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
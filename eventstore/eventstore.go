@@ -0,0 +1,246 @@
+// Package eventstore keeps posts as an append-only log of what happened to
+// them, one JSON commit per line, and folds the log back into posts. The
+// server's events storage backend writes it; postctl replay reads it.
+package eventstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	PostCreated  Type = "PostCreated"
+	TitleChanged Type = "TitleChanged"
+	BodyChanged  Type = "BodyChanged"
+	PostDeleted  Type = "PostDeleted"
+)
+
+// Event is one thing that happened to a post at At. PostCreated carries the
+// whole post, and UpdatedAt when it was last changed after At, as imported
+// and restored posts can be; TitleChanged and BodyChanged carry the new
+// value.
+type Event struct {
+	Type      Type      `json:"type"`
+	PostID    int       `json:"post_id"`
+	UID       string    `json:"uid,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	At        time.Time `json:"at"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Commit is the events of one write, applied all together. Seq numbers the
+// commits of a log from 1.
+type Commit struct {
+	Seq    int64   `json:"seq"`
+	Events []Event `json:"events"`
+}
+
+// Log is an event log open for appending. A failed append is cut back off
+// the file, and the log then refuses further appends: after a failed sync
+// what reached the disk is unknown, so it has to be opened again.
+type Log struct {
+	mu     sync.Mutex
+	f      file
+	seq    int64
+	end    int64
+	failed error
+}
+
+// file is the part of *os.File a Log writes through.
+type file interface {
+	io.WriteSeeker
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// Open opens the log at path, creating it if needed, and passes fn its
+// commits in order. A last line without its newline is a commit that was
+// being written when the process stopped; it never happened, so it is cut
+// off.
+func Open(path string, fn func(Commit) error) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{f: f}
+	end, err := read(f, func(c Commit) error {
+		l.seq = c.Seq
+		return fn(c)
+	})
+	if err == nil {
+		err = f.Truncate(end)
+	}
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("eventstore: %s: %w", path, err)
+	}
+	l.end = end
+	return l, nil
+}
+
+// Read passes fn the commits of a log in order, skipping an unfinished last
+// line like Open.
+func Read(r io.Reader, fn func(Commit) error) error {
+	_, err := read(r, fn)
+	return err
+}
+
+// read returns the offset after the last whole line.
+func read(r io.Reader, fn func(Commit) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var end int64
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return end, nil
+		}
+		if err != nil {
+			return end, err
+		}
+		end += int64(len(b))
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		var c Commit
+		if err := json.Unmarshal(b, &c); err != nil {
+			return end, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(c); err != nil {
+			return end, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// Append writes events as the next commit and syncs the file, so the
+// commit survives a crash once Append returns. If it fails, the commit is
+// truncated off again so a replay doesn't apply it, and the log fails.
+func (l *Log) Append(events ...Event) (Commit, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failed != nil {
+		return Commit{}, fmt.Errorf("eventstore: log failed earlier: %w", l.failed)
+	}
+	c := Commit{Seq: l.seq + 1, Events: events}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return Commit{}, err
+	}
+	b = append(b, '\n')
+	if _, err := l.f.Write(b); err != nil {
+		return Commit{}, l.fail(fmt.Errorf("eventstore: append: %w", err))
+	}
+	if err := l.f.Sync(); err != nil {
+		return Commit{}, l.fail(fmt.Errorf("eventstore: sync: %w", err))
+	}
+	l.seq, l.end = c.Seq, l.end+int64(len(b))
+	return c, nil
+}
+
+// fail cuts the file back to the last commit that was synced and keeps err
+// for later appends.
+func (l *Log) fail(err error) error {
+	l.failed = err
+	if terr := l.f.Truncate(l.end); terr != nil {
+		return errors.Join(err, fmt.Errorf("eventstore: truncate: %w", terr))
+	}
+	if _, serr := l.f.Seek(l.end, io.SeekStart); serr != nil {
+		return errors.Join(err, fmt.Errorf("eventstore: seek: %w", serr))
+	}
+	if serr := l.f.Sync(); serr != nil {
+		return errors.Join(err, fmt.Errorf("eventstore: sync: %w", serr))
+	}
+	return err
+}
+
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Post is a post as the events have left it.
+type Post struct {
+	ID        int       `json:"id"`
+	UID       string    `json:"uid,omitempty"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Apply folds e into post, which is the zero Post for PostCreated.
+func (e Event) Apply(post *Post) {
+	switch e.Type {
+	case PostCreated:
+		*post = Post{ID: e.PostID, UID: e.UID, Title: e.Title, Body: e.Body, CreatedAt: e.At, UpdatedAt: e.At}
+		if !e.UpdatedAt.IsZero() {
+			post.UpdatedAt = e.UpdatedAt
+		}
+	case TitleChanged:
+		post.Title, post.UpdatedAt = e.Title, e.At
+	case BodyChanged:
+		post.Body, post.UpdatedAt = e.Body, e.At
+	}
+}
+
+// Projection is the posts a log describes up to commit Seq. LastID is the
+// highest post ID it ever created, deleted posts included, so new posts
+// don't take the ID of a deleted one.
+type Projection struct {
+	Posts  map[int]Post
+	Seq    int64
+	LastID int
+}
+
+func NewProjection() *Projection {
+	return &Projection{Posts: make(map[int]Post)}
+}
+
+// Apply folds commit c into the projection. Events that don't fit, such as
+// changing a post that doesn't exist, mean the log is damaged.
+func (p *Projection) Apply(c Commit) error {
+	for _, e := range c.Events {
+		post, exists := p.Posts[e.PostID]
+		switch {
+		case e.Type == PostCreated && exists:
+			return fmt.Errorf("commit %d: post %d created twice", c.Seq, e.PostID)
+		case e.Type == PostCreated:
+			p.LastID = max(p.LastID, e.PostID)
+		case e.Type != TitleChanged && e.Type != BodyChanged && e.Type != PostDeleted:
+			return fmt.Errorf("commit %d: unknown event %q", c.Seq, e.Type)
+		case !exists:
+			return fmt.Errorf("commit %d: %s for missing post %d", c.Seq, e.Type, e.PostID)
+		}
+		if e.Type == PostDeleted {
+			delete(p.Posts, e.PostID)
+			continue
+		}
+		e.Apply(&post)
+		p.Posts[e.PostID] = post
+	}
+	p.Seq = c.Seq
+	return nil
+}
+
+// List returns the posts in ID order.
+func (p *Projection) List() []Post {
+	posts := make([]Post, 0, len(p.Posts))
+	for _, post := range p.Posts {
+		posts = append(posts, post)
+	}
+	slices.SortFunc(posts, func(a, b Post) int { return a.ID - b.ID })
+	return posts
+}
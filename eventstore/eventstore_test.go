@@ -0,0 +1,84 @@
+package eventstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// faultyFile fails the next write part way through, or the next sync.
+type faultyFile struct {
+	*os.File
+	failWrite, failSync bool
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (f *faultyFile) Write(b []byte) (int, error) {
+	if f.failWrite {
+		f.failWrite = false
+		n, _ := f.File.Write(b[:len(b)/2])
+		return n, errDiskFull
+	}
+	return f.File.Write(b)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errDiskFull
+	}
+	return f.File.Sync()
+}
+
+func created(id int) Event {
+	return Event{Type: PostCreated, PostID: id, Title: "post", At: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func TestAppendFailureLeavesNoCommit(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		fault faultyFile
+	}{
+		{"partial write", faultyFile{failWrite: true}},
+		{"failed sync", faultyFile{failSync: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "posts.events.jsonl")
+			l, err := Open(path, func(Commit) error { return nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := l.Append(created(1)); err != nil {
+				t.Fatal(err)
+			}
+			fault := tc.fault
+			fault.File = l.f.(*os.File)
+			l.f = &fault
+
+			if _, err := l.Append(created(2)); !errors.Is(err, errDiskFull) {
+				t.Fatalf("failing append: err = %v, want %v", err, errDiskFull)
+			}
+			// The file would take this one, but the log has failed.
+			if _, err := l.Append(created(3)); !errors.Is(err, errDiskFull) {
+				t.Errorf("append after a failure: err = %v, want the earlier failure", err)
+			}
+			l.Close()
+
+			projection := NewProjection()
+			l, err = Open(path, projection.Apply)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer l.Close()
+			if _, ok := projection.Posts[1]; !ok || len(projection.Posts) != 1 || projection.Seq != 1 {
+				t.Fatalf("replayed %d posts up to commit %d, want only post 1 from commit 1", len(projection.Posts), projection.Seq)
+			}
+			if c, err := l.Append(created(2)); err != nil || c.Seq != 2 {
+				t.Errorf("append after reopening = commit %d, %v; want commit 2", c.Seq, err)
+			}
+		})
+	}
+}
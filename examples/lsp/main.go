@@ -0,0 +1,68 @@
+// Command lsp shows a Liskov substitution violation: PostHandler takes any
+// PostUpdateNotifier but only calls the ones it recognises, so swapping in
+// LineNotifier silently drops notifications. Run it and PUT /posts/1; only
+// the email notifier logs anything.
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/domain"
+)
+
+type EmailNotifier struct{}
+
+func (n *EmailNotifier) NotifyPostUpdated(ctx context.Context, post domain.Post, action domain.Action) error {
+	log.Printf("email: post %s: %s", action, post.Title)
+	return nil
+}
+
+type LineNotifier struct{}
+
+func (n *LineNotifier) NotifyPostUpdated(ctx context.Context, post domain.Post, action domain.Action) error {
+	log.Printf("line: post %s: %s", action, post.Title)
+	return nil
+}
+
+type PostHandler struct {
+	notifiers []domain.PostUpdateNotifier
+}
+
+func NewPostHandler(notifiers ...domain.PostUpdateNotifier) *PostHandler {
+	return &PostHandler{
+		notifiers: notifiers,
+	}
+}
+
+func (h *PostHandler) UpdateHandler(c *gin.Context) {
+	// Update logic for the post
+	// ...
+	post := domain.Post{Title: "Updated post"}
+
+	// Notify all registered notifiers about the post update
+	for _, notifier := range h.notifiers {
+		// Violating LSP by using a type switch
+		// This is not a good practice as it breaks the Liskov Substitution Principle
+		// Ideally, we should not check the type of notifier here
+		// Instead, we should rely on the interface contract
+		switch notifier.(type) {
+		case *EmailNotifier:
+			// Specific logic for EmailNotifier if needed
+			if err := notifier.NotifyPostUpdated(c.Request.Context(), post, domain.ActionUpdate); err != nil {
+				c.Error(err) // Handle error appropriately
+				return
+			}
+		}
+	}
+	c.JSON(200, gin.H{"status": "post updated"})
+}
+
+func main() {
+	r := gin.Default()
+	postHandler := NewPostHandler(&EmailNotifier{}, &LineNotifier{})
+	r.PUT("/posts/:id", postHandler.UpdateHandler)
+	r.Run(":8080") // Start the server
+}
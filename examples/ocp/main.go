@@ -0,0 +1,113 @@
+// Command ocp shows the open/closed principle: PostHandler is closed for
+// modification but open for extension, because any PostUpdateNotifier can
+// be added without touching it. Run it and PUT /posts/1 to see both
+// notifiers fire.
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/domain"
+)
+
+type EmailService interface {
+	SendEmail(ctx context.Context, sender string, recipient string, subject string, body string) error
+}
+
+// GmailService stands in for Gmail: it logs the email instead of sending it.
+type GmailService struct{}
+
+func NewGmailService() *GmailService {
+	return &GmailService{}
+}
+
+func (s *GmailService) SendEmail(ctx context.Context, sender string, recipient string, subject string, body string) error {
+	log.Printf("gmail: from %s to %s: %s\n%s", sender, recipient, subject, body)
+	return nil
+}
+
+type EmailNotifier struct {
+	emailService EmailService
+	recipient    string
+}
+
+func (n *EmailNotifier) NotifyPostUpdated(ctx context.Context, post domain.Post, action domain.Action) error {
+	subject := "Post Update Notification"
+	body := "The post has been updated with the following details:\n" +
+		"Title: " + post.Title + "\n" +
+		"Body: " + post.Body + "\n" +
+		"Action: " + string(action)
+	return n.emailService.SendEmail(ctx, "noreply@example.com", n.recipient, subject, body)
+}
+
+type LineService interface {
+	SendMessage(ctx context.Context, message string) error
+}
+
+// LineNotifyService stands in for LINE Notify: it logs the message instead of
+// sending it.
+type LineNotifyService struct{}
+
+func NewLineService() *LineNotifyService {
+	return &LineNotifyService{}
+}
+
+func (s *LineNotifyService) SendMessage(ctx context.Context, message string) error {
+	log.Printf("line: %s", message)
+	return nil
+}
+
+// LineNotifier was added after EmailNotifier without changing PostHandler.
+type LineNotifier struct {
+	lineService LineService
+}
+
+func (n *LineNotifier) NotifyPostUpdated(ctx context.Context, post domain.Post, action domain.Action) error {
+	return n.lineService.SendMessage(ctx, "Post "+string(action)+": "+post.Title)
+}
+
+type PostHandler struct {
+	notifiers []domain.PostUpdateNotifier
+}
+
+func NewPostHandler(notifiers ...domain.PostUpdateNotifier) *PostHandler {
+	return &PostHandler{
+		notifiers: notifiers,
+	}
+}
+
+func (h *PostHandler) UpdateHandler(c *gin.Context) {
+	// Update logic for the post
+	// ...
+	post := domain.Post{Title: "Updated post"}
+
+	// Notify all registered notifiers about the post update
+	for _, notifier := range h.notifiers {
+		if err := notifier.NotifyPostUpdated(c.Request.Context(), post, domain.ActionUpdate); err != nil {
+			c.Error(err) // Handle error appropriately
+			return
+		}
+	}
+	c.JSON(200, gin.H{"status": "post updated"})
+}
+
+func main() {
+	r := gin.Default()
+
+	newGmailService := NewGmailService()
+	emailNotifier := &EmailNotifier{emailService: newGmailService, recipient: "author@example.com"}
+	lineNotifier := &LineNotifier{lineService: NewLineService()}
+	postHandler := NewPostHandler(emailNotifier, lineNotifier)
+
+	logReqMiddleware := func(c *gin.Context) {
+		// Log the incoming request
+		log.Printf("Incoming request: %s %s", c.Request.Method, c.Request.URL)
+		c.Next()
+	}
+
+	r.PUT("/posts/:id", logReqMiddleware, postHandler.UpdateHandler)
+	r.Run(":8080") // Start the server
+}
@@ -0,0 +1,7 @@
+// Package integration runs the server's whole HTTP flow against real
+// backends in containers: the storage backends, and MinIO behind the S3
+// blob store. Its tests need Docker and build only with the integration
+// tag, so plain go test stays fast:
+//
+//	go test -tags integration ./integration
+package integration
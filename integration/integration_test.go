@@ -0,0 +1,262 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	tcminio "github.com/testcontainers/testcontainers-go/modules/minio"
+
+	"gosolid/client"
+	"gosolid/server"
+)
+
+// token is what the tests authenticate with; it has every scope.
+const token = "integration-token"
+
+// bucket is where the S3 blob store keeps attachments.
+const bucket = "gosolid-attachments"
+
+// s3 is the MinIO container TestMain starts, shared by every test.
+var s3 struct {
+	endpoint, user, password string
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	container, err := tcminio.Run(ctx, "minio/minio:RELEASE.2024-01-16T16-07-38Z")
+	if err != nil {
+		log.Printf("start minio: %v", err)
+		return 1
+	}
+	defer container.Terminate(ctx)
+
+	s3.user, s3.password = container.Username, container.Password
+	if s3.endpoint, err = container.ConnectionString(ctx); err != nil {
+		log.Printf("minio endpoint: %v", err)
+		return 1
+	}
+	mc, err := minio.New(s3.endpoint, &minio.Options{Creds: credentials.NewStaticV4(s3.user, s3.password, "")})
+	if err != nil {
+		log.Printf("minio client: %v", err)
+		return 1
+	}
+	if err := mc.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+		log.Printf("create bucket: %v", err)
+		return 1
+	}
+	return m.Run()
+}
+
+// startServer starts a server on a free port with its storage backend
+// named backend, its attachments in MinIO and everything it writes under
+// dir, and shuts it down when the test ends.
+func startServer(t *testing.T, backend, dir string) (*server.App, *client.PostClient) {
+	t.Helper()
+	secrets := filepath.Join(dir, "secrets.env")
+	env := fmt.Sprintf("S3_ACCESS_KEY=%s\nS3_SECRET_KEY=%s\n", s3.user, s3.password)
+	if err := os.WriteFile(secrets, []byte(env), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := server.DefaultConfig()
+	cfg.GRPCAddr = ""
+	cfg.Log.Level = "warn"
+	cfg.Storage.Backend = backend
+	cfg.Storage.EventLog = filepath.Join(dir, "posts.events.jsonl")
+	cfg.Secrets.File = secrets
+	cfg.Blobs.Backend = "s3"
+	cfg.Blobs.S3 = server.S3Config{Endpoint: s3.endpoint, Bucket: bucket, PathStyle: true, PartSize: 16 << 20}
+
+	tokens := server.NewTokenStore()
+	tokens.Add(token, server.Principal{Name: "integration", Scopes: []server.Scope{server.ScopePostsRead, server.ScopePostsWrite, server.ScopeAdmin}})
+	srv, err := server.NewServer(server.WithConfig(cfg), server.WithPort(0), server.WithAuth(tokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+	})
+	return srv, client.NewPostClient("http://"+srv.Addr().String(), token)
+}
+
+func TestPostLifecycle(t *testing.T) {
+	for _, backend := range []string{"memory", "events"} {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			_, posts := startServer(t, backend, t.TempDir())
+
+			created, err := posts.CreatePost(ctx, client.NewPostReq{Title: "hello", Body: "first post"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := posts.GetPost(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != "hello" || got.Body != "first post" {
+				t.Errorf("GetPost = %+v, want the created post", got)
+			}
+
+			title := "hello again"
+			updated, err := posts.UpdatePost(ctx, created.ID, client.UpdatePostReq{Title: &title, Body: &got.Body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Title != title {
+				t.Errorf("UpdatePost title = %q, want %q", updated.Title, title)
+			}
+
+			list, total, err := posts.ListPosts(ctx, client.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 1 || len(list) != 1 || list[0].Title != title {
+				t.Errorf("ListPosts = %+v (total %d), want the updated post", list, total)
+			}
+
+			if err := posts.DeletePost(ctx, created.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := posts.GetPost(ctx, created.ID); !client.IsNotFound(err) {
+				t.Errorf("GetPost after delete: err = %v, want not found", err)
+			}
+		})
+	}
+}
+
+// TestEventsBackendReplays restarts a server on the same event log and
+// expects the posts written before the restart.
+func TestEventsBackendReplays(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	srv, posts := startServer(t, "events", dir)
+	created, err := posts.CreatePost(ctx, client.NewPostReq{Title: "kept", Body: "across restarts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, posts = startServer(t, "events", dir)
+	got, err := posts.GetPost(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "kept" {
+		t.Errorf("GetPost after restart = %+v, want the post written before it", got)
+	}
+}
+
+func TestAttachmentsOnS3(t *testing.T) {
+	ctx := context.Background()
+	srv, posts := startServer(t, "memory", t.TempDir())
+	post, err := posts.CreatePost(ctx, client.NewPostReq{Title: "with attachment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := fmt.Sprintf("http://%s/posts/%d/attachments", srv.Addr(), post.ID)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "stored in minio")
+	mw.Close()
+	resp := do(t, http.MethodPost, base, mw.FormDataContentType(), &form)
+	expectStatus(t, resp, http.StatusCreated)
+
+	resp = do(t, http.MethodGet, base, "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	var list []server.AttachmentResp
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "notes.txt" || !strings.Contains(list[0].URL, s3.endpoint) {
+		t.Fatalf("attachments = %+v, want notes.txt with a presigned URL", list)
+	}
+
+	// The API redirects to a URL MinIO serves without the token.
+	resp = do(t, http.MethodGet, base+"/notes.txt", "", nil)
+	expectStatus(t, resp, http.StatusFound)
+	location := resp.Header.Get("Location")
+	if !strings.Contains(location, s3.endpoint) {
+		t.Fatalf("download redirects to %q, want a presigned MinIO URL", location)
+	}
+	download, err := http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer download.Body.Close()
+	if body, _ := io.ReadAll(download.Body); download.StatusCode != http.StatusOK || string(body) != "stored in minio" {
+		t.Errorf("presigned download = %d %q, want the uploaded content", download.StatusCode, body)
+	}
+
+	resp = do(t, http.MethodDelete, base+"/notes.txt", "", nil)
+	expectStatus(t, resp, http.StatusNoContent)
+	resp = do(t, http.MethodGet, base+"/notes.txt", "", nil)
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+// do sends an authenticated request without following redirects; the
+// response body is closed when the test ends.
+func do(t *testing.T, method, url, contentType string, body io.Reader) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := noRedirects.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+var noRedirects = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}}
+
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: status %d, want %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, body)
+	}
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestImportBoundary keeps the domain free of HTTP and frameworks: what it
+// imports, directly or through other packages, may be the standard library,
+// except net/http, and gosolid packages.
+func TestImportBoundary(t *testing.T) {
+	// One line per dependency: its path, whether it is in the standard
+	// library and the packages it imports.
+	out, err := exec.Command("go", "list", "-deps",
+		"-f", `{{.ImportPath}} {{.Standard}}{{range .Imports}} {{.}}{{end}}`, ".").Output()
+	if err != nil {
+		t.Fatalf("go list: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		pkg, standard, imports := fields[0], fields[1] == "true", fields[2:]
+		if standard {
+			continue
+		}
+		if !strings.HasPrefix(pkg, "gosolid/") {
+			t.Errorf("the domain depends on %s, which isn't in the standard library", pkg)
+			continue
+		}
+		for _, imp := range imports {
+			if imp == "net/http" {
+				t.Errorf("%s imports net/http, which the domain then depends on; HTTP belongs to the transport", pkg)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ListSort is the order ListPosts returns posts in: a field, descending
+// when prefixed with "-". The zero value is ascending ID order.
+type ListSort string
+
+const (
+	SortID          ListSort = "id"
+	SortIDDesc      ListSort = "-id"
+	SortCreated     ListSort = "created_at"
+	SortCreatedDesc ListSort = "-created_at"
+	SortUpdated     ListSort = "updated_at"
+	SortUpdatedDesc ListSort = "-updated_at"
+	SortTitle       ListSort = "title"
+	SortTitleDesc   ListSort = "-title"
+)
+
+var listSorts = []ListSort{SortID, SortIDDesc, SortCreated, SortCreatedDesc, SortUpdated, SortUpdatedDesc, SortTitle, SortTitleDesc}
+
+func (s ListSort) Valid() bool { return s == "" || slices.Contains(listSorts, s) }
+
+func (s ListSort) ByID() bool { return s == "" || s == SortID || s == SortIDDesc }
+
+// ListOptions selects the posts ListPosts returns. The zero value is every
+// post in ID order.
+type ListOptions struct {
+	Limit  int // 0 for no limit
+	Offset int
+	// After is a cursor for the ID orders: only posts past this ID in the
+	// direction of the sort are listed. Unlike Offset it costs nothing to
+	// page deep with. Other orders ignore it, except the updated_at ones
+	// when AfterUpdatedAt is set: then only posts past the one with ID
+	// After, updated at AfterUpdatedAt, are listed.
+	After          int
+	AfterUpdatedAt time.Time
+	Sort           ListSort
+	// Query keeps the posts whose title or body contains it, ignoring case.
+	Query string
+	// CreatedAfter and UpdatedAfter, unless zero, keep the posts created or
+	// updated after them.
+	CreatedAfter time.Time
+	UpdatedAfter time.Time
+	// Excerpt, if positive, cuts each body to at most that many runes, for
+	// listings that only show the start of it. A backend may then skip
+	// loading the rest.
+	Excerpt int
+}
+
+func (o ListOptions) Filtered() bool {
+	return o.Query != "" || !o.CreatedAfter.IsZero() || !o.UpdatedAfter.IsZero()
+}
+
+func (o ListOptions) Match() func(Post) bool {
+	query := MatchQuery(o.Query)
+	return func(p Post) bool {
+		return query(p) &&
+			(o.CreatedAfter.IsZero() || p.CreatedAt.After(o.CreatedAfter)) &&
+			(o.UpdatedAfter.IsZero() || p.UpdatedAt.After(o.UpdatedAfter))
+	}
+}
+
+// Cursor is the post After points past, if o.Sort takes a cursor.
+func (o ListOptions) Cursor() (Post, bool) {
+	switch {
+	case o.After <= 0:
+		return Post{}, false
+	case o.Sort.ByID():
+		return Post{ID: o.After}, true
+	case (o.Sort == SortUpdated || o.Sort == SortUpdatedDesc) && !o.AfterUpdatedAt.IsZero():
+		return Post{ID: o.After, UpdatedAt: o.AfterUpdatedAt}, true
+	}
+	return Post{}, false
+}
+
+// Compare orders posts by o.Sort, breaking ties by ID in the same direction.
+func (o ListOptions) Compare(a, b Post) int {
+	field, desc := strings.CutPrefix(string(o.Sort), "-")
+	var c int
+	switch ListSort(field) {
+	case SortCreated:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case SortUpdated:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case SortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+// CutBodies shortens the bodies of posts to o.Excerpt runes in place.
+func (o ListOptions) CutBodies(posts []Post) {
+	if o.Excerpt <= 0 {
+		return
+	}
+	for i := range posts {
+		posts[i].Body = TruncateRunes(posts[i].Body, o.Excerpt)
+	}
+}
+
+// MatchQuery is the search used without a search index: a case-insensitive
+// substring match on title or body.
+func MatchQuery(q string) func(Post) bool {
+	q = strings.ToLower(q)
+	return func(p Post) bool {
+		return q == "" || strings.Contains(strings.ToLower(p.Title), q) || strings.Contains(strings.ToLower(p.Body), q)
+	}
+}
+
+// TruncateRunes cuts s to n runes, the last an ellipsis, without decoding
+// more of s than it keeps.
+func TruncateRunes(s string, n int) string {
+	count, cut := 0, 0
+	for i := range s {
+		if count == n-1 {
+			cut = i
+		}
+		if count == n {
+			return strings.TrimSpace(s[:cut]) + "…"
+		}
+		count++
+	}
+	return s
+}
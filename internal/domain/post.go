@@ -0,0 +1,63 @@
+// Package domain is the post model the rest of gosolid is built around:
+// posts, what can happen to them, and the ports storage backends and
+// notifiers plug into. It knows nothing of HTTP or any framework; an
+// import-boundary test keeps it that way.
+package domain
+
+import (
+	"context"
+	"time"
+
+	"gosolid/apperr"
+)
+
+type Post struct {
+	ID int
+	// UID is the string ID of posts numbered by a UUIDv7 or ULID generator.
+	UID       string
+	Title     string
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// PostRepository is a post store, or a layer over one. Consumers that only
+// read, like exports, stats and the search index, take a PostReader.
+type PostRepository interface {
+	PostReader
+	PostWriter
+}
+
+type PostReader interface {
+	GetPostByID(ctx context.Context, id int) (Post, error)
+	// ListPosts returns the page of posts opts selects and the number of
+	// posts matching its filters.
+	ListPosts(ctx context.Context, opts ListOptions) ([]Post, int, error)
+	// EachPost calls fn for every post in ID order, stopping at the first
+	// error, without loading them all at once.
+	EachPost(ctx context.Context, fn func(Post) error) error
+}
+
+type PostWriter interface {
+	AddPost(ctx context.Context, newPost Post) (Post, error)
+	// AddPosts adds every post or none of them, returning them with their
+	// IDs in the same order.
+	AddPosts(ctx context.Context, newPosts []Post) ([]Post, error)
+	UpdatePost(ctx context.Context, updatePost Post) (Post, error)
+	DeletePostByID(ctx context.Context, id int) error
+}
+
+var ErrNotFound = apperr.New(apperr.PostNotFound, "post not found")
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// PostUpdateNotifier is told about every write once it has happened.
+type PostUpdateNotifier interface {
+	NotifyPostUpdated(ctx context.Context, post Post, action Action) error
+}
@@ -0,0 +1,197 @@
+// Package fixtures reads seed data for demos, tests and local development
+// from YAML or JSON files and writes it to a post store.
+//
+// A fixture file lists posts, and may list authors and tags:
+//
+//	authors:
+//	  - name: Ada
+//	    email: ada@example.com
+//	tags: [go, solid]
+//	posts:
+//	  - title: Hello
+//	    body: First post.
+//	    author: Ada
+//	    tags: [go]
+//	    created_at: 2024-01-02T15:04:05Z
+//
+// Posts are the only entity so far: authors and tags are read, so files
+// written for a richer model load, but Seed only counts them.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"gosolid/internal/domain"
+)
+
+// File is one fixture file, or several merged by Load.
+type File struct {
+	Authors []Author `json:"authors" yaml:"authors"`
+	Tags    []string `json:"tags" yaml:"tags"`
+	Posts   []Post   `json:"posts" yaml:"posts"`
+}
+
+type Author struct {
+	Name  string `json:"name" yaml:"name"`
+	Email string `json:"email" yaml:"email"`
+}
+
+// Post times are optional: a missing created_at is the time of seeding and
+// a missing updated_at is created_at.
+type Post struct {
+	Title     string    `json:"title" yaml:"title"`
+	Body      string    `json:"body" yaml:"body"`
+	Author    string    `json:"author" yaml:"author"`
+	Tags      []string  `json:"tags" yaml:"tags"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// Parse decodes a fixture file in format, "json" or "yaml". Unknown fields
+// are errors so a misspelt key doesn't quietly seed empty posts.
+func Parse(data []byte, format string) (File, error) {
+	var f File
+	var err error
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// An empty file is an empty fixture, not an error.
+		if err = dec.Decode(&f); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return File{}, fmt.Errorf("fixtures: unsupported format %q", format)
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("fixtures: %w", err)
+	}
+	return f, f.Validate()
+}
+
+// Validate rejects posts without a title.
+func (f File) Validate() error {
+	for i, post := range f.Posts {
+		if strings.TrimSpace(post.Title) == "" {
+			return fmt.Errorf("fixtures: post %d has no title", i)
+		}
+	}
+	return nil
+}
+
+// Load reads the fixture file at path, or every .json, .yaml and .yml file
+// in the directory at path in name order, merged into one File.
+func Load(path string) (File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, err
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return File{}, err
+		}
+		paths = paths[:0]
+		for _, entry := range entries {
+			if _, ok := formatOf(entry.Name()); ok && !entry.IsDir() {
+				paths = append(paths, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(paths)
+	}
+
+	var all File
+	for _, p := range paths {
+		format, ok := formatOf(p)
+		if !ok {
+			return File{}, fmt.Errorf("fixtures: unsupported file type %q", filepath.Ext(p))
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return File{}, err
+		}
+		f, err := Parse(data, format)
+		if err != nil {
+			return File{}, fmt.Errorf("%s: %w", p, err)
+		}
+		all.Authors = append(all.Authors, f.Authors...)
+		all.Tags = append(all.Tags, f.Tags...)
+		all.Posts = append(all.Posts, f.Posts...)
+	}
+	return all, nil
+}
+
+func formatOf(path string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json", true
+	case ".yaml", ".yml":
+		return "yaml", true
+	}
+	return "", false
+}
+
+// Report is what Seed wrote. Authors and tags are counted, once each
+// however many posts name them, but not written.
+type Report struct {
+	Created        int `json:"created"`
+	AuthorsSkipped int `json:"authors_skipped"`
+	TagsSkipped    int `json:"tags_skipped"`
+}
+
+// Seed adds the posts of f in one batch, all or none, in file order.
+func Seed(ctx context.Context, w domain.PostWriter, f File, now time.Time) (Report, error) {
+	authors, tags := map[string]bool{}, map[string]bool{}
+	for _, author := range f.Authors {
+		authors[author.Name] = true
+	}
+	for _, tag := range f.Tags {
+		tags[tag] = true
+	}
+
+	posts := make([]domain.Post, len(f.Posts))
+	for i, p := range f.Posts {
+		if p.Author != "" {
+			authors[p.Author] = true
+		}
+		for _, tag := range p.Tags {
+			tags[tag] = true
+		}
+		post := domain.Post{Title: p.Title, Body: p.Body, CreatedAt: p.CreatedAt.UTC(), UpdatedAt: p.UpdatedAt.UTC()}
+		if p.CreatedAt.IsZero() {
+			post.CreatedAt = now.UTC()
+		}
+		if p.UpdatedAt.IsZero() {
+			post.UpdatedAt = post.CreatedAt
+		}
+		posts[i] = post
+	}
+
+	report := Report{AuthorsSkipped: len(authors), TagsSkipped: len(tags)}
+	if len(posts) == 0 {
+		return report, nil
+	}
+	created, err := w.AddPosts(ctx, posts)
+	if err != nil {
+		return report, err
+	}
+	report.Created = len(created)
+	return report, nil
+}
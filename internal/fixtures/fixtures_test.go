@@ -0,0 +1,60 @@
+package fixtures
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosolid/internal/domain"
+	"gosolid/internal/storage"
+)
+
+func TestLoadAndSeed(t *testing.T) {
+	f, err := Load("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	db := storage.NewDB()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	report, err := Seed(ctx, db, f, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Report{Created: 4, AuthorsSkipped: 2, TagsSkipped: 3}); report != want {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+
+	posts, _, err := db.ListPosts(ctx, domain.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// demo.yaml sorts before more.json.
+	titles := []string{"Single responsibility in practice", "Accepting interfaces", "Draft notes", "Notifiers as plugins"}
+	if len(posts) != len(titles) {
+		t.Fatalf("got %d posts, want %d", len(posts), len(titles))
+	}
+	for i, title := range titles {
+		if posts[i].Title != title {
+			t.Errorf("post %d title = %q, want %q", i, posts[i].Title, title)
+		}
+	}
+	if want := time.Date(2024, 1, 6, 8, 15, 0, 0, time.UTC); !posts[1].UpdatedAt.Equal(want) {
+		t.Errorf("updated_at = %v, want %v", posts[1].UpdatedAt, want)
+	}
+	if !posts[2].CreatedAt.Equal(now) || !posts[2].UpdatedAt.Equal(now) {
+		t.Errorf("undated post times = %v, %v, want %v", posts[2].CreatedAt, posts[2].UpdatedAt, now)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for name, tc := range map[string]struct{ data, format string }{
+		"unknown field": {`posts: [{title: a, titel: b}]`, "yaml"},
+		"missing title": {`{"posts": [{"body": "no title"}]}`, "json"},
+		"format":        {`posts: []`, "toml"},
+	} {
+		if _, err := Parse([]byte(tc.data), tc.format); err == nil {
+			t.Errorf("%s: Parse succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// JSONCodec encodes the JSON the handlers write themselves: streamed lists
+// and exports, cached post bodies and JSON:API documents. encoding/json is
+// always there as "std"; faster codecs register themselves when built with
+// their tag, such as go_json, which switches gin's own rendering too.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	NewEncoder(w io.Writer) JSONEncoder
+}
+
+type JSONEncoder interface {
+	Encode(v any) error
+	SetEscapeHTML(on bool)
+}
+
+type stdJSONCodec struct{}
+
+func (stdJSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (stdJSONCodec) NewEncoder(w io.Writer) JSONEncoder { return json.NewEncoder(w) }
+
+var (
+	jsonCodecs = map[string]JSONCodec{"std": stdJSONCodec{}}
+	// preferredJSONCodec is used when json_codec is empty. A codec built in
+	// with its tag makes itself preferred.
+	preferredJSONCodec = "std"
+
+	codec     JSONCodec = stdJSONCodec{}
+	codecName           = "std"
+)
+
+// Codec returns the codec in use, and CodecName its name.
+func Codec() JSONCodec { return codec }
+
+func CodecName() string { return codecName }
+
+func CodecNames() []string {
+	names := make([]string, 0, len(jsonCodecs))
+	for name := range jsonCodecs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// UseCodec switches to the codec called name, or to the preferred one for
+// "". It is for startup, before anything is encoded.
+func UseCodec(name string) bool {
+	if name == "" {
+		name = preferredJSONCodec
+	}
+	c, ok := jsonCodecs[name]
+	if ok {
+		codec, codecName = c, name
+	}
+	return ok
+}
+
+// JSONRender is gin's render.JSON through the codec in use.
+type JSONRender struct {
+	Data any
+}
+
+func (r JSONRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	body, err := codec.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (r JSONRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+}
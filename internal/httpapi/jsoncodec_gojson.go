@@ -0,0 +1,22 @@
+//go:build go_json
+
+package httpapi
+
+import (
+	"io"
+
+	gojson "github.com/goccy/go-json"
+)
+
+// Built with -tags go_json, goccy/go-json is registered as "go-json" and
+// preferred. gin switches to it for c.JSON under the same tag.
+func init() {
+	jsonCodecs["go-json"] = goJSONCodec{}
+	preferredJSONCodec = "go-json"
+	UseCodec("")
+}
+
+type goJSONCodec struct{}
+
+func (goJSONCodec) Marshal(v any) ([]byte, error)      { return gojson.Marshal(v) }
+func (goJSONCodec) NewEncoder(w io.Writer) JSONEncoder { return gojson.NewEncoder(w) }
@@ -0,0 +1,138 @@
+// Package httpapi is what the HTTP handlers share whichever router serves
+// them: routes written against net/http and adapters that mount them on
+// gin, chi or a ServeMux, problem details, request IDs and the JSON codec.
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+
+	"gosolid/apperr"
+)
+
+// Route is an endpoint written against net/http alone, so gin, chi and a
+// plain ServeMux can all serve it. Path parameters are {name} segments, or
+// {name...} for the rest of the path, and handlers read them with
+// r.PathValue whichever router matched: ServeMux and chi set them, and
+// GinHandler copies them over from gin.
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.Handler
+}
+
+type errorReporterKey struct{}
+
+// GinHandler serves h on gin. Errors h writes with WriteError end up on the
+// gin context, so the logging and error reporting middleware see them.
+func GinHandler(h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := c.Request.WithContext(context.WithValue(c.Request.Context(), errorReporterKey{}, func(err error) { c.Error(err) }))
+		for _, param := range c.Params {
+			r.SetPathValue(param.Key, strings.TrimPrefix(param.Value, "/"))
+		}
+		h.ServeHTTP(c.Writer, r)
+	}
+}
+
+// ginPath turns {name} and {name...} segments into gin's :name and *name.
+func ginPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, "{"); ok && strings.HasSuffix(name, "}") {
+			name = strings.TrimSuffix(name, "}")
+			if rest, ok := strings.CutSuffix(name, "..."); ok {
+				segments[i] = "*" + rest
+			} else {
+				segments[i] = ":" + name
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// MountGin adds routes to a gin engine or group, behind middleware.
+func MountGin(routes gin.IRoutes, rs []Route, middleware ...gin.HandlerFunc) {
+	for _, route := range rs {
+		routes.Handle(route.Method, ginPath(route.Path), append(middleware[:len(middleware):len(middleware)], GinHandler(route.Handler))...)
+	}
+}
+
+// MountChi adds routes to a chi router. chi names a {name...} segment "*",
+// so it is also set under its own name.
+func MountChi(r chi.Router, rs []Route) {
+	for _, route := range rs {
+		path, handler := route.Path, route.Handler
+		if i := strings.LastIndex(path, "/{"); i >= 0 && strings.HasSuffix(path, "...}") {
+			name := strings.TrimSuffix(path[i+2:], "...}")
+			path = path[:i+1] + "*"
+			handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				req.SetPathValue(name, chi.URLParam(req, "*"))
+				route.Handler.ServeHTTP(w, req)
+			})
+		}
+		r.Method(route.Method, path, handler)
+	}
+}
+
+// MountServeMux adds routes to a ServeMux as "METHOD /path" patterns.
+func MountServeMux(mux *http.ServeMux, rs []Route) {
+	for _, route := range rs {
+		mux.Handle(route.Method+" "+route.Path, route.Handler)
+	}
+}
+
+// WriteJSON sends v with the configured JSON codec.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	codec.NewEncoder(w).Encode(v)
+}
+
+// WriteProblem writes a problem+json body for code. Routes go through no
+// format negotiation, so errors are always problem+json.
+func WriteProblem(w http.ResponseWriter, r *http.Request, code apperr.Code, detail string) {
+	status := code.Status()
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	codec.NewEncoder(w).Encode(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Code:      code,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
+
+// WriteError is WriteProblem for err, by its apperr code.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	ReportError(r, err)
+	appErr := apperr.From(err)
+	WriteProblem(w, r, appErr.Code, appErr.Detail)
+}
+
+// ReportError hands err to the router's error handling, for routes that
+// write their error responses themselves or fail after writing one.
+func ReportError(r *http.Request, err error) {
+	if report, ok := r.Context().Value(errorReporterKey{}).(func(error)); ok {
+		report(err)
+	}
+}
+
+// Problem is an RFC 9457 problem details body, extended with a stable code
+// from the apperr catalog.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Code      apperr.Code `json:"code"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+)
+
+// TestMountPathValues checks that every adapter hands the handler the same
+// path values, {name...} included.
+func TestMountPathValues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/posts/{id}", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("post " + r.PathValue("id")))
+		})},
+		{Method: http.MethodGet, Path: "/files/{id}/{path...}", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.PathValue("id") + ":" + r.PathValue("path")))
+		})},
+	}
+	e := gin.New()
+	MountGin(e, routes)
+	r := chi.NewRouter()
+	MountChi(r, routes)
+	mux := http.NewServeMux()
+	MountServeMux(mux, routes)
+
+	for name, h := range map[string]http.Handler{"gin": e, "chi": r, "servemux": mux} {
+		for target, want := range map[string]string{
+			"/posts/7":           "post 7",
+			"/files/7/a/b/c.txt": "7:a/b/c.txt",
+		} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			if w.Code != http.StatusOK || w.Body.String() != want {
+				t.Errorf("%s: GET %s = %d %q, want 200 %q", name, target, w.Code, w.Body, want)
+			}
+		}
+	}
+}
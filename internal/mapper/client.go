@@ -0,0 +1,28 @@
+package mapper
+
+import (
+	"gosolid/client"
+	"gosolid/internal/domain"
+)
+
+// PostResp is any of the REST API's post bodies. They carry the same
+// fields, so one conversion fills them all.
+type PostResp interface {
+	client.NewPostResp | client.GetPostResp | client.ListPostDataResp | client.UpdatePostResp
+}
+
+// ToPostResp returns post as the body R.
+func ToPostResp[R PostResp](post domain.Post) R {
+	return R(client.GetPostResp{ID: post.ID, UID: post.UID, Title: post.Title, Body: post.Body})
+}
+
+// ToPostSummary returns post as GET /posts?view=summary lists it. The body
+// is taken as the excerpt, so it should already be cut.
+func ToPostSummary(post domain.Post) client.PostSummaryResp {
+	return client.PostSummaryResp{ID: post.ID, UID: post.UID, Title: post.Title, Excerpt: post.Body, CreatedAt: post.CreatedAt, UpdatedAt: post.UpdatedAt}
+}
+
+// FromNewPostReq returns the post req asks to create.
+func FromNewPostReq(req client.NewPostReq) domain.Post {
+	return domain.Post{Title: req.Title, Body: req.Body}
+}
@@ -0,0 +1,16 @@
+// Package mapper converts posts between the domain and the wire types of
+// each API: the REST bodies of package client and the gRPC v1 messages of
+// postpb. Each API's conversions live in a file of their own, so a new
+// version adds its own file and never changes what an older one sends, and
+// the domain never learns about either.
+package mapper
+
+// Slice converts every element of from with f. It never returns nil, so an
+// empty list still encodes as [].
+func Slice[From, To any](from []From, f func(From) To) []To {
+	to := make([]To, len(from))
+	for i, v := range from {
+		to[i] = f(v)
+	}
+	return to
+}
@@ -0,0 +1,17 @@
+package mapper
+
+import (
+	"gosolid/internal/domain"
+	"gosolid/postpb"
+)
+
+// ToPostpb returns post as the gRPC v1 API sends it, without the UID and
+// timestamps it has no fields for.
+func ToPostpb(post domain.Post) *postpb.Post {
+	return &postpb.Post{Id: int64(post.ID), Title: post.Title, Body: post.Body}
+}
+
+// FromCreatePostRequest returns the post req asks to create.
+func FromCreatePostRequest(req *postpb.CreatePostRequest) domain.Post {
+	return domain.Post{Title: req.GetTitle(), Body: req.GetBody()}
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"gosolid/internal/domain"
+)
+
+// MQTTNotifier publishes every post event to an MQTT broker. The payload is
+// the same JSON the webhooks receive. The client reconnects on its own, so
+// a broker outage only fails the publishes made while it lasts, each after
+// the notifier timeout.
+type MQTTNotifier struct {
+	client  mqtt.Client
+	topic   string
+	qos     byte
+	retain  bool
+	timeout time.Duration
+}
+
+// MQTTConfig is where and how MQTTNotifier publishes. Topic may hold
+// {action} and {id}, which Topic fills in.
+type MQTTConfig struct {
+	Broker   string
+	ClientID string
+	Username string
+	Topic    string
+	QoS      int
+	Retain   bool
+}
+
+func NewMQTTNotifier(cfg MQTTConfig, password string, timeout time.Duration) *MQTTNotifier {
+	clientID := cfg.ClientID
+	if clientID == "" {
+		// Brokers drop the older connection when two clients share an ID.
+		host, _ := os.Hostname()
+		clientID = "gosolid-" + host
+	}
+	logger := slog.With("component", "mqtt")
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(mqtt.Client) { logger.Info("connected", "broker", cfg.Broker) }).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) { logger.Warn("connection lost", "broker", cfg.Broker, "error", err) })
+
+	client := mqtt.NewClient(opts)
+	client.Connect()
+	return &MQTTNotifier{client: client, topic: cfg.Topic, qos: byte(cfg.QoS), retain: cfg.Retain, timeout: timeout}
+}
+
+// Topic fills in the {action} and {id} placeholders.
+func (n *MQTTNotifier) Topic(post domain.Post, action domain.Action) string {
+	return strings.NewReplacer("{action}", string(action), "{id}", strconv.Itoa(post.ID)).Replace(n.topic)
+}
+
+func (n *MQTTNotifier) NotifyPostUpdated(ctx context.Context, post domain.Post, action domain.Action) error {
+	payload, err := json.Marshal(WebhookPayload{
+		Action: string(action),
+		Post: WebhookPostData{
+			ID:    post.ID,
+			Title: post.Title,
+			Body:  post.Body,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	topic := n.Topic(post, action)
+	token := n.client.Publish(topic, n.qos, n.retain, payload)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("mqtt publish %s: %w", topic, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("mqtt publish %s: %w", topic, ctx.Err())
+	}
+}
+
+// Close waits briefly for in-flight publishes before disconnecting.
+func (n *MQTTNotifier) Close(context.Context) error {
+	n.client.Disconnect(uint((250 * time.Millisecond).Milliseconds()))
+	return nil
+}
@@ -0,0 +1,11 @@
+// Package notify delivers post events: to webhooks and MQTT, with retries,
+// and from a repository whose writes should be announced. EmailService is
+// the mail transport the server's mailers share.
+package notify
+
+import "context"
+
+// EmailService sends one plain-text email, giving up when ctx is done.
+type EmailService interface {
+	SendEmail(ctx context.Context, sender string, recipient string, subject string, body string) error
+}
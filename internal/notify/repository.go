@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gosolid/internal/domain"
+	"gosolid/internal/storage"
+)
+
+// NotifyingPostRepository tells every notifier about successful writes,
+// up to concurrency of them at once, so the write waits for the slowest
+// notifier rather than the sum of them. The write has already happened by
+// then, so delivery failures are logged rather than returned to the caller.
+type NotifyingPostRepository struct {
+	domain.PostRepository
+
+	mu          sync.RWMutex
+	notifiers   []domain.PostUpdateNotifier
+	concurrency int
+	deadline    time.Duration
+}
+
+func NewNotifyingPostRepository(next domain.PostRepository, notifiers ...domain.PostUpdateNotifier) *NotifyingPostRepository {
+	return &NotifyingPostRepository{PostRepository: next, notifiers: notifiers, concurrency: 1}
+}
+
+func (r *NotifyingPostRepository) Unwrap() domain.PostRepository { return r.PostRepository }
+
+func (r *NotifyingPostRepository) SetNotifiers(notifiers ...domain.PostUpdateNotifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifiers = notifiers
+}
+
+// SetFanOut bounds how many notifiers run at once and how long each gets,
+// 0 for no deadline.
+func (r *NotifyingPostRepository) SetFanOut(concurrency int, deadline time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.concurrency = max(concurrency, 1)
+	r.deadline = deadline
+}
+
+func (r *NotifyingPostRepository) notify(ctx context.Context, post domain.Post, action domain.Action) {
+	r.mu.RLock()
+	notifiers, concurrency, deadline := r.notifiers, r.concurrency, r.deadline
+	r.mu.RUnlock()
+
+	errs := make([]error, len(notifiers))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, notifier := range notifiers {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ctx := ctx
+			if deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, deadline)
+				defer cancel()
+			}
+			errs[i] = notifier.NotifyPostUpdated(ctx, post, action)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		slog.With("component", "notifier").ErrorContext(ctx, "notify post updated", "post_id", post.ID, "action", action, "error", err)
+	}
+}
+
+func (r *NotifyingPostRepository) AddPost(ctx context.Context, newPost domain.Post) (domain.Post, error) {
+	post, err := r.PostRepository.AddPost(ctx, newPost)
+	if err != nil {
+		return domain.Post{}, err
+	}
+	r.notify(ctx, post, domain.ActionCreate)
+	return post, nil
+}
+
+func (r *NotifyingPostRepository) AddPosts(ctx context.Context, newPosts []domain.Post) ([]domain.Post, error) {
+	posts, err := r.PostRepository.AddPosts(ctx, newPosts)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		r.notify(ctx, post, domain.ActionCreate)
+	}
+	return posts, nil
+}
+
+func (r *NotifyingPostRepository) UpdatePost(ctx context.Context, updatePost domain.Post) (domain.Post, error) {
+	post, err := r.PostRepository.UpdatePost(ctx, updatePost)
+	if err != nil {
+		return domain.Post{}, err
+	}
+	r.notify(ctx, post, domain.ActionUpdate)
+	return post, nil
+}
+
+// ApplyPostWrites notifies once the whole batch is written.
+func (r *NotifyingPostRepository) ApplyPostWrites(ctx context.Context, writes []storage.PostWrite) ([]domain.Post, error) {
+	posts, err := storage.ApplyPostWrites(ctx, r.PostRepository, writes)
+	if err != nil {
+		return nil, err
+	}
+	actions := map[storage.PostWriteOp]domain.Action{storage.PostWriteAdd: domain.ActionCreate, storage.PostWriteUpdate: domain.ActionUpdate, storage.PostWriteDelete: domain.ActionDelete}
+	for i, post := range posts {
+		r.notify(ctx, post, actions[writes[i].Op])
+	}
+	return posts, nil
+}
+
+func (r *NotifyingPostRepository) DeletePostByID(ctx context.Context, id int) error {
+	post, err := r.PostRepository.GetPostByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.PostRepository.DeletePostByID(ctx, id); err != nil {
+		return err
+	}
+	r.notify(ctx, post, domain.ActionDelete)
+	return nil
+}
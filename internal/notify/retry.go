@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gosolid/internal/domain"
+)
+
+// RetryingNotifier retries a failed delivery up to retries times, doubling
+// backoff between attempts, until ctx is done. onRetry, if not nil, is
+// called before each retry, for metrics.
+type RetryingNotifier struct {
+	next    domain.PostUpdateNotifier
+	retries int
+	backoff time.Duration
+	onRetry func()
+}
+
+func NewRetryingNotifier(next domain.PostUpdateNotifier, retries int, backoff time.Duration, onRetry func()) *RetryingNotifier {
+	return &RetryingNotifier{next: next, retries: retries, backoff: backoff, onRetry: onRetry}
+}
+
+func (n *RetryingNotifier) NotifyPostUpdated(ctx context.Context, post domain.Post, action domain.Action) error {
+	err := n.next.NotifyPostUpdated(ctx, post, action)
+	backoff := n.backoff
+	for attempt := 0; err != nil && attempt < n.retries; attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+
+		if n.onRetry != nil {
+			n.onRetry()
+		}
+		err = n.next.NotifyPostUpdated(ctx, post, action)
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPEmailService sends mail through an SMTP server, upgrading to TLS when
+// the server offers STARTTLS and logging in with PLAIN when a username is
+// set.
+type SMTPEmailService struct {
+	addr     string
+	username string
+	password string
+}
+
+func NewSMTPEmailService(addr, username, password string) *SMTPEmailService {
+	return &SMTPEmailService{addr: addr, username: username, password: password}
+}
+
+func (s *SMTPEmailService) SendEmail(ctx context.Context, sender string, recipient string, subject string, body string) error {
+	// Addresses and the subject end up in headers; a line break in one
+	// would let it add headers of its own.
+	if strings.ContainsAny(sender+recipient+subject, "\r\n") {
+		return fmt.Errorf("smtp: line break in an address or the subject")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(s.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("smtp: starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return fmt.Errorf("smtp: auth: %w", err)
+		}
+	}
+	if err := c.Mail(sender); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := c.Rcpt(recipient); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	fmt.Fprintf(w, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n",
+		sender, recipient, mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z))
+	w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return c.Quit()
+}
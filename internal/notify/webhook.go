@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gosolid/internal/domain"
+)
+
+// WebhookPayload is the JSON a webhook receives, and an MQTT message
+// carries, for each post event.
+type WebhookPayload struct {
+	Action string          `json:"action"`
+	Post   WebhookPostData `json:"post"`
+}
+
+type WebhookPostData struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier posts to url with client, which sets the timeout.
+func NewWebhookNotifier(url string, client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: client}
+}
+
+func (n *WebhookNotifier) NotifyPostUpdated(ctx context.Context, post domain.Post, action domain.Action) error {
+	payload, err := json.Marshal(WebhookPayload{
+		Action: string(action),
+		Post: WebhookPostData{
+			ID:    post.ID,
+			Title: post.Title,
+			Body:  post.Body,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: unexpected status %s", n.url, resp.Status)
+	}
+	return nil
+}
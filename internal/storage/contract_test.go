@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gosolid/internal/domain"
+	"gosolid/internal/storage/repotest"
+)
+
+func TestDBContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) domain.PostRepository {
+		return NewDB()
+	})
+}
+
+func TestEventSourcedStoreContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) domain.PostRepository {
+		store, err := OpenEventSourcedStore(filepath.Join(t.TempDir(), "posts.events.jsonl"), SequentialIDs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
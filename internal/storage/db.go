@@ -0,0 +1,485 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"gosolid/internal/domain"
+)
+
+// dbShards spreads posts over independently locked maps so that requests
+// for different posts don't wait on each other.
+const dbShards = 32
+
+type dbShard struct {
+	mu    sync.RWMutex
+	posts map[int]domain.Post
+}
+
+// DB is the in-memory PostRepository. Every operation locks the shards it
+// touches, reads with read locks so they run alongside each other. IDs come
+// from an atomic counter, so adds don't wait on each other for one; posts
+// added at the same time may show up in lists slightly out of ID order.
+// replaceMu keeps adds out of ReplaceAll, which resets the counter.
+//
+// ids is every post ID in ascending order, so lists walk it instead of
+// sorting the posts on each call. Since IDs only grow, adding a post is
+// almost always an append. uids maps the UIDs idGen gives posts, if any,
+// to their IDs.
+type DB struct {
+	replaceMu sync.RWMutex
+	lastID    atomic.Int64
+	idGen     IDGenerator
+	shards    [dbShards]dbShard
+
+	indexMu sync.RWMutex
+	ids     []int
+	uids    map[string]int
+}
+
+// eachPostChunk is how many IDs EachPost copies out of the index at a time.
+const eachPostChunk = 256
+
+// shard mixes id before picking a shard: snowflake IDs keep their counter,
+// which restarts every millisecond, in the low bits, so taking those as they
+// are would put nearly every post in one shard.
+func (d *DB) shard(id int) *dbShard {
+	return &d.shards[mixID(uint64(id))%dbShards]
+}
+
+// mixID is splitmix64's finalizer.
+func mixID(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (d *DB) put(post domain.Post) {
+	shard := d.shard(post.ID)
+	shard.mu.Lock()
+	shard.posts[post.ID] = post
+	shard.mu.Unlock()
+}
+
+// index adds ids, which must be ascending, to the ID index.
+func (d *DB) index(ids ...int) {
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+	d.indexLocked(ids...)
+}
+
+func (d *DB) indexLocked(ids ...int) {
+	for _, id := range ids {
+		if n := len(d.ids); n == 0 || d.ids[n-1] < id {
+			d.ids = append(d.ids, id)
+			continue
+		}
+		if i, found := slices.BinarySearch(d.ids, id); !found {
+			d.ids = slices.Insert(d.ids, i, id)
+		}
+	}
+}
+
+func (d *DB) unindex(id int, uid string) {
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+	d.unindexLocked(id, uid)
+}
+
+func (d *DB) unindexLocked(id int, uid string) {
+	if i, found := slices.BinarySearch(d.ids, id); found {
+		d.ids = slices.Delete(d.ids, i, i+1)
+	}
+	delete(d.uids, uid)
+}
+
+// indexUIDs adds the UIDs of posts to the UID index.
+func (d *DB) indexUIDs(posts ...domain.Post) {
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+	d.indexUIDsLocked(posts...)
+}
+
+func (d *DB) indexUIDsLocked(posts ...domain.Post) {
+	for _, post := range posts {
+		if post.UID != "" {
+			d.uids[post.UID] = post.ID
+		}
+	}
+}
+
+// number gives newPost the ID and UID idGen makes of seq.
+func (d *DB) number(ctx context.Context, newPost *domain.Post, seq int) error {
+	if d.idGen == nil {
+		newPost.ID = seq
+		return nil
+	}
+	var err error
+	newPost.ID, newPost.UID, err = d.idGen.NewID(ctx, seq)
+	return err
+}
+
+func (d *DB) PostIDByUID(ctx context.Context, uid string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	d.indexMu.RLock()
+	defer d.indexMu.RUnlock()
+	id, ok := d.uids[uid]
+	if !ok {
+		return 0, domain.ErrNotFound
+	}
+	return id, nil
+}
+
+func (d *DB) AddPost(ctx context.Context, newPost domain.Post) (domain.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Post{}, err
+	}
+	d.replaceMu.RLock()
+	defer d.replaceMu.RUnlock()
+	if err := d.number(ctx, &newPost, int(d.lastID.Add(1))); err != nil {
+		return domain.Post{}, err
+	}
+	d.put(newPost)
+	d.index(newPost.ID)
+	d.indexUIDs(newPost)
+
+	return newPost, nil
+}
+
+func (d *DB) AddPosts(ctx context.Context, newPosts []domain.Post) ([]domain.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.replaceMu.RLock()
+	defer d.replaceMu.RUnlock()
+	posts := make([]domain.Post, len(newPosts))
+	ids := make([]int, len(newPosts))
+	first := int(d.lastID.Add(int64(len(newPosts)))) - len(newPosts)
+	for i, post := range newPosts {
+		if err := d.number(ctx, &post, first+i+1); err != nil {
+			return nil, err
+		}
+		posts[i], ids[i] = post, post.ID
+	}
+	for _, post := range posts {
+		d.put(post)
+	}
+	d.index(ids...)
+	d.indexUIDs(posts...)
+	return posts, nil
+}
+
+func (d *DB) GetPostByID(ctx context.Context, id int) (domain.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Post{}, err
+	}
+	shard := d.shard(id)
+	shard.mu.RLock()
+	post, ok := shard.posts[id]
+	shard.mu.RUnlock()
+	if !ok {
+		return domain.Post{}, domain.ErrNotFound
+	}
+	return post, nil
+}
+
+// ListPosts walks the ID index for unfiltered ID orders, copying out only
+// the IDs on the page; anything else reads every post and uses ListPage. A
+// write racing with it may or may not be included, but no post is seen
+// half-written.
+func (d *DB) ListPosts(ctx context.Context, opts domain.ListOptions) ([]domain.Post, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if opts.Filtered() || !opts.Sort.ByID() {
+		ids, _ := d.pageIDs(domain.ListOptions{})
+		posts, total := ListPage(d.collect(ids), opts)
+		return posts, total, nil
+	}
+	ids, total := d.pageIDs(opts)
+	posts := d.collect(ids)
+	opts.CutBodies(posts)
+	return posts, total, nil
+}
+
+// pageIDs copies the IDs of an unfiltered page in ID order out of the index,
+// along with the number of posts.
+func (d *DB) pageIDs(opts domain.ListOptions) ([]int, int) {
+	d.indexMu.RLock()
+	defer d.indexMu.RUnlock()
+	if opts.Sort == domain.SortIDDesc {
+		end := len(d.ids)
+		if opts.After > 0 {
+			end, _ = slices.BinarySearch(d.ids, opts.After)
+		}
+		end = max(end-opts.Offset, 0)
+		start := 0
+		if opts.Limit > 0 {
+			start = max(end-opts.Limit, 0)
+		}
+		ids := slices.Clone(d.ids[start:end])
+		slices.Reverse(ids)
+		return ids, len(d.ids)
+	}
+	start := 0
+	if opts.After > 0 {
+		start, _ = slices.BinarySearch(d.ids, opts.After+1)
+	}
+	start = min(start+opts.Offset, len(d.ids))
+	end := len(d.ids)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, end)
+	}
+	return slices.Clone(d.ids[start:end]), len(d.ids)
+}
+
+// collect reads the posts with ids, skipping any deleted since the IDs were
+// read.
+func (d *DB) collect(ids []int) []domain.Post {
+	posts := make([]domain.Post, 0, len(ids))
+	for _, id := range ids {
+		shard := d.shard(id)
+		shard.mu.RLock()
+		post, ok := shard.posts[id]
+		shard.mu.RUnlock()
+		if ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+// EachPost copies IDs out of the index a chunk at a time, picking up after
+// the last ID it saw, so stopping early costs only what was read and posts
+// added or deleted meanwhile don't throw it off.
+func (d *DB) EachPost(ctx context.Context, fn func(domain.Post) error) error {
+	chunk := make([]int, 0, eachPostChunk)
+	after := 0
+	for {
+		d.indexMu.RLock()
+		i, _ := slices.BinarySearch(d.ids, after+1)
+		chunk = append(chunk[:0], d.ids[i:min(i+eachPostChunk, len(d.ids))]...)
+		d.indexMu.RUnlock()
+		if len(chunk) == 0 {
+			return nil
+		}
+		for _, id := range chunk {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			post, err := d.GetPostByID(ctx, id)
+			if errors.Is(err, domain.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			// fn runs without any lock held, so it may call back into d.
+			if err := fn(post); err != nil {
+				return err
+			}
+		}
+		after = chunk[len(chunk)-1]
+	}
+}
+
+// UpdatePost replaces a post that exists and answers domain.ErrNotFound
+// otherwise, so an update racing a delete can't bring the post back. The
+// shard stays locked while the UID is indexed, so a delete that follows
+// unindexes it after.
+func (d *DB) UpdatePost(ctx context.Context, updatePost domain.Post) (domain.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Post{}, err
+	}
+	shard := d.shard(updatePost.ID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if _, ok := shard.posts[updatePost.ID]; !ok {
+		return domain.Post{}, domain.ErrNotFound
+	}
+	shard.posts[updatePost.ID] = updatePost
+	d.indexUIDs(updatePost)
+	return updatePost, nil
+}
+
+func (d *DB) DeletePostByID(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	shard := d.shard(id)
+	shard.mu.Lock()
+	uid := shard.posts[id].UID
+	delete(shard.posts, id)
+	shard.mu.Unlock()
+	d.unindex(id, uid)
+
+	return nil
+}
+
+// ReplaceAll swaps the whole data set for posts, keeping their IDs, and moves
+// the ID counter past the highest one. It holds every lock at once, so no
+// reader sees a mix of the old and new data sets.
+func (d *DB) ReplaceAll(ctx context.Context, posts []domain.Post) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.replaceMu.Lock()
+	defer d.replaceMu.Unlock()
+	for i := range d.shards {
+		d.shards[i].mu.Lock()
+		defer d.shards[i].mu.Unlock()
+	}
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+
+	lastID := 0
+	for i := range d.shards {
+		d.shards[i].posts = make(map[int]domain.Post)
+	}
+	for _, post := range posts {
+		d.shard(post.ID).posts[post.ID] = post
+		lastID = max(lastID, post.ID)
+	}
+	d.ids = d.ids[:0]
+	clear(d.uids)
+	for i := range d.shards {
+		d.ids = slices.AppendSeq(d.ids, maps.Keys(d.shards[i].posts))
+		for id, post := range d.shards[i].posts {
+			if post.UID != "" {
+				d.uids[post.UID] = id
+			}
+		}
+	}
+	slices.Sort(d.ids)
+	d.lastID.Store(int64(lastID))
+	return nil
+}
+
+// ApplyPostWrites holds every lock while it checks and applies writes, as
+// ReplaceAll does, so no reader sees part of the batch. Every update and
+// delete is checked before anything is written.
+func (d *DB) ApplyPostWrites(ctx context.Context, writes []PostWrite) ([]domain.Post, error) {
+	return d.commitPostWrites(ctx, writes, nil)
+}
+
+// checkPreconditionsLocked fails writes if an update's IfUpdatedAt doesn't
+// match the post as the batch leaves it by then.
+func (d *DB) checkPreconditionsLocked(writes []PostWrite) error {
+	staged := make(map[int]domain.Post)
+	for _, w := range writes {
+		if w.Op == PostWriteAdd {
+			continue
+		}
+		id := w.Post.ID
+		prev, ok := staged[id]
+		if !ok {
+			prev = d.shard(id).posts[id]
+		}
+		if w.Op == PostWriteUpdate && !w.IfUpdatedAt.IsZero() && !prev.UpdatedAt.Equal(w.IfUpdatedAt) {
+			return ErrStalePost
+		}
+		staged[id] = w.Post
+	}
+	return nil
+}
+
+// commitPostWrites is ApplyPostWrites with a say before the writes are
+// applied: once they are checked and the adds numbered, persist, if not
+// nil, gets each post before and after its write. Adds have no before and
+// deletes no after. It can fail the batch, which then leaves nothing
+// written, or change what after holds.
+func (d *DB) commitPostWrites(ctx context.Context, writes []PostWrite, persist func(writes []PostWrite, before, after []domain.Post) error) ([]domain.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.replaceMu.RLock()
+	defer d.replaceMu.RUnlock()
+	for i := range d.shards {
+		d.shards[i].mu.Lock()
+		defer d.shards[i].mu.Unlock()
+	}
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+
+	exists := make(map[int]bool)
+	adds := 0
+	for _, w := range writes {
+		if w.Op == PostWriteAdd {
+			adds++
+			continue
+		}
+		id := w.Post.ID
+		if _, ok := exists[id]; !ok {
+			_, exists[id] = d.shard(id).posts[id]
+		}
+		if !exists[id] {
+			return nil, domain.ErrNotFound
+		}
+		exists[id] = w.Op != PostWriteDelete
+	}
+	if err := d.checkPreconditionsLocked(writes); err != nil {
+		return nil, err
+	}
+
+	seq := int(d.lastID.Add(int64(adds))) - adds
+	before, after := make([]domain.Post, len(writes)), make([]domain.Post, len(writes))
+	staged := make(map[int]domain.Post)
+	for i, w := range writes {
+		post := w.Post
+		if w.Op == PostWriteAdd {
+			seq++
+			if err := d.number(ctx, &post, seq); err != nil {
+				return nil, err
+			}
+			after[i] = post
+			continue
+		}
+		prev, ok := staged[post.ID]
+		if !ok {
+			prev = d.shard(post.ID).posts[post.ID]
+		}
+		before[i] = prev
+		if w.Op == PostWriteUpdate {
+			after[i], staged[post.ID] = post, post
+		}
+	}
+	if persist != nil {
+		if err := persist(writes, before, after); err != nil {
+			return nil, err
+		}
+	}
+
+	posts := make([]domain.Post, len(writes))
+	for i, w := range writes {
+		if w.Op == PostWriteDelete {
+			post := before[i]
+			delete(d.shard(post.ID).posts, post.ID)
+			d.unindexLocked(post.ID, post.UID)
+			posts[i] = post
+			continue
+		}
+		post := after[i]
+		d.shard(post.ID).posts[post.ID] = post
+		d.indexLocked(post.ID)
+		d.indexUIDsLocked(post)
+		posts[i] = post
+	}
+	return posts, nil
+}
+
+func NewDB() *DB {
+	d := &DB{uids: make(map[string]int)}
+	for i := range d.shards {
+		d.shards[i].posts = make(map[int]domain.Post)
+	}
+	return d
+}
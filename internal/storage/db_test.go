@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"gosolid/internal/clock"
+	"gosolid/internal/domain"
+)
+
+// singleLockDB is the store as it was before sharding: one map behind one
+// mutex. It is only here to compare against.
+type singleLockDB struct {
+	mu     sync.Mutex
+	lastID int
+	posts  map[int]domain.Post
+}
+
+func (d *singleLockDB) AddPost(_ context.Context, post domain.Post) (domain.Post, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastID++
+	post.ID = d.lastID
+	d.posts[post.ID] = post
+	return post, nil
+}
+
+func (d *singleLockDB) GetPostByID(_ context.Context, id int) (domain.Post, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	post, ok := d.posts[id]
+	if !ok {
+		return domain.Post{}, domain.ErrNotFound
+	}
+	return post, nil
+}
+
+func (d *singleLockDB) UpdatePost(_ context.Context, post domain.Post) (domain.Post, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.posts[post.ID] = post
+	return post, nil
+}
+
+type benchStore interface {
+	AddPost(ctx context.Context, post domain.Post) (domain.Post, error)
+	GetPostByID(ctx context.Context, id int) (domain.Post, error)
+	UpdatePost(ctx context.Context, post domain.Post) (domain.Post, error)
+}
+
+const benchPosts = 10000
+
+// benchmarkMixed runs a read-heavy mix from every goroutine: 90% reads and
+// 10% updates of random posts.
+func benchmarkMixed(b *testing.B, store benchStore) {
+	ctx := context.Background()
+	for range benchPosts {
+		if _, err := store.AddPost(ctx, domain.Post{Title: "title", Body: "body"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := rand.IntN(benchPosts) + 1
+			if rand.IntN(10) == 0 {
+				if _, err := store.UpdatePost(ctx, domain.Post{ID: id, Title: "updated", Body: "body"}); err != nil {
+					b.Error(err)
+				}
+				continue
+			}
+			if _, err := store.GetPostByID(ctx, id); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStoreMixedParallel(b *testing.B) {
+	b.Run("sharded", func(b *testing.B) { benchmarkMixed(b, NewDB()) })
+	b.Run("single-lock", func(b *testing.B) { benchmarkMixed(b, &singleLockDB{posts: map[int]domain.Post{}}) })
+}
+
+func BenchmarkStoreAddParallel(b *testing.B) {
+	ctx := context.Background()
+	for name, store := range map[string]benchStore{"sharded": NewDB(), "single-lock": &singleLockDB{posts: map[int]domain.Post{}}} {
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := store.AddPost(ctx, domain.Post{Title: "title"}); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// TestDBConcurrentAccess is mostly for go test -race: every operation runs
+// from several goroutines at once.
+func TestDBConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	const workers, perWorker = 8, 200
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				post, err := db.AddPost(ctx, domain.Post{Title: "title"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := db.GetPostByID(ctx, post.ID); err != nil {
+					t.Error(err)
+				}
+				if _, err := db.UpdatePost(ctx, domain.Post{ID: post.ID, Title: "updated"}); err != nil {
+					t.Error(err)
+				}
+				if _, _, err := db.ListPosts(ctx, domain.ListOptions{}); err != nil {
+					t.Error(err)
+				}
+				if post.ID%2 == 0 {
+					if err := db.DeletePostByID(ctx, post.ID); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	posts, _, err := db.ListPosts(ctx, domain.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := workers * perWorker / 2; len(posts) != want {
+		t.Fatalf("got %d posts, want %d", len(posts), want)
+	}
+	for i, post := range posts {
+		if i > 0 && post.ID <= posts[i-1].ID {
+			t.Fatalf("posts not in ID order at %d: %d after %d", i, post.ID, posts[i-1].ID)
+		}
+		if post.ID%2 == 0 || post.Title != "updated" {
+			t.Fatalf("unexpected post %+v", post)
+		}
+	}
+
+	var seen int
+	err = db.EachPost(ctx, func(domain.Post) error { seen++; return nil })
+	if err != nil || seen != len(posts) {
+		t.Fatalf("EachPost saw %d posts, err %v; want %d", seen, err, len(posts))
+	}
+}
+
+func TestApplyPostWritesIfUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	read := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	post, err := db.AddPost(ctx, domain.Post{Title: "first", UpdatedAt: read})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := post
+	edited.Title, edited.UpdatedAt = "edited", read.Add(time.Minute)
+	if _, err := db.UpdatePost(ctx, edited); err != nil {
+		t.Fatal(err)
+	}
+	stale := post
+	stale.Title = "stale"
+	_, err = db.ApplyPostWrites(ctx, []PostWrite{{Op: PostWriteUpdate, Post: stale, IfUpdatedAt: read}})
+	if !errors.Is(err, ErrStalePost) {
+		t.Fatalf("update from a stale read: err = %v, want %v", err, ErrStalePost)
+	}
+	if got, _ := db.GetPostByID(ctx, post.ID); got.Title != "edited" {
+		t.Errorf("title = %q after a stale update, want %q", got.Title, "edited")
+	}
+
+	stale.Title = "current"
+	if _, err := db.ApplyPostWrites(ctx, []PostWrite{{Op: PostWriteUpdate, Post: stale, IfUpdatedAt: edited.UpdatedAt}}); err != nil {
+		t.Fatalf("update from a current read: %v", err)
+	}
+	if got, _ := db.GetPostByID(ctx, post.ID); got.Title != "current" {
+		t.Errorf("title = %q, want %q", got.Title, "current")
+	}
+}
+
+// TestSnowflakeIDsSpreadAcrossShards adds one post a millisecond, so every
+// ID's counter is 0, and checks no shard takes much more than its share.
+func TestSnowflakeIDsSpreadAcrossShards(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	ids := NewSnowflakeIDs(3, fake)
+	db := NewDB()
+	const n = dbShards * 100
+
+	counts := map[*dbShard]int{}
+	for range n {
+		fake.Advance(time.Millisecond)
+		id, _, err := ids.NewID(ctx, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[db.shard(id)]++
+	}
+	if len(counts) != dbShards {
+		t.Errorf("IDs landed in %d of %d shards", len(counts), dbShards)
+	}
+	for _, count := range counts {
+		if count > 2*n/dbShards {
+			t.Errorf("a shard took %d of %d IDs, more than twice its share", count, n)
+		}
+	}
+}
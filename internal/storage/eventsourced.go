@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"gosolid/eventstore"
+	"gosolid/internal/clock"
+	"gosolid/internal/domain"
+)
+
+// EventSourcedStore is the events backend. Posts are never stored, only
+// what happened to them: every write is appended to an event log before it
+// is applied, and the posts are rebuilt from the log when the store opens.
+// Reads are served from a DB kept as the projection of the log. Writes are
+// taken one at a time, so the log has them in the order they were applied.
+type EventSourcedStore struct {
+	mu    sync.Mutex
+	log   *eventstore.Log
+	view  *DB
+	clock clock.Clock
+}
+
+// OpenEventSourcedStore replays the log at path, creating it if needed.
+func OpenEventSourcedStore(path string, ids IDGenerator) (*EventSourcedStore, error) {
+	projection := eventstore.NewProjection()
+	log, err := eventstore.Open(path, projection.Apply)
+	if err != nil {
+		return nil, err
+	}
+	view := NewDB()
+	view.idGen = ids
+	posts := make([]domain.Post, 0, len(projection.Posts))
+	for _, post := range projection.List() {
+		posts = append(posts, domain.Post(post))
+	}
+	if err := view.ReplaceAll(context.Background(), posts); err != nil {
+		log.Close()
+		return nil, err
+	}
+	view.lastID.Store(int64(projection.LastID))
+	return &EventSourcedStore{log: log, view: view, clock: clock.System}, nil
+}
+
+func (s *EventSourcedStore) Close() error {
+	return s.log.Close()
+}
+
+func (s *EventSourcedStore) GetPostByID(ctx context.Context, id int) (domain.Post, error) {
+	return s.view.GetPostByID(ctx, id)
+}
+
+func (s *EventSourcedStore) ListPosts(ctx context.Context, opts domain.ListOptions) ([]domain.Post, int, error) {
+	return s.view.ListPosts(ctx, opts)
+}
+
+func (s *EventSourcedStore) EachPost(ctx context.Context, fn func(domain.Post) error) error {
+	return s.view.EachPost(ctx, fn)
+}
+
+func (s *EventSourcedStore) PostIDByUID(ctx context.Context, uid string) (int, error) {
+	return s.view.PostIDByUID(ctx, uid)
+}
+
+func (s *EventSourcedStore) AddPost(ctx context.Context, newPost domain.Post) (domain.Post, error) {
+	posts, err := s.ApplyPostWrites(ctx, []PostWrite{{Op: PostWriteAdd, Post: newPost}})
+	if err != nil {
+		return domain.Post{}, err
+	}
+	return posts[0], nil
+}
+
+func (s *EventSourcedStore) AddPosts(ctx context.Context, newPosts []domain.Post) ([]domain.Post, error) {
+	writes := make([]PostWrite, len(newPosts))
+	for i, post := range newPosts {
+		writes[i] = PostWrite{Op: PostWriteAdd, Post: post}
+	}
+	return s.ApplyPostWrites(ctx, writes)
+}
+
+func (s *EventSourcedStore) UpdatePost(ctx context.Context, updatePost domain.Post) (domain.Post, error) {
+	posts, err := s.ApplyPostWrites(ctx, []PostWrite{{Op: PostWriteUpdate, Post: updatePost}})
+	if err != nil {
+		return domain.Post{}, err
+	}
+	return posts[0], nil
+}
+
+// DeletePostByID does nothing for a post that doesn't exist, like DB.
+func (s *EventSourcedStore) DeletePostByID(ctx context.Context, id int) error {
+	_, err := s.ApplyPostWrites(ctx, []PostWrite{{Op: PostWriteDelete, Post: domain.Post{ID: id}}})
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// ApplyPostWrites appends the events of writes as one commit, which a
+// replay applies whole or not at all like the batch.
+func (s *EventSourcedStore) ApplyPostWrites(ctx context.Context, writes []PostWrite) ([]domain.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.view.commitPostWrites(ctx, writes, s.record)
+}
+
+// record appends the events of checked writes. Only titles and bodies
+// change in an update; one that changes neither isn't recorded, so it
+// isn't applied either, or a replay would come out different.
+func (s *EventSourcedStore) record(writes []PostWrite, before, after []domain.Post) error {
+	var events []eventstore.Event
+	for i, w := range writes {
+		switch w.Op {
+		case PostWriteAdd:
+			events = append(events, postCreated(after[i]))
+		case PostWriteUpdate:
+			old, post := before[i], after[i]
+			if old.Title != post.Title {
+				events = append(events, eventstore.Event{Type: eventstore.TitleChanged, PostID: post.ID, Title: post.Title, At: post.UpdatedAt})
+			}
+			if old.Body != post.Body {
+				events = append(events, eventstore.Event{Type: eventstore.BodyChanged, PostID: post.ID, Body: post.Body, At: post.UpdatedAt})
+			}
+			if old.Title == post.Title && old.Body == post.Body {
+				after[i] = old
+			}
+		case PostWriteDelete:
+			events = append(events, eventstore.Event{Type: eventstore.PostDeleted, PostID: before[i].ID, At: s.clock.Now().UTC()})
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	_, err := s.log.Append(events...)
+	return err
+}
+
+func postCreated(post domain.Post) eventstore.Event {
+	e := eventstore.Event{Type: eventstore.PostCreated, PostID: post.ID, UID: post.UID, Title: post.Title, Body: post.Body, At: post.CreatedAt}
+	if !post.UpdatedAt.Equal(post.CreatedAt) {
+		e.UpdatedAt = post.UpdatedAt
+	}
+	return e
+}
+
+// ReplaceAll records a restore as deleting every post and creating posts,
+// in one commit. IDs of posts deleted before stay taken.
+func (s *EventSourcedStore) ReplaceAll(ctx context.Context, posts []domain.Post) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now().UTC()
+	var events []eventstore.Event
+	err := s.view.EachPost(ctx, func(post domain.Post) error {
+		events = append(events, eventstore.Event{Type: eventstore.PostDeleted, PostID: post.ID, At: now})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		events = append(events, postCreated(post))
+	}
+	if len(events) > 0 {
+		if _, err := s.log.Append(events...); err != nil {
+			return err
+		}
+	}
+	lastID := s.view.lastID.Load()
+	if err := s.view.ReplaceAll(ctx, posts); err != nil {
+		return err
+	}
+	s.view.lastID.Store(max(lastID, s.view.lastID.Load()))
+	return nil
+}
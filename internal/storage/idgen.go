@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gosolid/internal/clock"
+)
+
+// IDGenerator numbers new posts. seq is the next number in the store's own
+// sequence. Sequential and Snowflake IDs are numbers and become Post.ID.
+// UUIDv7 and ULID IDs don't fit one, so those posts keep seq as Post.ID,
+// which lists, cursors and the other APIs go by, and get the string as
+// Post.UID, which /posts/:id routes accept in place of the number. ctx is
+// the write's, for generators that ask another service.
+type IDGenerator interface {
+	NewID(ctx context.Context, seq int) (id int, uid string, err error)
+}
+
+// IDStrategies are the generators NewIDGenerator knows.
+var IDStrategies = []string{"sequential", "snowflake", "uuidv7", "ulid"}
+
+// NewIDGenerator returns the generator cfg.IDs names.
+func NewIDGenerator(cfg Config) (IDGenerator, error) {
+	switch cfg.IDs {
+	case "sequential":
+		return SequentialIDs{}, nil
+	case "snowflake":
+		return NewSnowflakeIDs(cfg.SnowflakeNode, clock.System), nil
+	case "uuidv7":
+		return UUIDv7IDs{Clock: clock.System}, nil
+	case "ulid":
+		return ULIDs{Clock: clock.System}, nil
+	}
+	return nil, fmt.Errorf("storage: unknown ids %q", cfg.IDs)
+}
+
+// SequentialIDs is the store's sequence itself.
+type SequentialIDs struct{}
+
+func (SequentialIDs) NewID(_ context.Context, seq int) (int, string, error) { return seq, "", nil }
+
+// snowflakeEpoch is when Snowflake timestamps start, so 41 bits of
+// milliseconds last until 2093.
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeIDs are 63-bit numbers: milliseconds since snowflakeEpoch, 10
+// bits of node, so that several servers can number posts without asking
+// each other, and a 12-bit counter for IDs in the same millisecond. They
+// grow with time, so ID order stays creation order. When the counter runs
+// out, or the clock goes back, it carries on from the last millisecond
+// instead of waiting.
+type SnowflakeIDs struct {
+	node  int64
+	clock clock.Clock
+
+	mu   sync.Mutex
+	last int64
+	seq  int64
+}
+
+func NewSnowflakeIDs(node int, clock clock.Clock) *SnowflakeIDs {
+	return &SnowflakeIDs{node: int64(node), clock: clock}
+}
+
+func (g *SnowflakeIDs) NewID(context.Context, int) (int, string, error) {
+	now := g.clock.Now().Sub(snowflakeEpoch).Milliseconds()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now > g.last {
+		g.last, g.seq = now, 0
+	} else if g.seq++; g.seq >= 1<<12 {
+		g.last, g.seq = g.last+1, 0
+	}
+	return int(g.last<<22 | g.node<<12 | g.seq), "", nil
+}
+
+// timeRandom returns 16 bytes starting with the current Unix time in
+// milliseconds, big-endian in 48 bits, and random after that.
+func timeRandom(clock clock.Clock) [16]byte {
+	var b [16]byte
+	rand.Read(b[6:])
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(clock.Now().UnixMilli()))
+	copy(b[:6], ms[2:])
+	return b
+}
+
+// UUIDv7IDs are RFC 9562 version 7 UUIDs: a millisecond timestamp and 74
+// random bits.
+type UUIDv7IDs struct{ Clock clock.Clock }
+
+func (g UUIDv7IDs) NewID(_ context.Context, seq int) (int, string, error) {
+	b := timeRandom(g.Clock)
+	b[6] = 0x70 | b[6]&0x0f
+	b[8] = 0x80 | b[8]&0x3f
+	h := hex.EncodeToString(b[:])
+	return seq, h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDs are a millisecond timestamp and 80 random bits in 26 characters of
+// Crockford's base32.
+type ULIDs struct{ Clock clock.Clock }
+
+func (g ULIDs) NewID(_ context.Context, seq int) (int, string, error) {
+	b := timeRandom(g.Clock)
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var s [26]byte
+	for i := 25; i >= 0; i-- {
+		s[i] = crockfordBase32[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return seq, string(s[:]), nil
+}
+
+// PostUIDResolver is implemented by stores that can find a post by the
+// UID its ID generator gave it.
+type PostUIDResolver interface {
+	PostIDByUID(ctx context.Context, uid string) (int, error)
+}
+
+var ErrUIDsUnsupported = errors.New("the repository does not support post UIDs")
@@ -0,0 +1,402 @@
+// Package repotest is the contract every post backend is held to. A
+// backend's tests call Run with a factory for empty stores, and the suite
+// checks what the rest of gosolid relies on: CRUD round trips, not found
+// errors, list order, paging and concurrent writes.
+//
+// An update of a missing post is not found rather than an insert:
+// PostCommands looks the post up before it writes, and a delete may land in
+// between. Deleting a missing post is not an error.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"gosolid/apperr"
+	"gosolid/internal/domain"
+)
+
+// Factory returns an empty store for one test, registering its own cleanup
+// with t.
+type Factory func(t *testing.T) domain.PostRepository
+
+// Run runs the contract as subtests of t, each on a store of its own.
+func Run(t *testing.T, newRepo Factory) {
+	for _, tc := range []struct {
+		name string
+		run  func(t *testing.T, repo domain.PostRepository)
+	}{
+		{"AddAndGet", testAddAndGet},
+		{"AddPosts", testAddPosts},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"Order", testOrder},
+		{"Pagination", testPagination},
+		{"Cursor", testCursor},
+		{"Filters", testFilters},
+		{"EachPost", testEachPost},
+		{"ConcurrentWrites", testConcurrentWrites},
+		{"UpdateRacesDelete", testUpdateRacesDelete},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo(t))
+		})
+	}
+}
+
+// base is when the posts the suite writes were created; backends that
+// serialize times may drop anything finer than a second.
+var base = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newPost is the nth post the suite writes, with distinct times.
+func newPost(n int, title string) domain.Post {
+	at := base.Add(time.Duration(n) * time.Hour)
+	return domain.Post{Title: title, Body: "body of " + title, CreatedAt: at, UpdatedAt: at}
+}
+
+// seed adds posts titled titles in order and returns them with their IDs.
+func seed(t *testing.T, repo domain.PostRepository, titles ...string) []domain.Post {
+	t.Helper()
+	posts := make([]domain.Post, len(titles))
+	for i, title := range titles {
+		post, err := repo.AddPost(context.Background(), newPost(i, title))
+		if err != nil {
+			t.Fatalf("AddPost(%q): %v", title, err)
+		}
+		posts[i] = post
+	}
+	return posts
+}
+
+func samePost(a, b domain.Post) bool {
+	return a.ID == b.ID && a.UID == b.UID && a.Title == b.Title && a.Body == b.Body &&
+		a.CreatedAt.Equal(b.CreatedAt) && a.UpdatedAt.Equal(b.UpdatedAt)
+}
+
+func ids(posts []domain.Post) []int {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	return ids
+}
+
+func testAddAndGet(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	want := newPost(0, "hello")
+	added, err := repo.AddPost(ctx, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.ID <= 0 {
+		t.Fatalf("AddPost gave ID %d, want a positive one", added.ID)
+	}
+	want.ID, want.UID = added.ID, added.UID
+	if !samePost(added, want) {
+		t.Errorf("AddPost = %+v, want %+v", added, want)
+	}
+	got, err := repo.GetPostByID(ctx, added.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !samePost(got, want) {
+		t.Errorf("GetPostByID = %+v, want %+v", got, want)
+	}
+}
+
+func testAddPosts(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	added, err := repo.AddPosts(ctx, []domain.Post{newPost(0, "a"), newPost(1, "b"), newPost(2, "c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 3 {
+		t.Fatalf("AddPosts returned %d posts, want 3", len(added))
+	}
+	for i, title := range []string{"a", "b", "c"} {
+		if added[i].Title != title {
+			t.Errorf("AddPosts[%d] is %q, want %q: posts must come back in order", i, added[i].Title, title)
+		}
+		if i > 0 && added[i].ID <= added[i-1].ID {
+			t.Errorf("AddPosts gave IDs %v, want them increasing", ids(added))
+		}
+		got, err := repo.GetPostByID(ctx, added[i].ID)
+		if err != nil || !samePost(got, added[i]) {
+			t.Errorf("GetPostByID(%d) = %+v, %v; want %+v", added[i].ID, got, err, added[i])
+		}
+	}
+}
+
+func testUpdate(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	posts := seed(t, repo, "before", "other")
+	post := posts[0]
+	post.Title, post.Body, post.UpdatedAt = "after", "new body", base.Add(48*time.Hour)
+	updated, err := repo.UpdatePost(ctx, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !samePost(updated, post) {
+		t.Errorf("UpdatePost = %+v, want %+v", updated, post)
+	}
+	got, err := repo.GetPostByID(ctx, post.ID)
+	if err != nil || !samePost(got, post) {
+		t.Errorf("GetPostByID after update = %+v, %v; want %+v", got, err, post)
+	}
+	if got, err := repo.GetPostByID(ctx, posts[1].ID); err != nil || !samePost(got, posts[1]) {
+		t.Errorf("UpdatePost changed another post: %+v, %v", got, err)
+	}
+}
+
+func testDelete(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	posts := seed(t, repo, "doomed", "kept")
+	if err := repo.DeletePostByID(ctx, posts[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetPostByID(ctx, posts[0].ID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("GetPostByID after delete: err = %v, want not found", err)
+	}
+	list, total, err := repo.ListPosts(ctx, domain.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || !slices.Equal(ids(list), []int{posts[1].ID}) {
+		t.Errorf("ListPosts after delete = %v (total %d), want [%d]", ids(list), total, posts[1].ID)
+	}
+}
+
+func testNotFound(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	if _, err := repo.GetPostByID(ctx, 1); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("GetPostByID on an empty store: err = %v, want not found", err)
+	}
+	posts := seed(t, repo, "only")
+	missing := posts[0].ID + 1000
+	if _, err := repo.GetPostByID(ctx, missing); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("GetPostByID of a missing ID: err = %v, want not found", err)
+	}
+	if _, err := repo.UpdatePost(ctx, domain.Post{ID: missing, Title: "ghost"}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("UpdatePost of a missing ID: err = %v, want not found", err)
+	}
+	if _, err := repo.GetPostByID(ctx, missing); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("UpdatePost of a missing ID created it: err = %v", err)
+	}
+	if err := repo.DeletePostByID(ctx, missing); err != nil {
+		t.Errorf("DeletePostByID of a missing ID: %v", err)
+	}
+	if _, total, err := repo.ListPosts(ctx, domain.ListOptions{}); err != nil || total != 1 {
+		t.Errorf("ListPosts total = %d, %v after writes to a missing ID, want 1", total, err)
+	}
+}
+
+func testOrder(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	// Titles out of ID order, so each sort gives a different order.
+	posts := seed(t, repo, "charlie", "alpha", "bravo")
+	a, b, c := posts[1].ID, posts[2].ID, posts[0].ID
+	for _, tc := range []struct {
+		sort domain.ListSort
+		want []int
+	}{
+		{"", []int{c, a, b}},
+		{domain.SortID, []int{c, a, b}},
+		{domain.SortIDDesc, []int{b, a, c}},
+		{domain.SortCreated, []int{c, a, b}},
+		{domain.SortCreatedDesc, []int{b, a, c}},
+		{domain.SortTitle, []int{a, b, c}},
+		{domain.SortTitleDesc, []int{c, b, a}},
+	} {
+		list, _, err := repo.ListPosts(ctx, domain.ListOptions{Sort: tc.sort})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(list); !slices.Equal(got, tc.want) {
+			t.Errorf("ListPosts sorted by %q = %v, want %v", tc.sort, got, tc.want)
+		}
+	}
+}
+
+func testPagination(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	posts := seed(t, repo, "p1", "p2", "p3", "p4", "p5")
+	all := ids(posts)
+	for _, tc := range []struct {
+		limit, offset int
+		want          []int
+	}{
+		{2, 0, all[:2]},
+		{2, 2, all[2:4]},
+		{2, 4, all[4:]},
+		{0, 3, all[3:]},
+		{2, 5, nil},
+	} {
+		list, total, err := repo.ListPosts(ctx, domain.ListOptions{Limit: tc.limit, Offset: tc.offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(list); !slices.Equal(got, tc.want) || total != len(all) {
+			t.Errorf("ListPosts(limit %d, offset %d) = %v (total %d), want %v (total %d)", tc.limit, tc.offset, got, total, tc.want, len(all))
+		}
+	}
+}
+
+func testCursor(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	posts := seed(t, repo, "c1", "c2", "c3", "c4")
+	all := ids(posts)
+	for _, tc := range []struct {
+		opts domain.ListOptions
+		want []int
+	}{
+		{domain.ListOptions{Sort: domain.SortID, After: all[1]}, all[2:]},
+		{domain.ListOptions{Sort: domain.SortIDDesc, After: all[1]}, []int{all[0]}},
+		{domain.ListOptions{Sort: domain.SortUpdatedDesc, After: all[2], AfterUpdatedAt: posts[2].UpdatedAt}, []int{all[1], all[0]}},
+		{domain.ListOptions{Sort: domain.SortUpdated, After: all[2], AfterUpdatedAt: posts[2].UpdatedAt, Limit: 1}, []int{all[3]}},
+	} {
+		list, _, err := repo.ListPosts(ctx, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(list); !slices.Equal(got, tc.want) {
+			t.Errorf("ListPosts(sort %q, after %d) = %v, want %v", tc.opts.Sort, tc.opts.After, got, tc.want)
+		}
+	}
+}
+
+func testFilters(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	posts := seed(t, repo, "Go generics", "Rust traits", "more GO")
+	list, total, err := repo.ListPosts(ctx, domain.ListOptions{Query: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{posts[0].ID, posts[2].ID}; !slices.Equal(ids(list), want) || total != 2 {
+		t.Errorf("ListPosts(query go) = %v (total %d), want %v (total 2)", ids(list), total, want)
+	}
+	list, total, err = repo.ListPosts(ctx, domain.ListOptions{CreatedAfter: posts[0].CreatedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{posts[1].ID, posts[2].ID}; !slices.Equal(ids(list), want) || total != 2 {
+		t.Errorf("ListPosts(created after the first) = %v (total %d), want %v (total 2)", ids(list), total, want)
+	}
+}
+
+func testEachPost(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	posts := seed(t, repo, "e1", "e2", "e3")
+	var seen []int
+	if err := repo.EachPost(ctx, func(post domain.Post) error {
+		seen = append(seen, post.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := ids(posts); !slices.Equal(seen, want) {
+		t.Errorf("EachPost visited %v, want %v in ID order", seen, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := repo.EachPost(ctx, func(domain.Post) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("EachPost returned %v after %d calls, want fn's error after 1", err, calls)
+	}
+}
+
+// testConcurrentWrites adds posts from several goroutines at once; every
+// post must get an ID of its own and be there afterwards.
+func testConcurrentWrites(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	const workers, perWorker = 8, 25
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[int]bool{}
+		errs []error
+	)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				post, err := repo.AddPost(ctx, newPost(i, fmt.Sprintf("worker %d post %d", w, i)))
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else if seen[post.ID] {
+					errs = append(errs, fmt.Errorf("ID %d given twice", post.ID))
+				}
+				seen[post.ID] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+	_, total, err := repo.ListPosts(ctx, domain.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != workers*perWorker {
+		t.Errorf("ListPosts total = %d after %d concurrent adds", total, workers*perWorker)
+	}
+}
+
+// testUpdateRacesDelete updates posts while they are being deleted. Each
+// update either lands before the delete or is not found; no deleted post
+// may come back.
+func testUpdateRacesDelete(t *testing.T, repo domain.PostRepository) {
+	ctx := context.Background()
+	const n = 50
+	titles := make([]string, n)
+	for i := range titles {
+		titles[i] = fmt.Sprintf("post %d", i)
+	}
+	posts := seed(t, repo, titles...)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for _, post := range posts {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := repo.DeletePostByID(ctx, post.ID); err != nil {
+				errs <- fmt.Errorf("DeletePostByID(%d): %w", post.ID, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			post.Title = "updated"
+			if _, err := repo.UpdatePost(ctx, post); err != nil && !errors.Is(err, apperr.ErrNotFound) {
+				errs <- fmt.Errorf("UpdatePost(%d): %w", post.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, post := range posts {
+		if got, err := repo.GetPostByID(ctx, post.ID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("post %d is back after its delete: %+v, %v", post.ID, got, err)
+		}
+	}
+	if _, total, err := repo.ListPosts(ctx, domain.ListOptions{}); err != nil || total != 0 {
+		t.Errorf("ListPosts total = %d, %v after deleting every post, want 0", total, err)
+	}
+}
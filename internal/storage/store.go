@@ -0,0 +1,121 @@
+// Package storage holds the post backends, built in and registered, and
+// what decorators over them share: batched writes, reaching through layers
+// to a capability, and paging posts that can't be paged closer to the data.
+package storage
+
+import (
+	"fmt"
+	"slices"
+
+	"gosolid/internal/domain"
+)
+
+// Config is what a backend is built from: the server's storage section,
+// less the layers it puts in front of the backend.
+type Config struct {
+	Backend       string
+	EventLog      string
+	IDs           string
+	SnowflakeNode int
+	Settings      map[string]string
+}
+
+// Constructor builds the backend Backend names. ids is the generator IDs
+// picks; backends that number their own posts can ignore it. Plugin
+// backends read their own settings from cfg.Settings.
+type Constructor func(cfg Config, ids IDGenerator) (domain.PostRepository, error)
+
+// backends is filled by Register, the built-in backends included.
+var backends = map[string]Constructor{
+	"memory": func(cfg Config, ids IDGenerator) (domain.PostRepository, error) {
+		db := NewDB()
+		db.idGen = ids
+		return db, nil
+	},
+	"events": func(cfg Config, ids IDGenerator) (domain.PostRepository, error) {
+		return OpenEventSourcedStore(cfg.EventLog, ids)
+	},
+}
+
+// Register makes a backend available under name. Call it from an init
+// function, or before the app starts; it panics if name is already taken,
+// as database/sql.Register does.
+func Register(name string, constructor Constructor) {
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("storage backend %q registered twice", name))
+	}
+	backends[name] = constructor
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewPostStore returns the backend cfg names, built in or registered with
+// Register.
+func NewPostStore(cfg Config) (domain.PostRepository, error) {
+	ids, err := NewIDGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	constructor, ok := backends[cfg.Backend]
+	if !ok {
+		return nil, fmt.Errorf("storage: unknown backend %q", cfg.Backend)
+	}
+	return constructor(cfg, ids)
+}
+
+// Unwrap looks through decorators, following their Unwrap methods, for the
+// first layer that implements T. It lets optional capabilities such as Ping,
+// Close or ReplaceAll reach the backend.
+func Unwrap[T any](repo domain.PostRepository) (T, bool) {
+	for repo != nil {
+		if t, ok := repo.(T); ok {
+			return t, true
+		}
+		wrapper, ok := repo.(interface{ Unwrap() domain.PostRepository })
+		if !ok {
+			break
+		}
+		repo = wrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// ListPage applies opts to posts, which must be in ascending ID order, and
+// returns the page and the number of posts matching the filters. posts is
+// reordered in place. It is for backends, or layers such as encryption,
+// that can't do it closer to the data.
+func ListPage(posts []domain.Post, opts domain.ListOptions) ([]domain.Post, int) {
+	if opts.Filtered() {
+		match := opts.Match()
+		posts = slices.DeleteFunc(posts, func(p domain.Post) bool { return !match(p) })
+	}
+	total := len(posts)
+	switch {
+	case opts.Sort == domain.SortIDDesc:
+		slices.Reverse(posts)
+	case !opts.Sort.ByID():
+		slices.SortStableFunc(posts, opts.Compare)
+	}
+	if cursor, ok := opts.Cursor(); ok {
+		i, found := slices.BinarySearchFunc(posts, cursor, opts.Compare)
+		if found {
+			i++
+		}
+		posts = posts[i:]
+	}
+	posts = posts[min(opts.Offset, len(posts)):]
+	if opts.Limit > 0 {
+		posts = posts[:min(opts.Limit, len(posts))]
+	}
+	opts.CutBodies(posts)
+	return posts, total
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gosolid/apperr"
+	"gosolid/internal/domain"
+)
+
+type PostWriteOp int
+
+const (
+	PostWriteAdd PostWriteOp = iota + 1
+	PostWriteUpdate
+	PostWriteDelete
+)
+
+// PostWrite is one write of a unit of work. Deletes only read Post.ID.
+// An update with IfUpdatedAt set only applies while the stored post still
+// has that UpdatedAt, so one computed from an earlier read can't overwrite
+// a write made since; otherwise the batch fails with ErrStalePost.
+type PostWrite struct {
+	Op          PostWriteOp
+	Post        domain.Post
+	IfUpdatedAt time.Time
+}
+
+// PostBatchWriter applies writes in order, all of them or none: readers see
+// the data set from before or after the batch, never in between. It returns
+// what each write wrote, and for deletes the post as it was. Updating or
+// deleting a post that doesn't exist fails the whole batch.
+//
+// Like ReplaceAll it reaches the backend through Unwrap, so every
+// layer that acts on writes, such as a cache, has to implement it too.
+type PostBatchWriter interface {
+	ApplyPostWrites(ctx context.Context, writes []PostWrite) ([]domain.Post, error)
+}
+
+var ErrUnitOfWorkUnsupported = errors.New("the repository does not support units of work")
+
+var ErrStalePost = fmt.Errorf("post changed since it was read: %w", apperr.ErrConflict)
+
+// ApplyPostWrites hands writes to the next layer down that takes batches.
+func ApplyPostWrites(ctx context.Context, next domain.PostRepository, writes []PostWrite) ([]domain.Post, error) {
+	batcher, ok := Unwrap[PostBatchWriter](next)
+	if !ok {
+		return nil, ErrUnitOfWorkUnsupported
+	}
+	return batcher.ApplyPostWrites(ctx, writes)
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/httpapi"
+)
+
+// RotatingFile rotates when the file grows past maxSize bytes or has been open
+// longer than interval, whichever comes first. Zero disables either trigger.
+// Rotated files are renamed with a timestamp suffix, optionally gzipped, and
+// pruned down to maxBackups.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	compress   bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	wg       sync.WaitGroup
+}
+
+func NewRotatingFile(path string, maxSize int64, interval time.Duration, maxBackups int, compress bool) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) shouldRotate(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxSize > 0 && f.size+next > f.maxSize {
+		return true
+	}
+	return f.interval > 0 && time.Since(f.openedAt) >= f.interval
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	rotated := f.path + "." + time.Now().UTC().Format("20060102T150405.000")
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		if f.compress {
+			if err := gzipFile(rotated); err != nil {
+				slog.Warn("compress rotated log", "file", rotated, "error", err)
+			}
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (f *RotatingFile) prune() {
+	if f.maxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	// The timestamp suffix sorts lexically in rotation order.
+	slices.Sort(backups)
+	for len(backups) > f.maxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.wg.Wait()
+	return f.file.Close()
+}
+
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	RemoteIP  string    `json:"remote_ip"`
+	User      string    `json:"user,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int       `json:"bytes"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// AccessLogMiddleware writes one line per request in Apache combined log
+// format, or as a JSON object when format is "json".
+func AccessLogMiddleware(w io.Writer, format string) gin.HandlerFunc {
+	var mu sync.Mutex
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := accessLogEntry{
+			Time:      start,
+			RemoteIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.RequestURI(),
+			Proto:     c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     max(c.Writer.Size(), 0),
+			Referer:   c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			RequestID: httpapi.RequestIDFromContext(c.Request.Context()),
+		}
+		if principal, ok := PrincipalFromContext(c); ok {
+			entry.User = principal.Name
+		}
+
+		var line []byte
+		if strings.EqualFold(format, "json") {
+			line, _ = json.Marshal(entry)
+			line = append(line, '\n')
+		} else {
+			line = fmt.Appendf(nil, "%s - %s [%s] %q %d %d %q %q\n",
+				entry.RemoteIP,
+				dashIfEmpty(entry.User),
+				entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+				entry.Method+" "+entry.Path+" "+entry.Proto,
+				entry.Status,
+				entry.Bytes,
+				dashIfEmpty(entry.Referer),
+				dashIfEmpty(entry.UserAgent),
+			)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(line)
+	}
+}
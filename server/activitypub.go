@@ -0,0 +1,539 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/internal/clock"
+)
+
+const (
+	activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	activityPublic         = activityStreamsContext + "#Public"
+	activityMediaType      = "application/activity+json"
+
+	apOutboxSize         = 20
+	apSignatureTolerance = 5 * time.Minute
+	apMaxActorBytes      = 1 << 20
+)
+
+var apNoteTemplate = template.Must(template.New("note").Parse(
+	`<p><strong>{{.Title}}</strong></p>{{range .Paragraphs}}<p>{{.}}</p>{{end}}<p><a href="{{.URL}}">{{.URL}}</a></p>`))
+
+type apObject struct {
+	Context      any      `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Actor        string   `json:"actor,omitempty"`
+	AttributedTo string   `json:"attributedTo,omitempty"`
+	Content      string   `json:"content,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	Published    string   `json:"published,omitempty"`
+	Updated      string   `json:"updated,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Cc           []string `json:"cc,omitempty"`
+	Object       any      `json:"object,omitempty"`
+}
+
+type apCollection struct {
+	Context      any    `json:"@context"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems any    `json:"orderedItems"`
+}
+
+type apPublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type apActor struct {
+	Context           any         `json:"@context"`
+	ID                string      `json:"id"`
+	Type              string      `json:"type"`
+	PreferredUsername string      `json:"preferredUsername"`
+	Name              string      `json:"name"`
+	URL               string      `json:"url"`
+	Inbox             string      `json:"inbox"`
+	Outbox            string      `json:"outbox"`
+	Followers         string      `json:"followers"`
+	PublicKey         apPublicKey `json:"publicKey"`
+}
+
+// apRemoteActor is the part of another server's actor document we use.
+type apRemoteActor struct {
+	ID        string `json:"id"`
+	Inbox     string `json:"inbox"`
+	Endpoints struct {
+		SharedInbox string `json:"sharedInbox"`
+	} `json:"endpoints"`
+	PublicKey apPublicKey `json:"publicKey"`
+}
+
+type apFollower struct {
+	ID          string
+	Inbox       string
+	SharedInbox string
+}
+
+type apDelivery struct {
+	inbox string
+	body  []byte
+}
+
+// ActivityPub federates the blog as a single actor, since posts have no
+// authors of their own. Followers are kept in memory, like the posts.
+// Deliveries to their inboxes are signed and sent from a queue by Run, so
+// writing a post doesn't wait on remote servers.
+type ActivityPub struct {
+	cfg     ActivityPubConfig
+	site    string
+	base    string
+	key     *rsa.PrivateKey
+	pubPEM  string
+	posts   PostReader
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	queue   chan apDelivery
+	logger  *slog.Logger
+	clock   clock.Clock
+
+	mu        sync.RWMutex
+	followers map[string]apFollower
+}
+
+// NewActivityPub needs blog.base_url: actor and object IDs have to stay the
+// same however the server is reached.
+func NewActivityPub(cfg ActivityPubConfig, blog BlogConfig, notifiers NotifiersConfig, key *rsa.PrivateKey, posts PostReader) (*ActivityPub, error) {
+	if blog.BaseURL == "" {
+		return nil, errors.New("activitypub: blog.base_url is required")
+	}
+	pubPEM, err := encodeRSAPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("activitypub: %w", err)
+	}
+	return &ActivityPub{
+		cfg:       cfg,
+		site:      blog.Title,
+		base:      strings.TrimSuffix(blog.BaseURL, "/"),
+		key:       key,
+		pubPEM:    pubPEM,
+		posts:     posts,
+		client:    outboundClient(notifiers.Timeout.Duration, cfg.AllowPrivateTargets),
+		retries:   notifiers.Retries,
+		backoff:   notifiers.RetryBackoff.Duration,
+		queue:     make(chan apDelivery, cfg.QueueSize),
+		logger:    slog.With("component", "activitypub"),
+		clock:     clock.System,
+		followers: map[string]apFollower{},
+	}, nil
+}
+
+func (a *ActivityPub) actorID() string      { return a.base + "/ap/actor" }
+func (a *ActivityPub) keyID() string        { return a.actorID() + "#main-key" }
+func (a *ActivityPub) followersID() string  { return a.base + "/ap/followers" }
+func (a *ActivityPub) noteID(id int) string { return a.base + "/ap/posts/" + strconv.Itoa(id) }
+
+func (a *ActivityPub) note(post Post) (apObject, error) {
+	postURL := a.base + "/blog/" + blogSlug(post)
+	var content bytes.Buffer
+	err := apNoteTemplate.Execute(&content, map[string]any{"Title": post.Title, "Paragraphs": blogParagraphs(post.Body), "URL": postURL})
+	if err != nil {
+		return apObject{}, err
+	}
+	note := apObject{
+		ID:           a.noteID(post.ID),
+		Type:         "Note",
+		AttributedTo: a.actorID(),
+		Content:      content.String(),
+		URL:          postURL,
+		Published:    post.CreatedAt.UTC().Format(time.RFC3339),
+		To:           []string{activityPublic},
+		Cc:           []string{a.followersID()},
+	}
+	if post.UpdatedAt.After(post.CreatedAt) {
+		note.Updated = post.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return note, nil
+}
+
+// activity wraps post in the activity for action. Updates get an ID per
+// edit because servers ignore activities whose ID they have seen.
+func (a *ActivityPub) activity(post Post, action Action) (apObject, error) {
+	activity := apObject{
+		Context: activityStreamsContext,
+		Actor:   a.actorID(),
+		To:      []string{activityPublic},
+		Cc:      []string{a.followersID()},
+	}
+	switch action {
+	case ActionDelete:
+		activity.ID = a.noteID(post.ID) + "#delete"
+		activity.Type = "Delete"
+		activity.Object = apObject{ID: a.noteID(post.ID), Type: "Tombstone"}
+		return activity, nil
+	case ActionUpdate:
+		activity.ID = a.noteID(post.ID) + "#update-" + strconv.FormatInt(post.UpdatedAt.UnixNano(), 10)
+		activity.Type = "Update"
+	default:
+		activity.ID = a.noteID(post.ID) + "#create"
+		activity.Type = "Create"
+	}
+	note, err := a.note(post)
+	if err != nil {
+		return apObject{}, err
+	}
+	activity.Published = note.Published
+	activity.Object = note
+	return activity, nil
+}
+
+// NotifyPostUpdated queues the activity for every follower, once per shared
+// inbox.
+func (a *ActivityPub) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	activity, err := a.activity(post, action)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, inbox := range a.followerInboxes() {
+		errs = append(errs, a.enqueue(inbox, body))
+	}
+	return errors.Join(errs...)
+}
+
+func (a *ActivityPub) followerInboxes() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var inboxes []string
+	for _, follower := range a.followers {
+		inbox := follower.Inbox
+		if follower.SharedInbox != "" {
+			inbox = follower.SharedInbox
+		}
+		if !slices.Contains(inboxes, inbox) {
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	return inboxes
+}
+
+func (a *ActivityPub) enqueue(inbox string, body []byte) error {
+	select {
+	case a.queue <- apDelivery{inbox: inbox, body: body}:
+		return nil
+	default:
+		return fmt.Errorf("activitypub: delivery queue full, dropped delivery to %s", inbox)
+	}
+}
+
+// Run delivers queued activities until ctx is done. Each delivery is tried
+// notifiers.retries more times, doubling notifiers.retry_backoff between
+// attempts.
+func (a *ActivityPub) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-a.queue:
+			err := a.deliver(ctx, d)
+			backoff := a.backoff
+			for attempt := 0; err != nil && attempt < a.retries && ctx.Err() == nil; attempt++ {
+				select {
+				case <-ctx.Done():
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				err = a.deliver(ctx, d)
+			}
+			if err != nil {
+				a.logger.ErrorContext(ctx, "deliver activity", "inbox", d.inbox, "error", err)
+			}
+		}
+	}
+}
+
+func (a *ActivityPub) deliver(ctx context.Context, d apDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.inbox, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", activityMediaType)
+	if err := signHTTPRequest(req, d.body, a.keyID(), a.key, a.clock.Now()); err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("inbox %s: unexpected status %s", d.inbox, resp.Status)
+	}
+	return nil
+}
+
+// fetchActor gets a remote actor document with a signed GET, which servers
+// running in authorized fetch mode require.
+func (a *ActivityPub) fetchActor(ctx context.Context, rawURL string) (apRemoteActor, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && !(a.cfg.AllowHTTP && u.Scheme == "http")) {
+		return apRemoteActor{}, apperr.New(apperr.InvalidSignature, "activitypub: keyId must be an https URL")
+	}
+	u.Fragment = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return apRemoteActor{}, err
+	}
+	req.Header.Set("Accept", activityMediaType)
+	if err := signHTTPRequest(req, nil, a.keyID(), a.key, a.clock.Now()); err != nil {
+		return apRemoteActor{}, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return apRemoteActor{}, apperr.Wrap(apperr.InvalidSignature, fmt.Errorf("activitypub: fetch %s: %w", u, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apRemoteActor{}, apperr.New(apperr.InvalidSignature, fmt.Sprintf("activitypub: fetch %s: %s", u, resp.Status))
+	}
+	var actor apRemoteActor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, apMaxActorBytes)).Decode(&actor); err != nil {
+		return apRemoteActor{}, apperr.Wrap(apperr.InvalidSignature, fmt.Errorf("activitypub: decode %s: %w", u, err))
+	}
+	return actor, nil
+}
+
+// verifyInbox checks the request's HTTP signature against the key of the
+// actor that signed it and returns that actor.
+func (a *ActivityPub) verifyInbox(c *gin.Context, body []byte) (apRemoteActor, error) {
+	sig, err := parseHTTPSignature(c.GetHeader("Signature"))
+	if err != nil {
+		return apRemoteActor{}, err
+	}
+	actor, err := a.fetchActor(c.Request.Context(), sig.KeyID)
+	if err != nil {
+		return apRemoteActor{}, err
+	}
+	if actor.PublicKey.ID != sig.KeyID || actor.PublicKey.Owner != actor.ID {
+		return apRemoteActor{}, apperr.New(apperr.InvalidSignature, "activitypub: keyId is not the actor's key")
+	}
+	key, err := parseRSAPublicKey(actor.PublicKey.PublicKeyPem)
+	if err != nil {
+		return apRemoteActor{}, apperr.Wrap(apperr.InvalidSignature, fmt.Errorf("activitypub: %w", err))
+	}
+	return actor, verifyHTTPSignature(c.Request, sig, body, key, apSignatureTolerance, a.clock.Now())
+}
+
+func renderActivityJSON(c *gin.Context, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Data(status, activityMediaType+"; charset=utf-8", body)
+}
+
+// WebFingerHandler answers acct:<username>@<host> and the actor URL.
+func (a *ActivityPub) WebFingerHandler() func(*gin.Context) {
+	base, _ := url.Parse(a.base)
+	subject := "acct:" + a.cfg.Username + "@" + strings.ToLower(base.Host)
+	return func(c *gin.Context) {
+		resource := c.Query("resource")
+		if !strings.EqualFold(resource, subject) && resource != a.actorID() {
+			abortWithProblem(c, apperr.NotFound, "unknown resource")
+			return
+		}
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Content-Type", "application/jrd+json")
+		c.JSON(http.StatusOK, gin.H{
+			"subject": subject,
+			"aliases": []string{a.actorID(), a.base + "/blog"},
+			"links": []gin.H{
+				{"rel": "self", "type": activityMediaType, "href": a.actorID()},
+				{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": a.base + "/blog"},
+			},
+		})
+	}
+}
+
+func (a *ActivityPub) ActorHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		renderActivityJSON(c, http.StatusOK, apActor{
+			Context:           []string{activityStreamsContext, "https://w3id.org/security/v1"},
+			ID:                a.actorID(),
+			Type:              "Person",
+			PreferredUsername: a.cfg.Username,
+			Name:              a.site,
+			URL:               a.base + "/blog",
+			Inbox:             a.base + "/ap/inbox",
+			Outbox:            a.base + "/ap/outbox",
+			Followers:         a.followersID(),
+			PublicKey:         apPublicKey{ID: a.keyID(), Owner: a.actorID(), PublicKeyPem: a.pubPEM},
+		})
+	}
+}
+
+func (a *ActivityPub) NoteHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, ok := postIDParam(c)
+		if !ok {
+			return
+		}
+		post, err := a.posts.GetPostByID(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		note, err := a.note(post)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		note.Context = activityStreamsContext
+		renderActivityJSON(c, http.StatusOK, note)
+	}
+}
+
+// OutboxHandler lists Create activities for the newest posts.
+func (a *ActivityPub) OutboxHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		newest, total, err := a.posts.ListPosts(c.Request.Context(), ListOptions{Sort: SortCreatedDesc, Limit: apOutboxSize})
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		items := []apObject{}
+		for _, post := range newest {
+			activity, err := a.activity(post, ActionCreate)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			activity.Context = nil
+			items = append(items, activity)
+		}
+		renderActivityJSON(c, http.StatusOK, apCollection{
+			Context:      activityStreamsContext,
+			ID:           a.base + "/ap/outbox",
+			Type:         "OrderedCollection",
+			TotalItems:   total,
+			OrderedItems: items,
+		})
+	}
+}
+
+// FollowersHandler only publishes the count, as Mastodon does.
+func (a *ActivityPub) FollowersHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		a.mu.RLock()
+		total := len(a.followers)
+		a.mu.RUnlock()
+		renderActivityJSON(c, http.StatusOK, apCollection{
+			Context:      activityStreamsContext,
+			ID:           a.followersID(),
+			Type:         "OrderedCollection",
+			TotalItems:   total,
+			OrderedItems: []string{},
+		})
+	}
+}
+
+// InboxHandler accepts Follow and Undo of a Follow from signed requests.
+// Other activities are acknowledged and ignored.
+func (a *ActivityPub) InboxHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		actor, err := a.verifyInbox(c, body)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		var activity struct {
+			Type   string          `json:"type"`
+			Actor  string          `json:"actor"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := json.Unmarshal(body, &activity); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		if activity.Actor != actor.ID {
+			abortWithProblem(c, apperr.InvalidSignature, "activity actor is not the signer")
+			return
+		}
+
+		switch activity.Type {
+		case "Follow":
+			var object string
+			if json.Unmarshal(activity.Object, &object) != nil || object != a.actorID() {
+				abortWithProblem(c, apperr.ValidationFailed, "Follow object must be "+a.actorID())
+				return
+			}
+			if actor.Inbox == "" {
+				abortWithProblem(c, apperr.ValidationFailed, "actor has no inbox")
+				return
+			}
+			a.mu.Lock()
+			a.followers[actor.ID] = apFollower{ID: actor.ID, Inbox: actor.Inbox, SharedInbox: actor.Endpoints.SharedInbox}
+			a.mu.Unlock()
+
+			accept, err := json.Marshal(apObject{
+				Context: activityStreamsContext,
+				ID:      a.actorID() + "#accepts/" + rand.Text(),
+				Type:    "Accept",
+				Actor:   a.actorID(),
+				Object:  json.RawMessage(body),
+			})
+			if err == nil {
+				err = a.enqueue(actor.Inbox, accept)
+			}
+			if err != nil {
+				a.logger.ErrorContext(c.Request.Context(), "accept follow", "actor", actor.ID, "error", err)
+			}
+			a.logger.InfoContext(c.Request.Context(), "new follower", "actor", actor.ID)
+		case "Undo":
+			var undone struct {
+				Type string `json:"type"`
+			}
+			if json.Unmarshal(activity.Object, &undone) == nil && undone.Type == "Follow" {
+				a.mu.Lock()
+				delete(a.followers, actor.ID)
+				a.mu.Unlock()
+				a.logger.InfoContext(c.Request.Context(), "follower left", "actor", actor.ID)
+			}
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestActivityPub(t *testing.T, cfg ActivityPubConfig) *ActivityPub {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifiers := NotifiersConfig{Timeout: Duration{Duration: 5 * time.Second}}
+	a, err := NewActivityPub(cfg, BlogConfig{BaseURL: "https://blog.example.com"}, notifiers, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// TestFetchActorRefusesLoopback checks that an inbox request can't make the
+// server fetch a keyId on its own network.
+func TestFetchActorRefusesLoopback(t *testing.T) {
+	var hits atomic.Int32
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		json.NewEncoder(w).Encode(apRemoteActor{ID: "http://" + r.Host + "/actor"})
+	}))
+	defer remote.Close()
+	keyID := remote.URL + "/actor#main-key"
+
+	a := newTestActivityPub(t, ActivityPubConfig{AllowHTTP: true})
+	if _, err := a.fetchActor(context.Background(), keyID); err == nil {
+		t.Fatal("fetched an actor from a loopback keyId")
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("loopback server got %d requests, want none", n)
+	}
+
+	a = newTestActivityPub(t, ActivityPubConfig{AllowHTTP: true, AllowPrivateTargets: true})
+	if _, err := a.fetchActor(context.Background(), keyID); err != nil {
+		t.Fatalf("with allow_private_targets: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/clock"
+	"gosolid/internal/notify"
+)
+
+type Alert struct {
+	Name      string    `json:"name"`
+	Rate      float64   `json:"rate"`
+	Threshold float64   `json:"threshold"`
+	Failures  int       `json:"failures"`
+	Total     int       `json:"total"`
+	Window    string    `json:"window"`
+	Time      time.Time `json:"time"`
+}
+
+func (a Alert) String() string {
+	return fmt.Sprintf("%s: %.1f%% failed (%d of %d) over the last %s, threshold %.1f%%",
+		a.Name, a.Rate*100, a.Failures, a.Total, a.Window, a.Threshold*100)
+}
+
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+type WebhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookAlerter(url string) *WebhookAlerter {
+	return &WebhookAlerter{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (a *WebhookAlerter) Alert(ctx context.Context, alert Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+type EmailAlerter struct {
+	emailService notify.EmailService
+	sender       string
+	recipients   []string
+}
+
+func NewEmailAlerter(emailService notify.EmailService, sender string, recipients ...string) *EmailAlerter {
+	return &EmailAlerter{emailService: emailService, sender: sender, recipients: recipients}
+}
+
+func (a *EmailAlerter) Alert(ctx context.Context, alert Alert) error {
+	var errs []error
+	for _, recipient := range a.recipients {
+		if err := a.emailService.SendEmail(ctx, a.sender, recipient, "[gosolid] "+alert.Name+" alert", alert.String()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type rateBucket struct {
+	second   int64
+	total    int
+	failures int
+}
+
+// RateMonitor tracks the failure rate over a sliding window of one-second
+// buckets. Once at least minEvents have been seen in the window and the rate
+// reaches threshold it alerts, then stays quiet for cooldown.
+type RateMonitor struct {
+	name      string
+	threshold float64
+	minEvents int
+	cooldown  time.Duration
+	alerter   Alerter
+	clock     clock.Clock
+
+	mu        sync.Mutex
+	buckets   []rateBucket
+	lastAlert time.Time
+}
+
+func NewRateMonitor(name string, window time.Duration, threshold float64, minEvents int, cooldown time.Duration, alerter Alerter) *RateMonitor {
+	return &RateMonitor{
+		name:      name,
+		threshold: threshold,
+		minEvents: minEvents,
+		cooldown:  cooldown,
+		alerter:   alerter,
+		clock:     clock.System,
+		buckets:   make([]rateBucket, max(int(window/time.Second), 1)),
+	}
+}
+
+func (m *RateMonitor) Record(failed bool) {
+	now := m.clock.Now()
+	alert, fire := m.record(now, failed)
+	if !fire {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := m.alerter.Alert(ctx, alert); err != nil {
+			slog.With("component", "alerting").Warn("send alert", "alert", alert.Name, "error", err)
+		}
+	}()
+}
+
+func (m *RateMonitor) record(now time.Time, failed bool) (Alert, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	second := now.Unix()
+	bucket := &m.buckets[second%int64(len(m.buckets))]
+	if bucket.second != second {
+		*bucket = rateBucket{second: second}
+	}
+	bucket.total++
+	if failed {
+		bucket.failures++
+	}
+
+	var total, failures int
+	for _, b := range m.buckets {
+		if second-b.second < int64(len(m.buckets)) {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	if total < m.minEvents || total == 0 {
+		return Alert{}, false
+	}
+	rate := float64(failures) / float64(total)
+	if rate < m.threshold || now.Sub(m.lastAlert) < m.cooldown {
+		return Alert{}, false
+	}
+	m.lastAlert = now
+
+	return Alert{
+		Name:      m.name,
+		Rate:      rate,
+		Threshold: m.threshold,
+		Failures:  failures,
+		Total:     total,
+		Window:    (time.Duration(len(m.buckets)) * time.Second).String(),
+		Time:      now,
+	}, true
+}
+
+// ErrorRateMiddleware counts every 5xx response as a failure.
+func ErrorRateMiddleware(monitor *RateMonitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		monitor.Record(c.Writer.Status() >= 500)
+	}
+}
+
+type MonitoredNotifier struct {
+	next    PostUpdateNotifier
+	monitor *RateMonitor
+}
+
+func NewMonitoredNotifier(next PostUpdateNotifier, monitor *RateMonitor) *MonitoredNotifier {
+	return &MonitoredNotifier{next: next, monitor: monitor}
+}
+
+func (n *MonitoredNotifier) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	err := n.next.NotifyPostUpdated(ctx, post, action)
+	n.monitor.Record(err != nil)
+	return err
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosolid/internal/clock"
+)
+
+// alertInbox is an Alerter that hands alerts to the test.
+type alertInbox chan Alert
+
+func (a alertInbox) Alert(ctx context.Context, alert Alert) error {
+	a <- alert
+	return nil
+}
+
+func TestRateMonitorWindowAndCooldown(t *testing.T) {
+	alerts := make(alertInbox, 10)
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := NewRateMonitor("errors", 10*time.Second, 0.5, 4, time.Minute, alerts)
+	m.clock = fake
+
+	expect := func(step string, want bool) {
+		t.Helper()
+		select {
+		case alert := <-alerts:
+			if !want {
+				t.Errorf("%s: alerted %v, want no alert", step, alert)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if want {
+				t.Errorf("%s: no alert, want one", step)
+			}
+		}
+	}
+
+	for range 3 {
+		m.Record(true)
+	}
+	// Failures that have left the window don't count towards minEvents.
+	fake.Advance(11 * time.Second)
+	m.Record(true)
+	expect("after the window moved on", false)
+
+	for range 3 {
+		m.Record(true)
+	}
+	expect("four failures in the window", true)
+
+	fake.Advance(30 * time.Second)
+	for range 4 {
+		m.Record(true)
+	}
+	expect("within the cooldown", false)
+
+	fake.Advance(31 * time.Second)
+	m.Record(true)
+	expect("after the cooldown", false) // the window only holds one event
+	for range 3 {
+		m.Record(true)
+	}
+	expect("after the cooldown with a full window", true)
+}
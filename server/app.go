@@ -0,0 +1,872 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"google.golang.org/grpc"
+
+	"gosolid/apperr"
+	"gosolid/internal/httpapi"
+	"gosolid/internal/notify"
+	"gosolid/internal/storage"
+)
+
+// App is the composition root. NewApp builds the server from the config in
+// dependency order, config → repositories → notifiers → services →
+// handlers, and Run serves it. Each provider takes the parts it depends on
+// as arguments and registers whatever it starts with the shutdown hooks,
+// which run in reverse, so the graph reads top to bottom in NewApp.
+type App struct {
+	cfg       Config
+	load      func() (Config, error)
+	startedAt time.Time
+	logger    *slog.Logger
+	logLevels *LogLevels
+	hooks     ShutdownHooks
+	reloader  *ConfigReloader
+	secrets   SecretsProvider
+
+	alerts    Alerts
+	repos     *Repositories
+	notifiers *Notifiers
+	features  *FeatureFlags
+	tokens    *TokenStore
+	mail      notify.EmailService
+	resets    *PasswordResetMailer
+	verifier  *EmailVerifier
+	health    *HealthChecker
+	posts     *PostService
+	blobs     BlobStore
+	links     *AttachmentLinks
+	grpc      *grpc.Server
+
+	router    *gin.Engine
+	tlsConfig *tls.Config
+	http      *http.Server
+	listener  net.Listener
+	done      chan error
+	stopOnce  sync.Once
+	stopErr   error
+
+	// Set by the Options of NewServer; nil ones are built from the config.
+	store      PostRepository
+	extra      []PostUpdateNotifier
+	middleware []gin.HandlerFunc
+}
+
+// NewApp assembles the server. args are the command line arguments the
+// config came from, for reloads.
+func NewApp(cfg Config, args []string, startedAt time.Time) (*App, error) {
+	a := &App{cfg: cfg, startedAt: startedAt, load: func() (Config, error) {
+		return LoadConfig(args)
+	}}
+	if err := a.assemble(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// assemble builds a. On error, the parts already started are stopped.
+func (a *App) assemble() error {
+	if err := a.build(); err != nil {
+		a.hooks.Run(context.Background())
+		return err
+	}
+	return nil
+}
+
+func (a *App) build() error {
+	var err error
+	cfg := a.cfg
+	if a.logger, a.logLevels, err = provideLogging(cfg.Log); err != nil {
+		return err
+	}
+	slog.SetDefault(a.logger)
+	gin.DebugPrintRouteFunc = func(httpMethod, absolutePath, handlerName string, nuHandlers int) {
+		slog.Debug("route", "method", httpMethod, "path", absolutePath, "handler", handlerName)
+	}
+	slog.Info("config loaded", "config", cfg, "version", version)
+	httpapi.UseCodec(cfg.JSONCodec)
+	slog.Info("json codec", "codec", httpapi.CodecName())
+
+	a.reloader = NewConfigReloader(a.load, cfg)
+	a.reloader.OnReload("logging", func(cfg Config) error {
+		lvl, err := ParseLogLevel(cfg.Log.Level)
+		if err != nil {
+			return err
+		}
+		a.logLevels.SetLevel("", lvl)
+		return nil
+	})
+
+	shutdownTracing, err := SetupTracing(context.Background())
+	if err != nil {
+		return fmt.Errorf("configure tracing: %w", err)
+	}
+	a.hooks.Add("tracing", shutdownTracing)
+
+	a.alerts = provideAlerts(cfg)
+	if a.secrets, err = NewSecretsProvider(cfg.Secrets); err != nil {
+		return fmt.Errorf("configure secrets: %w", err)
+	}
+	if a.repos, err = provideRepositories(cfg, a.store, a.secrets, &a.hooks); err != nil {
+		return err
+	}
+	if a.notifiers, err = provideNotifiers(cfg, a.secrets, a.repos.Plain, a.alerts.NotifierFailures, &a.hooks); err != nil {
+		return err
+	}
+	for _, notifier := range a.extra {
+		a.notifiers.Static = append(a.notifiers.Static, a.notifiers.monitored(notifier))
+	}
+	if err := a.repos.wrapWrites(cfg, a.notifiers, a.reloader); err != nil {
+		return err
+	}
+	a.features = provideFeatures(cfg.Features, &a.hooks)
+	if a.tokens == nil {
+		if a.tokens, err = provideTokens(a.secrets); err != nil {
+			return err
+		}
+	}
+	if a.mail, err = provideMail(cfg.Mail, a.secrets); err != nil {
+		return err
+	}
+	if cfg.Auth.ResetURL != "" {
+		a.resets = NewPasswordResetMailer(a.mail, a.tokens, cfg.Mail.From, cfg.Auth.ResetURL)
+	}
+	if cfg.Auth.VerifyURL != "" {
+		a.verifier = NewEmailVerifier(a.mail, cfg.Mail.From, cfg.Auth.VerifyURL)
+	}
+	a.health = provideHealth(cfg, a.repos.Top, &a.hooks)
+	if a.posts, err = a.providePosts(); err != nil {
+		return err
+	}
+	if cfg.Seed != "" {
+		if err := a.seed(context.Background()); err != nil {
+			return fmt.Errorf("seed: %w", err)
+		}
+	}
+	if a.notifiers.GitSync != nil {
+		if err := startGitSync(a.notifiers.GitSync, a.posts, a.repos.Top, &a.hooks); err != nil {
+			return err
+		}
+	}
+	if a.blobs, err = provideBlobs(cfg.Blobs, a.secrets, &a.hooks); err != nil {
+		return err
+	}
+	if a.links, err = provideAttachmentLinks(cfg.Blobs, a.blobs, a.secrets); err != nil {
+		return err
+	}
+	a.grpc = provideGRPCServer(a.posts, a.tokens, a.verifier, cfg.Limits.RequestTimeout.Duration, &a.hooks)
+	if err := a.provideRouter(); err != nil {
+		return err
+	}
+	return a.startTelegram()
+}
+
+func provideLogging(cfg LogConfig) (*slog.Logger, *LogLevels, error) {
+	lvl, err := ParseLogLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, fmt.Errorf("configure logging: %w", err)
+	}
+	levels := NewLogLevels(lvl)
+	logger, err := NewLogger(os.Stderr, cfg.Format, levels)
+	if err != nil {
+		return nil, nil, fmt.Errorf("configure logging: %w", err)
+	}
+	return logger, levels, nil
+}
+
+// Alerts are where errors are reported and the rates that alert; the
+// monitors are nil without an alert webhook or threshold.
+type Alerts struct {
+	Reporter         ErrorReporter
+	ErrorRate        *RateMonitor
+	NotifierFailures *RateMonitor
+}
+
+func provideAlerts(cfg Config) Alerts {
+	reporter := MultiErrorReporter{LogErrorReporter{}}
+	if cfg.Errors.ReportURL != "" {
+		reporter = append(reporter, NewWebhookErrorReporter(cfg.Errors.ReportURL))
+	}
+	alerts := Alerts{Reporter: reporter}
+	if cfg.Alerts.WebhookURL != "" {
+		alerter := NewWebhookAlerter(cfg.Alerts.WebhookURL)
+		if cfg.Alerts.ErrorRateThreshold > 0 {
+			alerts.ErrorRate = NewRateMonitor("http_5xx_rate", cfg.Alerts.Window.Duration, cfg.Alerts.ErrorRateThreshold, cfg.Alerts.MinEvents, cfg.Alerts.Cooldown.Duration, alerter)
+		}
+		if cfg.Alerts.NotifierFailureThreshold > 0 {
+			alerts.NotifierFailures = NewRateMonitor("notifier_failure_rate", cfg.Alerts.Window.Duration, cfg.Alerts.NotifierFailureThreshold, cfg.Alerts.MinEvents, cfg.Alerts.Cooldown.Duration, alerter)
+		}
+	}
+	return alerts
+}
+
+// Repositories is the repository stack with the layers other parts reach
+// directly; layers that aren't configured are nil. provideRepositories
+// builds it up to encryption and wrapWrites adds the layers that act on
+// every write, which need the notifiers.
+type Repositories struct {
+	Store PostRepository
+	// Decorated is the store in the layers storage.layers names.
+	Decorated PostRepository
+	Encrypted *EncryptedPostRepository
+	// Plain is the stack up to encryption, for background readers such as
+	// the search index.
+	Plain     PostReader
+	Index     *InvertedIndex
+	Bodies    *PostBodyCache
+	Notifying *notify.NotifyingPostRepository
+	// Top is the whole stack, what the service uses.
+	Top PostRepository
+}
+
+// provideRepositories builds the stack on store, or on the configured
+// backend if store is nil. Only the configured backend is closed on
+// shutdown; a given store belongs to the caller.
+func provideRepositories(cfg Config, store PostRepository, secrets SecretsProvider, hooks *ShutdownHooks) (*Repositories, error) {
+	if store == nil {
+		var err error
+		if store, err = NewPostStore(cfg.Storage); err != nil {
+			return nil, fmt.Errorf("configure storage: %w", err)
+		}
+		hooks.Add("repository", CloseRepository(store))
+	}
+	r := &Repositories{Store: store}
+
+	decorators, err := RepositoryDecorators(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.Decorated = DecorateRepository(store, decorators...)
+	db := r.Decorated
+
+	encryptionKeys, err := secrets.GetSecret(context.Background(), "POST_ENCRYPTION_KEYS")
+	switch {
+	case err == nil:
+		keyring, err := ParseEncryptionKeyring(encryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("configure encryption: %w", err)
+		}
+		r.Encrypted = NewEncryptedPostRepository(db, keyring)
+		db = r.Encrypted
+	case !errors.Is(err, ErrSecretNotFound):
+		return nil, fmt.Errorf("load POST_ENCRYPTION_KEYS: %w", err)
+	}
+	r.Plain, r.Top = db, db
+	return r, nil
+}
+
+func (r *Repositories) wrapWrites(cfg Config, notifiers *Notifiers, reloader *ConfigReloader) error {
+	db := r.Top
+	if cfg.Search.URL == "" && cfg.Search.InvertedIndex {
+		r.Index = NewInvertedIndex(db)
+		if err := r.Index.Load(context.Background()); err != nil {
+			return fmt.Errorf("load inverted index: %w", err)
+		}
+		db = r.Index
+	}
+	if cfg.HTTPCache.PostBodies > 0 {
+		r.Bodies = NewPostBodyCache(db, cfg.HTTPCache.PostBodies)
+		db = r.Bodies
+	}
+	r.Notifying = notify.NewNotifyingPostRepository(db, notifiers.Build(cfg.Notifiers)...)
+	r.Notifying.SetFanOut(cfg.Notifiers.Concurrency, cfg.Notifiers.Deadline.Duration)
+	reloader.OnReload("notifiers", func(cfg Config) error {
+		r.Notifying.SetNotifiers(notifiers.Build(cfg.Notifiers)...)
+		r.Notifying.SetFanOut(cfg.Notifiers.Concurrency, cfg.Notifiers.Deadline.Duration)
+		return nil
+	})
+	r.Top = r.Notifying
+	return nil
+}
+
+// Restorer is what admin restores write through, if the backend can
+// restore. Backups hold what the backend stores, so restores skip the
+// layers above it except for purging the caches and starting new reads.
+func (r *Repositories) Restorer() (PostRestorer, bool) {
+	if _, ok := storage.Unwrap[PostRestorer](r.Store); !ok {
+		return nil, false
+	}
+	restorer, _ := storage.Unwrap[PostRestorer](r.Decorated)
+	if r.Index != nil {
+		restorer = purgingRestorer{restorer, func() {
+			if err := r.Index.Load(context.Background()); err != nil {
+				slog.Error("reload inverted index", "error", err)
+			}
+		}}
+	}
+	if r.Bodies != nil {
+		restorer = purgingRestorer{restorer, r.Bodies.Purge}
+	}
+	return restorer, true
+}
+
+// Notifiers are the receivers of post changes. Static ones are set up once;
+// Build adds the webhooks, which change with reloads.
+type Notifiers struct {
+	Events      *EventBus
+	Static      []PostUpdateNotifier
+	ActivityPub *ActivityPub
+	GitSync     *GitSync
+	SearchIndex *SearchIndex
+	RESTHooks   *RESTHooks
+	failures    *RateMonitor
+}
+
+// monitored wraps notifier with the failure rate monitor, if there is one.
+func (n *Notifiers) monitored(notifier PostUpdateNotifier) PostUpdateNotifier {
+	if n.failures == nil {
+		return notifier
+	}
+	return NewMonitoredNotifier(notifier, n.failures)
+}
+
+func (n *Notifiers) Build(cfg NotifiersConfig) []PostUpdateNotifier {
+	notifiers := NewWebhookNotifiers(cfg)
+	for i, notifier := range notifiers {
+		notifiers[i] = n.monitored(notifier)
+	}
+	return append(slices.Clone(n.Static), notifiers...)
+}
+
+// provideNotifiers sets up the static notifiers. posts is what ActivityPub
+// and the search index read.
+func provideNotifiers(cfg Config, secrets SecretsProvider, posts PostReader, failures *RateMonitor, hooks *ShutdownHooks) (*Notifiers, error) {
+	n := &Notifiers{Events: NewEventBus(64, 1000), failures: failures}
+	hooks.Add("event bus", func(context.Context) error {
+		n.Events.Close()
+		return nil
+	})
+	n.Static = []PostUpdateNotifier{n.Events}
+	if cfg.Notifiers.MQTT.Broker != "" {
+		password, err := secrets.GetSecret(context.Background(), "MQTT_PASSWORD")
+		if err != nil && !errors.Is(err, ErrSecretNotFound) {
+			return nil, fmt.Errorf("load MQTT_PASSWORD: %w", err)
+		}
+		mqttNotifier := notify.NewMQTTNotifier(notify.MQTTConfig(cfg.Notifiers.MQTT), password, cfg.Notifiers.Timeout.Duration)
+		hooks.Add("mqtt", mqttNotifier.Close)
+		n.Static = append(n.Static, NewTracingNotifier("mqtt", n.monitored(NewMetricsNotifier("mqtt", mqttNotifier))))
+	}
+	apKey, err := secrets.GetSecret(context.Background(), "ACTIVITYPUB_PRIVATE_KEY")
+	switch {
+	case err == nil:
+		key, err := parseRSAPrivateKey(apKey)
+		if err != nil {
+			return nil, fmt.Errorf("parse ACTIVITYPUB_PRIVATE_KEY: %w", err)
+		}
+		if n.ActivityPub, err = NewActivityPub(cfg.ActivityPub, cfg.Blog, cfg.Notifiers, key, posts); err != nil {
+			return nil, fmt.Errorf("configure activitypub: %w", err)
+		}
+		runInBackground("activitypub", n.ActivityPub.Run, hooks)
+		n.Static = append(n.Static, NewTracingNotifier("activitypub", n.monitored(NewMetricsNotifier("activitypub", n.ActivityPub))))
+	case !errors.Is(err, ErrSecretNotFound):
+		return nil, fmt.Errorf("load ACTIVITYPUB_PRIVATE_KEY: %w", err)
+	}
+	if cfg.GitSync.URL != "" {
+		password, err := secrets.GetSecret(context.Background(), "GIT_SYNC_PASSWORD")
+		if err != nil && !errors.Is(err, ErrSecretNotFound) {
+			return nil, fmt.Errorf("load GIT_SYNC_PASSWORD: %w", err)
+		}
+		n.GitSync = NewGitSync(cfg.GitSync, password)
+		n.Static = append(n.Static, NewTracingNotifier("gitsync", n.monitored(NewMetricsNotifier("gitsync", n.GitSync))))
+	}
+	if cfg.Search.URL != "" {
+		password, err := secrets.GetSecret(context.Background(), "SEARCH_PASSWORD")
+		if err != nil && !errors.Is(err, ErrSecretNotFound) {
+			return nil, fmt.Errorf("load SEARCH_PASSWORD: %w", err)
+		}
+		n.SearchIndex = NewSearchIndex(cfg.Search, cfg.Notifiers, password, posts)
+		runInBackground("search", n.SearchIndex.Run, hooks)
+		n.Static = append(n.Static, NewTracingNotifier("search", n.monitored(NewMetricsNotifier("search", n.SearchIndex))))
+	}
+	for _, plugin := range cfg.Notifiers.Plugins {
+		notifier, err := notifierPlugins[plugin.Name](plugin.Settings, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("configure notifier %s: %w", plugin.Name, err)
+		}
+		hooks.Add(plugin.Name, closeNotifier(notifier))
+		n.Static = append(n.Static, NewTracingNotifier(plugin.Name, n.monitored(NewMetricsNotifier(plugin.Name, notifier))))
+	}
+	n.RESTHooks = NewRESTHooks(cfg.Triggers, cfg.Notifiers.Timeout.Duration)
+	n.Static = append(n.Static, NewTracingNotifier("resthooks", NewMetricsNotifier("resthooks", n.RESTHooks)))
+	return n, nil
+}
+
+// runInBackground starts run in a goroutine and registers a hook named
+// name that cancels its context.
+func runInBackground(name string, run func(ctx context.Context), hooks *ShutdownHooks) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go run(ctx)
+	hooks.Add(name, func(context.Context) error {
+		cancel()
+		return nil
+	})
+}
+
+func provideFeatures(cfg FeaturesConfig, hooks *ShutdownHooks) *FeatureFlags {
+	features := NewFeatureFlags(cfg.Flags)
+	if cfg.RemoteURL != "" {
+		runInBackground("feature flags", func(ctx context.Context) {
+			features.Watch(ctx, NewHTTPFlagProvider(cfg.RemoteURL), cfg.RefreshInterval.Duration)
+		}, hooks)
+	}
+	return features
+}
+
+func provideTokens(secrets SecretsProvider) (*TokenStore, error) {
+	tokens := NewTokenStore()
+	adminToken, err := secrets.GetSecret(context.Background(), "ADMIN_TOKEN")
+	switch {
+	case err == nil:
+		tokens.Add(adminToken, Principal{Name: "admin", Scopes: []Scope{ScopeAdmin}})
+	case errors.Is(err, ErrSecretNotFound):
+		slog.Warn("ADMIN_TOKEN is not set; no API tokens can be issued")
+	default:
+		return nil, fmt.Errorf("load ADMIN_TOKEN: %w", err)
+	}
+	return tokens, nil
+}
+
+// provideMail is the SMTP transport, or nil without mail.smtp_addr.
+func provideMail(cfg MailConfig, secrets SecretsProvider) (notify.EmailService, error) {
+	if cfg.SMTPAddr == "" {
+		return nil, nil
+	}
+	var password string
+	if cfg.Username != "" {
+		var err error
+		if password, err = secrets.GetSecret(context.Background(), "SMTP_PASSWORD"); err != nil {
+			return nil, fmt.Errorf("load SMTP_PASSWORD: %w", err)
+		}
+	}
+	return notify.NewSMTPEmailService(cfg.SMTPAddr, cfg.Username, password), nil
+}
+
+func provideHealth(cfg Config, db PostRepository, hooks *ShutdownHooks) *HealthChecker {
+	health := NewHealthChecker(cfg.Limits.HealthCheckTimeout.Duration)
+	health.Register("repository", RepositoryHealthCheck(db))
+
+	if cfg.Heartbeat.URL != "" {
+		heartbeat := NewHeartbeat(cfg.Heartbeat.URL, cfg.Heartbeat.Interval.Duration, health)
+		ctx, cancel := context.WithCancel(context.Background())
+		go heartbeat.Run(ctx)
+		hooks.Add("heartbeat", func(ctx context.Context) error {
+			cancel()
+			return heartbeat.Stop(ctx)
+		})
+	}
+	return health
+}
+
+// providePosts splits the service into commands, which write through the
+// whole stack so every write raises its events, and queries, which search
+// with the search index when there is one.
+func (a *App) providePosts() (*PostService, error) {
+	var searcher PostSearcher
+	switch {
+	case a.notifiers.SearchIndex != nil:
+		searcher = a.notifiers.SearchIndex
+	case a.repos.Index != nil:
+		searcher = a.repos.Index
+	}
+	commands := NewPostCommands(a.repos.Top, a.features)
+	validator, err := NewValidator(a.cfg.Validation.Posts, Rules{})
+	if err != nil {
+		return nil, err
+	}
+	importValidator, err := NewValidator(a.cfg.Validation.Imports, Rules{TitleRequired})
+	if err != nil {
+		return nil, err
+	}
+	commands.SetValidators(validator, importValidator)
+	return &PostService{
+		PostCommands: commands,
+		PostQueries:  NewPostQueries(a.repos.Top, searcher),
+	}, nil
+}
+
+func startGitSync(gitSync *GitSync, posts *PostService, db PostRepository, hooks *ShutdownHooks) error {
+	restorer, _ := storage.Unwrap[PostRestorer](db)
+	if err := gitSync.Start(context.Background(), posts, restorer); err != nil {
+		return fmt.Errorf("start git sync: %w", err)
+	}
+	runInBackground("gitsync", gitSync.Run, hooks)
+	return nil
+}
+
+// provideAttachmentLinks presigns download URLs with stores that can, and
+// otherwise signs them with URL_SIGNING_KEY, or with a random key when the
+// secret isn't set.
+func provideAttachmentLinks(cfg BlobsConfig, blobs BlobStore, secrets SecretsProvider) (*AttachmentLinks, error) {
+	key, err := secrets.GetSecret(context.Background(), "URL_SIGNING_KEY")
+	switch {
+	case errors.Is(err, ErrSecretNotFound):
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		key = string(b)
+		slog.Warn("URL_SIGNING_KEY is not set; download URLs will stop working on restart")
+	case err != nil:
+		return nil, fmt.Errorf("load URL_SIGNING_KEY: %w", err)
+	}
+	return NewAttachmentLinks(blobs, NewURLSigner([]byte(key)), cfg.URLTTL.Duration), nil
+}
+
+func provideBlobs(cfg BlobsConfig, secrets SecretsProvider, hooks *ShutdownHooks) (BlobStore, error) {
+	var accessKey, secretKey string
+	if cfg.Backend == "s3" {
+		var err error
+		if accessKey, err = secrets.GetSecret(context.Background(), "S3_ACCESS_KEY"); err != nil {
+			return nil, fmt.Errorf("load S3_ACCESS_KEY: %w", err)
+		}
+		if secretKey, err = secrets.GetSecret(context.Background(), "S3_SECRET_KEY"); err != nil {
+			return nil, fmt.Errorf("load S3_SECRET_KEY: %w", err)
+		}
+	}
+	blobs, err := NewBlobStore(context.Background(), cfg, accessKey, secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("configure blobs: %w", err)
+	}
+	if local, ok := blobs.(*LocalBlobStore); ok && cfg.ExpireAfter.Duration > 0 {
+		runInBackground("blob expiry", func(ctx context.Context) {
+			local.ExpireLoop(ctx, cfg.ExpireAfter.Duration, time.Hour)
+		}, hooks)
+	}
+	return blobs, nil
+}
+
+func provideGRPCServer(posts PostUseCases, tokens *TokenStore, verifier *EmailVerifier, timeout time.Duration, hooks *ShutdownHooks) *grpc.Server {
+	srv := NewGRPCServer(posts, tokens, verifier, timeout)
+	hooks.Add("grpc server", func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			srv.Stop()
+			return ctx.Err()
+		}
+	})
+	return srv
+}
+
+// provideRouter sets up the HTTP server's middleware and routes.
+func (a *App) provideRouter() error {
+	cfg := a.cfg
+	e := gin.New()
+	if err := e.SetTrustedProxies(cfg.Auth.TrustedProxies); err != nil {
+		return fmt.Errorf("configure trusted proxies: %w", err)
+	}
+
+	ipFilter, err := NewIPFilter(cfg.Auth.IPAllow, cfg.Auth.IPDeny)
+	if err != nil {
+		return fmt.Errorf("configure ip filter: %w", err)
+	}
+	adminIPFilter, err := NewIPFilter(cfg.Auth.AdminIPAllow, cfg.Auth.AdminIPDeny)
+	if err != nil {
+		return fmt.Errorf("configure admin ip filter: %w", err)
+	}
+	if cfg.TLS.ClientCAFile != "" {
+		if a.tlsConfig, err = NewMTLSConfig(cfg.TLS.ClientCAFile); err != nil {
+			return fmt.Errorf("configure mtls: %w", err)
+		}
+	}
+	shedder := NewLoadShedder(cfg.Limits.MaxInFlight, cfg.Limits.MaxQueue, cfg.Limits.QueueTimeout.Duration, cfg.Limits.RetryAfter.Duration)
+	global, err := MiddlewareChain(routeGroup{app: a, ipFilter: ipFilter, shedder: shedder}, cfg.Middleware.Global)
+	if err != nil {
+		return err
+	}
+	e.Use(global...)
+	e.Use(a.middleware...)
+
+	httpapi.MountGin(e, []httpapi.Route{
+		{Method: http.MethodGet, Path: "/healthz", Handler: LivenessHandler()},
+		{Method: http.MethodGet, Path: "/readyz", Handler: ReadinessHandler(a.health)},
+		{Method: http.MethodGet, Path: "/version", Handler: VersionHandler()},
+		{Method: http.MethodGet, Path: "/errors", Handler: ErrorCatalogHandler()},
+	})
+	e.NoRoute(func(c *gin.Context) { abortWithProblem(c, apperr.NotFound, "") })
+	e.GET("/metrics", adminIPFilter.Middleware(), gin.WrapH(promhttp.Handler()))
+
+	stats := NewStats(cfg.Storage.Backend, a.startedAt)
+	stats.RegisterQueue("load_shedder", shedder.Queued)
+	a.reloader.OnReload("load shedder", func(cfg Config) error {
+		shedder.SetLimits(cfg.Limits.MaxInFlight, cfg.Limits.MaxQueue, cfg.Limits.QueueTimeout.Duration, cfg.Limits.RetryAfter.Duration)
+		return nil
+	})
+	if err := a.registerPostRoutes(e, routeGroup{app: a, ipFilter: ipFilter, shedder: shedder, timeout: cfg.Limits.RequestTimeout.Duration}); err != nil {
+		return err
+	}
+	if err := a.registerAdminRoutes(e, routeGroup{app: a, ipFilter: adminIPFilter, shedder: shedder, timeout: cfg.Limits.AdminRequestTimeout.Duration}, stats); err != nil {
+		return err
+	}
+	if err := a.registerPublicRoutes(e, shedder); err != nil {
+		return err
+	}
+
+	e.GET("/openapi.json", OpenAPIHandler(NewOpenAPI(e.Routes())))
+	e.GET("/docs", func(c *gin.Context) { c.Redirect(http.StatusMovedPermanently, "/docs/index.html") })
+	e.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("/openapi.json")))
+	a.router = e
+	return nil
+}
+
+// registerPostRoutes adds the authenticated post API, its streams and
+// attachments.
+func (a *App) registerPostRoutes(e *gin.Engine, group routeGroup) error {
+	cfg, posts, db, tokens, events := a.cfg, a.posts, a.repos.Top, a.tokens, a.notifiers.Events
+	middleware, err := MiddlewareChain(group, cfg.Middleware.API)
+	if err != nil {
+		return err
+	}
+	api := e.Group("/", middleware...)
+	memoryGuard := NewMemoryGuard(cfg.Limits.MemoryBudget, cfg.Limits.PressurePageSize, cfg.Limits.RetryAfter.Duration)
+	a.reloader.OnReload("memory guard", func(cfg Config) error {
+		memoryGuard.SetLimits(cfg.Limits.MemoryBudget, cfg.Limits.PressurePageSize, cfg.Limits.RetryAfter.Duration)
+		return nil
+	})
+
+	verified := RequireVerifiedEmail(a.verifier)
+	postUID := PostUIDParam(posts)
+	postMiddleware := map[string][]gin.HandlerFunc{
+		"POST /posts":        {RequireScope(ScopePostsWrite), verified},
+		"GET /posts":         {RequireScope(ScopePostsRead), memoryGuard.TruncateLists()},
+		"GET /posts/{id}":    {RequireScope(ScopePostsRead), postUID},
+		"PATCH /posts/{id}":  {RequireScope(ScopePostsWrite), verified, postUID},
+		"DELETE /posts/{id}": {RequireScope(ScopePostsWrite), postUID},
+	}
+	for _, route := range PostRoutes(posts) {
+		middleware, ok := postMiddleware[route.Method+" "+route.Path]
+		if !ok {
+			return fmt.Errorf("no middleware for %s %s", route.Method, route.Path)
+		}
+		httpapi.MountGin(api, []httpapi.Route{route}, middleware...)
+	}
+	api.POST("/posts/import", RequireScope(ScopePostsWrite), verified, ImportPostsHandler(posts))
+	api.GET("/posts/search", RequireScope(ScopePostsRead), memoryGuard.TruncateLists(), SearchPostsHandler(posts))
+	api.GET("/triggers/new-posts", RequireScope(ScopePostsRead), PostTriggerHandler(posts, HookEventNewPost))
+	api.GET("/triggers/updated-posts", RequireScope(ScopePostsRead), PostTriggerHandler(posts, HookEventUpdatedPost))
+	api.POST("/hooks", RequireScope(ScopePostsRead), a.notifiers.RESTHooks.SubscribeHandler())
+	api.DELETE("/hooks/:id", RequireScope(ScopePostsRead), a.notifiers.RESTHooks.UnsubscribeHandler())
+
+	// Streams and exports stay outside the api group: its request timeout
+	// and load shedder slots are meant for short requests.
+	e.GET("/ws", AuthMiddleware(tokens), RequireScope(ScopePostsRead), WebSocketHandler(events))
+	e.GET("/events", AuthMiddleware(tokens), RequireScope(ScopePostsRead), SSEHandler(events))
+	e.GET("/posts/export", AuthMiddleware(tokens), RequireScope(ScopePostsRead), memoryGuard.RejectUnderPressure(), ExportHandler(db))
+
+	attachments := e.Group("/posts/:id/attachments", AuthMiddleware(tokens), postUID)
+	attachments.POST("", RequireScope(ScopePostsWrite), UploadAttachmentHandler(posts, a.blobs, a.links))
+	attachments.GET("", RequireScope(ScopePostsRead), ListAttachmentsHandler(posts, a.blobs, a.links))
+	attachments.GET("/:name", RequireScope(ScopePostsRead), DownloadAttachmentHandler(posts, a.blobs, a.links))
+	attachments.DELETE("/:name", RequireScope(ScopePostsWrite), DeleteAttachmentHandler(posts, a.blobs))
+	e.GET("/files/:id/:name", RequireSignedURL(a.links.signer), SignedFileHandler(a.blobs))
+
+	ingestSecret, err := a.secrets.GetSecret(context.Background(), "INGEST_SECRET")
+	switch {
+	case err == nil:
+		signer := NewIngestSigner([]byte(ingestSecret), cfg.Ingest.Tolerance.Duration)
+		e.POST("/hooks/ingest", IngestHandler(signer, NewIngestReceiver(posts, cfg.Ingest)))
+	case !errors.Is(err, ErrSecretNotFound):
+		return fmt.Errorf("load INGEST_SECRET: %w", err)
+	}
+	return nil
+}
+
+func (a *App) registerAdminRoutes(e *gin.Engine, group routeGroup, stats *Stats) error {
+	cfg, db := a.cfg, a.repos.Top
+	middleware, err := MiddlewareChain(group, cfg.Middleware.Admin)
+	if err != nil {
+		return err
+	}
+	admin := e.Group("/admin", middleware...)
+	admin.POST("/tokens", IssueTokenHandler(a.tokens, a.verifier))
+	RegisterDebugRoutes(admin)
+	admin.GET("/stats", StatsHandler(db, stats))
+	admin.POST("/config/reload", ReloadConfigHandler(a.reloader))
+	admin.POST("/backup", BackupHandler(a.repos.Store, cfg.Storage.Backend))
+	if restorer, ok := a.repos.Restorer(); ok {
+		admin.POST("/restore", RestoreHandler(restorer))
+	}
+	admin.POST("/import/wordpress", ImportWordPressHandler(a.posts))
+	admin.POST("/seed", SeedHandler(a.posts))
+	admin.GET("/loglevel", GetLogLevelHandler(a.logLevels))
+	admin.PUT("/loglevel", SetLogLevelHandler(a.logLevels))
+	admin.DELETE("/loglevel/:component", ResetLogLevelHandler(a.logLevels))
+	if a.repos.Encrypted != nil {
+		admin.POST("/encryption/rotate", RotateEncryptionKeysHandler(a.repos.Encrypted, a.repos.Top))
+	}
+	return nil
+}
+
+// registerPublicRoutes adds the gRPC gateway, the blog and federation.
+func (a *App) registerPublicRoutes(e *gin.Engine, shedder *LoadShedder) error {
+	gateway, err := NewGateway(a.grpc)
+	if err != nil {
+		return fmt.Errorf("configure grpc gateway: %w", err)
+	}
+	a.hooks.Add("grpc gateway", gateway.Close)
+	e.Any("/v1/*path", shedder.Middleware(), gateway.Handler())
+
+	blog := e.Group("/blog", shedder.Middleware())
+	blog.GET("", BlogIndexHandler(a.posts, a.cfg.Blog))
+	blog.GET("/:slug", BlogPostHandler(a.posts, a.cfg.Blog))
+	e.GET("/oembed", shedder.Middleware(), OEmbedHandler(a.posts, a.cfg.Blog))
+
+	if a.resets != nil {
+		e.POST("/auth/reset", shedder.Middleware(), RequestResetHandler(a.resets))
+		e.POST("/auth/reset/confirm", shedder.Middleware(), ConfirmResetHandler(a.resets))
+	}
+	if a.verifier != nil {
+		e.GET("/auth/verify", shedder.Middleware(), ConfirmEmailHandler(a.verifier))
+		e.POST("/auth/verify", shedder.Middleware(), AuthMiddleware(a.tokens), ResendVerificationHandler(a.verifier))
+	}
+
+	if activityPub := a.notifiers.ActivityPub; activityPub != nil {
+		e.GET("/.well-known/webfinger", shedder.Middleware(), activityPub.WebFingerHandler())
+		federation := e.Group("/ap", shedder.Middleware())
+		federation.GET("/actor", activityPub.ActorHandler())
+		federation.POST("/inbox", activityPub.InboxHandler())
+		federation.GET("/outbox", activityPub.OutboxHandler())
+		federation.GET("/followers", activityPub.FollowersHandler())
+		federation.GET("/posts/:id", activityPub.NoteHandler())
+	}
+	return nil
+}
+
+func (a *App) startTelegram() error {
+	botToken, err := a.secrets.GetSecret(context.Background(), "TELEGRAM_BOT_TOKEN")
+	switch {
+	case err == nil:
+		bot := NewTelegramBot(a.cfg.Telegram, botToken, a.tokens, a.posts, a.cfg.Blog.BaseURL)
+		runInBackground("telegram", bot.Run, &a.hooks)
+	case !errors.Is(err, ErrSecretNotFound):
+		return fmt.Errorf("load TELEGRAM_BOT_TOKEN: %w", err)
+	}
+	return nil
+}
+
+// Run starts the server and serves until the process is told to stop,
+// reloading the config on SIGHUP, then shuts it down within the configured
+// timeout.
+func (a *App) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go a.reloader.WatchSignals(ctx)
+
+	if err := a.Start(); err != nil {
+		a.hooks.Run(context.Background())
+		return err
+	}
+	select {
+	case err := <-a.done:
+		if err != nil {
+			a.hooks.Run(context.Background())
+			return fmt.Errorf("serve: %w", err)
+		}
+	case <-ctx.Done():
+	}
+	stop()
+
+	timeout := a.cfg.ShutdownTimeout.Duration
+	slog.Info("shutting down", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return a.Shutdown(shutdownCtx)
+}
+
+// Start listens on the HTTP address, and the gRPC one if there is one, and
+// serves them in the background. It returns once both are listening, so
+// Addr can tell which port ":0" picked.
+func (a *App) Start() error {
+	cfg := a.cfg
+	if cfg.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			return fmt.Errorf("listen grpc: %w", err)
+		}
+		go func() {
+			if err := a.grpc.Serve(lis); err != nil {
+				slog.Error("serve grpc", "error", err)
+			}
+		}()
+		slog.Info("listening", "grpc_addr", lis.Addr().String())
+	}
+
+	lis, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	a.listener = lis
+	a.http = NewHTTPServer(cfg.Addr, a.router, cfg.Server)
+	serve := a.http.Serve
+	if a.tlsConfig != nil {
+		a.http.TLSConfig = a.tlsConfig
+		serve = func(lis net.Listener) error {
+			return a.http.ServeTLS(lis, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		}
+	}
+	a.done = make(chan error, 1)
+	go func() {
+		err := serve(lis)
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		a.done <- err
+	}()
+	slog.Info("listening", "addr", lis.Addr().String(), "mtls", a.tlsConfig != nil, "h2c", cfg.Server.H2C)
+	return nil
+}
+
+// Addr is the address the HTTP server listens on, once started.
+func (a *App) Addr() net.Addr {
+	if a.listener == nil {
+		return nil
+	}
+	return a.listener.Addr()
+}
+
+// Done receives the HTTP server's error if it stops on its own after
+// Start, or nil once Shutdown has stopped it.
+func (a *App) Done() <-chan error {
+	return a.done
+}
+
+// Shutdown stops accepting connections, waits for in-flight requests until
+// ctx is done and runs the shutdown hooks within the same deadline. Calls
+// after the first return its result.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.stopOnce.Do(func() {
+		var err error
+		if a.http != nil {
+			if err = a.http.Shutdown(ctx); err != nil {
+				slog.Error("draining connections", "error", err)
+			}
+		}
+		a.stopErr = errors.Join(err, a.hooks.Run(ctx))
+	})
+	return a.stopErr
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+// attachmentPrefix is where attachments live in the blob store; lifecycle
+// rules are scoped to it.
+const attachmentPrefix = "posts/"
+
+func attachmentKey(postID int, name string) string {
+	return attachmentPrefix + strconv.Itoa(postID) + "/" + name
+}
+
+type AttachmentResp struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	ModifiedAt  time.Time `json:"modified_at"`
+	URL         string    `json:"url"`
+}
+
+func attachmentName(key string) string {
+	return key[strings.LastIndexByte(key, '/')+1:]
+}
+
+func toAttachmentResp(blob BlobInfo, url string) AttachmentResp {
+	return AttachmentResp{
+		Name:        attachmentName(blob.Key),
+		Size:        blob.Size,
+		ContentType: blob.ContentType,
+		ModifiedAt:  blob.ModTime,
+		URL:         url,
+	}
+}
+
+// AttachmentLinks makes attachment download URLs that work without a token
+// until they expire. Stores that can presign URLs serve the bytes
+// themselves; for the others the URL is a signed /files/ one the API
+// serves, checked by RequireSignedURL.
+type AttachmentLinks struct {
+	blobs  BlobStore
+	signer *URLSigner
+	ttl    time.Duration
+}
+
+func NewAttachmentLinks(blobs BlobStore, signer *URLSigner, ttl time.Duration) *AttachmentLinks {
+	return &AttachmentLinks{blobs: blobs, signer: signer, ttl: ttl}
+}
+
+// URL is where postID's attachment blob can be downloaded from.
+func (l *AttachmentLinks) URL(ctx context.Context, postID int, blob BlobInfo) (string, error) {
+	name := attachmentName(blob.Key)
+	if presigner, ok := l.blobs.(BlobPresigner); ok {
+		return presigner.PresignGet(ctx, blob.Key, name, l.ttl)
+	}
+	path := "/files/" + strconv.Itoa(postID) + "/" + name
+	return l.signer.Sign(path, l.signer.clock.Now().Add(l.ttl)), nil
+}
+
+// validAttachmentName keeps names usable as a single path segment on disk
+// and in URLs.
+func validAttachmentName(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > 200 || strings.HasPrefix(name, ".") {
+		return false
+	}
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\' || unicode.IsControl(r)
+	})
+}
+
+type postGetter interface {
+	GetPost(ctx context.Context, id int) (Post, error)
+}
+
+// attachmentPost checks the post in the URL exists before touching its
+// attachments, so a missing post is a POST_NOT_FOUND.
+func attachmentPost(c *gin.Context, posts postGetter) (int, bool) {
+	id, ok := postIDParam(c)
+	if !ok {
+		return 0, false
+	}
+	if _, err := posts.GetPost(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return 0, false
+	}
+	return id, true
+}
+
+// UploadAttachmentHandler stores the "file" field of a multipart upload,
+// replacing an attachment of the same name. Form parsing spills large files
+// to a temporary file, which the blob store then reads in parts.
+func UploadAttachmentHandler(posts postGetter, blobs BlobStore, links *AttachmentLinks) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, ok := attachmentPost(c, posts)
+		if !ok {
+			return
+		}
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		if !validAttachmentName(header.Filename) {
+			abortWithProblem(c, apperr.ValidationFailed, "file name must be a plain name without slashes or a leading dot")
+			return
+		}
+		contentType := header.Header.Get("Content-Type")
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType == "application/octet-stream" {
+			if byExt := mime.TypeByExtension(path.Ext(header.Filename)); byExt != "" {
+				contentType = byExt
+			}
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		defer f.Close()
+
+		blob, err := blobs.Put(c.Request.Context(), attachmentKey(id, header.Filename), f, header.Size, contentType)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		url, err := links.URL(c.Request.Context(), id, blob)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, toAttachmentResp(blob, url))
+	}
+}
+
+func ListAttachmentsHandler(posts postGetter, blobs BlobStore, links *AttachmentLinks) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, ok := attachmentPost(c, posts)
+		if !ok {
+			return
+		}
+		list, err := blobs.List(c.Request.Context(), attachmentKey(id, ""))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		resp := make([]AttachmentResp, 0, len(list))
+		for _, blob := range list {
+			url, err := links.URL(c.Request.Context(), id, blob)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			resp = append(resp, toAttachmentResp(blob, url))
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func attachmentNameParam(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if !validAttachmentName(name) {
+		abortWithError(c, ErrBlobNotFound)
+		return "", false
+	}
+	return name, true
+}
+
+// DownloadAttachmentHandler redirects to a fresh download URL, so the bytes
+// come from the bucket, or at least don't pass through the API's request
+// timeout and load shedding.
+func DownloadAttachmentHandler(posts postGetter, blobs BlobStore, links *AttachmentLinks) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, ok := attachmentPost(c, posts)
+		if !ok {
+			return
+		}
+		name, ok := attachmentNameParam(c)
+		if !ok {
+			return
+		}
+
+		blob, err := blobs.Stat(c.Request.Context(), attachmentKey(id, name))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		url, err := links.URL(c.Request.Context(), id, blob)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, url)
+	}
+}
+
+// SignedFileHandler serves the attachment a signed /files/ URL names. It
+// sits behind RequireSignedURL instead of a token.
+func SignedFileHandler(blobs BlobStore) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, ok := postIDParam(c)
+		if !ok {
+			return
+		}
+		name, ok := attachmentNameParam(c)
+		if !ok {
+			return
+		}
+
+		r, blob, err := blobs.Get(c.Request.Context(), attachmentKey(id, name))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		defer r.Close()
+
+		markStreaming(c)
+		contentType := blob.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.DataFromReader(http.StatusOK, blob.Size, contentType, r, map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": name}),
+			"Last-Modified":       blob.ModTime.UTC().Format(http.TimeFormat),
+		})
+	}
+}
+
+func DeleteAttachmentHandler(posts postGetter, blobs BlobStore) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, ok := attachmentPost(c, posts)
+		if !ok {
+			return
+		}
+		name, ok := attachmentNameParam(c)
+		if !ok {
+			return
+		}
+		if err := blobs.Delete(c.Request.Context(), attachmentKey(id, name)); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+type Scope string
+
+const (
+	ScopePostsRead  Scope = "posts:read"
+	ScopePostsWrite Scope = "posts:write"
+	ScopeAdmin      Scope = "admin"
+)
+
+var knownScopes = []Scope{ScopePostsRead, ScopePostsWrite, ScopeAdmin}
+
+type Principal struct {
+	Name   string
+	Scopes []Scope
+}
+
+func (p Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// TokenStore only keeps SHA-256 hashes of tokens, never the tokens themselves.
+type TokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]Principal
+}
+
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		tokens: make(map[string]Principal),
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *TokenStore) Add(token string, principal Principal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[hashToken(token)] = principal
+}
+
+func (s *TokenStore) Issue(principal Principal) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	s.Add(token, principal)
+	return token, nil
+}
+
+func (s *TokenStore) Lookup(token string) (Principal, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	principal, ok := s.tokens[hashToken(token)]
+	return principal, ok
+}
+
+// Has reports whether any token belongs to a principal named name.
+func (s *TokenStore) Has(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, principal := range s.tokens {
+		if principal.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeName drops every token of the principals named name and returns a
+// principal with all their scopes, or false when there were none.
+func (s *TokenStore) RevokeName(name string) (Principal, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revoked := Principal{Name: name}
+	found := false
+	for key, principal := range s.tokens {
+		if principal.Name != name {
+			continue
+		}
+		found = true
+		for _, scope := range principal.Scopes {
+			if !slices.Contains(revoked.Scopes, scope) {
+				revoked.Scopes = append(revoked.Scopes, scope)
+			}
+		}
+		delete(s.tokens, key)
+	}
+	return revoked, found
+}
+
+const principalKey = "principal"
+
+func AuthMiddleware(tokens *TokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := PrincipalFromContext(c); ok {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="gosolid"`)
+			abortWithProblem(c, apperr.Unauthenticated, "bearer token required")
+			return
+		}
+
+		principal, ok := tokens.Lookup(token)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="gosolid", error="invalid_token"`)
+			abortWithProblem(c, apperr.Unauthenticated, "invalid token")
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+func PrincipalFromContext(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := v.(Principal)
+	return principal, ok
+}
+
+func RequireScope(scope Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFromContext(c)
+		if !ok {
+			abortWithProblem(c, apperr.Unauthenticated, "")
+			return
+		}
+		if !principal.HasScope(scope) {
+			abortWithProblem(c, apperr.InsufficientScope, "requires scope "+string(scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+type IssueTokenReq struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+type IssueTokenResp struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Token  string   `json:"token"`
+}
+
+// IssueTokenHandler issues a token for a principal. With a verifier, a
+// principal without the admin scope must be named by an email address,
+// which has to be confirmed before the token can publish.
+func IssueTokenHandler(tokens *TokenStore, verifier *EmailVerifier) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var issueTokenReq IssueTokenReq
+
+		if err := c.ShouldBindJSON(&issueTokenReq); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+
+		principal := Principal{Name: issueTokenReq.Name}
+		for _, s := range issueTokenReq.Scopes {
+			if !slices.Contains(knownScopes, Scope(s)) {
+				abortWithProblem(c, apperr.ValidationFailed, "unknown scope "+s)
+				return
+			}
+			principal.Scopes = append(principal.Scopes, Scope(s))
+		}
+		if verifier != nil && !principal.HasScope(ScopeAdmin) {
+			if !validEmail(principal.Name) {
+				abortWithProblem(c, apperr.ValidationFailed, "name must be an email address while email verification is on")
+				return
+			}
+			if err := verifier.Register(c.Request.Context(), principal.Name); err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+
+		token, err := tokens.Issue(principal)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, IssueTokenResp{
+			Name:   issueTokenReq.Name,
+			Scopes: issueTokenReq.Scopes,
+			Token:  token,
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+const (
+	backupFormat  = "gosolid-backup"
+	backupVersion = 1
+)
+
+// Backup is the archive layout: gzip-compressed JSON that doesn't depend on
+// the backend it was taken from. Posts are stored exactly as the backend
+// holds them, so encrypted bodies stay encrypted.
+type Backup struct {
+	Format    string       `json:"format"`
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	Backend   string       `json:"backend"`
+	Posts     []BackupPost `json:"posts"`
+}
+
+// BackupPost timestamps are omitted for posts created before they were
+// tracked.
+type BackupPost struct {
+	ID        int       `json:"id"`
+	UID       string    `json:"uid,omitempty"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// PostRestorer is implemented by backends that can load a backup while
+// keeping the archived IDs.
+type PostRestorer interface {
+	ReplaceAll(ctx context.Context, posts []Post) error
+}
+
+func (b Backup) Validate() error {
+	if b.Format != backupFormat {
+		return fmt.Errorf("backup: unexpected format %q", b.Format)
+	}
+	if b.Version != backupVersion {
+		return fmt.Errorf("backup: unsupported version %d", b.Version)
+	}
+	seen := make(map[int]bool, len(b.Posts))
+	for i, post := range b.Posts {
+		if post.ID <= 0 {
+			return fmt.Errorf("backup: post %d has invalid id %d", i, post.ID)
+		}
+		if seen[post.ID] {
+			return fmt.Errorf("backup: duplicate post id %d", post.ID)
+		}
+		seen[post.ID] = true
+	}
+	return nil
+}
+
+func BackupHandler(db PostReader, backend string) func(*gin.Context) {
+	return func(c *gin.Context) {
+		posts, _, err := db.ListPosts(c.Request.Context(), ListOptions{})
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		backup := Backup{
+			Format:    backupFormat,
+			Version:   backupVersion,
+			CreatedAt: time.Now().UTC(),
+			Backend:   backend,
+			Posts:     make([]BackupPost, 0, len(posts)),
+		}
+		for _, post := range posts {
+			backup.Posts = append(backup.Posts, BackupPost{
+				ID:        post.ID,
+				UID:       post.UID,
+				Title:     post.Title,
+				Body:      post.Body,
+				CreatedAt: post.CreatedAt,
+				UpdatedAt: post.UpdatedAt,
+			})
+		}
+
+		filename := "gosolid-backup-" + backup.CreatedAt.Format("20060102T150405Z") + ".json.gz"
+		c.Header("Content-Type", "application/gzip")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+
+		zw := gzip.NewWriter(c.Writer)
+		if err := json.NewEncoder(zw).Encode(backup); err != nil {
+			c.Error(err)
+			return
+		}
+		if err := zw.Close(); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+type RestoreResp struct {
+	Restored int `json:"restored"`
+}
+
+// RestoreHandler replaces everything in the backend with the uploaded
+// archive. Nothing is written unless the whole archive decodes and validates.
+func RestoreHandler(db PostRestorer) func(*gin.Context) {
+	return func(c *gin.Context) {
+		zr, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			abortWithError(c, apperr.Invalid(fmt.Errorf("backup: %w", err)))
+			return
+		}
+		defer zr.Close()
+
+		var backup Backup
+		if err := json.NewDecoder(zr).Decode(&backup); err != nil {
+			abortWithError(c, apperr.Invalid(fmt.Errorf("backup: %w", err)))
+			return
+		}
+		if err := backup.Validate(); err != nil {
+			abortWithError(c, apperr.Wrap(apperr.InvalidBackup, err))
+			return
+		}
+
+		posts := make([]Post, 0, len(backup.Posts))
+		for _, post := range backup.Posts {
+			posts = append(posts, Post{
+				ID:        post.ID,
+				UID:       post.UID,
+				Title:     post.Title,
+				Body:      post.Body,
+				CreatedAt: post.CreatedAt,
+				UpdatedAt: post.UpdatedAt,
+			})
+		}
+		if err := db.ReplaceAll(c.Request.Context(), posts); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, RestoreResp{Restored: len(posts)})
+	}
+}
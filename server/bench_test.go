@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/httpapi"
+	"gosolid/internal/storage"
+)
+
+// benchPosts is how many posts the benchmarks start with.
+const benchPosts = 10000
+
+// newBenchDB returns a store holding n posts with IDs 1 to n.
+func newBenchDB(b *testing.B, n int) *storage.DB {
+	db := storage.NewDB()
+	posts := make([]Post, n)
+	for i := range posts {
+		posts[i] = Post{Title: "title " + strconv.Itoa(n-i), Body: "body"}
+	}
+	if _, err := db.AddPosts(context.Background(), posts); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+func BenchmarkRepository(b *testing.B) {
+	ctx := context.Background()
+	db := newBenchDB(b, benchPosts)
+	for _, bm := range []struct {
+		name string
+		fn   func(i int) error
+	}{
+		{"GetPostByID", func(i int) error {
+			_, err := db.GetPostByID(ctx, i%benchPosts+1)
+			return err
+		}},
+		{"UpdatePost", func(i int) error {
+			_, err := db.UpdatePost(ctx, Post{ID: i%benchPosts + 1, Title: "updated", Body: "body"})
+			return err
+		}},
+		{"ListPosts/page", func(int) error {
+			_, _, err := db.ListPosts(ctx, ListOptions{Limit: 50, Offset: benchPosts / 2})
+			return err
+		}},
+		{"ListPosts/cursor", func(int) error {
+			_, _, err := db.ListPosts(ctx, ListOptions{Limit: 50, After: benchPosts / 2, Sort: SortIDDesc})
+			return err
+		}},
+		{"ListPosts/sorted-page", func(int) error {
+			_, _, err := db.ListPosts(ctx, ListOptions{Limit: 50, Sort: SortTitle})
+			return err
+		}},
+		{"ListPosts/query", func(int) error {
+			_, _, err := db.ListPosts(ctx, ListOptions{Limit: 50, Query: "title 99"})
+			return err
+		}},
+		{"ListPosts/all", func(int) error {
+			_, _, err := db.ListPosts(ctx, ListOptions{})
+			return err
+		}},
+		{"EachPost/first-50", func(int) error {
+			n := 0
+			err := db.EachPost(ctx, func(Post) error {
+				if n++; n == 50 {
+					return errStopIteration
+				}
+				return nil
+			})
+			if err == errStopIteration {
+				err = nil
+			}
+			return err
+		}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				if err := bm.fn(i); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Run("AddPost", func(b *testing.B) {
+		b.ReportAllocs()
+		db := storage.NewDB()
+		for b.Loop() {
+			if _, err := db.AddPost(ctx, Post{Title: "title", Body: "body"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// newBenchRouter serves the post endpoints the way main does, minus
+// authentication and the middleware around them.
+func newBenchRouter(db PostRepository) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(JSONAPIMiddleware(), WireFormatMiddleware())
+	posts := NewPostService(db, NewFeatureFlags(nil))
+	httpapi.MountGin(e, PostRoutes(posts))
+	return e
+}
+
+func BenchmarkHandlers(b *testing.B) {
+	e := newBenchRouter(newBenchDB(b, benchPosts))
+	for _, bm := range []struct {
+		name, method, target, accept string
+		body                         []byte
+	}{
+		{name: "GetPost", method: http.MethodGet, target: "/posts/5000"},
+		{name: "ListPosts/page", method: http.MethodGet, target: "/posts?limit=50&offset=5000"},
+		{name: "ListPosts/page-jsonapi", method: http.MethodGet, target: "/posts?limit=50&offset=5000", accept: jsonAPIMediaType},
+		{name: "ListPosts/page-protobuf", method: http.MethodGet, target: "/posts?limit=50&offset=5000", accept: protobufMediaType},
+		{name: "ListPosts/all", method: http.MethodGet, target: "/posts"},
+		{name: "CreatePost", method: http.MethodPost, target: "/posts", body: []byte(`{"title":"title","body":"body"}`)},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				req := httptest.NewRequest(bm.method, bm.target, bytes.NewReader(bm.body))
+				if bm.body != nil {
+					req.Header.Set("Content-Type", "application/json")
+				}
+				if bm.accept != "" {
+					req.Header.Set("Accept", bm.accept)
+				}
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, req)
+				if w.Code >= 300 {
+					b.Fatalf("%s %s: status %d: %s", bm.method, bm.target, w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkJSONCodecs encodes a page of 500 posts, the way a large list or
+// export writes them, with each codec built in. make bench-json builds
+// go-json in as well.
+func BenchmarkJSONCodecs(b *testing.B) {
+	posts := make([]Post, 500)
+	for i := range posts {
+		posts[i] = Post{ID: i + 1, Title: "title " + strconv.Itoa(i), Body: strings.Repeat("body <b>text</b> ", 20)}
+	}
+	page := postListData(posts)
+	defer httpapi.UseCodec(httpapi.CodecName())
+	for _, name := range httpapi.CodecNames() {
+		httpapi.UseCodec(name)
+		b.Run(name+"/stream", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				a := newJSONArrayWriter(io.Discard)
+				for i := range page {
+					if err := a.Write(&page[i]); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := a.Close(); err != nil {
+					b.Fatal(err)
+				}
+				a.release()
+			}
+		})
+		b.Run(name+"/marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := httpapi.Codec().Marshal(page); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	ctx := context.Background()
+	db := newBenchDB(b, benchPosts)
+	index := NewInvertedIndex(db)
+	if err := index.Load(ctx); err != nil {
+		b.Fatal(err)
+	}
+	for _, bm := range []struct {
+		name   string
+		search PostSearcher
+	}{
+		{"builtin", NewBuiltinSearch(NewPostService(db, NewFeatureFlags(nil)))},
+		{"inverted-index", index},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, _, err := bm.search.SearchPosts(ctx, "title 99", 50, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
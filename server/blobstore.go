@@ -0,0 +1,364 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+
+	"gosolid/apperr"
+	"gosolid/internal/clock"
+)
+
+var ErrBlobNotFound = apperr.New(apperr.AttachmentNotFound, "attachment not found")
+
+type BlobInfo struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	ModTime     time.Time `json:"modified_at"`
+}
+
+// BlobStore keeps attachment contents under slash-separated keys. A size of
+// -1 in Put means unknown.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (BlobInfo, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error)
+	Stat(ctx context.Context, key string) (BlobInfo, error)
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobPresigner is implemented by blob stores clients can download from
+// directly, with a URL that stops working after ttl.
+type BlobPresigner interface {
+	PresignGet(ctx context.Context, key, filename string, ttl time.Duration) (string, error)
+}
+
+func NewBlobStore(ctx context.Context, cfg BlobsConfig, accessKey, secretKey string) (BlobStore, error) {
+	switch cfg.Backend {
+	case "local":
+		return NewLocalBlobStore(cfg.Dir), nil
+	case "s3":
+		return NewS3BlobStore(ctx, cfg, accessKey, secretKey)
+	default:
+		return nil, fmt.Errorf("blobs: unknown backend %q", cfg.Backend)
+	}
+}
+
+// LocalBlobStore keeps blobs as files under dir. Uploads are written to a
+// temporary file and renamed into place, so readers never see a partial
+// file. Content types come from the file extension.
+type LocalBlobStore struct {
+	dir   string
+	clock clock.Clock
+}
+
+const localUploadPrefix = ".upload-"
+
+func NewLocalBlobStore(dir string) *LocalBlobStore {
+	return &LocalBlobStore{dir: dir, clock: clock.System}
+}
+
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("blobs: invalid key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalBlobStore) info(key string, fi fs.FileInfo) BlobInfo {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return BlobInfo{Key: key, Size: fi.Size(), ContentType: contentType, ModTime: fi.ModTime()}
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (BlobInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return BlobInfo{}, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), localUploadPrefix+"*")
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return BlobInfo{}, err
+	}
+	if err := f.Close(); err != nil {
+		return BlobInfo{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return BlobInfo{}, err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return BlobInfo{}, err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	return s.info(key, fi), nil
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, BlobInfo{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, BlobInfo{}, err
+	}
+	return f, s.info(key, fi), nil
+}
+
+func (s *LocalBlobStore) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	fi, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	return s.info(key, fi), nil
+}
+
+func (s *LocalBlobStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	root, err := s.path(prefix)
+	if err != nil {
+		return nil, err
+	}
+	blobs := []BlobInfo{}
+	err = filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), localUploadPrefix) {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, s.info(filepath.ToSlash(rel), fi))
+		return ctx.Err()
+	})
+	return blobs, err
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); errors.Is(err, fs.ErrNotExist) {
+		return ErrBlobNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ExpireLoop is the local stand-in for an S3 lifecycle rule: every interval
+// it deletes blobs last written more than after ago, until ctx is done.
+func (s *LocalBlobStore) ExpireLoop(ctx context.Context, after, interval time.Duration) {
+	logger := slog.With("component", "blobs")
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		blobs, err := s.List(ctx, ".")
+		if err != nil {
+			logger.ErrorContext(ctx, "list blobs for expiry", "error", err)
+			continue
+		}
+		cutoff := s.clock.Now().Add(-after)
+		for _, blob := range blobs {
+			if blob.ModTime.After(cutoff) {
+				continue
+			}
+			if err := s.Delete(ctx, blob.Key); err != nil && !errors.Is(err, ErrBlobNotFound) {
+				logger.ErrorContext(ctx, "expire blob", "key", blob.Key, "error", err)
+			}
+		}
+	}
+}
+
+// S3BlobStore keeps blobs in an S3-compatible bucket such as MinIO.
+// Uploads larger than the part size, or of unknown size, use multipart
+// upload.
+type S3BlobStore struct {
+	client        *minio.Client
+	bucket        string
+	partSize      uint64
+	retentionMode minio.RetentionMode
+	retention     time.Duration
+}
+
+// NewS3BlobStore connects to the bucket. When expire_after is set it
+// replaces the bucket's lifecycle configuration with two rules: one that
+// expires attachments and one that aborts multipart uploads left incomplete
+// for a day.
+func NewS3BlobStore(ctx context.Context, cfg BlobsConfig, accessKey, secretKey string) (*S3BlobStore, error) {
+	lookup := minio.BucketLookupAuto
+	if cfg.S3.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.S3.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:       cfg.S3.UseSSL,
+		Region:       cfg.S3.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("blobs: %w", err)
+	}
+
+	if cfg.ExpireAfter.Duration > 0 {
+		days := lifecycle.ExpirationDays((cfg.ExpireAfter.Duration + 24*time.Hour - 1) / (24 * time.Hour))
+		rules := lifecycle.NewConfiguration()
+		rules.Rules = []lifecycle.Rule{
+			{
+				ID:         "gosolid-expire-attachments",
+				Status:     "Enabled",
+				RuleFilter: lifecycle.Filter{Prefix: attachmentPrefix},
+				Expiration: lifecycle.Expiration{Days: days},
+			},
+			{
+				ID:                             "gosolid-abort-incomplete-uploads",
+				Status:                         "Enabled",
+				RuleFilter:                     lifecycle.Filter{Prefix: attachmentPrefix},
+				AbortIncompleteMultipartUpload: lifecycle.AbortIncompleteMultipartUpload{DaysAfterInitiation: 1},
+			},
+		}
+		if err := client.SetBucketLifecycle(ctx, cfg.S3.Bucket, rules); err != nil {
+			return nil, fmt.Errorf("blobs: set lifecycle on %s: %w", cfg.S3.Bucket, err)
+		}
+	}
+
+	return &S3BlobStore{
+		client:        client,
+		bucket:        cfg.S3.Bucket,
+		partSize:      uint64(cfg.S3.PartSize),
+		retentionMode: minio.RetentionMode(cfg.S3.RetentionMode),
+		retention:     time.Duration(cfg.S3.RetentionDays) * 24 * time.Hour,
+	}, nil
+}
+
+func s3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (BlobInfo, error) {
+	opts := minio.PutObjectOptions{ContentType: contentType, PartSize: s.partSize}
+	if s.retentionMode != "" {
+		opts.Mode = s.retentionMode
+		opts.RetainUntilDate = time.Now().Add(s.retention)
+	}
+	upload, err := s.client.PutObject(ctx, s.bucket, key, r, size, opts)
+	if err != nil {
+		return BlobInfo{}, s3Error(err)
+	}
+	return BlobInfo{Key: key, Size: upload.Size, ContentType: contentType, ModTime: upload.LastModified}, nil
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, BlobInfo{}, s3Error(err)
+	}
+	// GetObject is lazy; Stat makes the request.
+	stat, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, BlobInfo{}, s3Error(err)
+	}
+	return obj, BlobInfo{Key: key, Size: stat.Size, ContentType: stat.ContentType, ModTime: stat.LastModified}, nil
+}
+
+func (s *S3BlobStore) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	stat, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return BlobInfo{}, s3Error(err)
+	}
+	return BlobInfo{Key: key, Size: stat.Size, ContentType: stat.ContentType, ModTime: stat.LastModified}, nil
+}
+
+// PresignGet makes a URL the bucket serves without credentials; browsers
+// save the download as filename.
+func (s *S3BlobStore) PresignGet(ctx context.Context, key, filename string, ttl time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, params)
+	if err != nil {
+		return "", s3Error(err)
+	}
+	return u.String(), nil
+}
+
+func (s *S3BlobStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	blobs := []BlobInfo{}
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, s3Error(obj.Err)
+		}
+		blobs = append(blobs, BlobInfo{Key: obj.Key, Size: obj.Size, ContentType: obj.ContentType, ModTime: obj.LastModified})
+	}
+	return blobs, nil
+}
+
+// Delete checks for the key first because S3 deletes of missing keys
+// succeed. In a bucket with object lock the delete only adds a delete
+// marker; the locked version stays until its retention runs out.
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		return s3Error(err)
+	}
+	return s3Error(s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}))
+}
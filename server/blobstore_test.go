@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gosolid/internal/clock"
+)
+
+func TestLocalBlobStoreExpireLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	store := NewLocalBlobStore(dir)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+	store.clock = fake
+
+	for key, modTime := range map[string]time.Time{
+		"old.txt":   now.Add(-2 * time.Hour),
+		"fresh.txt": now,
+	} {
+		if _, err := store.Put(ctx, key, strings.NewReader(key), int64(len(key)), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, key), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	go store.ExpireLoop(ctx, time.Hour, time.Minute)
+	// The loop may not have its ticker yet, so keep ticking until old.txt
+	// goes; fresh.txt has most of an hour to spare.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.Advance(time.Minute)
+		if _, err := store.Stat(ctx, "old.txt"); errors.Is(err, ErrBlobNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("old.txt was not expired")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := store.Stat(ctx, "fresh.txt"); err != nil {
+		t.Errorf("fresh.txt: %v, want it kept until it is an hour old", err)
+	}
+}
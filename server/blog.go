@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+// blogTemplates are the /blog pages. html/template escapes titles and bodies
+// by context; bodies are plain text, split into paragraphs on blank lines.
+var blogTemplates = template.Must(template.New("blog").Funcs(template.FuncMap{
+	"paragraphs": blogParagraphs,
+	"slug":       blogSlug,
+	"date":       func(t time.Time) string { return t.UTC().Format("2 January 2006") },
+}).Parse(`
+{{define "head"}}<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Heading}}{{.Heading}} · {{end}}{{.Site}}</title>
+{{if .OEmbed}}<link rel="alternate" type="application/json+oembed" href="{{.OEmbed}}" title="{{.Heading}}">
+{{end}}<style>body{font:1.05rem/1.6 system-ui,sans-serif;max-width:42rem;margin:2rem auto;padding:0 1rem;color:#222}a{color:#0b5cad}header a{color:inherit;text-decoration:none}time{color:#666;font-size:.9rem}nav{display:flex;justify-content:space-between;margin-top:2rem}</style>
+</head>
+<body>
+<header><a href="/blog"><strong>{{.Site}}</strong></a></header>
+<main>
+{{end}}
+
+{{define "foot"}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "head" .}}
+{{range .Posts}}<article>
+<h2><a href="/blog/{{slug .}}">{{.Title}}</a></h2>
+<time datetime="{{.CreatedAt.UTC.Format "2006-01-02"}}">{{date .CreatedAt}}</time>
+</article>
+{{else}}<p>No posts yet.</p>
+{{end}}<nav>{{if .Newer}}<a href="/blog?page={{.Newer}}" rel="prev">Newer posts</a>{{else}}<span></span>{{end}}{{if .Older}}<a href="/blog?page={{.Older}}" rel="next">Older posts</a>{{end}}</nav>
+{{template "foot" .}}{{end}}
+
+{{define "post"}}{{template "head" .}}
+<article>
+<h1>{{.Post.Title}}</h1>
+<time datetime="{{.Post.CreatedAt.UTC.Format "2006-01-02"}}">{{date .Post.CreatedAt}}</time>
+{{range paragraphs .Post.Body}}<p>{{.}}</p>
+{{end}}</article>
+{{template "foot" .}}{{end}}
+
+{{define "error"}}{{template "head" .}}
+<h1>{{.Heading}}</h1>
+<p><a href="/blog">Back to all posts</a></p>
+{{template "foot" .}}{{end}}
+`))
+
+type blogPage struct {
+	Site    string
+	Heading string
+	Posts   []Post
+	Post    Post
+	Newer   int
+	Older   int
+	OEmbed  string
+}
+
+// blogSlug is the path segment for a post. The leading ID is what's looked
+// up; the rest follows the title, and stale ones redirect to the current one.
+func blogSlug(post Post) string {
+	return strconv.Itoa(post.ID) + "-" + slugify(post.Title)
+}
+
+func blogPostID(slug string) (int, bool) {
+	idPart, _, _ := strings.Cut(slug, "-")
+	id, err := strconv.Atoi(idPart)
+	return id, err == nil && id > 0
+}
+
+// blogBaseURL is the site's public address without a trailing slash.
+func blogBaseURL(c *gin.Context, cfg BlogConfig) string {
+	if cfg.BaseURL != "" {
+		return strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func blogParagraphs(body string) []string {
+	var paragraphs []string
+	for _, p := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
+
+type postLister interface {
+	ListPosts(ctx context.Context) ([]Post, error)
+}
+
+type postPageLister interface {
+	ListPostPage(ctx context.Context, opts ListOptions) ([]Post, int, error)
+}
+
+// BlogIndexHandler lists posts newest first, cfg.PageSize to a page.
+func BlogIndexHandler(posts postPageLister, cfg BlogConfig) func(*gin.Context) {
+	return func(c *gin.Context) {
+		page := 1
+		if raw := c.Query("page"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				renderBlogError(c, cfg, apperr.New(apperr.NotFound, "no such page"))
+				return
+			}
+			page = n
+		}
+
+		start := (page - 1) * cfg.PageSize
+		newest, total, err := posts.ListPostPage(c.Request.Context(), ListOptions{Sort: SortCreatedDesc, Offset: start, Limit: cfg.PageSize})
+		if err != nil {
+			renderBlogError(c, cfg, err)
+			return
+		}
+		if start > 0 && start >= total {
+			renderBlogError(c, cfg, apperr.New(apperr.NotFound, "no such page"))
+			return
+		}
+		data := blogPage{Site: cfg.Title, Posts: newest}
+		if page > 1 {
+			data.Newer = page - 1
+		}
+		if start+len(newest) < total {
+			data.Older = page + 1
+		}
+
+		// Any change to any post can change the index.
+		latest, _, err := posts.ListPostPage(c.Request.Context(), ListOptions{Sort: SortUpdatedDesc, Limit: 1})
+		if err != nil {
+			renderBlogError(c, cfg, err)
+			return
+		}
+		var modified time.Time
+		if len(latest) > 0 {
+			modified = latest[0].UpdatedAt
+		}
+		renderBlog(c, cfg, http.StatusOK, "index", data, modified)
+	}
+}
+
+func BlogPostHandler(posts postGetter, cfg BlogConfig) func(*gin.Context) {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+		id, ok := blogPostID(slug)
+		if !ok {
+			renderBlogError(c, cfg, ErrNotFound)
+			return
+		}
+
+		post, err := posts.GetPost(c.Request.Context(), id)
+		if err != nil {
+			renderBlogError(c, cfg, err)
+			return
+		}
+		canonicalSlug := blogSlug(post)
+		if slug != canonicalSlug {
+			c.Redirect(http.StatusMovedPermanently, "/blog/"+canonicalSlug)
+			return
+		}
+		base := blogBaseURL(c, cfg)
+		renderBlog(c, cfg, http.StatusOK, "post", blogPage{
+			Site:    cfg.Title,
+			Heading: post.Title,
+			Post:    post,
+			OEmbed:  base + "/oembed?url=" + url.QueryEscape(base+"/blog/"+canonicalSlug),
+		}, post.UpdatedAt)
+	}
+}
+
+// renderBlog renders into a buffer so the page gets an ETag from its
+// contents; http.ServeContent then answers If-None-Match and
+// If-Modified-Since with 304.
+func renderBlog(c *gin.Context, cfg BlogConfig, status int, name string, data blogPage, modified time.Time) {
+	var buf bytes.Buffer
+	if err := blogTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	h.Set("X-Content-Type-Options", "nosniff")
+	if status != http.StatusOK {
+		h.Set("Cache-Control", "no-store")
+		c.Status(status)
+		c.Writer.Write(buf.Bytes())
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(cfg.CacheMaxAge.Seconds())))
+	http.ServeContent(c.Writer, c.Request, "", modified, bytes.NewReader(buf.Bytes()))
+}
+
+// renderBlogError shows an HTML page instead of problem+json; the error is
+// still attached to the context so it is logged and reported.
+func renderBlogError(c *gin.Context, cfg BlogConfig, err error) {
+	c.Error(err)
+	appErr := apperr.From(err)
+	status := appErr.Code.Status()
+	heading := "Something went wrong"
+	if status == http.StatusNotFound {
+		heading = "Page not found"
+	}
+	renderBlog(c, cfg, status, "error", blogPage{Site: cfg.Title, Heading: heading}, time.Time{})
+	c.Abort()
+}
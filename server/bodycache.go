@@ -0,0 +1,267 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"gosolid/client"
+	"gosolid/internal/httpapi"
+	"gosolid/internal/mapper"
+	"gosolid/internal/storage"
+)
+
+var postBodyCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "post_body_cache_requests_total",
+	Help: "GET /posts/:id JSON bodies answered by the serialized-body cache, by result: hit or miss.",
+}, []string{"result"})
+
+// PostBody is a post serialized the way GET /posts/:id sends it as plain
+// JSON, with the strong ETag of those bytes.
+type PostBody struct {
+	Post Post
+	JSON []byte
+	ETag string
+}
+
+func newPostBody(post Post) (PostBody, error) {
+	body, err := httpapi.Codec().Marshal(mapper.ToPostResp[client.GetPostResp](post))
+	if err != nil {
+		return PostBody{}, err
+	}
+	sum := sha256.Sum256(body)
+	return PostBody{Post: post, JSON: body, ETag: `"` + hex.EncodeToString(sum[:16]) + `"`}, nil
+}
+
+// PostBodyCache keeps the serialized bodies of up to size posts in an LRU.
+// Writes through it serialize the post they return, so the next read needs
+// no encoding; reads that miss serialize and fill. It has to sit above
+// encryption, which it does by wrapping the repository the service uses.
+//
+// Like CachingPostRepository it only fills when nothing could have changed
+// the post meanwhile: no write started since the read or write began, and
+// no other write to the post is still running.
+type PostBodyCache struct {
+	next PostRepository
+	size int
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used; values are post IDs
+	entries map[int]*list.Element
+	bodies  map[int]PostBody
+	writes  uint64
+	writing map[int]int
+}
+
+func NewPostBodyCache(next PostRepository, size int) *PostBodyCache {
+	return &PostBodyCache{
+		next:    next,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[int]*list.Element),
+		bodies:  make(map[int]PostBody),
+		writing: make(map[int]int),
+	}
+}
+
+func (r *PostBodyCache) Unwrap() PostRepository { return r.next }
+
+func (r *PostBodyCache) remove(id int) {
+	if elem, ok := r.entries[id]; ok {
+		r.order.Remove(elem)
+		delete(r.entries, id)
+		delete(r.bodies, id)
+	}
+}
+
+// fill stores body if no write started since writes was read and the
+// post isn't being written by anyone other than the caller's own writes.
+func (r *PostBodyCache) fill(body PostBody, writes uint64, own int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := body.Post.ID
+	if r.writes != writes || r.writing[id] != own {
+		return
+	}
+	if elem, ok := r.entries[id]; ok {
+		r.order.MoveToFront(elem)
+	} else {
+		r.entries[id] = r.order.PushFront(id)
+	}
+	r.bodies[id] = body
+	for r.order.Len() > r.size {
+		r.remove(r.order.Back().Value.(int))
+	}
+}
+
+// beginWrite drops id and marks it as being written until the returned
+// func is called.
+func (r *PostBodyCache) beginWrite(id int) (uint64, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes++
+	r.writing[id]++
+	r.remove(id)
+	return r.writes, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.writing[id]--; r.writing[id] == 0 {
+			delete(r.writing, id)
+		}
+	}
+}
+
+// Purge empties the cache, for writes that bypass it such as a restore.
+func (r *PostBodyCache) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes++
+	r.order.Init()
+	clear(r.entries)
+	clear(r.bodies)
+}
+
+func (r *PostBodyCache) currentWrites() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.writes
+}
+
+// GetPostBody returns the serialized body of post id.
+func (r *PostBodyCache) GetPostBody(ctx context.Context, id int) (PostBody, error) {
+	r.mu.Lock()
+	body, ok := r.bodies[id]
+	if ok {
+		r.order.MoveToFront(r.entries[id])
+	}
+	writes := r.writes
+	r.mu.Unlock()
+	if ok {
+		postBodyCacheRequestsTotal.WithLabelValues("hit").Inc()
+		return body, nil
+	}
+	postBodyCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+	post, err := r.next.GetPostByID(ctx, id)
+	if err != nil {
+		return PostBody{}, err
+	}
+	if body, err = newPostBody(post); err != nil {
+		return PostBody{}, err
+	}
+	r.fill(body, writes, 0)
+	return body, nil
+}
+
+func (r *PostBodyCache) GetPostByID(ctx context.Context, id int) (Post, error) {
+	return r.next.GetPostByID(ctx, id)
+}
+
+// AddPost and AddPosts read writes before adding, so a delete of a new
+// post that races the fill keeps it out of the cache.
+func (r *PostBodyCache) AddPost(ctx context.Context, newPost Post) (Post, error) {
+	writes := r.currentWrites()
+	post, err := r.next.AddPost(ctx, newPost)
+	if err != nil {
+		return Post{}, err
+	}
+	if body, err := newPostBody(post); err == nil {
+		r.fill(body, writes, 0)
+	}
+	return post, nil
+}
+
+func (r *PostBodyCache) AddPosts(ctx context.Context, newPosts []Post) ([]Post, error) {
+	writes := r.currentWrites()
+	posts, err := r.next.AddPosts(ctx, newPosts)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		if body, err := newPostBody(post); err == nil {
+			r.fill(body, writes, 0)
+		}
+	}
+	return posts, nil
+}
+
+func (r *PostBodyCache) ListPosts(ctx context.Context, opts ListOptions) ([]Post, int, error) {
+	return r.next.ListPosts(ctx, opts)
+}
+
+func (r *PostBodyCache) EachPost(ctx context.Context, fn func(Post) error) error {
+	return r.next.EachPost(ctx, fn)
+}
+
+func (r *PostBodyCache) UpdatePost(ctx context.Context, updatePost Post) (Post, error) {
+	writes, done := r.beginWrite(updatePost.ID)
+	defer done()
+	post, err := r.next.UpdatePost(ctx, updatePost)
+	if err != nil {
+		return Post{}, err
+	}
+	if body, err := newPostBody(post); err == nil {
+		r.fill(body, writes, 1)
+	}
+	return post, nil
+}
+
+func (r *PostBodyCache) DeletePostByID(ctx context.Context, id int) error {
+	_, done := r.beginWrite(id)
+	defer done()
+	return r.next.DeletePostByID(ctx, id)
+}
+
+func (r *PostBodyCache) ApplyPostWrites(ctx context.Context, writes []storage.PostWrite) ([]Post, error) {
+	writesBefore := r.currentWrites()
+	own := make(map[int]int)
+	for _, w := range writes {
+		if w.Op == storage.PostWriteAdd {
+			continue
+		}
+		var done func()
+		writesBefore, done = r.beginWrite(w.Post.ID)
+		defer done()
+		own[w.Post.ID]++
+	}
+	posts, err := storage.ApplyPostWrites(ctx, r.next, writes)
+	if err != nil {
+		return nil, err
+	}
+	for i, post := range posts {
+		if writes[i].Op == storage.PostWriteDelete {
+			continue
+		}
+		if body, err := newPostBody(post); err == nil {
+			r.fill(body, writesBefore, own[post.ID])
+		}
+	}
+	return posts, nil
+}
+
+// purgingRestorer calls purge after restores that skip the cache above
+// the restorer, like the admin restore.
+type purgingRestorer struct {
+	PostRestorer
+	purge func()
+}
+
+func (r purgingRestorer) ReplaceAll(ctx context.Context, posts []Post) error {
+	defer r.purge()
+	return r.PostRestorer.ReplaceAll(ctx, posts)
+}
+
+func (r *PostBodyCache) ReplaceAll(ctx context.Context, posts []Post) error {
+	restorer, ok := storage.Unwrap[PostRestorer](r.next)
+	if !ok {
+		return errors.New("body cache: the repository does not support ReplaceAll")
+	}
+	defer r.Purge()
+	return restorer.ReplaceAll(ctx, posts)
+}
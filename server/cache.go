@@ -0,0 +1,198 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"gosolid/internal/clock"
+	"gosolid/internal/storage"
+)
+
+var (
+	repositoryCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "repository_cache_requests_total",
+		Help: "GetPostByID calls answered by the post cache, by result: hit or miss.",
+	}, []string{"result"})
+
+	repositoryCacheEvictionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "repository_cache_evictions_total",
+		Help: "Posts evicted from the post cache to stay within storage.cache.size.",
+	})
+)
+
+type cacheEntry struct {
+	post    Post
+	expires time.Time
+}
+
+// CachingPostRepository keeps the most recently read posts in an LRU of at
+// most size entries, each for at most ttl (0 for no expiry). Only
+// GetPostByID is served from it; everything else goes to next.
+//
+// Writes drop the post from the cache rather than storing it, and a read
+// that missed only fills the cache if no write happened while it was
+// loading, so a slow read can't put back a post that a write replaced.
+type CachingPostRepository struct {
+	next  PostRepository
+	size  int
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used; values are post IDs
+	entries map[int]*list.Element
+	posts   map[int]cacheEntry
+	writes  uint64
+}
+
+func NewCachingPostRepository(next PostRepository, size int, ttl time.Duration) *CachingPostRepository {
+	return &CachingPostRepository{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		clock:   clock.System,
+		order:   list.New(),
+		entries: make(map[int]*list.Element),
+		posts:   make(map[int]cacheEntry),
+	}
+}
+
+func (r *CachingPostRepository) Unwrap() PostRepository { return r.next }
+
+func (r *CachingPostRepository) lookup(id int) (Post, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.posts[id]
+	if !ok {
+		return Post{}, false
+	}
+	if r.ttl > 0 && r.clock.Now().After(entry.expires) {
+		r.remove(id)
+		return Post{}, false
+	}
+	r.order.MoveToFront(r.entries[id])
+	return entry.post, true
+}
+
+// fill caches post unless a write happened since writes was read.
+func (r *CachingPostRepository) fill(post Post, writes uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writes != writes {
+		return
+	}
+	if elem, ok := r.entries[post.ID]; ok {
+		r.order.MoveToFront(elem)
+	} else {
+		r.entries[post.ID] = r.order.PushFront(post.ID)
+	}
+	r.posts[post.ID] = cacheEntry{post: post, expires: r.clock.Now().Add(r.ttl)}
+	for r.order.Len() > r.size {
+		r.remove(r.order.Back().Value.(int))
+		repositoryCacheEvictionsTotal.Inc()
+	}
+}
+
+func (r *CachingPostRepository) remove(id int) {
+	if elem, ok := r.entries[id]; ok {
+		r.order.Remove(elem)
+		delete(r.entries, id)
+		delete(r.posts, id)
+	}
+}
+
+func (r *CachingPostRepository) invalidate(ids ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes++
+	for _, id := range ids {
+		r.remove(id)
+	}
+}
+
+// Purge empties the cache, for writes that bypass it such as a restore.
+func (r *CachingPostRepository) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes++
+	r.order.Init()
+	clear(r.entries)
+	clear(r.posts)
+}
+
+func (r *CachingPostRepository) GetPostByID(ctx context.Context, id int) (Post, error) {
+	if post, ok := r.lookup(id); ok {
+		repositoryCacheRequestsTotal.WithLabelValues("hit").Inc()
+		return post, nil
+	}
+	repositoryCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+	r.mu.Lock()
+	writes := r.writes
+	r.mu.Unlock()
+	post, err := r.next.GetPostByID(ctx, id)
+	if err != nil {
+		return Post{}, err
+	}
+	r.fill(post, writes)
+	return post, nil
+}
+
+func (r *CachingPostRepository) AddPost(ctx context.Context, newPost Post) (Post, error) {
+	return r.next.AddPost(ctx, newPost)
+}
+
+func (r *CachingPostRepository) AddPosts(ctx context.Context, newPosts []Post) ([]Post, error) {
+	return r.next.AddPosts(ctx, newPosts)
+}
+
+func (r *CachingPostRepository) ListPosts(ctx context.Context, opts ListOptions) ([]Post, int, error) {
+	return r.next.ListPosts(ctx, opts)
+}
+
+func (r *CachingPostRepository) EachPost(ctx context.Context, fn func(Post) error) error {
+	return r.next.EachPost(ctx, fn)
+}
+
+// UpdatePost and DeletePostByID invalidate after the write too, since a
+// read may have filled the cache from the old value while it ran.
+func (r *CachingPostRepository) UpdatePost(ctx context.Context, updatePost Post) (Post, error) {
+	r.invalidate(updatePost.ID)
+	defer r.invalidate(updatePost.ID)
+	return r.next.UpdatePost(ctx, updatePost)
+}
+
+func (r *CachingPostRepository) DeletePostByID(ctx context.Context, id int) error {
+	r.invalidate(id)
+	defer r.invalidate(id)
+	return r.next.DeletePostByID(ctx, id)
+}
+
+func (r *CachingPostRepository) ApplyPostWrites(ctx context.Context, writes []storage.PostWrite) ([]Post, error) {
+	ids := make([]int, 0, len(writes))
+	for _, w := range writes {
+		if w.Op != storage.PostWriteAdd {
+			ids = append(ids, w.Post.ID)
+		}
+	}
+	r.invalidate(ids...)
+	defer r.invalidate(ids...)
+	return storage.ApplyPostWrites(ctx, r.next, writes)
+}
+
+// ReplaceAll lets restores through layers above reach the backend, purging
+// the cache once they are done.
+func (r *CachingPostRepository) ReplaceAll(ctx context.Context, posts []Post) error {
+	restorer, ok := storage.Unwrap[PostRestorer](r.next)
+	if !ok {
+		return errors.New("cache: the repository does not support ReplaceAll")
+	}
+	defer r.Purge()
+	return restorer.ReplaceAll(ctx, posts)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosolid/internal/clock"
+	"gosolid/internal/storage"
+)
+
+// countingRepo counts the GetPostByID calls that get past the cache.
+type countingRepo struct {
+	PostRepository
+	reads int
+}
+
+func (r *countingRepo) GetPostByID(ctx context.Context, id int) (Post, error) {
+	r.reads++
+	return r.PostRepository.GetPostByID(ctx, id)
+}
+
+func TestCachingPostRepositoryTTL(t *testing.T) {
+	ctx := context.Background()
+	db := storage.NewDB()
+	post, err := db.AddPost(ctx, Post{Title: "cached"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &countingRepo{PostRepository: db}
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	cache := NewCachingPostRepository(next, 10, time.Minute)
+	cache.clock = fake
+
+	steps := []struct {
+		advance time.Duration
+		reads   int
+	}{
+		{0, 1},                // miss fills the cache
+		{time.Minute, 1},      // still fresh at exactly the TTL
+		{time.Second, 2},      // expired, read again
+		{30 * time.Second, 2}, // the new entry is fresh
+	}
+	for i, step := range steps {
+		fake.Advance(step.advance)
+		got, err := cache.GetPostByID(ctx, post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != post.Title {
+			t.Fatalf("step %d: title %q, want %q", i, got.Title, post.Title)
+		}
+		if next.reads != step.reads {
+			t.Errorf("step %d: %d reads past the cache, want %d", i, next.reads, step.reads)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+
+	"gosolid/internal/storage"
+)
+
+var repositoryCoalescedReadsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "repository_coalesced_reads_total",
+	Help: "GetPostByID calls answered by a backend fetch shared with concurrent calls for the same post.",
+})
+
+// CoalescingPostRepository lets concurrent GetPostByID calls for the same
+// post share one call to next, so a burst of reads of a post that just fell
+// out of the cache reaches the backend once. It sits below the cache, where
+// only misses get to it.
+//
+// Every write starts a new generation of fetches, so a read that begins
+// after a write has returned never joins a fetch that may predate it.
+type CoalescingPostRepository struct {
+	next  PostRepository
+	group singleflight.Group
+	gen   atomic.Uint64
+}
+
+func NewCoalescingPostRepository(next PostRepository) *CoalescingPostRepository {
+	return &CoalescingPostRepository{next: next}
+}
+
+func (r *CoalescingPostRepository) Unwrap() PostRepository { return r.next }
+
+// GetPostByID fetches without the caller's cancellation, since other calls
+// may be waiting on the result, but each caller stops waiting when its own
+// context is done.
+func (r *CoalescingPostRepository) GetPostByID(ctx context.Context, id int) (Post, error) {
+	key := strconv.FormatUint(r.gen.Load(), 10) + ":" + strconv.Itoa(id)
+	ch := r.group.DoChan(key, func() (any, error) {
+		return r.next.GetPostByID(context.WithoutCancel(ctx), id)
+	})
+	select {
+	case <-ctx.Done():
+		return Post{}, ctx.Err()
+	case res := <-ch:
+		if res.Shared {
+			repositoryCoalescedReadsTotal.Inc()
+		}
+		if res.Err != nil {
+			return Post{}, res.Err
+		}
+		return res.Val.(Post), nil
+	}
+}
+
+func (r *CoalescingPostRepository) AddPost(ctx context.Context, newPost Post) (Post, error) {
+	return r.next.AddPost(ctx, newPost)
+}
+
+func (r *CoalescingPostRepository) AddPosts(ctx context.Context, newPosts []Post) ([]Post, error) {
+	return r.next.AddPosts(ctx, newPosts)
+}
+
+func (r *CoalescingPostRepository) ListPosts(ctx context.Context, opts ListOptions) ([]Post, int, error) {
+	return r.next.ListPosts(ctx, opts)
+}
+
+func (r *CoalescingPostRepository) EachPost(ctx context.Context, fn func(Post) error) error {
+	return r.next.EachPost(ctx, fn)
+}
+
+func (r *CoalescingPostRepository) UpdatePost(ctx context.Context, updatePost Post) (Post, error) {
+	defer r.gen.Add(1)
+	return r.next.UpdatePost(ctx, updatePost)
+}
+
+func (r *CoalescingPostRepository) DeletePostByID(ctx context.Context, id int) error {
+	defer r.gen.Add(1)
+	return r.next.DeletePostByID(ctx, id)
+}
+
+func (r *CoalescingPostRepository) ApplyPostWrites(ctx context.Context, writes []storage.PostWrite) ([]Post, error) {
+	defer r.gen.Add(1)
+	return storage.ApplyPostWrites(ctx, r.next, writes)
+}
+
+func (r *CoalescingPostRepository) ReplaceAll(ctx context.Context, posts []Post) error {
+	restorer, ok := storage.Unwrap[PostRestorer](r.next)
+	if !ok {
+		return errors.New("coalesce: the repository does not support ReplaceAll")
+	}
+	defer r.gen.Add(1)
+	return restorer.ReplaceAll(ctx, posts)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"gosolid/internal/clock"
+	"gosolid/internal/fixtures"
+	"gosolid/internal/storage"
+)
+
+// PostCommands are the post use cases that write. They check what they're
+// asked against the store itself, never against PostQueries, so a read side
+// that lags behind can't make them accept a stale write. Events come from
+// the notifying layer of the repository they write to.
+//
+// Every post written is checked by validator first, except imported rows,
+// which importValidator checks instead.
+type PostCommands struct {
+	db       PostRepository
+	features interface {
+		Enabled(name string) bool
+	}
+	clock           clock.Clock
+	validator       Validator
+	importValidator Validator
+}
+
+// NewPostCommands accepts any post, and imported rows with a title; see
+// SetValidators.
+func NewPostCommands(db PostRepository, features interface {
+	Enabled(name string) bool
+}) *PostCommands {
+	return &PostCommands{db: db, features: features, clock: clock.System, validator: Rules{}, importValidator: Rules{TitleRequired}}
+}
+
+// SetValidators replaces the validators of posts and of imported rows.
+func (s *PostCommands) SetValidators(posts, imports Validator) {
+	s.validator, s.importValidator = posts, imports
+}
+
+func (s *PostCommands) CreatePost(ctx context.Context, title, body string) (Post, error) {
+	now := s.clock.Now().UTC()
+	post := Post{Title: title, Body: body, CreatedAt: now, UpdatedAt: now}
+	if err := s.validator.ValidatePost(ctx, post); err != nil {
+		return Post{}, err
+	}
+	return s.db.AddPost(ctx, post)
+}
+
+// CreatePosts adds posts in one batch; either all of them are created or
+// none are.
+func (s *PostCommands) CreatePosts(ctx context.Context, posts []Post) ([]Post, error) {
+	for _, post := range posts {
+		if err := s.validator.ValidatePost(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+	return s.addPosts(ctx, posts)
+}
+
+func (s *PostCommands) addPosts(ctx context.Context, posts []Post) ([]Post, error) {
+	now := s.clock.Now().UTC()
+	for i := range posts {
+		posts[i].CreatedAt, posts[i].UpdatedAt = now, now
+	}
+	return s.db.AddPosts(ctx, posts)
+}
+
+// ImportPosts creates rows in one batch, skipping and reporting rows the
+// import validator rejects and rows whose title has the same slug as an
+// existing post or an earlier row. Results are in row order.
+func (s *PostCommands) ImportPosts(ctx context.Context, rows []Post) (ImportResp, error) {
+	slugs := map[string]bool{}
+	err := s.db.EachPost(ctx, func(post Post) error {
+		slugs[slugify(post.Title)] = true
+		return nil
+	})
+	if err != nil {
+		return ImportResp{}, err
+	}
+
+	resp := ImportResp{Results: make([]ImportResult, len(rows))}
+	var batch []Post
+	var batchRows []int
+	for i, row := range rows {
+		result := &resp.Results[i]
+		result.Row = i + 1
+
+		post := Post{Title: strings.TrimSpace(row.Title), Body: row.Body}
+		err := s.importValidator.ValidatePost(ctx, post)
+		msg, invalid := validationMessage(err)
+		if err != nil && !invalid {
+			return ImportResp{}, err
+		}
+		slug := slugify(post.Title)
+		switch {
+		case invalid:
+			result.Status, result.Error = ImportInvalid, msg
+			resp.Invalid++
+		case slugs[slug]:
+			result.Status, result.Error = ImportDuplicate, "a post titled like this already exists"
+			resp.Duplicates++
+		default:
+			slugs[slug] = true
+			batch = append(batch, post)
+			batchRows = append(batchRows, i)
+		}
+	}
+
+	if len(batch) > 0 {
+		created, err := s.addPosts(ctx, batch)
+		if err != nil {
+			return ImportResp{}, err
+		}
+		for j, post := range created {
+			result := &resp.Results[batchRows[j]]
+			result.Status, result.ID = ImportCreated, post.ID
+		}
+		resp.Created = len(created)
+	}
+	return resp, nil
+}
+
+// ImportWordPress adds the posts of a WordPress export with their original
+// dates, skipping and reporting those the import validator rejects.
+func (s *PostCommands) ImportWordPress(ctx context.Context, r io.Reader, dryRun bool) (WordPressImportReport, error) {
+	return importWordPress(ctx, r, s.db, s.importValidator, dryRun)
+}
+
+// Seed adds the posts of a fixture file with the dates it gives them, all
+// or none: a post the validator rejects fails the whole file.
+func (s *PostCommands) Seed(ctx context.Context, f fixtures.File) (SeedReport, error) {
+	report, err := fixtures.Seed(ctx, validatingWriter{s.db, s.validator}, f, s.clock.Now())
+	return SeedReport(report), err
+}
+
+// validatingWriter checks the posts it adds with validator before passing
+// them on, for writes whose posts are built outside the commands.
+type validatingWriter struct {
+	PostWriter
+	validator Validator
+}
+
+func (w validatingWriter) AddPost(ctx context.Context, post Post) (Post, error) {
+	if err := w.validator.ValidatePost(ctx, post); err != nil {
+		return Post{}, err
+	}
+	return w.PostWriter.AddPost(ctx, post)
+}
+
+func (w validatingWriter) AddPosts(ctx context.Context, posts []Post) ([]Post, error) {
+	for _, post := range posts {
+		if err := w.validator.ValidatePost(ctx, post); err != nil {
+			return nil, err
+		}
+	}
+	return w.PostWriter.AddPosts(ctx, posts)
+}
+
+// UpdatePost clears fields left nil unless FeaturePartialPatch is enabled,
+// in which case they keep their current value.
+func (s *PostCommands) UpdatePost(ctx context.Context, id int, title, body *string) (Post, error) {
+	post, err := s.db.GetPostByID(ctx, id)
+	if err != nil {
+		return Post{}, err
+	}
+	s.mergeUpdate(&post, title, body)
+	if err := s.validator.ValidatePost(ctx, post); err != nil {
+		return Post{}, err
+	}
+	return s.db.UpdatePost(ctx, post)
+}
+
+func (s *PostCommands) mergeUpdate(post *Post, title, body *string) {
+	if s.features.Enabled(FeaturePartialPatch) {
+		if title != nil {
+			post.Title = *title
+		}
+		if body != nil {
+			post.Body = *body
+		}
+	} else {
+		post.Body = valueOrZero(body)
+		post.Title = valueOrZero(title)
+	}
+	post.UpdatedAt = s.clock.Now().UTC()
+}
+
+func (s *PostCommands) DeletePost(ctx context.Context, id int) error {
+	if _, err := s.db.GetPostByID(ctx, id); err != nil {
+		return err
+	}
+	return s.db.DeletePostByID(ctx, id)
+}
+
+// InUnitOfWork commits the writes fn stages all together, returning what
+// each wrote in order. If fn fails, or so does any write or its
+// validation, nothing is written.
+func (s *PostCommands) InUnitOfWork(ctx context.Context, fn func(uow *UnitOfWork) error) ([]Post, error) {
+	uow := &UnitOfWork{svc: s}
+	if err := fn(uow); err != nil {
+		return nil, err
+	}
+	if len(uow.writes) == 0 {
+		return nil, nil
+	}
+	for _, w := range uow.writes {
+		if w.Op == storage.PostWriteDelete {
+			continue
+		}
+		if err := s.validator.ValidatePost(ctx, w.Post); err != nil {
+			return nil, err
+		}
+	}
+	return storage.ApplyPostWrites(ctx, s.db, uow.writes)
+}
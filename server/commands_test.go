@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/fixtures"
+	"gosolid/internal/storage"
+)
+
+// noDrafts rejects posts titled as drafts.
+func noDrafts(post Post) string {
+	if strings.HasPrefix(post.Title, "DRAFT") {
+		return "title must not start with DRAFT"
+	}
+	return ""
+}
+
+const testWXR = `<rss><channel>
+<item><title>Kept</title><wp:post_id>1</wp:post_id><wp:post_type>post</wp:post_type><wp:status>publish</wp:status></item>
+<item><title>DRAFT notes</title><wp:post_id>2</wp:post_id><wp:post_type>post</wp:post_type><wp:status>publish</wp:status></item>
+</channel></rss>`
+
+func TestImportWordPressValidates(t *testing.T) {
+	ctx := context.Background()
+	db := storage.NewDB()
+	commands := NewPostCommands(db, NewFeatureFlags(nil))
+	commands.SetValidators(Rules{}, Rules{noDrafts})
+
+	report, err := commands.ImportWordPress(ctx, strings.NewReader(testWXR), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || len(report.Skipped) != 1 || report.Skipped[0].WordPressID != 2 {
+		t.Errorf("report = %+v, want post 1 imported and post 2 skipped", report)
+	}
+	if _, total, err := db.ListPosts(ctx, ListOptions{Limit: 10}); err != nil || total != 1 {
+		t.Errorf("store holds %d posts (err %v), want 1", total, err)
+	}
+}
+
+func TestSeedValidates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db := storage.NewDB()
+	commands := NewPostCommands(db, NewFeatureFlags(nil))
+	commands.SetValidators(Rules{noDrafts}, Rules{})
+
+	f := fixtures.File{Posts: []fixtures.Post{{Title: "Kept"}, {Title: "DRAFT notes"}}}
+	if _, err := commands.Seed(ctx, f); err == nil {
+		t.Error("seeding a post the validator rejects succeeded")
+	}
+
+	e := gin.New()
+	e.POST("/admin/seed", SeedHandler(commands))
+	if w := serve(e, http.MethodPost, "/admin/seed", "", f); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /admin/seed: status %d, want 400: %s", w.Code, w.Body)
+	}
+	if _, total, err := db.ListPosts(ctx, ListOptions{Limit: 10}); err != nil || total != 0 {
+		t.Errorf("store holds %d posts (err %v), want none", total, err)
+	}
+}
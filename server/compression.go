@@ -0,0 +1,235 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// compressionEncodings are the supported codings, preferred in this order
+// when a client accepts several with the same q-value.
+var compressionEncodings = []string{"br", "gzip"}
+
+var (
+	gzipWriters   = sync.Pool{New: func() any { w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression); return w }}
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, 4) }}
+)
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// negotiateEncoding picks the coding to use from an Accept-Encoding header,
+// or "" to send the response as it is.
+func negotiateEncoding(header string) string {
+	q := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		weight := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				weight = v
+			}
+		}
+		if coding == "*" {
+			wildcard = weight
+		} else if coding != "" {
+			q[coding] = weight
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range compressionEncodings {
+		weight, ok := q[coding]
+		if !ok {
+			weight = wildcard
+		}
+		if weight > bestQ {
+			best, bestQ = coding, weight
+		}
+	}
+	return best
+}
+
+// CompressionMiddleware compresses responses with gzip or Brotli, whichever
+// the client prefers, when their content type is one of cfg.ContentTypes
+// and their body reaches cfg.MinSize bytes. Responses that already have a
+// Content-Encoding, such as backups, and partial content are sent as they
+// are. The start of a body is held back until there is enough of it to
+// decide; WebSocket upgrades aren't touched at all.
+func CompressionMiddleware(cfg CompressionConfig) gin.HandlerFunc {
+	types := map[string]bool{}
+	for _, t := range cfg.ContentTypes {
+		types[strings.ToLower(t)] = true
+	}
+	return func(c *gin.Context) {
+		if c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		encoding, minSize := negotiateEncoding(c.GetHeader("Accept-Encoding")), cfg.MinSize
+		if encoding == "" {
+			minSize = 0
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize, types: types}
+		c.Writer = w
+		defer func() {
+			w.Close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// compressWriter buffers the start of a body until it knows whether to
+// compress it, then passes everything on, compressed or not. An empty
+// encoding never compresses but still marks compressible responses as
+// varying on Accept-Encoding.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	types    map[string]bool
+
+	status  int
+	buf     []byte
+	wrote   bool
+	decided bool
+	enc     compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Status() int {
+	if !w.decided && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.wrote || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection underneath.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush sends what is buffered, so streamed responses keep streaming.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide writes the header, compressed or not, and the buffered body.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	h := w.ResponseWriter.Header()
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if w.types[mediaType] && !slices.Contains(h.Values("Vary"), "Accept-Encoding") {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if w.encoding != "" && len(w.buf) >= w.minSize && status == http.StatusOK && h.Get("Content-Encoding") == "" && w.types[mediaType] {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		// The compressed body is a different representation of the same
+		// content, so only a weak validator still holds.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		if w.encoding == "br" {
+			w.enc = brotliWriters.Get().(*brotli.Writer)
+		} else {
+			w.enc = gzipWriters.Get().(*gzip.Writer)
+		}
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Close writes out whatever is still buffered and finishes the compressed
+// stream.
+func (w *compressWriter) Close() {
+	if !w.decided {
+		if !w.wrote && w.status == 0 {
+			return
+		}
+		w.decide()
+	}
+	if w.enc == nil {
+		return
+	}
+	w.enc.Close()
+	switch enc := w.enc.(type) {
+	case *brotli.Writer:
+		brotliWriters.Put(enc)
+	case *gzip.Writer:
+		gzipWriters.Put(enc)
+	}
+	w.enc = nil
+}
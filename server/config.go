@@ -0,0 +1,1112 @@
+package server
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"gosolid/internal/httpapi"
+	"gosolid/internal/storage"
+)
+
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+type Config struct {
+	Addr            string   `yaml:"addr" toml:"addr"`
+	GRPCAddr        string   `yaml:"grpc_addr" toml:"grpc_addr"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// JSONCodec is std or a codec built in with its tag, like go-json with
+	// go_json; empty picks the built-in one.
+	JSONCodec string `yaml:"json_codec" toml:"json_codec"`
+	// Seed is a fixture file, or a directory of them, loaded on start into a
+	// store that has no posts yet.
+	Seed string `yaml:"seed" toml:"seed"`
+
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	Storage     StorageConfig     `yaml:"storage" toml:"storage"`
+	Secrets     SecretsConfig     `yaml:"secrets" toml:"secrets"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	Mail        MailConfig        `yaml:"mail" toml:"mail"`
+	TLS         TLSConfig         `yaml:"tls" toml:"tls"`
+	Notifiers   NotifiersConfig   `yaml:"notifiers" toml:"notifiers"`
+	Limits      LimitsConfig      `yaml:"limits" toml:"limits"`
+	Features    FeaturesConfig    `yaml:"features" toml:"features"`
+	Errors      ErrorsConfig      `yaml:"errors" toml:"errors"`
+	AccessLog   AccessLogConfig   `yaml:"access_log" toml:"access_log"`
+	Heartbeat   HeartbeatConfig   `yaml:"heartbeat" toml:"heartbeat"`
+	Alerts      AlertsConfig      `yaml:"alerts" toml:"alerts"`
+	Ingest      IngestConfig      `yaml:"ingest" toml:"ingest"`
+	Blobs       BlobsConfig       `yaml:"blobs" toml:"blobs"`
+	Blog        BlogConfig        `yaml:"blog" toml:"blog"`
+	ActivityPub ActivityPubConfig `yaml:"activitypub" toml:"activitypub"`
+	Telegram    TelegramConfig    `yaml:"telegram" toml:"telegram"`
+	GitSync     GitSyncConfig     `yaml:"git_sync" toml:"git_sync"`
+	Search      SearchConfig      `yaml:"search" toml:"search"`
+	Triggers    TriggersConfig    `yaml:"triggers" toml:"triggers"`
+	HTTPCache   HTTPCacheConfig   `yaml:"http_cache" toml:"http_cache"`
+	Compression CompressionConfig `yaml:"compression" toml:"compression"`
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Middleware  MiddlewareConfig  `yaml:"middleware" toml:"middleware"`
+	Validation  ValidationConfig  `yaml:"validation" toml:"validation"`
+}
+
+type LogConfig struct {
+	Format string `yaml:"format" toml:"format"`
+	Level  string `yaml:"level" toml:"level"`
+
+	// Zero disables the corresponding slow log.
+	SlowRequestThreshold Duration `yaml:"slow_request_threshold" toml:"slow_request_threshold"`
+	SlowQueryThreshold   Duration `yaml:"slow_query_threshold" toml:"slow_query_threshold"`
+}
+
+// StorageConfig picks the backend and the layers around it, innermost
+// first, from instrument, slow_query, coalesce and cache. Layers whose own
+// settings turn them off are skipped. IDs is how new posts are numbered:
+// sequential, snowflake, uuidv7 or ulid; SnowflakeNode tells the servers
+// sharing snowflake IDs apart. EventLog is the file the events backend
+// appends to. Settings are for backends registered with storage.Register.
+type StorageConfig struct {
+	Backend       string      `yaml:"backend" toml:"backend"`
+	EventLog      string      `yaml:"event_log" toml:"event_log"`
+	Layers        []string    `yaml:"layers" toml:"layers"`
+	IDs           string      `yaml:"ids" toml:"ids"`
+	SnowflakeNode int         `yaml:"snowflake_node" toml:"snowflake_node"`
+	Cache         CacheConfig `yaml:"cache" toml:"cache"`
+
+	Settings map[string]string `yaml:"settings" toml:"settings"`
+}
+
+// CacheConfig sizes the LRU of posts in front of the backend; Size 0
+// disables it and TTL 0 keeps entries until they are evicted or written.
+type CacheConfig struct {
+	Size int      `yaml:"size" toml:"size"`
+	TTL  Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// SecretsConfig only says where secrets live. The Vault token itself is
+// always taken from VAULT_TOKEN so it never ends up in a config file.
+// VaultCacheTTL is how long the secret read from Vault answers lookups
+// before it is read again; zero reads it for every lookup.
+type SecretsConfig struct {
+	Provider      string   `yaml:"provider" toml:"provider"`
+	File          string   `yaml:"file" toml:"file"`
+	VaultAddr     string   `yaml:"vault_addr" toml:"vault_addr"`
+	VaultMount    string   `yaml:"vault_mount" toml:"vault_mount"`
+	VaultPath     string   `yaml:"vault_path" toml:"vault_path"`
+	VaultCacheTTL Duration `yaml:"vault_cache_ttl" toml:"vault_cache_ttl"`
+}
+
+// AuthConfig.ResetURL is the page reset emails link to, with the reset
+// token in its token parameter; the page posts it to /auth/reset/confirm.
+// The reset routes are only served when it and mail are set. VerifyURL is
+// this server's GET /auth/verify as clients reach it; setting it requires
+// principals issued tokens to confirm their email address before they
+// publish.
+type AuthConfig struct {
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+	IPAllow        []string `yaml:"ip_allow" toml:"ip_allow"`
+	IPDeny         []string `yaml:"ip_deny" toml:"ip_deny"`
+	AdminIPAllow   []string `yaml:"admin_ip_allow" toml:"admin_ip_allow"`
+	AdminIPDeny    []string `yaml:"admin_ip_deny" toml:"admin_ip_deny"`
+	ResetURL       string   `yaml:"reset_url" toml:"reset_url"`
+	VerifyURL      string   `yaml:"verify_url" toml:"verify_url"`
+}
+
+// MailConfig is the SMTP server account emails are sent through, from From.
+// The password is the SMTP_PASSWORD secret, needed when Username is set.
+type MailConfig struct {
+	SMTPAddr string `yaml:"smtp_addr" toml:"smtp_addr"`
+	Username string `yaml:"username" toml:"username"`
+	From     string `yaml:"from" toml:"from"`
+}
+
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile      string `yaml:"key_file" toml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file"`
+	Identities   string `yaml:"identities" toml:"identities"`
+}
+
+type NotifiersConfig struct {
+	Webhooks []string `yaml:"webhooks" toml:"webhooks"`
+	Timeout  Duration `yaml:"timeout" toml:"timeout"`
+
+	Retries      int      `yaml:"retries" toml:"retries"`
+	RetryBackoff Duration `yaml:"retry_backoff" toml:"retry_backoff"`
+
+	// A write notifies up to Concurrency notifiers at once, giving each
+	// Deadline for its whole delivery, retries included; 0 for no deadline.
+	Concurrency int      `yaml:"concurrency" toml:"concurrency"`
+	Deadline    Duration `yaml:"deadline" toml:"deadline"`
+
+	// MQTT is connected once at startup; reloads don't change it.
+	MQTT MQTTConfig `yaml:"mqtt" toml:"mqtt"`
+
+	// Plugins are notifiers registered with RegisterNotifier. Like MQTT,
+	// they are built once at startup.
+	Plugins []NotifierPluginConfig `yaml:"plugins" toml:"plugins"`
+}
+
+// NotifierPluginConfig turns on the notifier registered as Name, passing
+// it Settings.
+type NotifierPluginConfig struct {
+	Name     string            `yaml:"name" toml:"name"`
+	Settings map[string]string `yaml:"settings" toml:"settings"`
+}
+
+// MQTTConfig publishes post events when Broker is set, e.g.
+// tcp://localhost:1883. Topic may contain {action} and {id}. The password
+// is the MQTT_PASSWORD secret.
+type MQTTConfig struct {
+	Broker   string `yaml:"broker" toml:"broker"`
+	ClientID string `yaml:"client_id" toml:"client_id"`
+	Username string `yaml:"username" toml:"username"`
+	Topic    string `yaml:"topic" toml:"topic"`
+	QoS      int    `yaml:"qos" toml:"qos"`
+	Retain   bool   `yaml:"retain" toml:"retain"`
+}
+
+type LimitsConfig struct {
+	MaxBodyBytes       int64    `yaml:"max_body_bytes" toml:"max_body_bytes"`
+	HealthCheckTimeout Duration `yaml:"health_check_timeout" toml:"health_check_timeout"`
+
+	// MaxInFlight of zero disables load shedding.
+	MaxInFlight  int      `yaml:"max_in_flight" toml:"max_in_flight"`
+	MaxQueue     int      `yaml:"max_queue" toml:"max_queue"`
+	QueueTimeout Duration `yaml:"queue_timeout" toml:"queue_timeout"`
+	RetryAfter   Duration `yaml:"retry_after" toml:"retry_after"`
+
+	// Per route group deadlines; zero means no deadline. Admin is off by
+	// default because pprof profiles run for as long as the caller asks.
+	RequestTimeout      Duration `yaml:"request_timeout" toml:"request_timeout"`
+	AdminRequestTimeout Duration `yaml:"admin_request_timeout" toml:"admin_request_timeout"`
+
+	// While the heap is over MemoryBudget bytes, lists are cut to
+	// PressurePageSize posts and exports refused; zero disables it.
+	MemoryBudget     int64 `yaml:"memory_budget" toml:"memory_budget"`
+	PressurePageSize int   `yaml:"pressure_page_size" toml:"pressure_page_size"`
+}
+
+type FeaturesConfig struct {
+	Flags           map[string]bool `yaml:"flags" toml:"flags"`
+	RemoteURL       string          `yaml:"remote_url" toml:"remote_url"`
+	RefreshInterval Duration        `yaml:"refresh_interval" toml:"refresh_interval"`
+}
+
+type ErrorsConfig struct {
+	ReportURL string `yaml:"report_url" toml:"report_url"`
+}
+
+// AccessLogConfig is independent of Log: access logs go to their own file and
+// are only written when Path is set.
+type AccessLogConfig struct {
+	Path           string   `yaml:"path" toml:"path"`
+	Format         string   `yaml:"format" toml:"format"`
+	MaxSizeMB      int64    `yaml:"max_size_mb" toml:"max_size_mb"`
+	RotateInterval Duration `yaml:"rotate_interval" toml:"rotate_interval"`
+	MaxBackups     int      `yaml:"max_backups" toml:"max_backups"`
+	Compress       bool     `yaml:"compress" toml:"compress"`
+}
+
+// HeartbeatConfig points at an external uptime monitor; heartbeats are only
+// sent when URL is set.
+type HeartbeatConfig struct {
+	URL      string   `yaml:"url" toml:"url"`
+	Interval Duration `yaml:"interval" toml:"interval"`
+}
+
+// AlertsConfig enables error-rate alerts when WebhookURL is set. Thresholds
+// are failure ratios between 0 and 1; zero disables that monitor.
+type AlertsConfig struct {
+	WebhookURL               string   `yaml:"webhook_url" toml:"webhook_url"`
+	Window                   Duration `yaml:"window" toml:"window"`
+	MinEvents                int      `yaml:"min_events" toml:"min_events"`
+	Cooldown                 Duration `yaml:"cooldown" toml:"cooldown"`
+	ErrorRateThreshold       float64  `yaml:"error_rate_threshold" toml:"error_rate_threshold"`
+	NotifierFailureThreshold float64  `yaml:"notifier_failure_threshold" toml:"notifier_failure_threshold"`
+}
+
+// IngestConfig maps the JSON payloads POST /hooks/ingest receives onto posts.
+// Fields are dot-separated paths into the payload. KeyField is optional:
+// without it every payload creates a post. The endpoint is only served when
+// the INGEST_SECRET secret is set.
+type IngestConfig struct {
+	TitleField string   `yaml:"title_field" toml:"title_field"`
+	BodyField  string   `yaml:"body_field" toml:"body_field"`
+	KeyField   string   `yaml:"key_field" toml:"key_field"`
+	Tolerance  Duration `yaml:"tolerance" toml:"tolerance"`
+}
+
+// BlobsConfig says where attachments are stored. ExpireAfter of zero keeps
+// them until they are deleted. MaxUploadBytes replaces limits.max_body_bytes
+// for uploads. URLTTL is how long download URLs work: S3 presigns them,
+// for at most a week, and local ones are signed with the URL_SIGNING_KEY
+// secret, or a key made at start when it is unset.
+type BlobsConfig struct {
+	Backend        string   `yaml:"backend" toml:"backend"`
+	Dir            string   `yaml:"dir" toml:"dir"`
+	MaxUploadBytes int64    `yaml:"max_upload_bytes" toml:"max_upload_bytes"`
+	ExpireAfter    Duration `yaml:"expire_after" toml:"expire_after"`
+	URLTTL         Duration `yaml:"url_ttl" toml:"url_ttl"`
+	S3             S3Config `yaml:"s3" toml:"s3"`
+}
+
+// S3Config is for any S3-compatible service. The keys are the S3_ACCESS_KEY
+// and S3_SECRET_KEY secrets. RetentionMode GOVERNANCE or COMPLIANCE locks
+// each upload for RetentionDays and needs a bucket with object lock enabled.
+type S3Config struct {
+	Endpoint      string `yaml:"endpoint" toml:"endpoint"`
+	Bucket        string `yaml:"bucket" toml:"bucket"`
+	Region        string `yaml:"region" toml:"region"`
+	UseSSL        bool   `yaml:"use_ssl" toml:"use_ssl"`
+	PathStyle     bool   `yaml:"path_style" toml:"path_style"`
+	PartSize      int64  `yaml:"part_size" toml:"part_size"`
+	RetentionMode string `yaml:"retention_mode" toml:"retention_mode"`
+	RetentionDays int    `yaml:"retention_days" toml:"retention_days"`
+}
+
+// BlogConfig is for the HTML pages under /blog. CacheMaxAge goes into their
+// Cache-Control header. BaseURL is the site's public address for oEmbed;
+// without it the request's host is used.
+type BlogConfig struct {
+	Title       string   `yaml:"title" toml:"title"`
+	BaseURL     string   `yaml:"base_url" toml:"base_url"`
+	PageSize    int      `yaml:"page_size" toml:"page_size"`
+	CacheMaxAge Duration `yaml:"cache_max_age" toml:"cache_max_age"`
+}
+
+// ActivityPubConfig is for federating the blog as the actor
+// <Username>@<blog.base_url host>. Federation is enabled by the
+// ACTIVITYPUB_PRIVATE_KEY secret, an RSA key in PEM. QueueSize bounds the
+// deliveries waiting to be sent. Remote actors and inboxes are named by
+// whoever talks to the inbox, so they are only fetched from and delivered
+// to over HTTPS at public addresses; AllowHTTP and AllowPrivateTargets lift
+// that for local testing.
+type ActivityPubConfig struct {
+	Username            string `yaml:"username" toml:"username"`
+	QueueSize           int    `yaml:"queue_size" toml:"queue_size"`
+	AllowHTTP           bool   `yaml:"allow_http" toml:"allow_http"`
+	AllowPrivateTargets bool   `yaml:"allow_private_targets" toml:"allow_private_targets"`
+}
+
+// TelegramConfig is for the chat bot, which runs when the
+// TELEGRAM_BOT_TOKEN secret is set. APIURL can point at a self-hosted Bot
+// API server.
+type TelegramConfig struct {
+	APIURL      string   `yaml:"api_url" toml:"api_url"`
+	PollTimeout Duration `yaml:"poll_timeout" toml:"poll_timeout"`
+}
+
+// GitSyncConfig is for mirroring posts into a Git repository; an empty URL
+// disables it. Dir is the local clone and Path the directory in the repo
+// holding one Markdown file per post. Username and the GIT_SYNC_PASSWORD
+// secret authenticate over HTTPS; without the secret no credentials are
+// sent. PullInterval 0 stops importing changes pushed by others.
+type GitSyncConfig struct {
+	URL          string   `yaml:"url" toml:"url"`
+	Branch       string   `yaml:"branch" toml:"branch"`
+	Dir          string   `yaml:"dir" toml:"dir"`
+	Path         string   `yaml:"path" toml:"path"`
+	Username     string   `yaml:"username" toml:"username"`
+	AuthorName   string   `yaml:"author_name" toml:"author_name"`
+	AuthorEmail  string   `yaml:"author_email" toml:"author_email"`
+	PullInterval Duration `yaml:"pull_interval" toml:"pull_interval"`
+}
+
+// SearchConfig is for the Elasticsearch or OpenSearch index behind
+// /posts/search; without a URL the built-in substring search is used.
+// Username and the SEARCH_PASSWORD secret are sent as basic auth when the
+// secret is set. QueueSize bounds the post changes waiting to be indexed.
+// InvertedIndex makes the built-in search use an in-memory index, which
+// holds a copy of every post, instead of scanning the store.
+type SearchConfig struct {
+	URL       string   `yaml:"url" toml:"url"`
+	Index     string   `yaml:"index" toml:"index"`
+	Username  string   `yaml:"username" toml:"username"`
+	Timeout   Duration `yaml:"timeout" toml:"timeout"`
+	QueueSize int      `yaml:"queue_size" toml:"queue_size"`
+
+	InvertedIndex bool `yaml:"inverted_index" toml:"inverted_index"`
+}
+
+// HTTPCacheConfig is for caching rendered GET /posts and /posts/:id
+// responses. Size 0 disables it. TTL bounds how long the server keeps an
+// entry; MaxAge is the max-age clients are told, 0 to revalidate every time.
+// PostBodies sizes the separate cache of serialized posts filled on write,
+// 0 to serialize on every read.
+type HTTPCacheConfig struct {
+	Size       int      `yaml:"size" toml:"size"`
+	TTL        Duration `yaml:"ttl" toml:"ttl"`
+	MaxAge     Duration `yaml:"max_age" toml:"max_age"`
+	PostBodies int      `yaml:"post_bodies" toml:"post_bodies"`
+}
+
+// CompressionConfig is for gzip and Brotli response compression. Only
+// bodies of at least MinSize bytes with one of ContentTypes are compressed.
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled" toml:"enabled"`
+	MinSize      int      `yaml:"min_size" toml:"min_size"`
+	ContentTypes []string `yaml:"content_types" toml:"content_types"`
+}
+
+// ServerConfig tunes the HTTP listener; zero timeouts are unlimited.
+// WriteTimeout also bounds streamed responses other than the event streams
+// and exports, which lift it for themselves. H2C serves HTTP/2 without TLS,
+// for proxies that speak it to their backends; with TLS, HTTP/2 is always
+// on.
+type ServerConfig struct {
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxHeaderBytes    int      `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	H2C               bool     `yaml:"h2c" toml:"h2c"`
+}
+
+// MiddlewareConfig lists the middleware of each route group, outermost
+// first: Global runs for every request, API for the post API and Admin for
+// /admin. ip_filter and timeout take the group's settings, the admin ones
+// in Admin. Middleware whose own settings turn it off is skipped.
+type MiddlewareConfig struct {
+	Global []string `yaml:"global" toml:"global"`
+	API    []string `yaml:"api" toml:"api"`
+	Admin  []string `yaml:"admin" toml:"admin"`
+}
+
+// ValidationConfig picks the policy posts written through the API are held
+// to, and the one imported rows are: lenient only asks imported rows for a
+// title, and strict holds both to the limits of PostRules.
+type ValidationConfig struct {
+	Posts   string `yaml:"posts" toml:"posts"`
+	Imports string `yaml:"imports" toml:"imports"`
+}
+
+// TriggersConfig is for the REST hooks automation platforms subscribe to.
+// Deliveries to loopback, private and link-local addresses are refused
+// unless AllowPrivateTargets is set.
+type TriggersConfig struct {
+	MaxHooksPerToken    int  `yaml:"max_hooks_per_token" toml:"max_hooks_per_token"`
+	AllowPrivateTargets bool `yaml:"allow_private_targets" toml:"allow_private_targets"`
+}
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:            ":8080",
+		GRPCAddr:        ":9090",
+		ShutdownTimeout: Duration{15 * time.Second},
+		Log:             LogConfig{Format: "text", Level: "info", SlowRequestThreshold: Duration{time.Second}, SlowQueryThreshold: Duration{100 * time.Millisecond}},
+		Storage:         StorageConfig{Backend: "memory", EventLog: "posts.events.jsonl", Layers: []string{"instrument", "slow_query", "coalesce", "cache"}, IDs: "sequential"},
+		Secrets:         SecretsConfig{Provider: "envfile", VaultMount: "secret", VaultPath: "gosolid", VaultCacheTTL: Duration{5 * time.Minute}},
+		Notifiers: NotifiersConfig{
+			Timeout:      Duration{5 * time.Second},
+			Retries:      2,
+			RetryBackoff: Duration{200 * time.Millisecond},
+			Concurrency:  8,
+			Deadline:     Duration{20 * time.Second},
+			MQTT:         MQTTConfig{Topic: "gosolid/posts/{action}", QoS: 1},
+		},
+		Features:    FeaturesConfig{RefreshInterval: Duration{30 * time.Second}},
+		Heartbeat:   HeartbeatConfig{Interval: Duration{time.Minute}},
+		Ingest:      IngestConfig{TitleField: "title", BodyField: "body", Tolerance: Duration{5 * time.Minute}},
+		ActivityPub: ActivityPubConfig{Username: "blog", QueueSize: 1024},
+		Telegram:    TelegramConfig{APIURL: "https://api.telegram.org", PollTimeout: Duration{30 * time.Second}},
+		Blog:        BlogConfig{Title: "gosolid", PageSize: 10, CacheMaxAge: Duration{time.Minute}},
+		GitSync: GitSyncConfig{
+			Branch:       "main",
+			Dir:          "content",
+			Path:         "posts",
+			Username:     "git",
+			AuthorName:   "gosolid",
+			AuthorEmail:  "gosolid@localhost",
+			PullInterval: Duration{time.Minute},
+		},
+		Triggers:   TriggersConfig{MaxHooksPerToken: 20},
+		Validation: ValidationConfig{Posts: "lenient", Imports: "lenient"},
+		HTTPCache:  HTTPCacheConfig{TTL: Duration{time.Minute}, PostBodies: 10000},
+		Search:     SearchConfig{Index: "posts", Username: "elastic", Timeout: Duration{5 * time.Second}, QueueSize: 1024, InvertedIndex: true},
+		Compression: CompressionConfig{
+			Enabled: true,
+			MinSize: 1024,
+			ContentTypes: []string{
+				"application/json", "application/vnd.api+json", "application/problem+json",
+				"application/activity+json", "application/ld+json", "application/x-ndjson",
+				"application/atom+xml", "application/rss+xml", "application/xml",
+				"text/html", "text/plain", "text/markdown", "text/csv", "text/xml",
+			},
+		},
+		Server: ServerConfig{
+			ReadTimeout:       Duration{time.Minute},
+			ReadHeaderTimeout: Duration{10 * time.Second},
+			IdleTimeout:       Duration{2 * time.Minute},
+			MaxHeaderBytes:    1 << 20,
+		},
+		Middleware: MiddlewareConfig{
+			Global: []string{
+				"access_log", "request_id", "logging", "recovery", "ip_filter", "tracing", "metrics",
+				"max_body", "slow_request", "error_rate", "compression", "problem", "client_cert",
+			},
+			API:   []string{"json_api", "wire_format", "load_shed", "timeout", "auth", "response_cache"},
+			Admin: []string{"ip_filter", "auth", "require_admin", "timeout"},
+		},
+		Blobs: BlobsConfig{
+			Backend:        "local",
+			Dir:            "attachments",
+			MaxUploadBytes: 32 << 20,
+			URLTTL:         Duration{15 * time.Minute},
+			S3:             S3Config{UseSSL: true, PartSize: 16 << 20},
+		},
+		Alerts: AlertsConfig{
+			Window:                   Duration{5 * time.Minute},
+			MinEvents:                20,
+			Cooldown:                 Duration{15 * time.Minute},
+			ErrorRateThreshold:       0.05,
+			NotifierFailureThreshold: 0.2,
+		},
+		AccessLog: AccessLogConfig{
+			Format:         "combined",
+			MaxSizeMB:      100,
+			RotateInterval: Duration{24 * time.Hour},
+			MaxBackups:     7,
+			Compress:       true,
+		},
+		Limits: LimitsConfig{
+			MaxBodyBytes:       1 << 20,
+			HealthCheckTimeout: Duration{2 * time.Second},
+			MaxQueue:           64,
+			QueueTimeout:       Duration{100 * time.Millisecond},
+			RetryAfter:         Duration{time.Second},
+			RequestTimeout:     Duration{10 * time.Second},
+			PressurePageSize:   100,
+		},
+	}
+}
+
+// LoadConfig layers defaults, then the config file, then environment
+// variables, then command-line flags, and validates the result.
+func LoadConfig(args []string) (Config, error) {
+	cfg := DefaultConfig()
+
+	fs := flag.NewFlagSet("gosolid", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	addr := fs.String("addr", "", "listen address")
+	logFormat := fs.String("log-format", "", "log format: text or json")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	storage := fs.String("storage", "", "storage backend")
+	seed := fs.String("seed", "", "fixture file or directory to seed an empty store with")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *configFile != "" {
+		if err := loadConfigFile(*configFile, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "log-format":
+			cfg.Log.Format = *logFormat
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "storage":
+			cfg.Storage.Backend = *storage
+		case "seed":
+			cfg.Seed = *seed
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config: unsupported file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	str := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+	list := func(key string, dst *[]string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = splitList(v)
+		}
+	}
+	var errs []error
+	duration := func(key string, dst *Duration) {
+		if v, ok := os.LookupEnv(key); ok {
+			if err := dst.UnmarshalText([]byte(v)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	}
+	intVar := func(key string, dst *int) {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+			*dst = n
+		}
+	}
+	int64Var := func(key string, dst *int64) {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+			*dst = n
+		}
+	}
+	boolVar := func(key string, dst *bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+			*dst = b
+		}
+	}
+	floatVar := func(key string, dst *float64) {
+		if v, ok := os.LookupEnv(key); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+			*dst = f
+		}
+	}
+
+	str("ADDR", &cfg.Addr)
+	str("GRPC_ADDR", &cfg.GRPCAddr)
+	duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	str("JSON_CODEC", &cfg.JSONCodec)
+	str("SEED", &cfg.Seed)
+	str("LOG_FORMAT", &cfg.Log.Format)
+	str("LOG_LEVEL", &cfg.Log.Level)
+	duration("SLOW_REQUEST_THRESHOLD", &cfg.Log.SlowRequestThreshold)
+	duration("SLOW_QUERY_THRESHOLD", &cfg.Log.SlowQueryThreshold)
+	str("STORAGE_BACKEND", &cfg.Storage.Backend)
+	str("STORAGE_EVENT_LOG", &cfg.Storage.EventLog)
+	list("STORAGE_LAYERS", &cfg.Storage.Layers)
+	list("MIDDLEWARE_GLOBAL", &cfg.Middleware.Global)
+	list("MIDDLEWARE_API", &cfg.Middleware.API)
+	list("MIDDLEWARE_ADMIN", &cfg.Middleware.Admin)
+	str("VALIDATION_POSTS", &cfg.Validation.Posts)
+	str("VALIDATION_IMPORTS", &cfg.Validation.Imports)
+	str("STORAGE_IDS", &cfg.Storage.IDs)
+	intVar("STORAGE_SNOWFLAKE_NODE", &cfg.Storage.SnowflakeNode)
+	intVar("STORAGE_CACHE_SIZE", &cfg.Storage.Cache.Size)
+	duration("STORAGE_CACHE_TTL", &cfg.Storage.Cache.TTL)
+	str("SECRETS_PROVIDER", &cfg.Secrets.Provider)
+	str("SECRETS_FILE", &cfg.Secrets.File)
+	str("VAULT_ADDR", &cfg.Secrets.VaultAddr)
+	str("VAULT_MOUNT", &cfg.Secrets.VaultMount)
+	str("VAULT_SECRET_PATH", &cfg.Secrets.VaultPath)
+	duration("VAULT_CACHE_TTL", &cfg.Secrets.VaultCacheTTL)
+	list("TRUSTED_PROXIES", &cfg.Auth.TrustedProxies)
+	list("IP_ALLOW", &cfg.Auth.IPAllow)
+	list("IP_DENY", &cfg.Auth.IPDeny)
+	list("ADMIN_IP_ALLOW", &cfg.Auth.AdminIPAllow)
+	list("ADMIN_IP_DENY", &cfg.Auth.AdminIPDeny)
+	str("AUTH_RESET_URL", &cfg.Auth.ResetURL)
+	str("AUTH_VERIFY_URL", &cfg.Auth.VerifyURL)
+	str("SMTP_ADDR", &cfg.Mail.SMTPAddr)
+	str("SMTP_USERNAME", &cfg.Mail.Username)
+	str("MAIL_FROM", &cfg.Mail.From)
+	str("TLS_CERT_FILE", &cfg.TLS.CertFile)
+	str("TLS_KEY_FILE", &cfg.TLS.KeyFile)
+	str("MTLS_CA_FILE", &cfg.TLS.ClientCAFile)
+	str("MTLS_IDENTITIES", &cfg.TLS.Identities)
+	list("NOTIFIER_WEBHOOKS", &cfg.Notifiers.Webhooks)
+	duration("NOTIFIER_TIMEOUT", &cfg.Notifiers.Timeout)
+	intVar("NOTIFIER_RETRIES", &cfg.Notifiers.Retries)
+	duration("NOTIFIER_RETRY_BACKOFF", &cfg.Notifiers.RetryBackoff)
+	intVar("NOTIFIER_CONCURRENCY", &cfg.Notifiers.Concurrency)
+	duration("NOTIFIER_DEADLINE", &cfg.Notifiers.Deadline)
+	str("MQTT_BROKER", &cfg.Notifiers.MQTT.Broker)
+	str("MQTT_CLIENT_ID", &cfg.Notifiers.MQTT.ClientID)
+	str("MQTT_USERNAME", &cfg.Notifiers.MQTT.Username)
+	str("MQTT_TOPIC", &cfg.Notifiers.MQTT.Topic)
+	intVar("MQTT_QOS", &cfg.Notifiers.MQTT.QoS)
+	boolVar("MQTT_RETAIN", &cfg.Notifiers.MQTT.Retain)
+	int64Var("MAX_BODY_BYTES", &cfg.Limits.MaxBodyBytes)
+	duration("HEALTH_CHECK_TIMEOUT", &cfg.Limits.HealthCheckTimeout)
+	intVar("MAX_IN_FLIGHT", &cfg.Limits.MaxInFlight)
+	intVar("MAX_QUEUE", &cfg.Limits.MaxQueue)
+	duration("QUEUE_TIMEOUT", &cfg.Limits.QueueTimeout)
+	duration("REQUEST_TIMEOUT", &cfg.Limits.RequestTimeout)
+	duration("ADMIN_REQUEST_TIMEOUT", &cfg.Limits.AdminRequestTimeout)
+	int64Var("MEMORY_BUDGET", &cfg.Limits.MemoryBudget)
+	intVar("PRESSURE_PAGE_SIZE", &cfg.Limits.PressurePageSize)
+	if v, ok := os.LookupEnv("FEATURE_FLAGS"); ok {
+		flags, err := parseFeatureFlags(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("FEATURE_FLAGS: %w", err))
+		}
+		cfg.Features.Flags = flags
+	}
+	str("FEATURE_FLAGS_URL", &cfg.Features.RemoteURL)
+	duration("FEATURE_FLAGS_REFRESH_INTERVAL", &cfg.Features.RefreshInterval)
+	str("ERROR_REPORT_URL", &cfg.Errors.ReportURL)
+	str("ACCESS_LOG_PATH", &cfg.AccessLog.Path)
+	str("ACCESS_LOG_FORMAT", &cfg.AccessLog.Format)
+	str("HEARTBEAT_URL", &cfg.Heartbeat.URL)
+	duration("HEARTBEAT_INTERVAL", &cfg.Heartbeat.Interval)
+	str("ALERT_WEBHOOK_URL", &cfg.Alerts.WebhookURL)
+	duration("ALERT_WINDOW", &cfg.Alerts.Window)
+	intVar("ALERT_MIN_EVENTS", &cfg.Alerts.MinEvents)
+	duration("ALERT_COOLDOWN", &cfg.Alerts.Cooldown)
+	floatVar("ALERT_ERROR_RATE_THRESHOLD", &cfg.Alerts.ErrorRateThreshold)
+	floatVar("ALERT_NOTIFIER_FAILURE_THRESHOLD", &cfg.Alerts.NotifierFailureThreshold)
+	str("INGEST_TITLE_FIELD", &cfg.Ingest.TitleField)
+	str("INGEST_BODY_FIELD", &cfg.Ingest.BodyField)
+	str("INGEST_KEY_FIELD", &cfg.Ingest.KeyField)
+	duration("INGEST_TOLERANCE", &cfg.Ingest.Tolerance)
+	str("BLOB_BACKEND", &cfg.Blobs.Backend)
+	str("BLOB_DIR", &cfg.Blobs.Dir)
+	int64Var("BLOB_MAX_UPLOAD_BYTES", &cfg.Blobs.MaxUploadBytes)
+	duration("BLOB_EXPIRE_AFTER", &cfg.Blobs.ExpireAfter)
+	duration("BLOB_URL_TTL", &cfg.Blobs.URLTTL)
+	str("S3_ENDPOINT", &cfg.Blobs.S3.Endpoint)
+	str("S3_BUCKET", &cfg.Blobs.S3.Bucket)
+	str("S3_REGION", &cfg.Blobs.S3.Region)
+	boolVar("S3_USE_SSL", &cfg.Blobs.S3.UseSSL)
+	boolVar("S3_PATH_STYLE", &cfg.Blobs.S3.PathStyle)
+	int64Var("S3_PART_SIZE", &cfg.Blobs.S3.PartSize)
+	str("S3_RETENTION_MODE", &cfg.Blobs.S3.RetentionMode)
+	intVar("S3_RETENTION_DAYS", &cfg.Blobs.S3.RetentionDays)
+	str("BLOG_TITLE", &cfg.Blog.Title)
+	str("BLOG_BASE_URL", &cfg.Blog.BaseURL)
+	intVar("BLOG_PAGE_SIZE", &cfg.Blog.PageSize)
+	duration("BLOG_CACHE_MAX_AGE", &cfg.Blog.CacheMaxAge)
+	str("ACTIVITYPUB_USERNAME", &cfg.ActivityPub.Username)
+	intVar("ACTIVITYPUB_QUEUE_SIZE", &cfg.ActivityPub.QueueSize)
+	boolVar("ACTIVITYPUB_ALLOW_HTTP", &cfg.ActivityPub.AllowHTTP)
+	boolVar("ACTIVITYPUB_ALLOW_PRIVATE_TARGETS", &cfg.ActivityPub.AllowPrivateTargets)
+	str("TELEGRAM_API_URL", &cfg.Telegram.APIURL)
+	duration("TELEGRAM_POLL_TIMEOUT", &cfg.Telegram.PollTimeout)
+	str("GIT_SYNC_URL", &cfg.GitSync.URL)
+	str("GIT_SYNC_BRANCH", &cfg.GitSync.Branch)
+	str("GIT_SYNC_DIR", &cfg.GitSync.Dir)
+	str("GIT_SYNC_PATH", &cfg.GitSync.Path)
+	str("GIT_SYNC_USERNAME", &cfg.GitSync.Username)
+	str("GIT_SYNC_AUTHOR_NAME", &cfg.GitSync.AuthorName)
+	str("GIT_SYNC_AUTHOR_EMAIL", &cfg.GitSync.AuthorEmail)
+	duration("GIT_SYNC_PULL_INTERVAL", &cfg.GitSync.PullInterval)
+	str("SEARCH_URL", &cfg.Search.URL)
+	str("SEARCH_INDEX", &cfg.Search.Index)
+	str("SEARCH_USERNAME", &cfg.Search.Username)
+	duration("SEARCH_TIMEOUT", &cfg.Search.Timeout)
+	intVar("SEARCH_QUEUE_SIZE", &cfg.Search.QueueSize)
+	boolVar("SEARCH_INVERTED_INDEX", &cfg.Search.InvertedIndex)
+	intVar("TRIGGERS_MAX_HOOKS_PER_TOKEN", &cfg.Triggers.MaxHooksPerToken)
+	boolVar("TRIGGERS_ALLOW_PRIVATE_TARGETS", &cfg.Triggers.AllowPrivateTargets)
+	intVar("HTTP_CACHE_SIZE", &cfg.HTTPCache.Size)
+	duration("HTTP_CACHE_TTL", &cfg.HTTPCache.TTL)
+	duration("HTTP_CACHE_MAX_AGE", &cfg.HTTPCache.MaxAge)
+	intVar("HTTP_CACHE_POST_BODIES", &cfg.HTTPCache.PostBodies)
+	boolVar("COMPRESSION_ENABLED", &cfg.Compression.Enabled)
+	intVar("COMPRESSION_MIN_SIZE", &cfg.Compression.MinSize)
+	list("COMPRESSION_CONTENT_TYPES", &cfg.Compression.ContentTypes)
+	duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	duration("SERVER_READ_HEADER_TIMEOUT", &cfg.Server.ReadHeaderTimeout)
+	duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	intVar("SERVER_MAX_HEADER_BYTES", &cfg.Server.MaxHeaderBytes)
+	boolVar("SERVER_H2C", &cfg.Server.H2C)
+
+	return errors.Join(errs...)
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr is required"))
+	}
+	if c.GRPCAddr != "" && c.GRPCAddr == c.Addr {
+		errs = append(errs, errors.New("grpc_addr must differ from addr"))
+	}
+	if c.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.JSONCodec != "" && !slices.Contains(httpapi.CodecNames(), c.JSONCodec) {
+		errs = append(errs, fmt.Errorf("json_codec %q is not built in; have %s", c.JSONCodec, strings.Join(httpapi.CodecNames(), ", ")))
+	}
+	if _, err := ParseLogLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := NewLogger(io.Discard, c.Log.Format, nil); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Log.SlowRequestThreshold.Duration < 0 || c.Log.SlowQueryThreshold.Duration < 0 {
+		errs = append(errs, errors.New("log.slow_request_threshold and slow_query_threshold must not be negative"))
+	}
+	if !slices.Contains(storage.Backends(), c.Storage.Backend) {
+		errs = append(errs, fmt.Errorf("storage.backend must be one of %v", storage.Backends()))
+	}
+	if c.Storage.Backend == "events" && c.Storage.EventLog == "" {
+		errs = append(errs, errors.New("storage.event_log is required for the events backend"))
+	}
+	for i, name := range c.Storage.Layers {
+		if _, ok := repositoryLayers[name]; !ok {
+			errs = append(errs, fmt.Errorf("storage.layers: unknown layer %q; have %s", name, strings.Join(repositoryLayerNames(), ", ")))
+		} else if slices.Contains(c.Storage.Layers[:i], name) {
+			errs = append(errs, fmt.Errorf("storage.layers: %s is listed twice", name))
+		}
+	}
+	// Auth can be reordered but not configured away: without it every
+	// route of the group would be open.
+	for _, group := range []struct {
+		name     string
+		names    []string
+		required []string
+	}{
+		{"global", c.Middleware.Global, nil},
+		{"api", c.Middleware.API, []string{"auth"}},
+		{"admin", c.Middleware.Admin, []string{"auth", "require_admin"}},
+	} {
+		for i, name := range group.names {
+			if _, ok := httpMiddleware[name]; !ok {
+				errs = append(errs, fmt.Errorf("middleware.%s: unknown middleware %q; have %s", group.name, name, strings.Join(middlewareNames(), ", ")))
+			} else if slices.Contains(group.names[:i], name) {
+				errs = append(errs, fmt.Errorf("middleware.%s: %s is listed twice", group.name, name))
+			}
+		}
+		for _, name := range group.required {
+			if !slices.Contains(group.names, name) {
+				errs = append(errs, fmt.Errorf("middleware.%s must include %s", group.name, name))
+			}
+		}
+	}
+	if auth, admin := slices.Index(c.Middleware.Admin, "auth"), slices.Index(c.Middleware.Admin, "require_admin"); auth >= 0 && admin >= 0 && admin < auth {
+		errs = append(errs, errors.New("middleware.admin: require_admin must come after auth"))
+	}
+	if !slices.Contains(ValidationPolicies, c.Validation.Posts) || !slices.Contains(ValidationPolicies, c.Validation.Imports) {
+		errs = append(errs, fmt.Errorf("validation.posts and validation.imports must be one of %v", ValidationPolicies))
+	}
+	if !slices.Contains(storage.IDStrategies, c.Storage.IDs) {
+		errs = append(errs, fmt.Errorf("storage.ids must be one of %v", storage.IDStrategies))
+	}
+	if c.Storage.SnowflakeNode < 0 || c.Storage.SnowflakeNode >= 1<<10 {
+		errs = append(errs, errors.New("storage.snowflake_node must be between 0 and 1023"))
+	}
+	if c.Storage.Cache.Size < 0 || c.Storage.Cache.TTL.Duration < 0 {
+		errs = append(errs, errors.New("storage.cache.size and storage.cache.ttl must not be negative"))
+	}
+	switch c.Secrets.Provider {
+	case "envfile":
+	case "vault":
+		if c.Secrets.VaultAddr == "" {
+			errs = append(errs, errors.New("secrets.vault_addr is required for the vault provider"))
+		}
+		if c.Secrets.VaultCacheTTL.Duration < 0 {
+			errs = append(errs, errors.New("secrets.vault_cache_ttl must not be negative"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("secrets.provider: unknown provider %q", c.Secrets.Provider))
+	}
+	if _, err := parsePrefixes(slices.Concat(c.Auth.IPAllow, c.Auth.IPDeny, c.Auth.AdminIPAllow, c.Auth.AdminIPDeny)); err != nil {
+		errs = append(errs, err)
+	}
+	if (c.Auth.ResetURL != "" || c.Auth.VerifyURL != "") && (c.Mail.SMTPAddr == "" || c.Mail.From == "") {
+		errs = append(errs, errors.New("mail.smtp_addr and mail.from are required with auth.reset_url or auth.verify_url"))
+	}
+	if c.TLS.ClientCAFile != "" && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file are required with tls.client_ca_file"))
+	}
+	if _, err := ParseCertIdentities(c.TLS.Identities); err != nil {
+		errs = append(errs, err)
+	}
+	for _, hook := range c.Notifiers.Webhooks {
+		if u, err := url.Parse(hook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("notifiers.webhooks: invalid url %q", hook))
+		}
+	}
+	for i, plugin := range c.Notifiers.Plugins {
+		if _, ok := notifierPlugins[plugin.Name]; !ok {
+			errs = append(errs, fmt.Errorf("notifiers.plugins[%d]: unknown notifier %q; have %v", i, plugin.Name, notifierPluginNames()))
+		}
+	}
+	if c.Notifiers.Timeout.Duration <= 0 {
+		errs = append(errs, errors.New("notifiers.timeout must be positive"))
+	}
+	if c.Notifiers.Retries < 0 || c.Notifiers.RetryBackoff.Duration < 0 {
+		errs = append(errs, errors.New("notifiers.retries and retry_backoff must not be negative"))
+	}
+	if c.Notifiers.Concurrency <= 0 {
+		errs = append(errs, errors.New("notifiers.concurrency must be positive"))
+	}
+	if c.Notifiers.Deadline.Duration < 0 {
+		errs = append(errs, errors.New("notifiers.deadline must not be negative"))
+	}
+	if mq := c.Notifiers.MQTT; mq.Broker != "" {
+		if u, err := url.Parse(mq.Broker); err != nil || !slices.Contains([]string{"tcp", "ssl", "tls", "mqtt", "mqtts", "ws", "wss"}, u.Scheme) || u.Host == "" {
+			errs = append(errs, fmt.Errorf("notifiers.mqtt.broker: invalid url %q", mq.Broker))
+		}
+		if mq.Topic == "" || strings.ContainsAny(mq.Topic, "+#") {
+			errs = append(errs, errors.New("notifiers.mqtt.topic is required and must not contain wildcards"))
+		}
+		if mq.QoS < 0 || mq.QoS > 2 {
+			errs = append(errs, errors.New("notifiers.mqtt.qos must be 0, 1 or 2"))
+		}
+	}
+	if c.Limits.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("limits.max_body_bytes must be positive"))
+	}
+	if c.Limits.HealthCheckTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("limits.health_check_timeout must be positive"))
+	}
+	if c.Limits.MaxInFlight < 0 || c.Limits.MaxQueue < 0 || c.Limits.QueueTimeout.Duration < 0 {
+		errs = append(errs, errors.New("limits.max_in_flight, max_queue and queue_timeout must not be negative"))
+	}
+	if c.Limits.RequestTimeout.Duration < 0 || c.Limits.AdminRequestTimeout.Duration < 0 {
+		errs = append(errs, errors.New("limits.request_timeout and admin_request_timeout must not be negative"))
+	}
+	if c.Limits.MemoryBudget < 0 {
+		errs = append(errs, errors.New("limits.memory_budget must not be negative"))
+	}
+	if c.Limits.MemoryBudget > 0 && c.Limits.PressurePageSize <= 0 {
+		errs = append(errs, errors.New("limits.pressure_page_size must be positive when memory_budget is set"))
+	}
+
+	if c.Features.RemoteURL != "" {
+		if u, err := url.Parse(c.Features.RemoteURL); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("features.remote_url: invalid url %q", c.Features.RemoteURL))
+		}
+		if c.Features.RefreshInterval.Duration <= 0 {
+			errs = append(errs, errors.New("features.refresh_interval must be positive"))
+		}
+	}
+
+	if c.AccessLog.Format != "combined" && c.AccessLog.Format != "json" {
+		errs = append(errs, fmt.Errorf("access_log.format must be combined or json, got %q", c.AccessLog.Format))
+	}
+	if c.AccessLog.MaxSizeMB < 0 || c.AccessLog.MaxBackups < 0 || c.AccessLog.RotateInterval.Duration < 0 {
+		errs = append(errs, errors.New("access_log limits must not be negative"))
+	}
+	if c.Errors.ReportURL != "" {
+		if u, err := url.Parse(c.Errors.ReportURL); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("errors.report_url: invalid url %q", c.Errors.ReportURL))
+		}
+	}
+	if c.Heartbeat.URL != "" {
+		if u, err := url.Parse(c.Heartbeat.URL); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("heartbeat.url: invalid url %q", c.Heartbeat.URL))
+		}
+		if c.Heartbeat.Interval.Duration <= 0 {
+			errs = append(errs, errors.New("heartbeat.interval must be positive"))
+		}
+	}
+	if c.Alerts.WebhookURL != "" {
+		if u, err := url.Parse(c.Alerts.WebhookURL); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("alerts.webhook_url: invalid url %q", c.Alerts.WebhookURL))
+		}
+		if c.Alerts.Window.Duration < time.Second || c.Alerts.Cooldown.Duration < 0 || c.Alerts.MinEvents < 0 {
+			errs = append(errs, errors.New("alerts.window must be at least 1s and cooldown and min_events must not be negative"))
+		}
+		if c.Alerts.ErrorRateThreshold < 0 || c.Alerts.ErrorRateThreshold > 1 ||
+			c.Alerts.NotifierFailureThreshold < 0 || c.Alerts.NotifierFailureThreshold > 1 {
+			errs = append(errs, errors.New("alerts.error_rate_threshold and notifier_failure_threshold must be between 0 and 1"))
+		}
+	}
+	if c.Ingest.TitleField == "" {
+		errs = append(errs, errors.New("ingest.title_field is required"))
+	}
+	if c.Ingest.Tolerance.Duration <= 0 {
+		errs = append(errs, errors.New("ingest.tolerance must be positive"))
+	}
+	switch c.Blobs.Backend {
+	case "local":
+		if c.Blobs.Dir == "" {
+			errs = append(errs, errors.New("blobs.dir is required for the local backend"))
+		}
+	case "s3":
+		if c.Blobs.S3.Endpoint == "" || c.Blobs.S3.Bucket == "" {
+			errs = append(errs, errors.New("blobs.s3.endpoint and bucket are required for the s3 backend"))
+		}
+		// S3 refuses parts under 5 MiB, except the last.
+		if c.Blobs.S3.PartSize < 5<<20 {
+			errs = append(errs, errors.New("blobs.s3.part_size must be at least 5 MiB"))
+		}
+		switch c.Blobs.S3.RetentionMode {
+		case "":
+		case "GOVERNANCE", "COMPLIANCE":
+			if c.Blobs.S3.RetentionDays <= 0 {
+				errs = append(errs, errors.New("blobs.s3.retention_days must be positive with a retention_mode"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("blobs.s3.retention_mode must be GOVERNANCE or COMPLIANCE, got %q", c.Blobs.S3.RetentionMode))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("blobs.backend must be local or s3, got %q", c.Blobs.Backend))
+	}
+	if c.Blobs.MaxUploadBytes <= 0 || c.Blobs.ExpireAfter.Duration < 0 {
+		errs = append(errs, errors.New("blobs.max_upload_bytes must be positive and expire_after must not be negative"))
+	}
+	if c.Blobs.URLTTL.Duration <= 0 || (c.Blobs.Backend == "s3" && c.Blobs.URLTTL.Duration > 7*24*time.Hour) {
+		errs = append(errs, errors.New("blobs.url_ttl must be positive, and at most 168h for the s3 backend"))
+	}
+	if c.Blog.PageSize <= 0 || c.Blog.CacheMaxAge.Duration < 0 {
+		errs = append(errs, errors.New("blog.page_size must be positive and cache_max_age must not be negative"))
+	}
+	if c.Blog.BaseURL != "" {
+		if u, err := url.Parse(c.Blog.BaseURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("blog.base_url: invalid url %q", c.Blog.BaseURL))
+		}
+	}
+	if !usernamePattern.MatchString(c.ActivityPub.Username) {
+		errs = append(errs, fmt.Errorf("activitypub.username must be lowercase letters, digits and underscores, got %q", c.ActivityPub.Username))
+	}
+	if c.ActivityPub.QueueSize <= 0 {
+		errs = append(errs, errors.New("activitypub.queue_size must be positive"))
+	}
+	if u, err := url.Parse(c.Telegram.APIURL); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("telegram.api_url: invalid url %q", c.Telegram.APIURL))
+	}
+	if c.Telegram.PollTimeout.Duration < time.Second {
+		errs = append(errs, errors.New("telegram.poll_timeout must be at least 1s"))
+	}
+	if c.GitSync.URL != "" {
+		if c.GitSync.Branch == "" || c.GitSync.Dir == "" {
+			errs = append(errs, errors.New("git_sync.branch and git_sync.dir are required"))
+		}
+		if p := path.Clean(c.GitSync.Path); c.GitSync.Path == "" || p == "." || path.IsAbs(p) || strings.HasPrefix(p, "..") {
+			errs = append(errs, fmt.Errorf("git_sync.path must be a directory inside the repository, got %q", c.GitSync.Path))
+		}
+		if c.GitSync.AuthorName == "" || c.GitSync.AuthorEmail == "" {
+			errs = append(errs, errors.New("git_sync.author_name and git_sync.author_email are required"))
+		}
+		if c.GitSync.PullInterval.Duration < 0 {
+			errs = append(errs, errors.New("git_sync.pull_interval must not be negative"))
+		}
+	}
+	if c.Search.URL != "" {
+		if u, err := url.Parse(c.Search.URL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("search.url: invalid url %q", c.Search.URL))
+		}
+		// Elasticsearch index names are lowercase and can't hold these.
+		if c.Search.Index == "" || c.Search.Index != strings.ToLower(c.Search.Index) || strings.ContainsAny(c.Search.Index, `\/*?"<>| ,#:`) {
+			errs = append(errs, fmt.Errorf("search.index: invalid index name %q", c.Search.Index))
+		}
+		if c.Search.Timeout.Duration <= 0 {
+			errs = append(errs, errors.New("search.timeout must be positive"))
+		}
+		if c.Search.QueueSize <= 0 {
+			errs = append(errs, errors.New("search.queue_size must be positive"))
+		}
+	}
+	if c.HTTPCache.Size < 0 || c.HTTPCache.MaxAge.Duration < 0 || c.HTTPCache.PostBodies < 0 {
+		errs = append(errs, errors.New("http_cache.size, http_cache.max_age and http_cache.post_bodies must not be negative"))
+	}
+	if c.HTTPCache.Size > 0 && c.HTTPCache.TTL.Duration <= 0 {
+		errs = append(errs, errors.New("http_cache.ttl must be positive"))
+	}
+	if c.Triggers.MaxHooksPerToken <= 0 {
+		errs = append(errs, errors.New("triggers.max_hooks_per_token must be positive"))
+	}
+	if c.Compression.Enabled {
+		if c.Compression.MinSize < 0 {
+			errs = append(errs, errors.New("compression.min_size must not be negative"))
+		}
+		for _, t := range c.Compression.ContentTypes {
+			if mediaType, params, err := mime.ParseMediaType(t); err != nil || len(params) > 0 || mediaType != strings.ToLower(t) {
+				errs = append(errs, fmt.Errorf("compression.content_types: %q is not a bare media type", t))
+			}
+		}
+	}
+	if c.Server.ReadTimeout.Duration < 0 || c.Server.ReadHeaderTimeout.Duration < 0 || c.Server.WriteTimeout.Duration < 0 || c.Server.IdleTimeout.Duration < 0 {
+		errs = append(errs, errors.New("server timeouts must not be negative"))
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		errs = append(errs, errors.New("server.max_header_bytes must be positive"))
+	}
+	if c.Server.H2C && c.TLS.ClientCAFile != "" {
+		errs = append(errs, errors.New("server.h2c is for plain HTTP; with tls, HTTP/2 is already on"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("addr", c.Addr),
+		slog.String("grpc_addr", c.GRPCAddr),
+		slog.String("json_codec", c.JSONCodec),
+		slog.String("storage", c.Storage.Backend),
+		slog.String("storage_ids", c.Storage.IDs),
+		slog.String("secrets", c.Secrets.Provider),
+		slog.Bool("mtls", c.TLS.ClientCAFile != ""),
+		slog.Int("webhooks", len(c.Notifiers.Webhooks)),
+		slog.Int("notifier_plugins", len(c.Notifiers.Plugins)),
+		slog.Bool("mqtt", c.Notifiers.MQTT.Broker != ""),
+		slog.String("blobs", c.Blobs.Backend),
+		slog.Bool("git_sync", c.GitSync.URL != ""),
+		slog.Bool("search_index", c.Search.URL != ""),
+		slog.Bool("inverted_index", c.Search.URL == "" && c.Search.InvertedIndex),
+		slog.Bool("compression", c.Compression.Enabled),
+		slog.Bool("h2c", c.Server.H2C),
+	)
+}
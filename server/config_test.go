@@ -0,0 +1,36 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateKeepsAuthMiddleware(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	for _, tc := range []struct {
+		name       string
+		api, admin []string
+		want       string
+	}{
+		{"api without auth", []string{"json_api", "timeout"}, nil, "middleware.api must include auth"},
+		{"admin without auth", nil, []string{"require_admin", "timeout"}, "middleware.admin must include auth"},
+		{"admin without require_admin", nil, []string{"auth", "timeout"}, "middleware.admin must include require_admin"},
+		{"require_admin before auth", nil, []string{"require_admin", "auth"}, "require_admin must come after auth"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			if tc.api != nil {
+				cfg.Middleware.API = tc.api
+			}
+			if tc.admin != nil {
+				cfg.Middleware.Admin = tc.admin
+			}
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"gosolid/apperr"
+	"gosolid/internal/httpapi"
+)
+
+// Resource builds the CRUD handlers of an entity from its use cases: each
+// handler binds the request, calls one of them and sends the result as
+// Resp, with errors going through writeError. T is the domain type and
+// CreateReq and UpdateReq the request bodies. Handlers are only built for
+// the use cases that are set, so a resource can be read-only. The handlers
+// are plain net/http ones, mounted through httpapi.Route, and read the id
+// from the {id} path value.
+//
+// Render and RenderList write a single T and a page of them, for entities
+// that negotiate formats the way posts do; the default is plain JSON.
+type Resource[T, CreateReq, UpdateReq, Resp any] struct {
+	// Name is what errors call the entity, as in "post id is required".
+	Name   string
+	Create func(ctx context.Context, req CreateReq) (T, error)
+	Get    func(ctx context.Context, id int) (T, error)
+	// Stored, when set, answers plain JSON reads of one T instead of Get,
+	// from JSON encoded ahead, so http.ServeContent can answer
+	// If-None-Match without encoding anything.
+	Stored func(ctx context.Context, id int) (StoredJSON, error)
+	// List returns a page and the total; X-Total-Count carries the total.
+	List   func(ctx context.Context, opts ListOptions) ([]T, int, error)
+	Update func(ctx context.Context, id int, req UpdateReq) (T, error)
+	Delete func(ctx context.Context, id int) error
+	ToResp func(T) Resp
+	// ToSummary is what ?view=summary lists of each T; without it that
+	// view is refused.
+	ToSummary func(T) any
+	// ID, when set, lets plain JSON lists in ID order follow the ID cursor
+	// from one chunk to the next rather than the offset.
+	ID         func(T) int
+	Render     func(w http.ResponseWriter, r *http.Request, status int, v T, resp any)
+	RenderList func(w http.ResponseWriter, r *http.Request, page []T, total int, opts ListOptions)
+}
+
+// StoredJSON is a T as Resource.Stored returns it.
+type StoredJSON struct {
+	JSON    []byte
+	ETag    string
+	ModTime time.Time
+}
+
+// Routes are the handlers that are set, under path such as "/comments" and
+// path+"/{id}". Scopes are left to whoever mounts them; see Register.
+func (res Resource[T, CreateReq, UpdateReq, Resp]) Routes(path string) []httpapi.Route {
+	var routes []httpapi.Route
+	add := func(method, path string, set bool, handler func() http.Handler) {
+		if set {
+			routes = append(routes, httpapi.Route{Method: method, Path: path, Handler: handler()})
+		}
+	}
+	add(http.MethodPost, path, res.Create != nil, res.CreateHandler)
+	add(http.MethodGet, path, res.List != nil, res.ListHandler)
+	add(http.MethodGet, path+"/{id}", res.Get != nil, res.GetHandler)
+	add(http.MethodPatch, path+"/{id}", res.Update != nil, res.UpdateHandler)
+	add(http.MethodDelete, path+"/{id}", res.Delete != nil, res.DeleteHandler)
+	return routes
+}
+
+// Register adds Routes(path) to gin, reads needing the read scope and
+// writes the write scope.
+func (res Resource[T, CreateReq, UpdateReq, Resp]) Register(routes gin.IRoutes, path string, read, write Scope) {
+	for _, route := range res.Routes(path) {
+		scope := write
+		if route.Method == http.MethodGet {
+			scope = read
+		}
+		httpapi.MountGin(routes, []httpapi.Route{route}, RequireScope(scope))
+	}
+}
+
+// negotiated reports whether r asked for a format other than plain JSON.
+func negotiated(r *http.Request) bool {
+	return wantsJSONAPI(r) || wireFormat(r) != ""
+}
+
+func (res Resource[T, CreateReq, UpdateReq, Resp]) render(w http.ResponseWriter, r *http.Request, status int, v T) {
+	resp := res.ToResp(v)
+	if res.Render != nil {
+		res.Render(w, r, status, v, resp)
+		return
+	}
+	httpapi.WriteJSON(w, status, resp)
+}
+
+func (res Resource[T, CreateReq, UpdateReq, Resp]) CreateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateReq
+		if err := binding.JSON.Bind(r, &req); err != nil {
+			writeError(w, r, apperr.Invalid(err))
+			return
+		}
+		v, err := res.Create(r.Context(), req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		res.render(w, r, http.StatusOK, v)
+	})
+}
+
+func (res Resource[T, CreateReq, UpdateReq, Resp]) GetHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r, res.Name)
+		if !ok {
+			return
+		}
+
+		if res.Stored != nil && !negotiated(r) {
+			stored, err := res.Stored(r.Context(), id)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("ETag", stored.ETag)
+			http.ServeContent(w, r, "", stored.ModTime, bytes.NewReader(stored.JSON))
+			return
+		}
+
+		v, err := res.Get(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		res.render(w, r, http.StatusOK, v)
+	})
+}
+
+// listStreamChunk is the page size ListHandler reads a long plain JSON list
+// in.
+const listStreamChunk = 500
+
+// ListHandler leaves filtering and paging to List. Plain JSON lists are
+// read from it listStreamChunk items at a time and written element by
+// element, so a full listing isn't held in memory; RenderList renders the
+// one requested page. Chunks after the first follow the ID cursor for ID
+// orders when ID is set and the offset otherwise, where a concurrent write
+// can shift an item across a chunk boundary.
+func (res Resource[T, CreateReq, UpdateReq, Resp]) ListHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts, ok := listOptionsParams(w, r)
+		if !ok {
+			return
+		}
+		summary := opts.Excerpt > 0
+		if summary && res.ToSummary == nil {
+			writeProblem(w, r, apperr.ValidationFailed, "view must be full")
+			return
+		}
+
+		if res.RenderList != nil && negotiated(r) {
+			page, total, err := res.List(r.Context(), opts)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			w.Header().Set("X-Total-Count", strconv.Itoa(total))
+			res.RenderList(w, r, page, total, opts)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		arr := newJSONArrayWriter(w)
+		defer arr.release()
+		var item Resp
+		write := func(v T) error {
+			if summary {
+				return arr.Write(res.ToSummary(v))
+			}
+			item = res.ToResp(v)
+			return arr.Write(&item)
+		}
+		chunk, remaining := opts, opts.Limit
+		var err error
+		for first := true; err == nil; first = false {
+			chunk.Limit = listStreamChunk
+			if opts.Limit > 0 {
+				chunk.Limit = min(remaining, listStreamChunk)
+			}
+			page, total, listErr := res.List(r.Context(), chunk)
+			if listErr != nil {
+				err = listErr
+				break
+			}
+			if first {
+				w.Header().Set("X-Total-Count", strconv.Itoa(total))
+			}
+			for _, v := range page {
+				if err = write(v); err != nil {
+					break
+				}
+			}
+			remaining -= len(page)
+			if len(page) < chunk.Limit || (opts.Limit > 0 && remaining == 0) {
+				break
+			}
+			if chunk.Sort.ByID() && res.ID != nil {
+				chunk.After, chunk.Offset = res.ID(page[len(page)-1]), 0
+			} else {
+				chunk.Offset += len(page)
+			}
+		}
+		if err == nil {
+			err = arr.Close()
+		}
+		if err != nil && arr.n == 0 {
+			writeError(w, r, err)
+		} else if err != nil {
+			// Past the first element the status is sent, so the array is
+			// left unterminated for the client to notice.
+			httpapi.ReportError(r, err)
+		}
+	})
+}
+
+func (res Resource[T, CreateReq, UpdateReq, Resp]) UpdateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r, res.Name)
+		if !ok {
+			return
+		}
+		var req UpdateReq
+		if err := binding.JSON.Bind(r, &req); err != nil {
+			writeError(w, r, apperr.Invalid(err))
+			return
+		}
+		v, err := res.Update(r.Context(), id, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		res.render(w, r, http.StatusOK, v)
+	})
+}
+
+func (res Resource[T, CreateReq, UpdateReq, Resp]) DeleteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r, res.Name)
+		if !ok {
+			return
+		}
+		if err := res.Delete(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// idParam reads the :id path parameter of the entity called name.
+func idParam(c *gin.Context, name string) (int, bool) {
+	idParam := c.Param("id")
+	if idParam == "" {
+		abortWithProblem(c, apperr.ValidationFailed, name+" id is required")
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		abortWithProblem(c, apperr.ValidationFailed, name+" id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// pathID is idParam for handlers written against net/http, which read the
+// {id} path value.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	raw := r.PathValue("id")
+	if raw == "" {
+		writeProblem(w, r, apperr.ValidationFailed, name+" id is required")
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		writeProblem(w, r, apperr.ValidationFailed, name+" id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
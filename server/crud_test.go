@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tagStore is an entity of the kind Resource is meant for: a few lines of
+// use cases and no handlers of its own.
+type tagStore struct {
+	mu   sync.Mutex
+	tags []string
+}
+
+type tagReq struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type tagResp struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type tag struct {
+	id   int
+	name string
+}
+
+func (s *tagStore) resource() Resource[tag, tagReq, tagReq, tagResp] {
+	return Resource[tag, tagReq, tagReq, tagResp]{
+		Name: "tag",
+		Create: func(ctx context.Context, req tagReq) (tag, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.tags = append(s.tags, req.Name)
+			return tag{len(s.tags), req.Name}, nil
+		},
+		Get: func(ctx context.Context, id int) (tag, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if id < 1 || id > len(s.tags) {
+				return tag{}, ErrNotFound
+			}
+			return tag{id, s.tags[id-1]}, nil
+		},
+		List: func(ctx context.Context, opts ListOptions) ([]tag, int, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			var page []tag
+			for i, name := range s.tags[min(opts.Offset, len(s.tags)):] {
+				if opts.Limit > 0 && len(page) == opts.Limit {
+					break
+				}
+				page = append(page, tag{opts.Offset + i + 1, name})
+			}
+			return page, len(s.tags), nil
+		},
+		ToResp: func(t tag) tagResp { return tagResp{t.id, t.name} },
+	}
+}
+
+func TestResourceRegister(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := NewTokenStore()
+	tokens.Add("reader", Principal{Name: "reader", Scopes: []Scope{ScopePostsRead}})
+	tokens.Add("writer", Principal{Name: "writer", Scopes: []Scope{ScopePostsWrite}})
+	e := gin.New()
+	api := e.Group("", AuthMiddleware(tokens))
+	(&tagStore{}).resource().Register(api, "/tags", ScopePostsRead, ScopePostsWrite)
+
+	for _, step := range []struct {
+		method, target, token string
+		body                  any
+		status                int
+		want                  string
+	}{
+		{http.MethodPost, "/tags", "reader", tagReq{"go"}, http.StatusForbidden, ""},
+		{http.MethodPost, "/tags", "writer", tagReq{"go"}, http.StatusOK, `{"id":1,"name":"go"}`},
+		{http.MethodPost, "/tags", "writer", tagReq{"rust"}, http.StatusOK, `"id":2`},
+		{http.MethodPost, "/tags", "writer", tagReq{}, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{http.MethodGet, "/tags/2", "reader", nil, http.StatusOK, `"name":"rust"`},
+		{http.MethodGet, "/tags/3", "reader", nil, http.StatusNotFound, ""},
+		{http.MethodGet, "/tags?offset=1", "reader", nil, http.StatusOK, `[{"id":2,"name":"rust"}]`},
+		{http.MethodGet, "/tags?view=summary", "reader", nil, http.StatusBadRequest, "view must be full"},
+		{http.MethodGet, "/tags", "writer", nil, http.StatusForbidden, ""},
+		// Without Update and Delete there are no such routes.
+		{http.MethodDelete, "/tags/1", "writer", nil, http.StatusNotFound, ""},
+	} {
+		w := serve(e, step.method, step.target, step.token, step.body)
+		if w.Code != step.status || !strings.Contains(w.Body.String(), step.want) {
+			t.Errorf("%s %s as %s: status %d, body %s; want %d with %s", step.method, step.target, step.token, w.Code, w.Body, step.status, step.want)
+		}
+	}
+}
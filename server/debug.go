@@ -0,0 +1,27 @@
+package server
+
+import (
+	"expvar"
+	"net/http/pprof"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterDebugRoutes mounts pprof and expvar on an already protected group.
+// pprof.Index only resolves profiles under /debug/pprof/, so named profiles are
+// routed to pprof.Handler explicitly.
+func RegisterDebugRoutes(group *gin.RouterGroup) {
+	debug := group.Group("/debug")
+
+	debug.GET("/vars", gin.WrapH(expvar.Handler()))
+
+	debug.GET("/pprof/", gin.WrapF(pprof.Index))
+	debug.GET("/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/pprof/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/pprof/trace", gin.WrapF(pprof.Trace))
+	debug.GET("/pprof/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+}
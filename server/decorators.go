@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RepositoryDecorator wraps a repository in one layer, such as a cache.
+// Layers forward what they don't handle to the one below and have an
+// Unwrap method, so storage.Unwrap can still reach the backend.
+type RepositoryDecorator func(next PostRepository) PostRepository
+
+// DecorateRepository wraps base in decorators, the first one innermost.
+func DecorateRepository(base PostRepository, decorators ...RepositoryDecorator) PostRepository {
+	for _, decorate := range decorators {
+		base = decorate(base)
+	}
+	return base
+}
+
+// repositoryLayers are the layers storage.layers can name, from the config
+// they need. A layer its config turns off, like a cache of size 0, is nil.
+var repositoryLayers = map[string]func(cfg Config) RepositoryDecorator{
+	// instrument records the latency histogram and a span for every call.
+	"instrument": func(cfg Config) RepositoryDecorator {
+		return func(next PostRepository) PostRepository {
+			return NewInstrumentedPostRepository(next, cfg.Storage.Backend)
+		}
+	},
+	"slow_query": func(cfg Config) RepositoryDecorator {
+		if cfg.Log.SlowQueryThreshold.Duration <= 0 {
+			return nil
+		}
+		return func(next PostRepository) PostRepository {
+			return NewSlowQueryPostRepository(next, cfg.Storage.Backend, cfg.Log.SlowQueryThreshold.Duration)
+		}
+	},
+	"coalesce": func(Config) RepositoryDecorator {
+		return func(next PostRepository) PostRepository {
+			return NewCoalescingPostRepository(next)
+		}
+	},
+	"cache": func(cfg Config) RepositoryDecorator {
+		if cfg.Storage.Cache.Size <= 0 {
+			return nil
+		}
+		return func(next PostRepository) PostRepository {
+			return NewCachingPostRepository(next, cfg.Storage.Cache.Size, cfg.Storage.Cache.TTL.Duration)
+		}
+	},
+}
+
+func repositoryLayerNames() []string {
+	names := make([]string, 0, len(repositoryLayers))
+	for name := range repositoryLayers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// RepositoryDecorators returns the layers cfg.Storage.Layers names, in
+// that order, leaving out the ones cfg turns off.
+func RepositoryDecorators(cfg Config) ([]RepositoryDecorator, error) {
+	var decorators []RepositoryDecorator
+	for _, name := range cfg.Storage.Layers {
+		layer, ok := repositoryLayers[name]
+		if !ok {
+			return nil, fmt.Errorf("storage: unknown layer %q", name)
+		}
+		if decorate := layer(cfg); decorate != nil {
+			decorators = append(decorators, decorate)
+		}
+	}
+	return decorators, nil
+}
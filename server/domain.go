@@ -0,0 +1,33 @@
+package server
+
+import "gosolid/internal/domain"
+
+// The post model lives in internal/domain, which knows nothing of HTTP.
+// These aliases keep its short names for the rest of the package.
+type (
+	Post               = domain.Post
+	PostRepository     = domain.PostRepository
+	PostReader         = domain.PostReader
+	PostWriter         = domain.PostWriter
+	PostUpdateNotifier = domain.PostUpdateNotifier
+	Action             = domain.Action
+	ListOptions        = domain.ListOptions
+	ListSort           = domain.ListSort
+)
+
+const (
+	ActionCreate = domain.ActionCreate
+	ActionUpdate = domain.ActionUpdate
+	ActionDelete = domain.ActionDelete
+
+	SortID          = domain.SortID
+	SortIDDesc      = domain.SortIDDesc
+	SortCreated     = domain.SortCreated
+	SortCreatedDesc = domain.SortCreatedDesc
+	SortUpdated     = domain.SortUpdated
+	SortUpdatedDesc = domain.SortUpdatedDesc
+	SortTitle       = domain.SortTitle
+	SortTitleDesc   = domain.SortTitleDesc
+)
+
+var ErrNotFound = domain.ErrNotFound
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosolid/internal/domain"
+	"gosolid/internal/httpapi"
+)
+
+// searchMaxWindow is Elasticsearch's default index.max_result_window:
+// from+size beyond it is rejected.
+const searchMaxWindow = 10000
+
+var searchMapping = map[string]any{
+	"mappings": map[string]any{
+		"properties": map[string]any{
+			"title":      map[string]any{"type": "text"},
+			"body":       map[string]any{"type": "text"},
+			"created_at": map[string]any{"type": "date"},
+			"updated_at": map[string]any{"type": "date"},
+			"indexed_at": map[string]any{"type": "date"},
+		},
+	},
+}
+
+type searchDoc struct {
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	IndexedAt time.Time `json:"indexed_at"`
+}
+
+type searchOp struct {
+	post   Post
+	action Action
+}
+
+// SearchIndex keeps an Elasticsearch or OpenSearch index of posts, fed by
+// post events, and searches it. Both speak the same REST API for what is
+// used here, so it talks to them over plain HTTP.
+//
+// Run creates the index if needed and reindexes every post before working
+// through the queued changes, so the index catches up with changes made
+// while it was unreachable or the queue was full.
+type SearchIndex struct {
+	base     string
+	index    string
+	username string
+	password string
+	client   *http.Client
+	retries  int
+	backoff  time.Duration
+	queue    chan searchOp
+	posts    PostReader
+	logger   *slog.Logger
+}
+
+func NewSearchIndex(cfg SearchConfig, notifiers NotifiersConfig, password string, posts PostReader) *SearchIndex {
+	return &SearchIndex{
+		base:     strings.TrimSuffix(cfg.URL, "/"),
+		index:    cfg.Index,
+		username: cfg.Username,
+		password: password,
+		client:   &http.Client{Timeout: cfg.Timeout.Duration, Transport: &httpapi.RequestIDTransport{}},
+		retries:  notifiers.Retries,
+		backoff:  notifiers.RetryBackoff.Duration,
+		queue:    make(chan searchOp, cfg.QueueSize),
+		posts:    posts,
+		logger:   slog.With("component", "search"),
+	}
+}
+
+func (s *SearchIndex) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	select {
+	case s.queue <- searchOp{post: post, action: action}:
+		return nil
+	default:
+		return fmt.Errorf("search: queue full, post %d not indexed until the next reindex", post.ID)
+	}
+}
+
+// Run keeps the index up to date until ctx is done.
+func (s *SearchIndex) Run(ctx context.Context) {
+	for {
+		err := s.reindex(ctx)
+		if err == nil {
+			break
+		}
+		s.logger.ErrorContext(ctx, "reindex", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(30 * time.Second):
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case op := <-s.queue:
+			err := s.apply(ctx, op)
+			backoff := s.backoff
+			for attempt := 0; err != nil && attempt < s.retries && ctx.Err() == nil; attempt++ {
+				select {
+				case <-ctx.Done():
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				err = s.apply(ctx, op)
+			}
+			if err != nil {
+				s.logger.ErrorContext(ctx, "index post", "post_id", op.post.ID, "action", op.action, "error", err)
+			}
+		}
+	}
+}
+
+func (s *SearchIndex) apply(ctx context.Context, op searchOp) error {
+	path := "/" + url.PathEscape(s.index) + "/_doc/" + strconv.Itoa(op.post.ID)
+	if op.action == ActionDelete {
+		status, _, err := s.do(ctx, http.MethodDelete, path, nil, "")
+		if err != nil && status != http.StatusNotFound {
+			return err
+		}
+		return nil
+	}
+	body, err := json.Marshal(postSearchDoc(op.post))
+	if err != nil {
+		return err
+	}
+	_, _, err = s.do(ctx, http.MethodPut, path, body, "application/json")
+	return err
+}
+
+func postSearchDoc(post Post) searchDoc {
+	return searchDoc{Title: post.Title, Body: post.Body, CreatedAt: post.CreatedAt, UpdatedAt: post.UpdatedAt, IndexedAt: time.Now().UTC()}
+}
+
+// reindex creates the index if it is missing, bulk-writes every post and
+// then deletes the documents it didn't write, which belong to posts deleted
+// while the index wasn't being fed.
+func (s *SearchIndex) reindex(ctx context.Context) error {
+	start := time.Now().UTC()
+	mapping, err := json.Marshal(searchMapping)
+	if err != nil {
+		return err
+	}
+	status, resp, err := s.do(ctx, http.MethodPut, "/"+url.PathEscape(s.index), mapping, "application/json")
+	if err != nil && !(status == http.StatusBadRequest && bytes.Contains(resp, []byte("resource_already_exists_exception"))) {
+		return fmt.Errorf("create index: %w", err)
+	}
+
+	posts, _, err := s.posts.ListPosts(ctx, ListOptions{})
+	if err != nil {
+		return err
+	}
+	for batch := range slices.Chunk(posts, 500) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, post := range batch {
+			if err := enc.Encode(map[string]any{"index": map[string]any{"_index": s.index, "_id": strconv.Itoa(post.ID)}}); err != nil {
+				return err
+			}
+			if err := enc.Encode(postSearchDoc(post)); err != nil {
+				return err
+			}
+		}
+		_, resp, err := s.do(ctx, http.MethodPost, "/_bulk", buf.Bytes(), "application/x-ndjson")
+		if err != nil {
+			return fmt.Errorf("bulk index: %w", err)
+		}
+		var result struct {
+			Errors bool `json:"errors"`
+		}
+		if err := json.Unmarshal(resp, &result); err != nil {
+			return fmt.Errorf("bulk index: %w", err)
+		}
+		if result.Errors {
+			return fmt.Errorf("bulk index: some posts failed: %s", domain.TruncateRunes(string(resp), 500))
+		}
+	}
+	stale, err := json.Marshal(map[string]any{
+		"query": map[string]any{"range": map[string]any{"indexed_at": map[string]any{"lt": start.Format(time.RFC3339Nano)}}},
+	})
+	if err != nil {
+		return err
+	}
+	if _, _, err := s.do(ctx, http.MethodPost, "/"+url.PathEscape(s.index)+"/_delete_by_query?refresh=true", stale, "application/json"); err != nil {
+		return fmt.Errorf("delete stale posts: %w", err)
+	}
+	s.logger.InfoContext(ctx, "reindexed", "index", s.index, "posts", len(posts))
+	return nil
+}
+
+// SearchPosts runs a match query over title and body, best matches first.
+// Matches past the index's result window can't be paged to.
+func (s *SearchIndex) SearchPosts(ctx context.Context, q string, limit, offset int) ([]Post, int, error) {
+	size := searchMaxWindow - offset
+	if limit > 0 {
+		size = min(limit, size)
+	}
+	query := map[string]any{
+		"query": map[string]any{
+			"multi_match": map[string]any{"query": q, "fields": []string{"title^2", "body"}},
+		},
+		"from":             offset,
+		"size":             max(size, 0),
+		"track_total_hits": true,
+	}
+	if size <= 0 {
+		query["from"] = 0
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, resp, err := s.do(ctx, http.MethodPost, "/"+url.PathEscape(s.index)+"/_search", body, "application/json")
+	if err != nil {
+		return nil, 0, fmt.Errorf("search: %w", err)
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID     string    `json:"_id"`
+				Source searchDoc `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, 0, fmt.Errorf("search: %w", err)
+	}
+	posts := make([]Post, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		id, err := strconv.Atoi(hit.ID)
+		if err != nil {
+			continue
+		}
+		posts = append(posts, Post{
+			ID:        id,
+			Title:     hit.Source.Title,
+			Body:      hit.Source.Body,
+			CreatedAt: hit.Source.CreatedAt,
+			UpdatedAt: hit.Source.UpdatedAt,
+		})
+	}
+	return posts, result.Hits.Total.Value, nil
+}
+
+// do sends a request and returns the status and body. Statuses from 300 up
+// are errors, returned with the status and body for the caller to inspect.
+func (s *SearchIndex) do(ctx context.Context, method, path string, body []byte, contentType string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.base+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, data, fmt.Errorf("%s %s: unexpected status %s: %s", method, path, resp.Status, domain.TruncateRunes(string(data), 200))
+	}
+	return resp.StatusCode, data, nil
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/internal/clock"
+	"gosolid/internal/notify"
+)
+
+// EmailVerifier holds principals registered through POST /admin/tokens back
+// from publishing until they confirm the email address they are named
+// after. Principals it never registered, like ADMIN_TOKEN and certificate
+// identities, aren't held back. Like tokens, the state lives in memory.
+type EmailVerifier struct {
+	emailService notify.EmailService
+	tokens       *OneTimeTokens
+	sender       string
+	confirmURL   string
+	clock        clock.Clock
+
+	mu       sync.RWMutex
+	pending  map[string]bool
+	verified map[string]bool
+}
+
+func NewEmailVerifier(emailService notify.EmailService, sender, confirmURL string) *EmailVerifier {
+	return &EmailVerifier{
+		emailService: emailService,
+		tokens:       NewOneTimeTokens(24 * time.Hour),
+		sender:       sender,
+		confirmURL:   confirmURL,
+		clock:        clock.System,
+		pending:      make(map[string]bool),
+		verified:     make(map[string]bool),
+	}
+}
+
+// Register holds email back from publishing and sends it a verification
+// link, unless it has confirmed the address already.
+func (v *EmailVerifier) Register(ctx context.Context, email string) error {
+	v.mu.Lock()
+	if v.verified[email] {
+		v.mu.Unlock()
+		return nil
+	}
+	v.pending[email] = true
+	v.mu.Unlock()
+	return v.SendVerification(ctx, email)
+}
+
+func (v *EmailVerifier) SendVerification(ctx context.Context, email string) error {
+	token, err := v.tokens.Issue(email, v.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	link := v.confirmURL + "?token=" + url.QueryEscape(token)
+	subject := "Confirm your email address"
+	body := "Please confirm your email address before publishing posts:\n" + link
+	return v.emailService.SendEmail(ctx, v.sender, email, subject, body)
+}
+
+func (v *EmailVerifier) Confirm(token string) (string, error) {
+	email, err := v.tokens.Consume(token, v.clock.Now())
+	if err != nil {
+		return "", err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.verified[email] = true
+	delete(v.pending, email)
+	v.tokens.RevokeSubject(email)
+
+	return email, nil
+}
+
+func (v *EmailVerifier) IsVerified(email string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.verified[email]
+}
+
+// Pending reports whether name registered and hasn't confirmed yet.
+func (v *EmailVerifier) Pending(name string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.pending[name]
+}
+
+// MayPublish reports whether principal may create and edit posts. A nil
+// verifier lets everyone publish.
+func (v *EmailVerifier) MayPublish(principal Principal) bool {
+	return v == nil || !v.Pending(principal.Name)
+}
+
+// validEmail reports whether name is a bare email address.
+func validEmail(name string) bool {
+	addr, err := mail.ParseAddress(name)
+	return err == nil && addr.Address == name
+}
+
+var errEmailNotVerified = apperr.New(apperr.Forbidden, "confirm your email address before publishing")
+
+// RequireVerifiedEmail stops principals that haven't confirmed their
+// address from going on to publish. It goes after the auth middleware.
+func RequireVerifiedEmail(verifier *EmailVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal, ok := PrincipalFromContext(c); ok && !verifier.MayPublish(principal) {
+			abortWithError(c, errEmailNotVerified)
+			return
+		}
+		c.Next()
+	}
+}
+
+type ConfirmEmailResp struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+}
+
+// ConfirmEmailHandler is what verification links point at.
+func ConfirmEmailHandler(verifier *EmailVerifier) func(*gin.Context) {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			abortWithProblem(c, apperr.ValidationFailed, "token is required")
+			return
+		}
+
+		email, err := verifier.Confirm(token)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ConfirmEmailResp{Email: email, Verified: true})
+	}
+}
+
+// ResendVerificationHandler mails the caller a new link while its address
+// is unconfirmed; otherwise there is nothing to do.
+func ResendVerificationHandler(verifier *EmailVerifier) func(*gin.Context) {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFromContext(c)
+		if !ok {
+			abortWithProblem(c, apperr.Unauthenticated, "")
+			return
+		}
+		if verifier.Pending(principal.Name) {
+			if err := verifier.SendVerification(c.Request.Context(), principal.Name); err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
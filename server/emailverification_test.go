@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/clock"
+)
+
+// newVerificationRouter serves token issuing, the confirm link and a
+// stand-in publish route behind the verification check.
+func newVerificationRouter(t *testing.T) (*gin.Engine, *TokenStore, *outbox, *clock.Fake) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	tokens := NewTokenStore()
+	tokens.Add("admin", Principal{Name: "admin", Scopes: []Scope{ScopeAdmin}})
+	tokens.Add("operator", Principal{Name: "ops", Scopes: []Scope{ScopePostsWrite}})
+	mail := &outbox{}
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	verifier := NewEmailVerifier(mail, "noreply@example.com", "https://example.com/auth/verify")
+	verifier.clock = fake
+
+	e := gin.New()
+	e.POST("/admin/tokens", AuthMiddleware(tokens), RequireScope(ScopeAdmin), IssueTokenHandler(tokens, verifier))
+	e.GET("/auth/verify", ConfirmEmailHandler(verifier))
+	e.POST("/posts", AuthMiddleware(tokens), RequireScope(ScopePostsWrite), RequireVerifiedEmail(verifier), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return e, tokens, mail, fake
+}
+
+func serve(e *gin.Engine, method, target, token string, body any) *httptest.ResponseRecorder {
+	var b bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&b).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &b)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func issueTestToken(t *testing.T, e *gin.Engine, name string) string {
+	t.Helper()
+	w := serve(e, http.MethodPost, "/admin/tokens", "admin", IssueTokenReq{Name: name, Scopes: []string{string(ScopePostsWrite)}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue token for %s: status %d: %s", name, w.Code, w.Body)
+	}
+	var resp IssueTokenResp
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Token
+}
+
+func TestPublishingWaitsForVerification(t *testing.T) {
+	e, _, mail, _ := newVerificationRouter(t)
+	token := issueTestToken(t, e, "ada@example.com")
+	if len(mail.sent) != 1 || mail.sent[0].recipient != "ada@example.com" {
+		t.Fatalf("sent %+v, want one verification email to ada@example.com", mail.sent)
+	}
+
+	if w := serve(e, http.MethodPost, "/posts", token, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("publish before confirming: status %d, want 403", w.Code)
+	}
+	link := "/auth/verify?token=" + url.QueryEscape(mail.linkToken(t))
+	if w := serve(e, http.MethodGet, link, "", nil); w.Code != http.StatusOK {
+		t.Fatalf("confirm: status %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := serve(e, http.MethodPost, "/posts", token, nil); w.Code != http.StatusCreated {
+		t.Errorf("publish after confirming: status %d, want 201", w.Code)
+	}
+	if w := serve(e, http.MethodGet, link, "", nil); w.Code != http.StatusGone {
+		t.Errorf("reusing the link: status %d, want 410", w.Code)
+	}
+
+	// A confirmed address isn't asked again for its next token.
+	next := issueTestToken(t, e, "ada@example.com")
+	if w := serve(e, http.MethodPost, "/posts", next, nil); w.Code != http.StatusCreated || len(mail.sent) != 1 {
+		t.Errorf("second token: status %d with %d emails, want 201 and no new email", w.Code, len(mail.sent))
+	}
+}
+
+func TestVerificationLinkExpires(t *testing.T) {
+	e, _, mail, fake := newVerificationRouter(t)
+	token := issueTestToken(t, e, "ada@example.com")
+	fake.Advance(24*time.Hour + time.Second)
+	if w := serve(e, http.MethodGet, "/auth/verify?token="+url.QueryEscape(mail.linkToken(t)), "", nil); w.Code != http.StatusGone {
+		t.Errorf("expired link: status %d, want 410", w.Code)
+	}
+	if w := serve(e, http.MethodPost, "/posts", token, nil); w.Code != http.StatusForbidden {
+		t.Errorf("publish after an expired link: status %d, want 403", w.Code)
+	}
+}
+
+func TestVerificationNeedsEmailNames(t *testing.T) {
+	e, _, mail, _ := newVerificationRouter(t)
+	w := serve(e, http.MethodPost, "/admin/tokens", "admin", IssueTokenReq{Name: "ci-bot", Scopes: []string{string(ScopePostsWrite)}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("token for a name that isn't an address: status %d, want 400", w.Code)
+	}
+	if len(mail.sent) != 0 {
+		t.Errorf("sent %d emails, want none", len(mail.sent))
+	}
+	// Principals that were never registered, like ADMIN_TOKEN, publish as before.
+	if w := serve(e, http.MethodPost, "/posts", "operator", nil); w.Code != http.StatusCreated {
+		t.Errorf("unregistered principal: status %d, want 201", w.Code)
+	}
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/storage"
+)
+
+const encryptedPrefix = "enc:v1:"
+
+var ErrUnknownEncryptionKey = errors.New("encryption: unknown key id")
+
+// EncryptionKeyring encrypts with the primary key and decrypts with any key it
+// knows, so old keys can be kept around until RotateKeys has re-encrypted
+// everything.
+type EncryptionKeyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+func NewEncryptionKeyring(primary string, keys map[string][]byte) (*EncryptionKeyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("encryption: primary key %q is not in the keyring", primary)
+	}
+
+	k := &EncryptionKeyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// ParseEncryptionKeyring reads "id:base64key,id:base64key"; the first entry is
+// the primary key.
+func ParseEncryptionKeyring(s string) (*EncryptionKeyring, error) {
+	var primary string
+	keys := make(map[string][]byte)
+	for _, entry := range splitList(s) {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, errors.New("encryption: expected id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: key %q: %w", id, err)
+		}
+		if primary == "" {
+			primary = id
+		}
+		keys[id] = key
+	}
+	return NewEncryptionKeyring(primary, keys)
+}
+
+func (k *EncryptionKeyring) Encrypt(plaintext string) (string, error) {
+	aead := k.keys[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt passes values without the prefix through untouched, which keeps
+// data written before encryption was enabled readable.
+func (k *EncryptionKeyring) Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("encryption: malformed ciphertext")
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", ErrUnknownEncryptionKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encryption: malformed ciphertext")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (k *EncryptionKeyring) usesPrimary(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix+k.primary+":")
+}
+
+type EncryptedPostRepository struct {
+	next PostRepository
+	keys *EncryptionKeyring
+}
+
+func NewEncryptedPostRepository(next PostRepository, keys *EncryptionKeyring) *EncryptedPostRepository {
+	return &EncryptedPostRepository{next: next, keys: keys}
+}
+
+func (r *EncryptedPostRepository) Unwrap() PostRepository { return r.next }
+
+func (r *EncryptedPostRepository) encrypt(post Post) (Post, error) {
+	body, err := r.keys.Encrypt(post.Body)
+	if err != nil {
+		return Post{}, err
+	}
+	post.Body = body
+	return post, nil
+}
+
+func (r *EncryptedPostRepository) decrypt(post Post) (Post, error) {
+	body, err := r.keys.Decrypt(post.Body)
+	if err != nil {
+		return Post{}, fmt.Errorf("post %d: %w", post.ID, err)
+	}
+	post.Body = body
+	return post, nil
+}
+
+func (r *EncryptedPostRepository) AddPost(ctx context.Context, newPost Post) (Post, error) {
+	encrypted, err := r.encrypt(newPost)
+	if err != nil {
+		return Post{}, err
+	}
+	post, err := r.next.AddPost(ctx, encrypted)
+	if err != nil {
+		return Post{}, err
+	}
+	return r.decrypt(post)
+}
+
+func (r *EncryptedPostRepository) AddPosts(ctx context.Context, newPosts []Post) ([]Post, error) {
+	encrypted := make([]Post, len(newPosts))
+	for i, post := range newPosts {
+		var err error
+		if encrypted[i], err = r.encrypt(post); err != nil {
+			return nil, err
+		}
+	}
+	posts, err := r.next.AddPosts(ctx, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		if posts[i], err = r.decrypt(posts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return posts, nil
+}
+
+func (r *EncryptedPostRepository) GetPostByID(ctx context.Context, id int) (Post, error) {
+	post, err := r.next.GetPostByID(ctx, id)
+	if err != nil {
+		return Post{}, err
+	}
+	return r.decrypt(post)
+}
+
+// ListPosts can only pass opts down when they don't search bodies, which
+// the backend holds encrypted; a Query reads every post and filters here.
+func (r *EncryptedPostRepository) ListPosts(ctx context.Context, opts ListOptions) ([]Post, int, error) {
+	// Ciphertext can't be cut, so excerpts are taken after decrypting.
+	nextOpts := opts
+	nextOpts.Excerpt = 0
+	if opts.Query != "" {
+		nextOpts = ListOptions{}
+	}
+	posts, total, err := r.next.ListPosts(ctx, nextOpts)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range posts {
+		if posts[i], err = r.decrypt(posts[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	if opts.Query != "" {
+		posts, total = storage.ListPage(posts, opts)
+	} else {
+		opts.CutBodies(posts)
+	}
+	return posts, total, nil
+}
+
+func (r *EncryptedPostRepository) EachPost(ctx context.Context, fn func(Post) error) error {
+	return r.next.EachPost(ctx, func(post Post) error {
+		post, err := r.decrypt(post)
+		if err != nil {
+			return err
+		}
+		return fn(post)
+	})
+}
+
+func (r *EncryptedPostRepository) UpdatePost(ctx context.Context, updatePost Post) (Post, error) {
+	encrypted, err := r.encrypt(updatePost)
+	if err != nil {
+		return Post{}, err
+	}
+	post, err := r.next.UpdatePost(ctx, encrypted)
+	if err != nil {
+		return Post{}, err
+	}
+	return r.decrypt(post)
+}
+
+func (r *EncryptedPostRepository) DeletePostByID(ctx context.Context, id int) error {
+	return r.next.DeletePostByID(ctx, id)
+}
+
+func (r *EncryptedPostRepository) ApplyPostWrites(ctx context.Context, writes []storage.PostWrite) ([]Post, error) {
+	encrypted := make([]storage.PostWrite, len(writes))
+	for i, w := range writes {
+		encrypted[i] = w
+		if w.Op == storage.PostWriteDelete {
+			continue
+		}
+		var err error
+		if encrypted[i].Post, err = r.encrypt(w.Post); err != nil {
+			return nil, err
+		}
+	}
+	posts, err := storage.ApplyPostWrites(ctx, r.next, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		if posts[i], err = r.decrypt(posts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return posts, nil
+}
+
+// ReplaceAll encrypts plaintext posts, such as the git sync restores, before
+// they replace the store's data set.
+func (r *EncryptedPostRepository) ReplaceAll(ctx context.Context, posts []Post) error {
+	restorer, ok := storage.Unwrap[PostRestorer](r.next)
+	if !ok {
+		return errors.New("encryption: the repository does not support ReplaceAll")
+	}
+	encrypted := make([]Post, len(posts))
+	for i, post := range posts {
+		var err error
+		if encrypted[i], err = r.encrypt(post); err != nil {
+			return err
+		}
+	}
+	return restorer.ReplaceAll(ctx, encrypted)
+}
+
+// rotateAttempts is how often RotateKeys re-reads and retries a post that
+// keeps changing under it before giving up.
+const rotateAttempts = 3
+
+// RotateKeys re-encrypts every stored body that isn't already sealed with the
+// primary key, including plaintext left over from before encryption. It
+// writes through top, the repository the rest of the server writes to, so
+// the index, caches and notifications above this layer see each rewrite.
+// A post is only rewritten if it hasn't changed since it was read; if it
+// has, it is read again and retried.
+func (r *EncryptedPostRepository) RotateKeys(ctx context.Context, top PostRepository) (int, error) {
+	var ids []int
+	err := r.next.EachPost(ctx, func(post Post) error {
+		if !r.keys.usesPrimary(post.Body) {
+			ids = append(ids, post.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, id := range ids {
+		ok, err := r.rotate(ctx, top, id)
+		if err != nil {
+			return rotated, err
+		}
+		if ok {
+			rotated++
+		}
+	}
+	return rotated, nil
+}
+
+// rotate rewrites post id through top unless it was deleted or rewritten
+// with the primary key since RotateKeys listed it.
+func (r *EncryptedPostRepository) rotate(ctx context.Context, top PostRepository, id int) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		stored, err := r.next.GetPostByID(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if r.keys.usesPrimary(stored.Body) {
+			return false, nil
+		}
+		post, err := r.decrypt(stored)
+		if err != nil {
+			return false, err
+		}
+		write := storage.PostWrite{Op: storage.PostWriteUpdate, Post: post, IfUpdatedAt: stored.UpdatedAt}
+		_, err = storage.ApplyPostWrites(ctx, top, []storage.PostWrite{write})
+		if errors.Is(err, storage.ErrStalePost) && attempt < rotateAttempts {
+			continue
+		}
+		return err == nil, err
+	}
+}
+
+type RotateKeysResp struct {
+	Rotated int `json:"rotated"`
+}
+
+func RotateEncryptionKeysHandler(repo *EncryptedPostRepository, top PostRepository) func(*gin.Context) {
+	return func(c *gin.Context) {
+		rotated, err := repo.RotateKeys(c.Request.Context(), top)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, RotateKeysResp{Rotated: rotated})
+	}
+}
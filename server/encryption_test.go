@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gosolid/internal/storage"
+)
+
+func testKeyring(t *testing.T, primary string, ids ...string) *EncryptionKeyring {
+	t.Helper()
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+	}
+	k, err := NewEncryptionKeyring(primary, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// editingRepo stands in for the layers above the encryption: before the
+// first batch reaches it, someone else edits a post.
+type editingRepo struct {
+	PostRepository
+	once sync.Once
+	edit func()
+}
+
+func (r *editingRepo) Unwrap() PostRepository { return r.PostRepository }
+
+func (r *editingRepo) ApplyPostWrites(ctx context.Context, writes []storage.PostWrite) ([]Post, error) {
+	r.once.Do(r.edit)
+	return storage.ApplyPostWrites(ctx, r.PostRepository, writes)
+}
+
+func TestRotateKeysKeepsConcurrentEdits(t *testing.T) {
+	ctx := context.Background()
+	db := storage.NewDB()
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := NewEncryptedPostRepository(db, testKeyring(t, "a", "a"))
+	first, err := old.AddPost(ctx, Post{Title: "first", Body: "one", CreatedAt: at, UpdatedAt: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := old.AddPost(ctx, Post{Title: "second", Body: "two", CreatedAt: at, UpdatedAt: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewEncryptedPostRepository(db, testKeyring(t, "b", "a", "b"))
+	top := &editingRepo{PostRepository: repo, edit: func() {
+		edited := first
+		edited.Body, edited.UpdatedAt = "edited", at.Add(time.Minute)
+		if _, err := repo.UpdatePost(ctx, edited); err != nil {
+			t.Error(err)
+		}
+	}}
+	rotated, err := repo.RotateKeys(ctx, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The edit already sealed the first post with the new key.
+	if rotated != 1 {
+		t.Errorf("rotated %d posts, want 1", rotated)
+	}
+
+	for _, want := range []Post{{ID: first.ID, Body: "edited"}, {ID: second.ID, Body: "two"}} {
+		got, err := repo.GetPostByID(ctx, want.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Body != want.Body {
+			t.Errorf("post %d body = %q, want %q", want.ID, got.Body, want.Body)
+		}
+		stored, _ := db.GetPostByID(ctx, want.ID)
+		if !strings.HasPrefix(stored.Body, encryptedPrefix+"b:") {
+			t.Errorf("post %d is stored as %q, want it sealed with key b", want.ID, stored.Body)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/notify"
+)
+
+var eventTypes = map[Action]string{
+	ActionCreate: "post.created",
+	ActionUpdate: "post.updated",
+	ActionDelete: "post.deleted",
+}
+
+type PostEvent struct {
+	ID   uint64                 `json:"id"`
+	Type string                 `json:"type"`
+	Post notify.WebhookPostData `json:"post"`
+	Time time.Time              `json:"time"`
+}
+
+// EventFilter narrows a subscription. Empty fields match everything.
+type EventFilter struct {
+	Types  []string
+	PostID int
+}
+
+// EventFilterFromQuery reads ?type=post.created,post.deleted&post_id=1.
+func EventFilterFromQuery(c *gin.Context) (EventFilter, error) {
+	var filter EventFilter
+	if types := c.Query("type"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+	if postID := c.Query("post_id"); postID != "" {
+		id, err := strconv.Atoi(postID)
+		if err != nil {
+			return EventFilter{}, err
+		}
+		filter.PostID = id
+	}
+	return filter, nil
+}
+
+func (f EventFilter) Match(event PostEvent) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	return f.PostID == 0 || f.PostID == event.Post.ID
+}
+
+type eventSubscriber struct {
+	filter EventFilter
+	ch     chan PostEvent
+}
+
+// EventBus fans post events out to in-process subscribers. It is registered
+// as a notifier, so it sees exactly the writes the webhooks do. A subscriber
+// that falls more than buffer events behind is dropped rather than slowing
+// writers down; its channel is closed and the client has to reconnect.
+//
+// The last history events are kept so reconnecting clients can resume. IDs
+// restart at 1 with the process.
+type EventBus struct {
+	buffer  int
+	history int
+
+	mu     sync.Mutex
+	nextID uint64
+	recent []PostEvent
+	subs   map[*eventSubscriber]struct{}
+}
+
+func NewEventBus(buffer, history int) *EventBus {
+	return &EventBus{buffer: buffer, history: history, subs: make(map[*eventSubscriber]struct{})}
+}
+
+func (b *EventBus) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	b.Publish(post, action)
+	return nil
+}
+
+func (b *EventBus) Publish(post Post, action Action) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := PostEvent{
+		ID:   b.nextID,
+		Type: eventTypes[action],
+		Post: notify.WebhookPostData{ID: post.ID, Title: post.Title, Body: post.Body},
+		Time: time.Now().UTC(),
+	}
+	if b.history > 0 {
+		if len(b.recent) == b.history {
+			b.recent = slices.Delete(b.recent, 0, 1)
+		}
+		b.recent = append(b.recent, event)
+	}
+	for sub := range b.subs {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			slog.With("component", "events").Warn("dropping slow event subscriber", "event_id", event.ID)
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe returns the retained events after afterID that match, a channel
+// of matching events from then on, and a func that ends the subscription.
+// The channel is closed when the subscription ends for either reason. Pass
+// an afterID of 0 to skip the replay.
+func (b *EventBus) Subscribe(filter EventFilter, afterID uint64) ([]PostEvent, <-chan PostEvent, func()) {
+	sub := &eventSubscriber{filter: filter, ch: make(chan PostEvent, b.buffer)}
+
+	b.mu.Lock()
+	var replay []PostEvent
+	if afterID > 0 {
+		for _, event := range b.recent {
+			if event.ID > afterID && filter.Match(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return replay, sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Close ends every subscription, so streaming handlers return on shutdown;
+// hijacked connections aren't drained by http.Server.Shutdown.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+const streamingKey = "streaming"
+
+// markStreaming tells the slow request log that a long-lived response is
+// expected to outlast its threshold, and lifts the server's write timeout
+// for it.
+func markStreaming(c *gin.Context) {
+	c.Set(streamingKey, true)
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,343 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"gosolid/apperr"
+	"gosolid/internal/domain"
+	"gosolid/internal/httpapi"
+)
+
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+var exportColumns = map[string]func(Post) string{
+	"id":         func(p Post) string { return strconv.Itoa(p.ID) },
+	"title":      func(p Post) string { return p.Title },
+	"body":       func(p Post) string { return p.Body },
+	"created_at": func(p Post) string { return formatTimestamp(p.CreatedAt) },
+	"updated_at": func(p Post) string { return formatTimestamp(p.UpdatedAt) },
+}
+
+var defaultExportColumns = []string{"id", "title", "body", "created_at", "updated_at"}
+
+type postIterator interface {
+	EachPost(ctx context.Context, fn func(Post) error) error
+}
+
+// errStopIteration ends an EachPost early without being an error.
+var errStopIteration = errors.New("stop iteration")
+
+// jsonArrayWriter encodes a JSON array one element at a time. Nothing is
+// written until the first element, so a handler can still send an error
+// status if the iteration fails before it.
+//
+// Writers come from a pool, with their buffer and encoder, so a list
+// doesn't pay for them each time; release hands one back. Passing elements
+// as pointers saves the encoder copying each one.
+type jsonArrayWriter struct {
+	w     io.Writer
+	buf   bytes.Buffer
+	codec httpapi.JSONCodec
+	enc   httpapi.JSONEncoder
+	n     int
+}
+
+var jsonArrayWriters = sync.Pool{New: func() any { return &jsonArrayWriter{} }}
+
+// jsonArrayWriterMaxBuf keeps a writer that encoded one huge element from
+// holding on to its buffer in the pool.
+const jsonArrayWriterMaxBuf = 64 << 10
+
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	a := jsonArrayWriters.Get().(*jsonArrayWriter)
+	if a.codec != httpapi.Codec() {
+		a.codec, a.enc = httpapi.Codec(), httpapi.Codec().NewEncoder(&a.buf)
+	}
+	a.w, a.n = w, 0
+	return a
+}
+
+func (a *jsonArrayWriter) release() {
+	if a.buf.Cap() > jsonArrayWriterMaxBuf {
+		return
+	}
+	a.w = nil
+	jsonArrayWriters.Put(a)
+}
+
+func (a *jsonArrayWriter) Write(v any) error {
+	a.buf.Reset()
+	if a.n == 0 {
+		a.buf.WriteByte('[')
+	} else {
+		a.buf.WriteByte(',')
+	}
+	if err := a.enc.Encode(v); err != nil {
+		return err
+	}
+	a.n++
+	// Drop Encode's newline so the output matches json.Marshal's.
+	_, err := a.w.Write(a.buf.Bytes()[:a.buf.Len()-1])
+	return err
+}
+
+// Close ends the array, writing [] if there were no elements.
+func (a *jsonArrayWriter) Close() error {
+	end := "]"
+	if a.n == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// exportedPost is a post in format=json exports.
+type exportedPost struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// ExportHandler streams posts straight from the repository iterator, so
+// memory use doesn't grow with the number of posts. Errors after the first
+// byte can't change the status any more; they end the download early and
+// are logged.
+func ExportHandler(db PostReader) func(*gin.Context) {
+	return func(c *gin.Context) {
+		match := domain.MatchQuery(c.Query("q"))
+		stamp := time.Now().UTC().Format("20060102T150405Z")
+
+		var err error
+		switch c.DefaultQuery("format", "csv") {
+		case "csv":
+			columns, ok := exportColumnsParam(c)
+			if !ok {
+				return
+			}
+			startDownload(c, "text/csv; charset=utf-8", "posts-"+stamp+".csv")
+			err = exportCSV(c.Request.Context(), c.Writer, db, match, columns)
+		case "markdown":
+			startDownload(c, "application/zip", "posts-"+stamp+".zip")
+			err = exportMarkdown(c.Request.Context(), c.Writer, db, match)
+		case "json":
+			startDownload(c, "application/json; charset=utf-8", "posts-"+stamp+".json")
+			err = exportJSON(c.Request.Context(), c.Writer, db, match)
+		default:
+			abortWithProblem(c, apperr.ValidationFailed, "format must be csv, json or markdown")
+			return
+		}
+		if err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+func exportColumnsParam(c *gin.Context) ([]string, bool) {
+	columns := defaultExportColumns
+	if v := c.Query("columns"); v != "" {
+		columns = strings.Split(v, ",")
+	}
+	for i, column := range columns {
+		if _, ok := exportColumns[column]; !ok {
+			abortWithProblem(c, apperr.ValidationFailed, "unknown column "+column+"; expected one of "+strings.Join(defaultExportColumns, ", "))
+			return nil, false
+		}
+		if slices.Contains(columns[:i], column) {
+			abortWithProblem(c, apperr.ValidationFailed, "column "+column+" is repeated")
+			return nil, false
+		}
+	}
+	return columns, true
+}
+
+func startDownload(c *gin.Context, contentType, filename string) {
+	markStreaming(c)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+}
+
+func exportCSV(ctx context.Context, out io.Writer, db postIterator, match func(Post) bool, columns []string) error {
+	w := csv.NewWriter(out)
+	w.Write(columns)
+	record := make([]string, len(columns))
+	err := db.EachPost(ctx, func(post Post) error {
+		if !match(post) {
+			return nil
+		}
+		for i, column := range columns {
+			record[i] = exportColumns[column](post)
+		}
+		return w.Write(record)
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+func exportJSON(ctx context.Context, out io.Writer, db postIterator, match func(Post) bool) error {
+	arr := newJSONArrayWriter(out)
+	defer arr.release()
+	var item exportedPost
+	err := db.EachPost(ctx, func(post Post) error {
+		if !match(post) {
+			return nil
+		}
+		item = exportedPost{
+			ID:        post.ID,
+			Title:     post.Title,
+			Body:      post.Body,
+			CreatedAt: formatTimestamp(post.CreatedAt),
+			UpdatedAt: formatTimestamp(post.UpdatedAt),
+		}
+		return arr.Write(&item)
+	})
+	if err != nil {
+		return err
+	}
+	return arr.Close()
+}
+
+// markdownFrontMatter follows Hugo's field names. Posts have no tags yet, so
+// there are none to export.
+type markdownFrontMatter struct {
+	Title     string    `yaml:"title"`
+	Date      time.Time `yaml:"date,omitempty"`
+	Lastmod   time.Time `yaml:"lastmod,omitempty"`
+	GosolidID int       `yaml:"gosolid_id"`
+}
+
+// markdownPostName is <id>-<slug>.md, so names are unique and sort in ID
+// order.
+func markdownPostName(post Post) string {
+	return fmt.Sprintf("%06d-%s.md", post.ID, slugify(post.Title))
+}
+
+func writeMarkdownPost(buf *bytes.Buffer, post Post) error {
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(buf)
+	if err := enc.Encode(markdownFrontMatter{
+		Title:     post.Title,
+		Date:      post.CreatedAt,
+		Lastmod:   post.UpdatedAt,
+		GosolidID: post.ID,
+	}); err != nil {
+		return err
+	}
+	enc.Close()
+	buf.WriteString("---\n\n")
+	buf.WriteString(post.Body)
+	if !strings.HasSuffix(post.Body, "\n") {
+		buf.WriteString("\n")
+	}
+	return nil
+}
+
+// parseMarkdownPost reads a file in the format writeMarkdownPost writes. ID
+// is zero when the front matter has no gosolid_id.
+func parseMarkdownPost(data []byte) (Post, error) {
+	rest, ok := bytes.CutPrefix(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), []byte("---\n"))
+	if !ok {
+		return Post{}, errors.New("markdown: missing front matter")
+	}
+	front, body, ok := bytes.Cut(rest, []byte("\n---\n"))
+	if !ok {
+		return Post{}, errors.New("markdown: unterminated front matter")
+	}
+	var meta markdownFrontMatter
+	if err := yaml.Unmarshal(front, &meta); err != nil {
+		return Post{}, fmt.Errorf("markdown: front matter: %w", err)
+	}
+	if strings.TrimSpace(meta.Title) == "" {
+		return Post{}, errors.New("markdown: title is required")
+	}
+	return Post{
+		ID:        meta.GosolidID,
+		Title:     meta.Title,
+		Body:      strings.TrimSuffix(strings.TrimPrefix(string(body), "\n"), "\n"),
+		CreatedAt: meta.Date,
+		UpdatedAt: meta.Lastmod,
+	}, nil
+}
+
+// exportMarkdown writes a ZIP with one Markdown file per post.
+func exportMarkdown(ctx context.Context, out io.Writer, db postIterator, match func(Post) bool) error {
+	zw := zip.NewWriter(out)
+	var buf bytes.Buffer
+	err := db.EachPost(ctx, func(post Post) error {
+		if !match(post) {
+			return nil
+		}
+
+		buf.Reset()
+		if err := writeMarkdownPost(&buf, post); err != nil {
+			return err
+		}
+
+		header := &zip.FileHeader{
+			Name:     markdownPostName(post),
+			Method:   zip.Deflate,
+			Modified: post.UpdatedAt,
+		}
+		if header.Modified.IsZero() {
+			header.Modified = time.Now()
+		}
+		f, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(buf.Bytes())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "post"
+	}
+	return slug
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gosolid/internal/clock"
+)
+
+// FeaturePartialPatch makes PATCH /posts/:id leave omitted fields untouched
+// instead of clearing them.
+const FeaturePartialPatch = "partial_patch"
+
+type FeatureFlagProvider interface {
+	Flags(ctx context.Context) (map[string]bool, error)
+}
+
+// FeatureFlags answers from the static config, overridden by whatever the
+// remote provider last returned.
+type FeatureFlags struct {
+	static map[string]bool
+	clock  clock.Clock
+
+	mu     sync.RWMutex
+	remote map[string]bool
+}
+
+func NewFeatureFlags(static map[string]bool) *FeatureFlags {
+	return &FeatureFlags{static: maps.Clone(static), clock: clock.System}
+}
+
+func (f *FeatureFlags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if enabled, ok := f.remote[name]; ok {
+		return enabled
+	}
+	return f.static[name]
+}
+
+func (f *FeatureFlags) Snapshot() map[string]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	flags := maps.Clone(f.static)
+	if flags == nil {
+		flags = make(map[string]bool)
+	}
+	maps.Copy(flags, f.remote)
+	return flags
+}
+
+// Watch polls provider until ctx is done. A failed refresh keeps the last
+// known remote values.
+func (f *FeatureFlags) Watch(ctx context.Context, provider FeatureFlagProvider, interval time.Duration) {
+	refresh := func() {
+		flags, err := provider.Flags(ctx)
+		if err != nil {
+			slog.With("component", "features").WarnContext(ctx, "refresh feature flags", "error", err)
+			return
+		}
+		f.mu.Lock()
+		f.remote = flags
+		f.mu.Unlock()
+	}
+
+	refresh()
+	ticker := f.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			refresh()
+		}
+	}
+}
+
+type HTTPFlagProvider struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPFlagProvider(url string) *HTTPFlagProvider {
+	return &HTTPFlagProvider{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (p *HTTPFlagProvider) Flags(ctx context.Context) (map[string]bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feature flags: unexpected status %s", resp.Status)
+	}
+
+	var flags map[string]bool
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// parseFeatureFlags reads "name=true,other=false"; a bare name means enabled.
+func parseFeatureFlags(s string) (map[string]bool, error) {
+	flags := make(map[string]bool)
+	for _, entry := range splitList(s) {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			flags[name] = true
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("feature flag %q: %w", name, err)
+		}
+		flags[name] = enabled
+	}
+	return flags, nil
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"gosolid/apperr"
+	"gosolid/internal/httpapi"
+	"gosolid/postpb"
+)
+
+type gatewayGinKey struct{}
+
+// gatewayCodes classifies errors the gateway raises itself, such as a body
+// that isn't valid JSON; errors from the gRPC server carry their apperr code.
+var gatewayCodes = map[codes.Code]apperr.Code{
+	codes.InvalidArgument:  apperr.ValidationFailed,
+	codes.NotFound:         apperr.NotFound,
+	codes.Unimplemented:    apperr.NotFound,
+	codes.Unauthenticated:  apperr.Unauthenticated,
+	codes.PermissionDenied: apperr.Forbidden,
+	codes.DeadlineExceeded: apperr.Timeout,
+}
+
+// Gateway serves PostService as JSON under /v1/ with grpc-gateway, routed by
+// the google.api.http options in post.proto. It calls the gRPC server over
+// an in-memory connection, so requests pass the same interceptors as gRPC
+// clients (auth, scopes, request IDs and deadlines) whether or not grpc_addr
+// is set.
+type Gateway struct {
+	mux  *runtime.ServeMux
+	conn *grpc.ClientConn
+}
+
+func NewGateway(srv *grpc.Server) (*Gateway, error) {
+	lis := bufconn.Listen(256 << 10)
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			slog.Error("serve grpc gateway", "error", err)
+		}
+	}()
+	conn, err := grpc.NewClient("passthrough:///gosolid",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := runtime.NewServeMux(
+		// The HTTP middleware has already picked the request ID and set
+		// the response header.
+		runtime.WithMetadata(func(_ context.Context, r *http.Request) metadata.MD {
+			return metadata.Pairs(strings.ToLower(httpapi.RequestIDHeader), httpapi.RequestIDFromContext(r.Context()))
+		}),
+		runtime.WithOutgoingHeaderMatcher(func(string) (string, bool) { return "", false }),
+		runtime.WithErrorHandler(gatewayErrorHandler),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+	)
+	if err := postpb.RegisterPostServiceHandler(context.Background(), mux, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Gateway{mux: mux, conn: conn}, nil
+}
+
+func (g *Gateway) Handler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), gatewayGinKey{}, c)
+		g.mux.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	}
+}
+
+func (g *Gateway) Close(context.Context) error {
+	return g.conn.Close()
+}
+
+// gatewayErrorHandler answers with the same problem documents as the rest
+// of the HTTP API.
+func gatewayErrorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	c, ok := r.Context().Value(gatewayGinKey{}).(*gin.Context)
+	if !ok {
+		runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+		return
+	}
+
+	st := status.Convert(err)
+	code, ok := gatewayCodes[st.Code()]
+	if !ok {
+		code = apperr.Internal
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == "gosolid" {
+			code = apperr.Code(info.Reason)
+		}
+	}
+
+	detail := st.Message()
+	if detail == string(code) || code == apperr.Internal {
+		detail = ""
+	}
+	abortWithProblem(c, code, detail)
+}
@@ -0,0 +1,460 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"gosolid/apperr"
+)
+
+const (
+	gitSyncTimeout  = time.Minute
+	gitSyncAttempts = 3
+)
+
+type gitSyncPosts interface {
+	CreatePost(ctx context.Context, title, body string) (Post, error)
+	GetPost(ctx context.Context, id int) (Post, error)
+	ListPosts(ctx context.Context) ([]Post, error)
+	UpdatePost(ctx context.Context, id int, title, body *string) (Post, error)
+	DeletePost(ctx context.Context, id int) error
+}
+
+type gitChange struct {
+	post   Post
+	action Action
+}
+
+// GitSync mirrors posts into a Git repository as Markdown files, one commit
+// per change, in the format of the Markdown export. Every pull_interval it
+// also fetches the branch and applies files that others pushed: new files
+// become posts (and are renamed to carry their ID), edits update posts and
+// deleted files delete them.
+//
+// One goroutine, Run, does all the Git work; NotifyPostUpdated only queues.
+// When a push is rejected because the branch moved, the change is
+// reapplied on top of the remote branch.
+type GitSync struct {
+	cfg    GitSyncConfig
+	auth   transport.AuthMethod
+	queue  chan gitChange
+	logger *slog.Logger
+
+	repo   *git.Repository
+	posts  gitSyncPosts
+	synced plumbing.Hash
+}
+
+func NewGitSync(cfg GitSyncConfig, password string) *GitSync {
+	s := &GitSync{
+		cfg:    cfg,
+		queue:  make(chan gitChange, 1024),
+		logger: slog.With("component", "gitsync"),
+	}
+	if password != "" {
+		s.auth = &githttp.BasicAuth{Username: cfg.Username, Password: password}
+	}
+	return s
+}
+
+func (s *GitSync) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	select {
+	case s.queue <- gitChange{post: post, action: action}:
+		return nil
+	default:
+		return fmt.Errorf("gitsync: queue full, post %d not synced", post.ID)
+	}
+}
+
+func (s *GitSync) branchRef() plumbing.ReferenceName {
+	return plumbing.NewBranchReferenceName(s.cfg.Branch)
+}
+
+func (s *GitSync) remoteRef() plumbing.ReferenceName {
+	return plumbing.NewRemoteReferenceName("origin", s.cfg.Branch)
+}
+
+// Start opens or clones the repository. When the post store is empty it is
+// restored from the branch, IDs included, through restorer.
+func (s *GitSync) Start(ctx context.Context, posts gitSyncPosts, restorer PostRestorer) error {
+	s.posts = posts
+	repo, err := git.PlainOpen(s.cfg.Dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = s.clone(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("gitsync: open %s: %w", s.cfg.Dir, err)
+	}
+	s.repo = repo
+
+	remote, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if !remote.IsZero() {
+		if err := s.reset(remote); err != nil {
+			return err
+		}
+	}
+	s.synced = remote
+
+	existing, err := posts.ListPosts(ctx)
+	if err != nil || len(existing) > 0 || remote.IsZero() {
+		return err
+	}
+	restored, err := s.readAll(remote)
+	if err != nil || len(restored) == 0 {
+		return err
+	}
+	if restorer == nil {
+		return errors.New("gitsync: the repository can't restore posts")
+	}
+	if err := restorer.ReplaceAll(ctx, restored); err != nil {
+		return fmt.Errorf("gitsync: restore: %w", err)
+	}
+	s.logger.InfoContext(ctx, "restored posts", "count", len(restored), "commit", remote.String())
+	return nil
+}
+
+func (s *GitSync) clone(ctx context.Context) (*git.Repository, error) {
+	repo, err := git.PlainCloneContext(ctx, s.cfg.Dir, false, &git.CloneOptions{
+		URL:           s.cfg.URL,
+		Auth:          s.auth,
+		ReferenceName: s.branchRef(),
+		SingleBranch:  true,
+	})
+	if !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return repo, err
+	}
+	// The first push creates the branch.
+	if err := os.RemoveAll(s.cfg.Dir); err != nil {
+		return nil, err
+	}
+	repo, err = git.PlainInitWithOptions(s.cfg.Dir, &git.PlainInitOptions{InitOptions: git.InitOptions{DefaultBranch: s.branchRef()}})
+	if err != nil {
+		return nil, err
+	}
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{s.cfg.URL}})
+	return repo, err
+}
+
+// fetch returns the remote branch's commit, or the zero hash when the
+// branch doesn't exist yet.
+func (s *GitSync) fetch(ctx context.Context) (plumbing.Hash, error) {
+	err := s.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       s.auth,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec("+" + s.branchRef() + ":" + s.remoteRef())},
+	})
+	var noMatch git.NoMatchingRefSpecError
+	switch {
+	case errors.Is(err, transport.ErrEmptyRemoteRepository), errors.As(err, &noMatch):
+		return plumbing.ZeroHash, nil
+	case err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate):
+		return plumbing.ZeroHash, fmt.Errorf("gitsync: fetch: %w", err)
+	}
+	ref, err := s.repo.Reference(s.remoteRef(), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+// reset points the local branch and worktree at commit, dropping local
+// commits that never made it to the remote.
+func (s *GitSync) reset(commit plumbing.Hash) error {
+	if err := s.repo.Storer.SetReference(plumbing.NewHashReference(s.branchRef(), commit)); err != nil {
+		return err
+	}
+	if err := s.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, s.branchRef())); err != nil {
+		return err
+	}
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Reset(&git.ResetOptions{Commit: commit, Mode: git.HardReset})
+}
+
+func (s *GitSync) tree(commit plumbing.Hash) (*object.Tree, error) {
+	if commit.IsZero() {
+		return &object.Tree{}, nil
+	}
+	c, err := s.repo.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+	return c.Tree()
+}
+
+func (s *GitSync) isPostFile(name string) bool {
+	return path.Dir(name) == path.Clean(s.cfg.Path) && strings.HasSuffix(name, ".md")
+}
+
+func (s *GitSync) readAll(commit plumbing.Hash) ([]Post, error) {
+	tree, err := s.tree(commit)
+	if err != nil {
+		return nil, err
+	}
+	var posts []Post
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !s.isPostFile(f.Name) {
+			return nil
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		post, err := parseMarkdownPost([]byte(content))
+		if err != nil || post.ID <= 0 {
+			s.logger.Warn("skip file without a post", "file", f.Name, "error", err)
+			return nil
+		}
+		posts = append(posts, post)
+		return nil
+	})
+	return posts, err
+}
+
+// Run applies queued changes and, every pull_interval, remote ones, until
+// ctx is done.
+func (s *GitSync) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if s.cfg.PullInterval.Duration > 0 {
+		ticker := time.NewTicker(s.cfg.PullInterval.Duration)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		var change *gitChange
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case c := <-s.queue:
+			change = &c
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, gitSyncTimeout)
+		if err := s.step(stepCtx, change); err != nil {
+			s.logger.ErrorContext(ctx, "sync", "error", err)
+		}
+		cancel()
+	}
+}
+
+// step brings the worktree up to the remote branch, applying what others
+// pushed, then commits and pushes change if there is one.
+func (s *GitSync) step(ctx context.Context, change *gitChange) error {
+	var err error
+	for attempt := 0; attempt < gitSyncAttempts; attempt++ {
+		if err = s.pull(ctx); err != nil {
+			return err
+		}
+		if change == nil {
+			return nil
+		}
+		var commit plumbing.Hash
+		commit, err = s.commit(*change)
+		if errors.Is(err, git.ErrEmptyCommit) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = s.repo.PushContext(ctx, &git.PushOptions{
+			RemoteName: "origin",
+			Auth:       s.auth,
+			RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(s.branchRef() + ":" + s.branchRef())},
+		})
+		if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+			s.synced = commit
+			return nil
+		}
+		s.logger.WarnContext(ctx, "push rejected, retrying on top of the remote branch", "post_id", change.post.ID, "error", err)
+	}
+	return fmt.Errorf("gitsync: post %d not pushed: %w", change.post.ID, err)
+}
+
+func (s *GitSync) pull(ctx context.Context) error {
+	remote, err := s.fetch(ctx)
+	if err != nil || remote.IsZero() {
+		return err
+	}
+	if head, err := s.repo.Head(); err != nil || head.Hash() != remote {
+		if err := s.reset(remote); err != nil {
+			return fmt.Errorf("gitsync: reset to %s: %w", remote, err)
+		}
+	}
+	if remote == s.synced {
+		return nil
+	}
+	from, to := s.synced, remote
+	s.synced = remote
+	return s.apply(ctx, from, to)
+}
+
+// apply turns the post files changed between two commits into post
+// changes. A file renamed by a title change shows up as a delete and an
+// insert, so a post is only deleted when no file with its ID is left.
+func (s *GitSync) apply(ctx context.Context, from, to plumbing.Hash) error {
+	fromTree, err := s.tree(from)
+	if err != nil {
+		return err
+	}
+	toTree, err := s.tree(to)
+	if err != nil {
+		return err
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, fromTree, toTree, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		before, after, err := change.Files()
+		if err != nil {
+			return err
+		}
+		// The files are named after their tree entry, not their path.
+		switch {
+		case after != nil && s.isPostFile(change.To.Name):
+			s.applyFile(ctx, change.To.Name, after)
+		case after == nil && s.isPostFile(change.From.Name):
+			s.applyDelete(ctx, toTree, before)
+		}
+	}
+	return nil
+}
+
+func (s *GitSync) applyFile(ctx context.Context, name string, f *object.File) {
+	logger := s.logger.With("file", name)
+	content, err := f.Contents()
+	if err != nil {
+		logger.ErrorContext(ctx, "read file", "error", err)
+		return
+	}
+	imported, err := parseMarkdownPost([]byte(content))
+	if err != nil {
+		logger.WarnContext(ctx, "skip file", "error", err)
+		return
+	}
+
+	if imported.ID > 0 {
+		post, err := s.posts.GetPost(ctx, imported.ID)
+		switch {
+		case err == nil:
+			if post.Title == imported.Title && strings.TrimSpace(post.Body) == strings.TrimSpace(imported.Body) {
+				return
+			}
+			if _, err := s.posts.UpdatePost(ctx, imported.ID, &imported.Title, &imported.Body); err != nil {
+				logger.ErrorContext(ctx, "update post", "post_id", imported.ID, "error", err)
+			}
+			return
+		case !errors.Is(err, apperr.ErrNotFound):
+			logger.ErrorContext(ctx, "get post", "post_id", imported.ID, "error", err)
+			return
+		}
+	}
+
+	// A new file, or one whose post is gone: create a post and drop the
+	// file, since the commit for the new post writes it under its ID.
+	post, err := s.posts.CreatePost(ctx, imported.Title, imported.Body)
+	if err != nil {
+		logger.ErrorContext(ctx, "create post", "error", err)
+		return
+	}
+	if err := os.Remove(filepath.Join(s.cfg.Dir, filepath.FromSlash(name))); err != nil {
+		logger.ErrorContext(ctx, "remove imported file", "error", err)
+	}
+	logger.InfoContext(ctx, "imported post", "post_id", post.ID)
+}
+
+func (s *GitSync) applyDelete(ctx context.Context, toTree *object.Tree, f *object.File) {
+	content, err := f.Contents()
+	if err != nil {
+		return
+	}
+	imported, err := parseMarkdownPost([]byte(content))
+	if err != nil || imported.ID <= 0 {
+		return
+	}
+	prefix := path.Join(s.cfg.Path, fmt.Sprintf("%06d-", imported.ID))
+	stillThere := false
+	_ = toTree.Files().ForEach(func(other *object.File) error {
+		if strings.HasPrefix(other.Name, prefix) {
+			stillThere = true
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if stillThere {
+		return
+	}
+	if err := s.posts.DeletePost(ctx, imported.ID); err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		s.logger.ErrorContext(ctx, "delete post", "post_id", imported.ID, "error", err)
+	}
+}
+
+// commit writes change to the worktree and commits everything that changed,
+// including files removed by apply.
+func (s *GitSync) commit(change gitChange) (plumbing.Hash, error) {
+	dir := filepath.Join(s.cfg.Dir, filepath.FromSlash(s.cfg.Path))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	old, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%06d-*.md", change.post.ID)))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	for _, name := range old {
+		if err := os.Remove(name); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	message := fmt.Sprintf("Delete post %d", change.post.ID)
+	if change.action != ActionDelete {
+		var buf bytes.Buffer
+		if err := writeMarkdownPost(&buf, change.post); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, markdownPostName(change.post)), buf.Bytes(), 0o644); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		verb := "Update"
+		if change.action == ActionCreate {
+			verb = "Create"
+		}
+		message = fmt.Sprintf("%s post %d: %s", verb, change.post.ID, change.post.Title)
+	}
+
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return wt.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: s.cfg.AuthorName, Email: s.cfg.AuthorEmail, When: time.Now()},
+	})
+}
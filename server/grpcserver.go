@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"gosolid/apperr"
+	"gosolid/internal/httpapi"
+	"gosolid/internal/mapper"
+	"gosolid/postpb"
+)
+
+// grpcScopes lists the scope each RPC needs, mirroring the HTTP routes.
+// Methods missing from the map are refused.
+var grpcScopes = map[string]Scope{
+	postpb.PostService_CreatePost_FullMethodName: ScopePostsWrite,
+	postpb.PostService_GetPost_FullMethodName:    ScopePostsRead,
+	postpb.PostService_ListPosts_FullMethodName:  ScopePostsRead,
+	postpb.PostService_UpdatePost_FullMethodName: ScopePostsWrite,
+	postpb.PostService_DeletePost_FullMethodName: ScopePostsWrite,
+}
+
+type postGRPCServer struct {
+	postpb.UnimplementedPostServiceServer
+	svc PostUseCases
+}
+
+func (s *postGRPCServer) CreatePost(ctx context.Context, req *postpb.CreatePostRequest) (*postpb.Post, error) {
+	post := mapper.FromCreatePostRequest(req)
+	post, err := s.svc.CreatePost(ctx, post.Title, post.Body)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return mapper.ToPostpb(post), nil
+}
+
+func (s *postGRPCServer) GetPost(ctx context.Context, req *postpb.GetPostRequest) (*postpb.Post, error) {
+	post, err := s.svc.GetPost(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return mapper.ToPostpb(post), nil
+}
+
+func (s *postGRPCServer) ListPosts(req *postpb.ListPostsRequest, stream grpc.ServerStreamingServer[postpb.Post]) error {
+	ctx := stream.Context()
+	var sendErr error
+	err := s.svc.EachPost(ctx, func(post Post) error {
+		sendErr = stream.Send(mapper.ToPostpb(post))
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return grpcError(ctx, err)
+	}
+	return nil
+}
+
+func (s *postGRPCServer) UpdatePost(ctx context.Context, req *postpb.UpdatePostRequest) (*postpb.Post, error) {
+	post, err := s.svc.UpdatePost(ctx, int(req.GetId()), req.Title, req.Body)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return mapper.ToPostpb(post), nil
+}
+
+func (s *postGRPCServer) DeletePost(ctx context.Context, req *postpb.DeletePostRequest) (*postpb.DeletePostResponse, error) {
+	if err := s.svc.DeletePost(ctx, int(req.GetId())); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &postpb.DeletePostResponse{}, nil
+}
+
+// grpcCode picks the gRPC code closest to the HTTP status of an apperr code,
+// so both APIs classify failures the same way.
+func grpcCode(code apperr.Code) codes.Code {
+	switch code.Status() {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusGone:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// grpcError turns err into a status carrying the apperr code as ErrorInfo,
+// logging errors that have no code the same way the HTTP recovery does.
+func grpcError(ctx context.Context, err error) error {
+	appErr := apperr.From(err)
+	if appErr.Code == apperr.Internal {
+		slog.With("component", "grpc").ErrorContext(ctx, "request failed", "request_id", httpapi.RequestIDFromContext(ctx), "error", err)
+	}
+	return newGRPCStatus(appErr.Code, appErr.Detail)
+}
+
+func newGRPCStatus(code apperr.Code, detail string) error {
+	msg := detail
+	if msg == "" {
+		msg = string(code)
+	}
+	st := status.New(grpcCode(code), msg)
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(code), Domain: "gosolid"}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// grpcPublishes are the RPCs RequireVerifiedEmail guards over HTTP.
+var grpcPublishes = map[string]bool{
+	postpb.PostService_CreatePost_FullMethodName: true,
+	postpb.PostService_UpdatePost_FullMethodName: true,
+}
+
+// grpcContext applies what the HTTP middleware chain does for the api group:
+// request ID, bearer token auth, scope and email verification checks and
+// request deadline.
+func grpcContext(ctx context.Context, method string, tokens *TokenStore, verifier *EmailVerifier, timeout time.Duration) (context.Context, context.CancelFunc, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := firstMetadata(md, strings.ToLower(httpapi.RequestIDHeader))
+	if !httpapi.ValidRequestID(id) {
+		id = httpapi.NewRequestID()
+	}
+	ctx = httpapi.WithRequestID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(httpapi.RequestIDHeader), id))
+
+	token, ok := strings.CutPrefix(firstMetadata(md, "authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, nil, newGRPCStatus(apperr.Unauthenticated, "bearer token required")
+	}
+	principal, ok := tokens.Lookup(token)
+	if !ok {
+		return nil, nil, newGRPCStatus(apperr.Unauthenticated, "invalid token")
+	}
+	scope, ok := grpcScopes[method]
+	if !ok {
+		return nil, nil, newGRPCStatus(apperr.Forbidden, "")
+	}
+	if !principal.HasScope(scope) {
+		return nil, nil, newGRPCStatus(apperr.InsufficientScope, "requires scope "+string(scope))
+	}
+	if grpcPublishes[method] && !verifier.MayPublish(principal) {
+		return nil, nil, newGRPCStatus(apperr.Forbidden, errEmailNotVerified.Error())
+	}
+
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context { return s.ctx }
+
+func NewGRPCServer(svc PostUseCases, tokens *TokenStore, verifier *EmailVerifier, timeout time.Duration) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, cancel, err := grpcContext(ctx, info.FullMethod, tokens, verifier, timeout)
+			if err != nil {
+				return nil, err
+			}
+			defer cancel()
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, cancel, err := grpcContext(ss.Context(), info.FullMethod, tokens, verifier, timeout)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+			return handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+		}),
+	)
+	postpb.RegisterPostServiceServer(srv, &postGRPCServer{svc: svc})
+	return srv
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"gosolid/apperr"
+	"gosolid/internal/httpapi"
+	"gosolid/internal/storage"
+)
+
+type HealthCheck func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type HealthChecker struct {
+	timeout time.Duration
+	mu      sync.RWMutex
+	checks  map[string]HealthCheck
+}
+
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{timeout: timeout, checks: make(map[string]HealthCheck)}
+}
+
+func (h *HealthChecker) Register(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Check runs every registered check concurrently, each under its own timeout.
+func (h *HealthChecker) Check(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	report := HealthReport{Status: "ok", Components: make(map[string]ComponentStatus, len(h.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			status := ComponentStatus{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				status.Status = "fail"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = status
+			if err != nil {
+				report.Status = "fail"
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// RepositoryHealthCheck uses a Ping method when the backend has one and
+// otherwise does a cheap lookup that must come back as found or not found.
+func RepositoryHealthCheck(db PostRepository) HealthCheck {
+	return func(ctx context.Context) error {
+		if pinger, ok := storage.Unwrap[interface{ Ping(ctx context.Context) error }](db); ok {
+			return pinger.Ping(ctx)
+		}
+		if _, err := db.GetPostByID(ctx, 0); err != nil && !errors.Is(err, apperr.ErrNotFound) {
+			return err
+		}
+		return ctx.Err()
+	}
+}
+
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpapi.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+func ReadinessHandler(checker *HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Check(r.Context())
+		if report.Status != "ok" {
+			httpapi.WriteJSON(w, http.StatusServiceUnavailable, report)
+			return
+		}
+		httpapi.WriteJSON(w, http.StatusOK, report)
+	})
+}
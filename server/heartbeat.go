@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Heartbeat pings an external uptime monitor using healthchecks.io-style
+// URLs: <url>/start on startup, <url> while readiness checks pass,
+// <url>/fail with the health report when they don't, and <url>/log on
+// shutdown. A monitor that stops hearing from us raises the alarm itself.
+type Heartbeat struct {
+	url      string
+	interval time.Duration
+	health   *HealthChecker
+	client   *http.Client
+}
+
+func NewHeartbeat(url string, interval time.Duration, health *HealthChecker) *Heartbeat {
+	return &Heartbeat{
+		url:      strings.TrimSuffix(url, "/"),
+		interval: interval,
+		health:   health,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (h *Heartbeat) ping(ctx context.Context, suffix string, body any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url+suffix, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *Heartbeat) beat(ctx context.Context) {
+	report := h.health.Check(ctx)
+	suffix := ""
+	if report.Status != "ok" {
+		suffix = "/fail"
+	}
+	if err := h.ping(ctx, suffix, report); err != nil {
+		slog.With("component", "heartbeat").WarnContext(ctx, "send heartbeat", "status", report.Status, "error", err)
+	}
+}
+
+// Run announces startup and then beats every interval until ctx is done.
+func (h *Heartbeat) Run(ctx context.Context) {
+	if err := h.ping(ctx, "/start", nil); err != nil {
+		slog.With("component", "heartbeat").WarnContext(ctx, "send heartbeat", "status", "start", "error", err)
+	}
+	h.beat(ctx)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.beat(ctx)
+		}
+	}
+}
+
+func (h *Heartbeat) Stop(ctx context.Context) error {
+	return h.ping(ctx, "/log", map[string]string{"status": "shutting down"})
+}
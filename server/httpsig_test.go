@@ -0,0 +1,45 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPSignatureFreshness(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"type":"Follow"}`)
+	signed := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	req := httptest.NewRequest(http.MethodPost, "https://blog.example.com/ap/inbox", strings.NewReader(string(body)))
+	if err := signHTTPRequest(req, body, "https://remote.example/actor#main-key", key, signed); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := parseHTTPSignature(req.Header.Get("Signature"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		now  time.Time
+		want error
+	}{
+		{"on time", signed.Add(time.Minute), nil},
+		{"at the tolerance", signed.Add(apSignatureTolerance), nil},
+		{"too old", signed.Add(apSignatureTolerance + time.Second), ErrHTTPSignatureStale},
+		{"from the future", signed.Add(-apSignatureTolerance - time.Second), ErrHTTPSignatureStale},
+	} {
+		err := verifyHTTPSignature(req, sig, body, &key.PublicKey, apSignatureTolerance, tc.now)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
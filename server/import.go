@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/client"
+)
+
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate"
+	ImportInvalid   = "invalid"
+)
+
+// ImportResult is the outcome for one row; Row counts from 1 and doesn't
+// include the CSV header.
+type ImportResult struct {
+	Row    int    `json:"row"`
+	Status string `json:"status"`
+	ID     int    `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportResp struct {
+	Created    int            `json:"created"`
+	Duplicates int            `json:"duplicates"`
+	Invalid    int            `json:"invalid"`
+	Results    []ImportResult `json:"results"`
+}
+
+// ImportPostsHandler accepts a JSON array of posts, a CSV with a title and
+// an optional body column, or either one as the "file" field of a multipart
+// upload, and imports the rows with PostService.ImportPosts.
+func ImportPostsHandler(svc interface {
+	ImportPosts(ctx context.Context, rows []Post) (ImportResp, error)
+}) func(*gin.Context) {
+	return func(c *gin.Context) {
+		rows, err := readImportRows(c)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		posts := make([]Post, len(rows))
+		for i, row := range rows {
+			posts[i] = Post{Title: row.Title, Body: row.Body}
+		}
+
+		resp, err := svc.ImportPosts(c.Request.Context(), posts)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func readImportRows(c *gin.Context) ([]client.NewPostReq, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if mediaType != "multipart/form-data" {
+		return decodeImportRows(c.Request.Body, mediaType)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, apperr.Invalid(fmt.Errorf("file: %w", err))
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mediaType, _, _ = mime.ParseMediaType(header.Header.Get("Content-Type"))
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".csv":
+		mediaType = "text/csv"
+	case ".json":
+		mediaType = "application/json"
+	}
+	return decodeImportRows(f, mediaType)
+}
+
+func decodeImportRows(r io.Reader, mediaType string) ([]client.NewPostReq, error) {
+	switch mediaType {
+	case "application/json":
+		var rows []client.NewPostReq
+		if err := json.NewDecoder(r).Decode(&rows); err != nil {
+			return nil, apperr.Invalid(err)
+		}
+		return rows, nil
+	case "text/csv":
+		return decodeImportCSV(r)
+	default:
+		return nil, apperr.New(apperr.ValidationFailed, "import must be application/json or text/csv")
+	}
+}
+
+func decodeImportCSV(r io.Reader) ([]client.NewPostReq, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apperr.Invalid(fmt.Errorf("csv: %w", err))
+	}
+	for i := range header {
+		// Spreadsheets often start the file with a byte order mark.
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	titleCol, bodyCol := slices.Index(header, "title"), slices.Index(header, "body")
+	if titleCol < 0 {
+		return nil, apperr.New(apperr.ValidationFailed, "csv: a title column is required")
+	}
+
+	var rows []client.NewPostReq
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, apperr.Invalid(fmt.Errorf("csv: %w", err))
+		}
+		var row client.NewPostReq
+		if titleCol < len(record) {
+			row.Title = record[titleCol]
+		}
+		if bodyCol >= 0 && bodyCol < len(record) {
+			row.Body = record[bodyCol]
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+const (
+	ingestTimestampHeader = "X-Gosolid-Timestamp"
+	ingestSignatureHeader = "X-Gosolid-Signature"
+)
+
+var (
+	ErrIngestSignatureInvalid = apperr.New(apperr.InvalidSignature, "ingest: invalid signature")
+	ErrIngestStale            = apperr.New(apperr.InvalidSignature, "ingest: timestamp outside the allowed window")
+)
+
+// IngestSigner signs inbound payloads the way senders are expected to:
+// HMAC-SHA256 over "<unix timestamp>.<body>", hex-encoded with a sha256=
+// prefix. The timestamp is signed too, so a captured request can't be
+// replayed once it falls outside the tolerance.
+type IngestSigner struct {
+	key       []byte
+	tolerance time.Duration
+}
+
+func NewIngestSigner(key []byte, tolerance time.Duration) *IngestSigner {
+	return &IngestSigner{key: key, tolerance: tolerance}
+}
+
+func (s *IngestSigner) Sign(timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *IngestSigner) Verify(timestamp, signature string, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrIngestSignatureInvalid
+	}
+	if !hmac.Equal([]byte(s.Sign(ts, body)), []byte(signature)) {
+		return ErrIngestSignatureInvalid
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > s.tolerance || d < -s.tolerance {
+		return ErrIngestStale
+	}
+	return nil
+}
+
+type IngestResp struct {
+	Action string `json:"action"`
+	ID     int    `json:"id"`
+}
+
+// IngestReceiver turns payloads into posts using the configured field
+// mapping. When a key field is configured it remembers which post each
+// external key created, so a later payload with the same key updates that
+// post instead of creating another one. The map lives in memory, like the
+// posts themselves.
+type IngestReceiver struct {
+	svc interface {
+		CreatePost(ctx context.Context, title, body string) (Post, error)
+		UpdatePost(ctx context.Context, id int, title, body *string) (Post, error)
+	}
+	mapping IngestConfig
+
+	mu   sync.Mutex
+	keys map[string]int
+}
+
+func NewIngestReceiver(svc interface {
+	CreatePost(ctx context.Context, title, body string) (Post, error)
+	UpdatePost(ctx context.Context, id int, title, body *string) (Post, error)
+}, mapping IngestConfig) *IngestReceiver {
+	return &IngestReceiver{svc: svc, mapping: mapping, keys: map[string]int{}}
+}
+
+// ingestField follows a dot-separated path through decoded JSON objects.
+// Strings are returned as is and numbers in their JSON form, so numeric
+// external IDs work as keys.
+func ingestField(payload any, path string) (string, bool) {
+	v := payload
+	for _, name := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[name]; !ok {
+			return "", false
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+// Ingest creates or updates a post from one payload. Fields missing from an
+// update follow the same rules as PATCH /posts/:id.
+func (r *IngestReceiver) Ingest(ctx context.Context, body []byte) (IngestResp, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var payload any
+	if err := dec.Decode(&payload); err != nil {
+		return IngestResp{}, apperr.Invalid(err)
+	}
+
+	title, ok := ingestField(payload, r.mapping.TitleField)
+	if !ok || strings.TrimSpace(title) == "" {
+		return IngestResp{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("%s must be a non-empty string", r.mapping.TitleField))
+	}
+	var bodyPtr *string
+	if r.mapping.BodyField != "" {
+		if v, ok := ingestField(payload, r.mapping.BodyField); ok {
+			bodyPtr = &v
+		}
+	}
+
+	var key string
+	if r.mapping.KeyField != "" {
+		if key, ok = ingestField(payload, r.mapping.KeyField); !ok || key == "" {
+			return IngestResp{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("%s must be a string or number", r.mapping.KeyField))
+		}
+	}
+
+	// Holding the lock for the write keeps two deliveries of the same new
+	// key from creating two posts.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.keys[key]; ok && key != "" {
+		post, err := r.svc.UpdatePost(ctx, id, &title, bodyPtr)
+		if err == nil {
+			return IngestResp{Action: "updated", ID: post.ID}, nil
+		}
+		if apperr.From(err).Code != apperr.PostNotFound {
+			return IngestResp{}, err
+		}
+		// The post was deleted here; the next payload recreates it.
+		delete(r.keys, key)
+	}
+
+	post, err := r.svc.CreatePost(ctx, title, valueOrZero(bodyPtr))
+	if err != nil {
+		return IngestResp{}, err
+	}
+	if key != "" {
+		r.keys[key] = post.ID
+	}
+	return IngestResp{Action: "created", ID: post.ID}, nil
+}
+
+// IngestHandler serves POST /hooks/ingest. It takes no bearer token: senders
+// authenticate with the payload signature instead.
+func IngestHandler(signer *IngestSigner, receiver *IngestReceiver) func(*gin.Context) {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		if err := signer.Verify(c.GetHeader(ingestTimestampHeader), c.GetHeader(ingestSignatureHeader), body, time.Now()); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		resp, err := receiver.Ingest(c.Request.Context(), body)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		status := http.StatusOK
+		if resp.Action == "created" {
+			status = http.StatusCreated
+		}
+		c.JSON(status, resp)
+	}
+}
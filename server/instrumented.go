@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentedPostRepository records a latency histogram and a span for every
+// call, both labelled with the backend. It is the instrument layer of
+// storage.layers, so any new backend is instrumented without extra wiring.
+type InstrumentedPostRepository struct {
+	next    PostRepository
+	backend string
+}
+
+func NewInstrumentedPostRepository(next PostRepository, backend string) *InstrumentedPostRepository {
+	return &InstrumentedPostRepository{next: next, backend: backend}
+}
+
+func (r *InstrumentedPostRepository) Unwrap() PostRepository { return r.next }
+
+func (r *InstrumentedPostRepository) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(err error, attrs ...attribute.KeyValue)) {
+	start := time.Now()
+	attrs = append(attrs, attribute.String("db.system", r.backend))
+	ctx, span := tracer.Start(ctx, "PostRepository."+operation, trace.WithAttributes(attrs...))
+	return ctx, func(err error, attrs ...attribute.KeyValue) {
+		repositoryOperationDuration.WithLabelValues(r.backend, operation, outcome(err)).Observe(time.Since(start).Seconds())
+		span.SetAttributes(attrs...)
+		endSpan(span, err)
+	}
+}
+
+func (r *InstrumentedPostRepository) AddPost(ctx context.Context, newPost Post) (post Post, err error) {
+	ctx, end := r.start(ctx, "AddPost")
+	defer func() { end(err, attribute.Int("post.id", post.ID)) }()
+	return r.next.AddPost(ctx, newPost)
+}
+
+func (r *InstrumentedPostRepository) AddPosts(ctx context.Context, newPosts []Post) (posts []Post, err error) {
+	ctx, end := r.start(ctx, "AddPosts", attribute.Int("post.count", len(newPosts)))
+	defer func() { end(err) }()
+	return r.next.AddPosts(ctx, newPosts)
+}
+
+func (r *InstrumentedPostRepository) GetPostByID(ctx context.Context, id int) (post Post, err error) {
+	ctx, end := r.start(ctx, "GetPostByID", attribute.Int("post.id", id))
+	defer func() { end(err) }()
+	return r.next.GetPostByID(ctx, id)
+}
+
+func (r *InstrumentedPostRepository) ListPosts(ctx context.Context, opts ListOptions) (posts []Post, total int, err error) {
+	ctx, end := r.start(ctx, "ListPosts", attribute.Int("list.limit", opts.Limit), attribute.Int("list.offset", opts.Offset), attribute.String("list.sort", string(opts.Sort)), attribute.Int("list.excerpt", opts.Excerpt))
+	defer func() { end(err, attribute.Int("post.count", len(posts))) }()
+	return r.next.ListPosts(ctx, opts)
+}
+
+func (r *InstrumentedPostRepository) EachPost(ctx context.Context, fn func(Post) error) (err error) {
+	ctx, end := r.start(ctx, "EachPost")
+	count := 0
+	defer func() { end(err, attribute.Int("post.count", count)) }()
+	return r.next.EachPost(ctx, func(post Post) error {
+		count++
+		return fn(post)
+	})
+}
+
+func (r *InstrumentedPostRepository) UpdatePost(ctx context.Context, updatePost Post) (post Post, err error) {
+	ctx, end := r.start(ctx, "UpdatePost", attribute.Int("post.id", updatePost.ID))
+	defer func() { end(err) }()
+	return r.next.UpdatePost(ctx, updatePost)
+}
+
+func (r *InstrumentedPostRepository) DeletePostByID(ctx context.Context, id int) (err error) {
+	ctx, end := r.start(ctx, "DeletePostByID", attribute.Int("post.id", id))
+	defer func() { end(err) }()
+	return r.next.DeletePostByID(ctx, id)
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"gosolid/internal/domain"
+	"gosolid/internal/storage"
+)
+
+// InvertedIndex answers built-in searches from memory instead of scanning
+// the store. It keeps a copy of every post and maps each term to the sorted
+// IDs of the posts containing it. Terms are the three-byte windows of the
+// lowercased title and body, so a query's terms narrow the posts down to
+// the few that can contain it, and those are checked the way BuiltinSearch
+// matches: a case-insensitive substring, in ID order. Queries shorter than
+// a term check every post, still without going to the store.
+//
+// It wraps the repository and updates on every write through it, so it has
+// to sit above encryption. Writes to the same post are serialized to apply
+// in the order the store saw them; Load fills it at startup.
+type InvertedIndex struct {
+	next PostRepository
+
+	// replacing is held for writing by Load and ReplaceAll, and for reading
+	// by all other writes.
+	replacing sync.RWMutex
+	postLocks [64]sync.Mutex
+
+	mu       sync.RWMutex
+	posts    map[int]Post
+	ids      []int
+	postings map[uint32][]int
+}
+
+func NewInvertedIndex(next PostRepository) *InvertedIndex {
+	return &InvertedIndex{next: next, posts: make(map[int]Post), postings: make(map[uint32][]int)}
+}
+
+func (x *InvertedIndex) Unwrap() PostRepository { return x.next }
+
+// indexTerms returns the distinct terms of post, sorted.
+func indexTerms(post Post) []uint32 {
+	title, body := strings.ToLower(post.Title), strings.ToLower(post.Body)
+	terms := make([]uint32, 0, len(title)+len(body))
+	for _, s := range []string{title, body} {
+		for i := 0; i+3 <= len(s); i++ {
+			terms = append(terms, uint32(s[i])<<16|uint32(s[i+1])<<8|uint32(s[i+2]))
+		}
+	}
+	slices.Sort(terms)
+	return slices.Compact(terms)
+}
+
+func insertSorted(ids []int, id int) []int {
+	i, found := slices.BinarySearch(ids, id)
+	if found {
+		return ids
+	}
+	return slices.Insert(ids, i, id)
+}
+
+func deleteSorted(ids []int, id int) []int {
+	i, found := slices.BinarySearch(ids, id)
+	if !found {
+		return ids
+	}
+	return slices.Delete(ids, i, i+1)
+}
+
+// put indexes post, replacing an older version of it. x.mu must be held.
+func (x *InvertedIndex) put(post Post) {
+	var old []uint32
+	if prev, ok := x.posts[post.ID]; ok {
+		old = indexTerms(prev)
+	} else {
+		x.ids = insertSorted(x.ids, post.ID)
+	}
+	terms := indexTerms(post)
+	for _, term := range old {
+		if _, keep := slices.BinarySearch(terms, term); !keep {
+			x.removePosting(term, post.ID)
+		}
+	}
+	for _, term := range terms {
+		if _, had := slices.BinarySearch(old, term); !had {
+			x.postings[term] = insertSorted(x.postings[term], post.ID)
+		}
+	}
+	x.posts[post.ID] = post
+}
+
+// remove drops post id from the index. x.mu must be held.
+func (x *InvertedIndex) remove(id int) {
+	prev, ok := x.posts[id]
+	if !ok {
+		return
+	}
+	for _, term := range indexTerms(prev) {
+		x.removePosting(term, id)
+	}
+	x.ids = deleteSorted(x.ids, id)
+	delete(x.posts, id)
+}
+
+func (x *InvertedIndex) removePosting(term uint32, id int) {
+	if ids := deleteSorted(x.postings[term], id); len(ids) > 0 {
+		x.postings[term] = ids
+	} else {
+		delete(x.postings, term)
+	}
+}
+
+// Load replaces the index with every post in the store.
+func (x *InvertedIndex) Load(ctx context.Context) error {
+	x.replacing.Lock()
+	defer x.replacing.Unlock()
+	return x.load(ctx)
+}
+
+func (x *InvertedIndex) load(ctx context.Context) error {
+	var posts []Post
+	if err := x.next.EachPost(ctx, func(post Post) error {
+		posts = append(posts, post)
+		return nil
+	}); err != nil {
+		return err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	clear(x.posts)
+	clear(x.postings)
+	x.ids = x.ids[:0]
+	for _, post := range posts {
+		x.put(post)
+	}
+	return nil
+}
+
+// candidates returns the IDs of the posts that can contain q, which must
+// be lowercased, in ascending order. x.mu must be held for reading.
+func (x *InvertedIndex) candidates(q string) []int {
+	if len(q) < 3 {
+		return x.ids
+	}
+	lists := make([][]int, 0, len(q)-2)
+	for _, term := range indexTerms(Post{Title: q}) {
+		ids, ok := x.postings[term]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, ids)
+	}
+	slices.SortFunc(lists, func(a, b []int) int { return len(a) - len(b) })
+	ids := slices.Clone(lists[0])
+	for _, list := range lists[1:] {
+		ids = slices.DeleteFunc(ids, func(id int) bool {
+			_, found := slices.BinarySearch(list, id)
+			return !found
+		})
+		if len(ids) == 0 {
+			break
+		}
+	}
+	return ids
+}
+
+func (x *InvertedIndex) SearchPosts(ctx context.Context, q string, limit, offset int) ([]Post, int, error) {
+	match := domain.MatchQuery(q)
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var page []Post
+	total := 0
+	for _, id := range x.candidates(strings.ToLower(q)) {
+		post := x.posts[id]
+		if !match(post) {
+			continue
+		}
+		if total >= offset && (limit == 0 || len(page) < limit) {
+			page = append(page, post)
+		}
+		total++
+	}
+	return page, total, nil
+}
+
+// lockPost serializes writes to post id and holds off Load and ReplaceAll
+// until the returned func is called.
+func (x *InvertedIndex) lockPost(id int) func() {
+	x.replacing.RLock()
+	mu := &x.postLocks[uint(id)%uint(len(x.postLocks))]
+	mu.Lock()
+	return func() {
+		mu.Unlock()
+		x.replacing.RUnlock()
+	}
+}
+
+func (x *InvertedIndex) GetPostByID(ctx context.Context, id int) (Post, error) {
+	return x.next.GetPostByID(ctx, id)
+}
+
+// AddPost and AddPosts need no post lock: nothing else can write a post
+// before its ID is returned.
+func (x *InvertedIndex) AddPost(ctx context.Context, newPost Post) (Post, error) {
+	x.replacing.RLock()
+	defer x.replacing.RUnlock()
+	post, err := x.next.AddPost(ctx, newPost)
+	if err != nil {
+		return Post{}, err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.put(post)
+	return post, nil
+}
+
+func (x *InvertedIndex) AddPosts(ctx context.Context, newPosts []Post) ([]Post, error) {
+	x.replacing.RLock()
+	defer x.replacing.RUnlock()
+	posts, err := x.next.AddPosts(ctx, newPosts)
+	if err != nil {
+		return nil, err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, post := range posts {
+		x.put(post)
+	}
+	return posts, nil
+}
+
+func (x *InvertedIndex) ListPosts(ctx context.Context, opts ListOptions) ([]Post, int, error) {
+	return x.next.ListPosts(ctx, opts)
+}
+
+func (x *InvertedIndex) EachPost(ctx context.Context, fn func(Post) error) error {
+	return x.next.EachPost(ctx, fn)
+}
+
+func (x *InvertedIndex) UpdatePost(ctx context.Context, updatePost Post) (Post, error) {
+	defer x.lockPost(updatePost.ID)()
+	post, err := x.next.UpdatePost(ctx, updatePost)
+	if err != nil {
+		return Post{}, err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.put(post)
+	return post, nil
+}
+
+func (x *InvertedIndex) DeletePostByID(ctx context.Context, id int) error {
+	defer x.lockPost(id)()
+	if err := x.next.DeletePostByID(ctx, id); err != nil {
+		return err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+	return nil
+}
+
+// ApplyPostWrites holds off every other write while the batch runs, rather
+// than taking the post locks of all the posts in it.
+func (x *InvertedIndex) ApplyPostWrites(ctx context.Context, writes []storage.PostWrite) ([]Post, error) {
+	x.replacing.Lock()
+	defer x.replacing.Unlock()
+	posts, err := storage.ApplyPostWrites(ctx, x.next, writes)
+	if err != nil {
+		return nil, err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for i, post := range posts {
+		if writes[i].Op == storage.PostWriteDelete {
+			x.remove(post.ID)
+		} else {
+			x.put(post)
+		}
+	}
+	return posts, nil
+}
+
+// ReplaceAll reloads the index from the store afterwards rather than from
+// posts, which get their IDs there.
+func (x *InvertedIndex) ReplaceAll(ctx context.Context, posts []Post) error {
+	restorer, ok := storage.Unwrap[PostRestorer](x.next)
+	if !ok {
+		return errors.New("inverted index: the repository does not support ReplaceAll")
+	}
+	x.replacing.Lock()
+	defer x.replacing.Unlock()
+	if err := restorer.ReplaceAll(ctx, posts); err != nil {
+		return err
+	}
+	return x.load(context.WithoutCancel(ctx))
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+type IPFilter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+func parsePrefixes(rules []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(rules))
+	for _, rule := range rules {
+		if !strings.Contains(rule, "/") {
+			addr, err := netip.ParseAddr(rule)
+			if err != nil {
+				return nil, fmt.Errorf("ipfilter: %w", err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(rule)
+		if err != nil {
+			return nil, fmt.Errorf("ipfilter: %w", err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	allowPrefixes, err := parsePrefixes(allow)
+	if err != nil {
+		return nil, err
+	}
+	denyPrefixes, err := parsePrefixes(deny)
+	if err != nil {
+		return nil, err
+	}
+	return &IPFilter{allow: allowPrefixes, deny: denyPrefixes}, nil
+}
+
+// Allowed lets deny rules win over allow rules; an empty allow list admits
+// everything that is not denied.
+func (f *IPFilter) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range f.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, p := range f.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware relies on gin's ClientIP, so X-Forwarded-For is only honored
+// when the engine's trusted proxies are configured.
+func (f *IPFilter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addr, err := netip.ParseAddr(c.ClientIP())
+		if err != nil || !f.Allowed(addr) {
+			abortWithProblem(c, apperr.IPNotAllowed, "")
+			return
+		}
+		c.Next()
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/client"
+	"gosolid/internal/httpapi"
+	"gosolid/internal/mapper"
+)
+
+const jsonAPIMediaType = "application/vnd.api+json"
+
+type jsonAPIKey struct{}
+
+// JSON:API document types. Posts have no related resources yet, so
+// Relationships and Included stay empty until they do.
+type JSONAPIDocument struct {
+	Data     any               `json:"data"`
+	Included []JSONAPIResource `json:"included,omitempty"`
+	Links    map[string]string `json:"links,omitempty"`
+	Meta     map[string]any    `json:"meta,omitempty"`
+}
+
+type JSONAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id,omitempty"`
+	Attributes    any                            `json:"attributes,omitempty"`
+	Relationships map[string]JSONAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+type JSONAPIRelationship struct {
+	Data  any               `json:"data"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+type JSONAPIError struct {
+	Status string            `json:"status"`
+	Code   apperr.Code       `json:"code"`
+	Title  string            `json:"title"`
+	Detail string            `json:"detail,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+}
+
+type jsonAPIRequest struct {
+	Data *struct {
+		Type       string          `json:"type"`
+		ID         string          `json:"id"`
+		Attributes json.RawMessage `json:"attributes"`
+	} `json:"data"`
+}
+
+// wantsJSONAPI reports whether JSONAPIMiddleware switched r to JSON:API.
+func wantsJSONAPI(r *http.Request) bool {
+	v, _ := r.Context().Value(jsonAPIKey{}).(bool)
+	return v
+}
+
+// useJSONAPI marks the request for wantsJSONAPI. It goes on the request's
+// context, not gin's, so handlers written against net/http see it too.
+func useJSONAPI(c *gin.Context) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), jsonAPIKey{}, true))
+}
+
+// JSONAPIMiddleware switches the post endpoints to JSON:API when the Accept
+// header asks for it. JSON:API request bodies are unwrapped into the plain
+// attributes the handlers bind, so the handlers only differ in how they
+// render.
+func JSONAPIMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+			if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == jsonAPIMediaType {
+				useJSONAPI(c)
+				break
+			}
+		}
+
+		if mediaType, _, _ := mime.ParseMediaType(c.ContentType()); mediaType != jsonAPIMediaType || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		useJSONAPI(c)
+
+		var req jsonAPIRequest
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		if req.Data == nil {
+			abortWithProblem(c, apperr.ValidationFailed, "data is required")
+			return
+		}
+		if req.Data.Type != "posts" {
+			abortWithProblem(c, apperr.Conflict, "resource type must be posts")
+			return
+		}
+		if id := c.Param("id"); id != "" && req.Data.ID != id {
+			abortWithProblem(c, apperr.Conflict, "resource id must match the URL")
+			return
+		}
+
+		attributes := []byte(req.Data.Attributes)
+		if len(attributes) == 0 {
+			attributes = []byte("{}")
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(attributes))
+		c.Request.ContentLength = int64(len(attributes))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Next()
+	}
+}
+
+// postAttributes is a struct rather than a map so lists don't build a map
+// per post.
+type postAttributes struct {
+	UID   string `json:"uid,omitempty"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func postResource(post Post) JSONAPIResource {
+	id := strconv.Itoa(post.ID)
+	return JSONAPIResource{
+		Type:       "posts",
+		ID:         id,
+		Attributes: postAttributes{UID: post.UID, Title: post.Title, Body: post.Body},
+		Links:      map[string]string{"self": "/posts/" + id},
+	}
+}
+
+// renderPost answers with a JSON:API document or a binary encoding when one
+// was negotiated and with plain otherwise.
+func renderPost(w http.ResponseWriter, r *http.Request, status int, post Post, plain any) {
+	if renderWirePost(w, r, status, post, plain) {
+		return
+	}
+	if !wantsJSONAPI(r) {
+		httpapi.WriteJSON(w, status, plain)
+		return
+	}
+	resource := postResource(post)
+	writeRender(w, r, status, jsonAPIRender{JSONAPIDocument{Data: resource, Links: resource.Links}})
+}
+
+// renderPostList is renderPost for a page of a list. Each format builds
+// its own body straight from page.
+func renderPostList(w http.ResponseWriter, r *http.Request, page []Post, total, limit, offset int) {
+	if renderWirePostList(w, r, page, total) {
+		return
+	}
+	if !wantsJSONAPI(r) {
+		writeRender(w, r, http.StatusOK, httpapi.JSONRender{Data: postListData(page)})
+		return
+	}
+	data := make([]JSONAPIResource, 0, len(page))
+	for _, post := range page {
+		data = append(data, postResource(post))
+	}
+	writeRender(w, r, http.StatusOK, jsonAPIRender{JSONAPIDocument{
+		Data:  data,
+		Links: jsonAPIPageLinks(r.URL, total, limit, offset),
+		Meta:  map[string]any{"total": total},
+	}})
+}
+
+// renderPostSummaries is renderPostList for view=summary, where each
+// post's Body already holds its excerpt.
+func renderPostSummaries(w http.ResponseWriter, r *http.Request, page []Post, total, limit, offset int) {
+	if renderWirePostSummaries(w, r, page) {
+		return
+	}
+	if !wantsJSONAPI(r) {
+		writeRender(w, r, http.StatusOK, httpapi.JSONRender{Data: postSummaryData(page)})
+		return
+	}
+	data := make([]JSONAPIResource, 0, len(page))
+	for _, post := range page {
+		resource := postResource(post)
+		resource.Attributes = postSummaryAttributes{Title: post.Title, Excerpt: post.Body, CreatedAt: post.CreatedAt, UpdatedAt: post.UpdatedAt}
+		data = append(data, resource)
+	}
+	writeRender(w, r, http.StatusOK, jsonAPIRender{JSONAPIDocument{
+		Data:  data,
+		Links: jsonAPIPageLinks(r.URL, total, limit, offset),
+		Meta:  map[string]any{"total": total},
+	}})
+}
+
+type postSummaryAttributes struct {
+	Title     string    `json:"title"`
+	Excerpt   string    `json:"excerpt"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func postSummaryData(page []Post) []client.PostSummaryResp {
+	return mapper.Slice(page, mapper.ToPostSummary)
+}
+
+// postListData is the plain body of a post list, in JSON or MessagePack.
+func postListData(page []Post) []client.ListPostDataResp {
+	return mapper.Slice(page, mapper.ToPostResp[client.ListPostDataResp])
+}
+
+// jsonAPIPageLinks links to pages of the requested list, keeping query
+// parameters other than paging, such as the q of /posts/search.
+func jsonAPIPageLinks(u *url.URL, total, limit, offset int) map[string]string {
+	params := u.Query()
+	for _, name := range []string{"limit", "offset", "page[limit]", "page[offset]"} {
+		params.Del(name)
+	}
+	link := func(offset int) string {
+		var query []string
+		if len(params) > 0 {
+			query = append(query, params.Encode())
+		}
+		if limit > 0 {
+			query = append(query, "page[limit]="+strconv.Itoa(limit))
+		}
+		if offset > 0 {
+			query = append(query, "page[offset]="+strconv.Itoa(offset))
+		}
+		if len(query) == 0 {
+			return u.Path
+		}
+		return u.Path + "?" + strings.Join(query, "&")
+	}
+
+	links := map[string]string{"self": link(offset), "first": link(0)}
+	if limit == 0 {
+		return links
+	}
+	links["last"] = link(max(total-1, 0) / limit * limit)
+	if offset > 0 {
+		links["prev"] = link(max(offset-limit, 0))
+	}
+	if offset+limit < total {
+		links["next"] = link(offset + limit)
+	}
+	return links
+}
+
+func writeJSONAPIError(w http.ResponseWriter, r *http.Request, code apperr.Code, detail string) {
+	status := code.Status()
+	apiErr := JSONAPIError{
+		Status: strconv.Itoa(status),
+		Code:   code,
+		Title:  http.StatusText(status),
+		Detail: detail,
+	}
+	if id := httpapi.RequestIDFromContext(r.Context()); id != "" {
+		apiErr.Meta = map[string]string{"request_id": id}
+	}
+	writeRender(w, r, status, jsonAPIRender{map[string][]JSONAPIError{"errors": {apiErr}}})
+}
+
+type jsonAPIRender struct {
+	doc any
+}
+
+func (r jsonAPIRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	enc := httpapi.Codec().NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r.doc)
+}
+
+func (r jsonAPIRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", jsonAPIMediaType)
+}
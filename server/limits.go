@@ -0,0 +1,297 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"gosolid/apperr"
+	"gosolid/internal/clock"
+)
+
+var shedRequestsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "http_requests_shed_total",
+	Help: "Requests rejected with 503 because the server was saturated.",
+})
+
+// MaxBodyBytes caps request bodies at n, or at the routeLimits entry for the
+// route, keyed by method and route pattern. Reading past the cap fails with
+// REQUEST_TOO_LARGE, which binding errors keep through apperr.Invalid.
+func MaxBodyBytes(n int64, routeLimits map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := n
+		if routeLimit, ok := routeLimits[c.Request.Method+" "+c.FullPath()]; ok {
+			limit = routeLimit
+		}
+		c.Request.Body = maxBytesBody{http.MaxBytesReader(c.Writer, c.Request.Body, limit)}
+		c.Next()
+	}
+}
+
+// maxBytesBody turns the *http.MaxBytesError of an oversized body into an
+// apperr.Error, since apperr can't name net/http's type itself.
+type maxBytesBody struct {
+	io.ReadCloser
+}
+
+func (b maxBytesBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = apperr.Wrap(apperr.RequestTooLarge, err)
+	}
+	return n, err
+}
+
+// LoadShedder admits up to maxInFlight requests at once and lets up to
+// maxQueue more wait for at most queueTimeout; everything beyond that is
+// turned away immediately so requests already running keep their latency.
+// A maxInFlight of zero admits everything.
+type LoadShedder struct {
+	queued atomic.Int64
+	clock  clock.Clock
+
+	mu           sync.RWMutex
+	slots        chan struct{}
+	maxQueue     int64
+	queueTimeout time.Duration
+	retryAfter   string
+}
+
+func NewLoadShedder(maxInFlight, maxQueue int, queueTimeout, retryAfter time.Duration) *LoadShedder {
+	s := &LoadShedder{clock: clock.System}
+	s.SetLimits(maxInFlight, maxQueue, queueTimeout, retryAfter)
+	return s
+}
+
+// SetLimits applies new limits to requests arriving from now on. Requests
+// already admitted keep their slot in the old pool, so for a short while the
+// two pools together may exceed the new maxInFlight.
+func (s *LoadShedder) SetLimits(maxInFlight, maxQueue int, queueTimeout, retryAfter time.Duration) {
+	var slots chan struct{}
+	if maxInFlight > 0 {
+		slots = make(chan struct{}, maxInFlight)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots = slots
+	s.maxQueue = int64(maxQueue)
+	s.queueTimeout = queueTimeout
+	s.retryAfter = strconv.Itoa(max(int(retryAfter.Seconds()), 1))
+}
+
+func (s *LoadShedder) InFlight() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.slots)
+}
+
+func (s *LoadShedder) Queued() int { return int(s.queued.Load()) }
+
+func (s *LoadShedder) acquire(c *gin.Context) (release func(), retryAfter string, ok bool) {
+	s.mu.RLock()
+	slots, maxQueue, queueTimeout, retryAfter := s.slots, s.maxQueue, s.queueTimeout, s.retryAfter
+	s.mu.RUnlock()
+
+	if slots == nil {
+		return func() {}, retryAfter, true
+	}
+	release = func() { <-slots }
+
+	select {
+	case slots <- struct{}{}:
+		return release, retryAfter, true
+	default:
+	}
+
+	if s.queued.Add(1) > maxQueue {
+		s.queued.Add(-1)
+		return nil, retryAfter, false
+	}
+	defer s.queued.Add(-1)
+
+	select {
+	case slots <- struct{}{}:
+		return release, retryAfter, true
+	case <-s.clock.After(queueTimeout):
+		return nil, retryAfter, false
+	case <-c.Request.Context().Done():
+		return nil, retryAfter, false
+	}
+}
+
+func (s *LoadShedder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, retryAfter, ok := s.acquire(c)
+		if !ok {
+			shedRequestsTotal.Inc()
+			c.Header("Retry-After", retryAfter)
+			abortWithProblem(c, apperr.Overloaded, "")
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// TimeoutMiddleware bounds the request context so repository and notifier
+// calls give up once d has passed. Handlers that surface the context error
+// answer 504 through apperr.From; anything that returns without writing gets
+// a 504 problem here.
+func TimeoutMiddleware(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			abortWithProblem(c, apperr.Timeout, "request timed out")
+		}
+	}
+}
+
+var memoryGuardedRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_memory_guarded_requests_total",
+	Help: "List and export requests cut down because the heap was over limits.memory_budget, by action: truncated or rejected.",
+}, []string{"action"})
+
+// heapBytes is the memory held by heap objects, live or not yet swept.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// MemoryGuard keeps the requests that can read every post from pushing the
+// heap past a budget. While the heap is over it, lists are truncated to
+// pageSize posts and exports are turned away with 503. A zero budget turns it
+// off.
+type MemoryGuard struct {
+	mu         sync.RWMutex
+	budget     uint64
+	pageSize   int
+	retryAfter string
+}
+
+func NewMemoryGuard(budget int64, pageSize int, retryAfter time.Duration) *MemoryGuard {
+	g := &MemoryGuard{}
+	g.SetLimits(budget, pageSize, retryAfter)
+	return g
+}
+
+func (g *MemoryGuard) SetLimits(budget int64, pageSize int, retryAfter time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.budget = uint64(max(budget, 0))
+	g.pageSize = pageSize
+	g.retryAfter = strconv.Itoa(max(int(retryAfter.Seconds()), 1))
+}
+
+func (g *MemoryGuard) limits() (pressure bool, pageSize int, retryAfter string) {
+	g.mu.RLock()
+	budget, pageSize, retryAfter := g.budget, g.pageSize, g.retryAfter
+	g.mu.RUnlock()
+	return budget > 0 && heapBytes() >= budget, pageSize, retryAfter
+}
+
+// TruncateLists lowers a missing or larger ?limit= (or page[limit]) to the
+// page size under memory pressure, saying so in X-Truncated-Limit. Clients
+// still see the full count in X-Total-Count and can page through the rest.
+// Malformed limits are left for the handler to reject.
+func (g *MemoryGuard) TruncateLists() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pressure, pageSize, _ := g.limits()
+		if !pressure {
+			c.Next()
+			return
+		}
+		query := c.Request.URL.Query()
+		raw := query.Get("limit")
+		if raw == "" {
+			raw = query.Get("page[limit]")
+		}
+		if limit, err := strconv.Atoi(raw); raw == "" || err == nil && (limit == 0 || limit > pageSize) {
+			query.Del("page[limit]")
+			query.Set("limit", strconv.Itoa(pageSize))
+			c.Request.URL.RawQuery = query.Encode()
+			c.Header("X-Truncated-Limit", strconv.Itoa(pageSize))
+			memoryGuardedRequestsTotal.WithLabelValues("truncated").Inc()
+		}
+		c.Next()
+	}
+}
+
+// RejectUnderPressure answers 503 with Retry-After under memory pressure,
+// for responses like exports that can't be cut short.
+func (g *MemoryGuard) RejectUnderPressure() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pressure, _, retryAfter := g.limits()
+		if !pressure {
+			c.Next()
+			return
+		}
+		memoryGuardedRequestsTotal.WithLabelValues("rejected").Inc()
+		c.Header("Retry-After", retryAfter)
+		abortWithProblem(c, apperr.Overloaded, "the server is low on memory; export later")
+	}
+}
+
+// Throttle lets each key through at most limit times per window, the window
+// starting at the key's first event. Keys whose window has passed are
+// swept as events come in, so it only holds the keys of the last window.
+type Throttle struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	counts    map[string]throttleCount
+	lastSweep time.Time
+}
+
+type throttleCount struct {
+	start time.Time
+	n     int
+}
+
+func NewThrottle(limit int, window time.Duration) *Throttle {
+	return &Throttle{limit: limit, window: window, counts: make(map[string]throttleCount)}
+}
+
+// Allow counts an event for key at now and reports whether it is within
+// the limit. Events turned away aren't counted.
+func (t *Throttle) Allow(key string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastSweep) >= t.window {
+		for k, count := range t.counts {
+			if now.Sub(count.start) >= t.window {
+				delete(t.counts, k)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	count, ok := t.counts[key]
+	if !ok || now.Sub(count.start) >= t.window {
+		count = throttleCount{start: now}
+	}
+	if count.n >= t.limit {
+		return false
+	}
+	count.n++
+	t.counts[key] = count
+	return true
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/internal/clock"
+)
+
+func TestMaxBodyBytesTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(MaxBodyBytes(16, nil))
+	e.POST("/echo", func(c *gin.Context) {
+		var body map[string]string
+		if err := c.ShouldBindJSON(&body); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	})
+
+	if w := serve(e, http.MethodPost, "/echo", "", map[string]string{"a": "b"}); w.Code != http.StatusOK {
+		t.Errorf("small body: status %d, want 200", w.Code)
+	}
+	w := serve(e, http.MethodPost, "/echo", "", map[string]string{"a": "a body well past sixteen bytes"})
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), string(apperr.RequestTooLarge)) {
+		t.Errorf("large body: status %d %s, want 413 %s", w.Code, w.Body, apperr.RequestTooLarge)
+	}
+}
+
+func TestLoadShedderQueueTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	shedder := NewLoadShedder(1, 1, time.Second, 5*time.Second)
+	shedder.clock = fake
+
+	running, finish := make(chan struct{}), make(chan struct{})
+	e := gin.New()
+	e.Use(shedder.Middleware())
+	e.GET("/slow", func(c *gin.Context) {
+		close(running)
+		<-finish
+		c.Status(http.StatusOK)
+	})
+	e.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	defer close(finish)
+
+	go serve(e, http.MethodGet, "/slow", "", nil)
+	<-running
+
+	queued := make(chan *httptest.ResponseRecorder)
+	go func() { queued <- serve(e, http.MethodGet, "/fast", "", nil) }()
+	for shedder.Queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The queue is full, so a third request is turned away without waiting.
+	if w := serve(e, http.MethodGet, "/fast", "", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("beyond the queue: status %d, want 503", w.Code)
+	}
+
+	// The queued request only gives up once its timeout has passed. It may
+	// not be waiting on the clock yet, so keep moving time until it answers.
+	fake.Advance(time.Second - time.Millisecond)
+	select {
+	case w := <-queued:
+		t.Fatalf("answered %d before the queue timeout", w.Code)
+	case <-time.After(10 * time.Millisecond):
+	}
+	var w *httptest.ResponseRecorder
+	for w == nil {
+		fake.Advance(time.Second)
+		select {
+		case w = <-queued:
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "5" {
+		t.Errorf("queue timeout: status %d, Retry-After %q; want 503 and 5", w.Code, w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ParseLogLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return 0, fmt.Errorf("logging: %w", err)
+		}
+	}
+	return lvl, nil
+}
+
+// NewLogger filters through levels so verbosity can change at runtime; the
+// underlying handler is told to accept everything. A nil levels logs at info.
+func NewLogger(w io.Writer, format string, levels *LogLevels) (*slog.Logger, error) {
+	if levels == nil {
+		levels = NewLogLevels(slog.LevelInfo)
+	}
+	opts := &slog.HandlerOptions{Level: slog.Level(math.MinInt)}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text", "":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", format)
+	}
+	return slog.New(requestIDLogHandler{componentLevelHandler{Handler: handler, levels: levels}}), nil
+}
+
+func SlogMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if principal, ok := PrincipalFromContext(c); ok {
+			attrs = append(attrs, slog.String("principal", principal.Name))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+// LogLevels holds the default level plus per-component overrides. A logger
+// belongs to a component when it was derived with With("component", name).
+type LogLevels struct {
+	mu         sync.RWMutex
+	base       slog.Level
+	components map[string]slog.Level
+}
+
+func NewLogLevels(base slog.Level) *LogLevels {
+	return &LogLevels{base: base, components: make(map[string]slog.Level)}
+}
+
+func (l *LogLevels) Level(component string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if lvl, ok := l.components[component]; ok {
+		return lvl
+	}
+	return l.base
+}
+
+// SetLevel changes the default level when component is empty.
+func (l *LogLevels) SetLevel(component string, level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if component == "" {
+		l.base = level
+		return
+	}
+	l.components[component] = level
+}
+
+func (l *LogLevels) ResetLevel(component string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.components, component)
+}
+
+func (l *LogLevels) Snapshot() (slog.Level, map[string]slog.Level) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.base, maps.Clone(l.components)
+}
+
+type componentLevelHandler struct {
+	slog.Handler
+	levels    *LogLevels
+	component string
+}
+
+func (h componentLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component)
+}
+
+func (h componentLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, attr := range attrs {
+		if attr.Key == "component" {
+			component = attr.Value.String()
+		}
+	}
+	return componentLevelHandler{h.Handler.WithAttrs(attrs), h.levels, component}
+}
+
+func (h componentLevelHandler) WithGroup(name string) slog.Handler {
+	return componentLevelHandler{h.Handler.WithGroup(name), h.levels, h.component}
+}
+
+type SetLogLevelReq struct {
+	Level     string `json:"level" binding:"required,oneof=debug info warn error"`
+	Component string `json:"component"`
+}
+
+type LogLevelResp struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func logLevelResp(levels *LogLevels) LogLevelResp {
+	base, components := levels.Snapshot()
+	resp := LogLevelResp{
+		Level:      strings.ToLower(base.String()),
+		Components: make(map[string]string, len(components)),
+	}
+	for name, lvl := range components {
+		resp.Components[name] = strings.ToLower(lvl.String())
+	}
+	return resp
+}
+
+func GetLogLevelHandler(levels *LogLevels) func(*gin.Context) {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, logLevelResp(levels))
+	}
+}
+
+func SetLogLevelHandler(levels *LogLevels) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var setLogLevelReq SetLogLevelReq
+
+		if err := c.ShouldBindJSON(&setLogLevelReq); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+
+		lvl, err := ParseLogLevel(setLogLevelReq.Level)
+		if err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		levels.SetLevel(setLogLevelReq.Component, lvl)
+		slog.InfoContext(c.Request.Context(), "log level changed", "level", lvl, "for_component", setLogLevelReq.Component)
+
+		c.JSON(http.StatusOK, logLevelResp(levels))
+	}
+}
+
+func ResetLogLevelHandler(levels *LogLevels) func(*gin.Context) {
+	return func(c *gin.Context) {
+		levels.ResetLevel(c.Param("component"))
+
+		c.JSON(http.StatusOK, logLevelResp(levels))
+	}
+}
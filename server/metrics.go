@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"gosolid/apperr"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	repositoryOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "repository_operation_duration_seconds",
+		Help:    "Post repository operation latency by backend.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+	}, []string{"backend", "operation", "outcome"})
+
+	notifierDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifier_deliveries_total",
+		Help: "Post update notification attempts by notifier and outcome.",
+	}, []string{"notifier", "outcome"})
+
+	notifierDeliveryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "notifier_delivery_duration_seconds",
+		Help:    "Post update notification attempt latency by notifier and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"notifier", "outcome"})
+
+	notifierRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifier_retries_total",
+		Help: "Post update notification attempts that were retries of a failed one.",
+	}, []string{"notifier"})
+)
+
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, apperr.ErrNotFound):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+// MetricsNotifier records every attempt it sees. Put it inside a
+// RetryingNotifier so each retry is counted and timed on its own.
+type MetricsNotifier struct {
+	name string
+	next PostUpdateNotifier
+}
+
+func NewMetricsNotifier(name string, next PostUpdateNotifier) *MetricsNotifier {
+	return &MetricsNotifier{name: name, next: next}
+}
+
+func (n *MetricsNotifier) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	start := time.Now()
+	err := n.next.NotifyPostUpdated(ctx, post, action)
+	notifierDeliveriesTotal.WithLabelValues(n.name, outcome(err)).Inc()
+	notifierDeliveryDuration.WithLabelValues(n.name, outcome(err)).Observe(time.Since(start).Seconds())
+	return err
+}
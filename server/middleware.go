@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+
+	"gosolid/internal/httpapi"
+)
+
+// routeGroup is what the middleware of one route group is built from: the
+// app, and what differs between groups, such as the admin group's own IP
+// filter and timeout.
+type routeGroup struct {
+	app      *App
+	ipFilter *IPFilter
+	shedder  *LoadShedder
+	timeout  time.Duration
+}
+
+// httpMiddleware are the middleware the middleware section can name. One
+// its config turns off, like compression when compression.enabled is
+// false, is nil.
+var httpMiddleware = map[string]func(g routeGroup) (gin.HandlerFunc, error){
+	"access_log": func(g routeGroup) (gin.HandlerFunc, error) {
+		cfg := g.app.cfg.AccessLog
+		if cfg.Path == "" {
+			return nil, nil
+		}
+		accessLog, err := NewRotatingFile(cfg.Path, cfg.MaxSizeMB<<20, cfg.RotateInterval.Duration, cfg.MaxBackups, cfg.Compress)
+		if err != nil {
+			return nil, fmt.Errorf("open access log: %w", err)
+		}
+		g.app.hooks.Add("access log", func(context.Context) error { return accessLog.Close() })
+		return AccessLogMiddleware(accessLog, cfg.Format), nil
+	},
+	"request_id": func(routeGroup) (gin.HandlerFunc, error) {
+		return httpapi.RequestIDMiddleware(), nil
+	},
+	"logging": func(g routeGroup) (gin.HandlerFunc, error) {
+		return SlogMiddleware(g.app.logger.With("component", "http")), nil
+	},
+	"recovery": func(g routeGroup) (gin.HandlerFunc, error) {
+		return RecoveryMiddleware(g.app.alerts.Reporter), nil
+	},
+	"ip_filter": func(g routeGroup) (gin.HandlerFunc, error) {
+		return g.ipFilter.Middleware(), nil
+	},
+	"tracing": func(routeGroup) (gin.HandlerFunc, error) {
+		return otelgin.Middleware("gosolid"), nil
+	},
+	"metrics": func(routeGroup) (gin.HandlerFunc, error) {
+		return MetricsMiddleware(), nil
+	},
+	"max_body": func(g routeGroup) (gin.HandlerFunc, error) {
+		return MaxBodyBytes(g.app.cfg.Limits.MaxBodyBytes, map[string]int64{
+			"POST /posts/:id/attachments": g.app.cfg.Blobs.MaxUploadBytes,
+		}), nil
+	},
+	"slow_request": func(g routeGroup) (gin.HandlerFunc, error) {
+		if g.app.cfg.Log.SlowRequestThreshold.Duration <= 0 {
+			return nil, nil
+		}
+		return SlowRequestMiddleware(g.app.cfg.Log.SlowRequestThreshold.Duration), nil
+	},
+	"error_rate": func(g routeGroup) (gin.HandlerFunc, error) {
+		if g.app.alerts.ErrorRate == nil {
+			return nil, nil
+		}
+		return ErrorRateMiddleware(g.app.alerts.ErrorRate), nil
+	},
+	"compression": func(g routeGroup) (gin.HandlerFunc, error) {
+		if !g.app.cfg.Compression.Enabled {
+			return nil, nil
+		}
+		return CompressionMiddleware(g.app.cfg.Compression), nil
+	},
+	// problem goes innermost of the global group, so the middleware that
+	// measures responses sees its status.
+	"problem": func(routeGroup) (gin.HandlerFunc, error) {
+		return ProblemMiddleware(), nil
+	},
+	"client_cert": func(g routeGroup) (gin.HandlerFunc, error) {
+		if g.app.cfg.TLS.ClientCAFile == "" {
+			return nil, nil
+		}
+		identities, err := ParseCertIdentities(g.app.cfg.TLS.Identities)
+		if err != nil {
+			return nil, fmt.Errorf("configure mtls identities: %w", err)
+		}
+		return ClientCertMiddleware(identities), nil
+	},
+	"json_api": func(routeGroup) (gin.HandlerFunc, error) {
+		return JSONAPIMiddleware(), nil
+	},
+	"wire_format": func(routeGroup) (gin.HandlerFunc, error) {
+		return WireFormatMiddleware(), nil
+	},
+	"load_shed": func(g routeGroup) (gin.HandlerFunc, error) {
+		return g.shedder.Middleware(), nil
+	},
+	"timeout": func(g routeGroup) (gin.HandlerFunc, error) {
+		if g.timeout <= 0 {
+			return nil, nil
+		}
+		return TimeoutMiddleware(g.timeout), nil
+	},
+	"auth": func(g routeGroup) (gin.HandlerFunc, error) {
+		return AuthMiddleware(g.app.tokens), nil
+	},
+	"require_admin": func(routeGroup) (gin.HandlerFunc, error) {
+		return RequireScope(ScopeAdmin), nil
+	},
+	"response_cache": func(g routeGroup) (gin.HandlerFunc, error) {
+		if g.app.cfg.HTTPCache.Size <= 0 {
+			return nil, nil
+		}
+		responseCache := NewResponseCache(g.app.cfg.HTTPCache, g.app.notifiers.Events)
+		runInBackground("response cache", responseCache.Run, &g.app.hooks)
+		return responseCache.Middleware(), nil
+	},
+}
+
+func middlewareNames() []string {
+	names := make([]string, 0, len(httpMiddleware))
+	for name := range httpMiddleware {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// MiddlewareChain returns the middleware names lists for g, outermost
+// first, leaving out the ones the config turns off.
+func MiddlewareChain(g routeGroup, names []string) ([]gin.HandlerFunc, error) {
+	var chain []gin.HandlerFunc
+	for _, name := range names {
+		build, ok := httpMiddleware[name]
+		if !ok {
+			return nil, fmt.Errorf("middleware: unknown middleware %q", name)
+		}
+		handler, err := build(g)
+		if err != nil {
+			return nil, err
+		}
+		if handler != nil {
+			chain = append(chain, handler)
+		}
+	}
+	return chain, nil
+}
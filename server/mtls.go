@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CertIdentities maps a client certificate identity (its first URI SAN, such
+// as a SPIFFE ID, or else its subject CN) to the principal it acts as.
+type CertIdentities map[string]Principal
+
+// ParseCertIdentities reads "identity=scope|scope,identity=scope". Scopes
+// are the ones tokens can have; anything else is an error, not a scope no
+// route checks for.
+func ParseCertIdentities(s string) (CertIdentities, error) {
+	identities := make(CertIdentities)
+	for _, entry := range splitList(s) {
+		identity, scopes, ok := strings.Cut(entry, "=")
+		if !ok || identity == "" || scopes == "" {
+			return nil, fmt.Errorf("mtls: invalid identity mapping %q", entry)
+		}
+		principal := Principal{Name: identity}
+		for scope := range strings.SplitSeq(scopes, "|") {
+			if !slices.Contains(knownScopes, Scope(scope)) {
+				return nil, fmt.Errorf("mtls: unknown scope %q for %q", scope, identity)
+			}
+			principal.Scopes = append(principal.Scopes, Scope(scope))
+		}
+		identities[identity] = principal
+	}
+	return identities, nil
+}
+
+func certIdentity(cert *x509.Certificate) string {
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.CommonName
+}
+
+// ClientCertMiddleware sets the principal for callers presenting a mapped
+// certificate; unmapped callers fall through to bearer token auth.
+func ClientCertMiddleware(identities CertIdentities) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 {
+			leaf := c.Request.TLS.VerifiedChains[0][0]
+			if principal, ok := identities[certIdentity(leaf)]; ok {
+				c.Set(principalKey, principal)
+			}
+		}
+		c.Next()
+	}
+}
+
+func NewMTLSConfig(caFile string) (*tls.Config, error) {
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("mtls: no certificates found in CA file")
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/internal/domain"
+)
+
+const (
+	oembedWidth      = 600
+	oembedHeight     = 200
+	oembedExcerptLen = 280
+)
+
+// oembedTemplate is a blockquote rather than an iframe: it needs no script
+// and the /blog pages refuse to be framed.
+var oembedTemplate = template.Must(template.New("oembed").Parse(
+	`<blockquote class="gosolid-post" cite="{{.URL}}"><p><a href="{{.URL}}">{{.Title}}</a></p>` +
+		`{{if .Excerpt}}<p>{{.Excerpt}}</p>{{end}}<footer>{{.Site}}</footer></blockquote>`))
+
+// OEmbedResp is a "rich" oEmbed 1.0 response. Posts have no author of their
+// own, so the author is the site.
+type OEmbedResp struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	AuthorURL    string `json:"author_url"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	CacheAge     int    `json:"cache_age"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// OEmbedHandler answers GET /oembed?url= for /blog post URLs on this site.
+// Per the oEmbed spec an unknown URL is a 404 and any format but json is a
+// 501.
+func OEmbedHandler(posts postGetter, cfg BlogConfig) func(*gin.Context) {
+	return func(c *gin.Context) {
+		if format := c.Query("format"); format != "" && format != "json" {
+			abortWithProblem(c, apperr.UnsupportedFormat, "only format=json is supported")
+			return
+		}
+		width, height := oembedWidth, oembedHeight
+		for name, dst := range map[string]*int{"maxwidth": &width, "maxheight": &height} {
+			raw := c.Query(name)
+			if raw == "" {
+				continue
+			}
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				abortWithProblem(c, apperr.ValidationFailed, name+" must be a positive integer")
+				return
+			}
+			*dst = min(*dst, n)
+		}
+
+		base := blogBaseURL(c, cfg)
+		id, ok := oembedPostID(c.Query("url"), base)
+		if !ok {
+			abortWithProblem(c, apperr.NotFound, "url is not a post on this site")
+			return
+		}
+		post, err := posts.GetPost(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		postURL := base + "/blog/" + blogSlug(post)
+		var excerpt string
+		if paragraphs := blogParagraphs(post.Body); len(paragraphs) > 0 {
+			excerpt = domain.TruncateRunes(paragraphs[0], oembedExcerptLen)
+		}
+		var snippet bytes.Buffer
+		err = oembedTemplate.Execute(&snippet, map[string]string{"URL": postURL, "Title": post.Title, "Excerpt": excerpt, "Site": cfg.Title})
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		maxAge := int(cfg.CacheMaxAge.Seconds())
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.JSON(http.StatusOK, OEmbedResp{
+			Type:         "rich",
+			Version:      "1.0",
+			Title:        post.Title,
+			AuthorName:   cfg.Title,
+			AuthorURL:    base + "/blog",
+			ProviderName: cfg.Title,
+			ProviderURL:  base + "/blog",
+			CacheAge:     maxAge,
+			HTML:         snippet.String(),
+			Width:        width,
+			Height:       height,
+		})
+	}
+}
+
+// oembedPostID accepts http and https URLs of /blog/:slug pages on base's
+// host.
+func oembedPostID(raw, base string) (int, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, false
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil || !strings.EqualFold(u.Host, baseURL.Host) {
+		return 0, false
+	}
+	slug, ok := strings.CutPrefix(strings.TrimSuffix(u.Path, "/"), "/blog/")
+	if !ok || strings.Contains(slug, "/") {
+		return 0, false
+	}
+	return blogPostID(slug)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"gosolid/apperr"
+)
+
+var (
+	ErrOneTimeTokenInvalid = apperr.New(apperr.LinkExpired, "token is invalid or already used")
+	ErrOneTimeTokenExpired = apperr.New(apperr.LinkExpired, "token has expired")
+)
+
+type oneTimeToken struct {
+	subject   string
+	expiresAt time.Time
+}
+
+// OneTimeTokens issues single-use tokens bound to a subject (an email address,
+// an account ID). Only hashes are kept, so a leaked store can't be replayed.
+// Expired tokens are swept as new ones are issued.
+type OneTimeTokens struct {
+	ttl    time.Duration
+	mu     sync.Mutex
+	tokens map[string]oneTimeToken
+}
+
+func NewOneTimeTokens(ttl time.Duration) *OneTimeTokens {
+	return &OneTimeTokens{
+		ttl:    ttl,
+		tokens: make(map[string]oneTimeToken),
+	}
+}
+
+func (t *OneTimeTokens) Issue(subject string, now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, issued := range t.tokens {
+		if now.After(issued.expiresAt) {
+			delete(t.tokens, key)
+		}
+	}
+	t.tokens[hashToken(token)] = oneTimeToken{subject: subject, expiresAt: now.Add(t.ttl)}
+
+	return token, nil
+}
+
+func (t *OneTimeTokens) Consume(token string, now time.Time) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := hashToken(token)
+	issued, ok := t.tokens[key]
+	if !ok {
+		return "", ErrOneTimeTokenInvalid
+	}
+	delete(t.tokens, key)
+
+	if now.After(issued.expiresAt) {
+		return "", ErrOneTimeTokenExpired
+	}
+	return issued.subject, nil
+}
+
+// RevokeSubject drops every outstanding token for subject, e.g. once a reset
+// has gone through and older links must stop working.
+func (t *OneTimeTokens) RevokeSubject(subject string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, issued := range t.tokens {
+		if issued.subject == subject {
+			delete(t.tokens, key)
+		}
+	}
+}
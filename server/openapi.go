@@ -0,0 +1,444 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/client"
+	"gosolid/internal/httpapi"
+)
+
+// apiOperation documents one route. Request and Response are zero values of
+// the handler DTOs so the schemas follow the structs; a rawBody stands in for
+// non-JSON bodies and a nil Response means no body.
+type apiOperation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Scope    Scope
+	Public   bool
+	Params   []apiParam
+	Request  any
+	Status   int
+	Response any
+	Errors   []apperr.Code
+}
+
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+}
+
+type rawBody struct {
+	ContentType string
+	Description string
+}
+
+var eventFilterParams = []apiParam{
+	{Name: "type", In: "query", Type: "string", Description: "Comma-separated event types: post.created, post.updated, post.deleted."},
+	{Name: "post_id", In: "query", Type: "integer", Description: "Only events for this post."},
+}
+
+var triggerParams = []apiParam{
+	{Name: "since", In: "query", Type: "string", Description: "RFC 3339 timestamp; only items after it."},
+	{Name: "limit", In: "query", Type: "integer", Description: "Items to return, 1 to 100; 50 by default."},
+}
+
+var apiOperations = []apiOperation{
+	{Method: http.MethodGet, Path: "/healthz", Tag: "ops", Summary: "Liveness probe", Public: true, Status: http.StatusOK, Response: map[string]string{}},
+	{Method: http.MethodGet, Path: "/readyz", Tag: "ops", Summary: "Readiness probe; 503 with the same body when a check fails", Public: true, Status: http.StatusOK, Response: HealthReport{}},
+	{Method: http.MethodGet, Path: "/version", Tag: "ops", Summary: "Build information", Public: true, Status: http.StatusOK, Response: VersionResp{}},
+	{Method: http.MethodGet, Path: "/errors", Tag: "ops", Summary: "Catalog of error codes", Public: true, Status: http.StatusOK, Response: []apperr.Definition{}},
+	{Method: http.MethodGet, Path: "/metrics", Tag: "ops", Summary: "Prometheus metrics, limited by the admin IP filter", Public: true, Status: http.StatusOK,
+		Response: rawBody{ContentType: "text/plain", Description: "Prometheus text exposition format."}, Errors: []apperr.Code{apperr.IPNotAllowed}},
+
+	{Method: http.MethodPost, Path: "/posts", Tag: "posts", Summary: "Create a post", Scope: ScopePostsWrite, Request: client.NewPostReq{}, Status: http.StatusOK, Response: client.NewPostResp{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.Forbidden, apperr.RequestTooLarge}},
+	{Method: http.MethodPost, Path: "/posts/import", Tag: "posts", Summary: "Create posts in bulk, skipping invalid rows and duplicate titles", Scope: ScopePostsWrite,
+		Request: rawBody{ContentType: "text/csv", Description: "A CSV with title and body columns, a JSON array of posts, or either as the file field of multipart/form-data."},
+		Status:  http.StatusOK, Response: ImportResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.Forbidden, apperr.RequestTooLarge}},
+	{Method: http.MethodGet, Path: "/posts", Tag: "posts", Summary: "List posts, in ID order by default; X-Total-Count has the number matching the filters", Scope: ScopePostsRead,
+		Params: []apiParam{
+			{Name: "limit", In: "query", Type: "integer", Description: "Page size; all posts when omitted."},
+			{Name: "offset", In: "query", Type: "integer", Description: "Posts to skip."},
+			{Name: "sort", In: "query", Type: "string", Description: "id (default), created_at, updated_at or title; prefix with - for descending."},
+			{Name: "after", In: "query", Type: "integer", Description: "Cursor for sort=id and sort=-id: only posts past this ID."},
+			{Name: "q", In: "query", Type: "string", Description: "Only posts whose title or body contains this, ignoring case."},
+			{Name: "created_after", In: "query", Type: "string", Description: "RFC 3339 timestamp; only posts created after it."},
+			{Name: "updated_after", In: "query", Type: "string", Description: "RFC 3339 timestamp; only posts updated after it."},
+			{Name: "view", In: "query", Type: "string", Description: "full (default) or summary, which lists id, title, excerpt, created_at and updated_at instead of whole bodies; not available as protobuf."},
+		},
+		Status: http.StatusOK, Response: []client.ListPostDataResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.UnsupportedFormat}},
+	{Method: http.MethodGet, Path: "/posts/search", Tag: "posts", Summary: "Search posts' titles and bodies; X-Total-Count has the number of matches", Scope: ScopePostsRead,
+		Params: []apiParam{
+			{Name: "q", In: "query", Type: "string", Description: "Required. Search text. With a search index configured it is a full-text query, best matches first; otherwise a case-insensitive substring, in ID order."},
+			{Name: "limit", In: "query", Type: "integer", Description: "Page size; all matches when omitted."},
+			{Name: "offset", In: "query", Type: "integer", Description: "Matches to skip."},
+		},
+		Status: http.StatusOK, Response: []client.ListPostDataResp{}, Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodGet, Path: "/posts/:id", Tag: "posts", Summary: "Get a post; plain JSON responses have an ETag and honor If-None-Match with 304", Scope: ScopePostsRead,
+		Params: []apiParam{{Name: "If-None-Match", In: "header", Type: "string", Description: "ETag of a copy the client has; 304 if it is current."}},
+		Status: http.StatusOK, Response: client.GetPostResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.PostNotFound}},
+	{Method: http.MethodPatch, Path: "/posts/:id", Tag: "posts", Summary: "Update a post; omitted fields are cleared unless partial_patch is on", Scope: ScopePostsWrite,
+		Request: client.UpdatePostReq{}, Status: http.StatusOK, Response: client.UpdatePostResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.Forbidden, apperr.PostNotFound, apperr.RequestTooLarge}},
+	{Method: http.MethodDelete, Path: "/posts/:id", Tag: "posts", Summary: "Delete a post", Scope: ScopePostsWrite, Status: http.StatusNoContent,
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.PostNotFound}},
+
+	{Method: http.MethodGet, Path: "/triggers/new-posts", Tag: "triggers", Summary: "Polling trigger: newest posts first, each item with a stable id to deduplicate on", Scope: ScopePostsRead,
+		Params: triggerParams, Status: http.StatusOK, Response: []TriggerItem{}, Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodGet, Path: "/triggers/updated-posts", Tag: "triggers", Summary: "Polling trigger: most recently updated posts first; the id changes with every update", Scope: ScopePostsRead,
+		Params: triggerParams, Status: http.StatusOK, Response: []TriggerItem{}, Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodPost, Path: "/hooks", Tag: "triggers", Summary: "Subscribe a REST hook; target_url gets each new_post, updated_post or deleted_post item POSTed to it", Scope: ScopePostsRead,
+		Request: HookSubscribeReq{}, Status: http.StatusCreated, Response: HookResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.Conflict}},
+	{Method: http.MethodDelete, Path: "/hooks/:id", Tag: "triggers", Summary: "Unsubscribe a REST hook created with the same token", Scope: ScopePostsRead,
+		Status: http.StatusNoContent, Errors: []apperr.Code{apperr.HookNotFound}},
+
+	{Method: http.MethodGet, Path: "/ws", Tag: "events", Summary: "WebSocket stream of post events as JSON text messages", Scope: ScopePostsRead, Params: eventFilterParams,
+		Status: http.StatusSwitchingProtocols, Response: rawBody{Description: "Upgrades to a WebSocket; each message is a PostEvent."}, Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodGet, Path: "/events", Tag: "events", Summary: "Server-Sent Events stream of post events", Scope: ScopePostsRead,
+		Params: append([]apiParam{
+			{Name: "Last-Event-ID", In: "header", Type: "integer", Description: "Resume after this event ID."},
+			{Name: "last_event_id", In: "query", Type: "integer", Description: "Same as Last-Event-ID, for EventSource's first request."},
+		}, eventFilterParams...),
+		Status: http.StatusOK, Response: rawBody{ContentType: "text/event-stream", Description: "Events named after their type with PostEvent JSON as data."}, Errors: []apperr.Code{apperr.ValidationFailed}},
+
+	{Method: http.MethodGet, Path: "/posts/export", Tag: "posts", Summary: "Download posts as CSV, JSON or a ZIP of Markdown files", Scope: ScopePostsRead,
+		Params: []apiParam{
+			{Name: "format", In: "query", Type: "string", Description: "csv (default), json: an array of posts, or markdown: a ZIP with one file per post and YAML front matter."},
+			{Name: "columns", In: "query", Type: "string", Description: "CSV only: comma-separated columns from id, title, body, created_at, updated_at; all by default."},
+			{Name: "q", In: "query", Type: "string", Description: "Only posts whose title or body contains this, ignoring case."},
+		},
+		Status: http.StatusOK, Response: rawBody{ContentType: "text/csv", Description: "A CSV with a header row, application/json for json, or application/zip for markdown."}, Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodPost, Path: "/posts/:id/attachments", Tag: "attachments", Summary: "Upload an attachment, replacing one with the same file name", Scope: ScopePostsWrite,
+		Request: rawBody{ContentType: "multipart/form-data", Description: "The file field; its file name names the attachment. blobs.max_upload_bytes limits the size."},
+		Status:  http.StatusCreated, Response: AttachmentResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.PostNotFound, apperr.RequestTooLarge}},
+	{Method: http.MethodGet, Path: "/posts/:id/attachments", Tag: "attachments", Summary: "List a post's attachments", Scope: ScopePostsRead, Status: http.StatusOK, Response: []AttachmentResp{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.PostNotFound}},
+	{Method: http.MethodGet, Path: "/posts/:id/attachments/:name", Tag: "attachments", Summary: "Redirect to a presigned or signed download URL for an attachment", Scope: ScopePostsRead,
+		Status: http.StatusFound, Errors: []apperr.Code{apperr.ValidationFailed, apperr.PostNotFound, apperr.AttachmentNotFound}},
+	{Method: http.MethodGet, Path: "/files/:id/:name", Tag: "attachments", Summary: "Download an attachment through a signed URL; the URL is the credential", Public: true,
+		Params: []apiParam{
+			{Name: "expires", In: "query", Type: "integer", Description: "Unix time the URL stops working."},
+			{Name: "signature", In: "query", Type: "string", Description: "HMAC of the path and expires."},
+		},
+		Status: http.StatusOK, Response: rawBody{ContentType: "application/octet-stream", Description: "The file, with its stored Content-Type."},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.InvalidSignature, apperr.LinkExpired, apperr.AttachmentNotFound}},
+	{Method: http.MethodDelete, Path: "/posts/:id/attachments/:name", Tag: "attachments", Summary: "Delete an attachment", Scope: ScopePostsWrite, Status: http.StatusNoContent,
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.PostNotFound, apperr.AttachmentNotFound}},
+
+	{Method: http.MethodPost, Path: "/auth/reset", Tag: "auth", Summary: "Email a token reset link to the principal named by an address; 202 whether or not one exists", Public: true,
+		Request: RequestResetReq{}, Status: http.StatusAccepted, Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodPost, Path: "/auth/reset/confirm", Tag: "auth", Summary: "Revoke every token of the principal a reset link was sent to and issue a new one", Public: true,
+		Request: ConfirmResetReq{}, Status: http.StatusOK, Response: IssueTokenResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.LinkExpired}},
+	{Method: http.MethodGet, Path: "/auth/verify", Tag: "auth", Summary: "Confirm an email address from a verification link", Public: true,
+		Params: []apiParam{{Name: "token", In: "query", Type: "string", Description: "The token from the link."}},
+		Status: http.StatusOK, Response: ConfirmEmailResp{}, Errors: []apperr.Code{apperr.ValidationFailed, apperr.LinkExpired}},
+	{Method: http.MethodPost, Path: "/auth/verify", Tag: "auth", Summary: "Mail the caller a new verification link while its address is unconfirmed",
+		Status: http.StatusAccepted},
+	{Method: http.MethodGet, Path: "/oembed", Tag: "posts", Summary: "oEmbed JSON for a /blog post URL, for rich previews on other sites", Public: true,
+		Params: []apiParam{
+			{Name: "url", In: "query", Type: "string", Description: "A /blog/{slug} URL on this site."},
+			{Name: "maxwidth", In: "query", Type: "integer", Description: "Upper bound for width."},
+			{Name: "maxheight", In: "query", Type: "integer", Description: "Upper bound for height."},
+			{Name: "format", In: "query", Type: "string", Description: "Only json is supported."},
+		},
+		Status: http.StatusOK, Response: OEmbedResp{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.NotFound, apperr.PostNotFound, apperr.UnsupportedFormat, apperr.Overloaded}},
+
+	{Method: http.MethodPost, Path: "/hooks/ingest", Tag: "posts", Summary: "Create or update a post from a signed external payload; 201 when created", Public: true,
+		Params: []apiParam{
+			{Name: ingestTimestampHeader, In: "header", Type: "integer", Description: "Unix time the payload was signed."},
+			{Name: ingestSignatureHeader, In: "header", Type: "string", Description: "sha256= and the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with INGEST_SECRET."},
+		},
+		Request: rawBody{ContentType: "application/json", Description: "Any JSON object; ingest.title_field, body_field and key_field pick the post fields."}, Status: http.StatusOK, Response: IngestResp{},
+		Errors: []apperr.Code{apperr.InvalidSignature, apperr.ValidationFailed, apperr.RequestTooLarge}},
+
+	{Method: http.MethodPost, Path: "/admin/tokens", Tag: "admin", Summary: "Issue an API token", Scope: ScopeAdmin, Request: IssueTokenReq{}, Status: http.StatusCreated, Response: IssueTokenResp{},
+		Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodGet, Path: "/admin/stats", Tag: "admin", Summary: "Runtime and storage statistics", Scope: ScopeAdmin, Status: http.StatusOK, Response: StatsResp{}},
+	{Method: http.MethodPost, Path: "/admin/config/reload", Tag: "admin", Summary: "Reload the configuration", Scope: ScopeAdmin, Status: http.StatusNoContent,
+		Errors: []apperr.Code{apperr.InvalidConfig}},
+	{Method: http.MethodPost, Path: "/admin/backup", Tag: "admin", Summary: "Download a backup of every post", Scope: ScopeAdmin, Status: http.StatusOK,
+		Response: rawBody{ContentType: "application/gzip", Description: "Gzip-compressed Backup JSON."}},
+	{Method: http.MethodPost, Path: "/admin/restore", Tag: "admin", Summary: "Replace every post with a backup", Scope: ScopeAdmin,
+		Request: rawBody{ContentType: "application/gzip", Description: "An archive from POST /admin/backup."}, Status: http.StatusOK, Response: RestoreResp{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.InvalidBackup, apperr.RequestTooLarge}},
+	{Method: http.MethodPost, Path: "/admin/import/wordpress", Tag: "admin", Summary: "Import posts from a WordPress export", Scope: ScopeAdmin,
+		Params:  []apiParam{{Name: "dry_run", In: "query", Type: "boolean", Description: "Parse and report without creating posts."}},
+		Request: rawBody{ContentType: "application/xml", Description: "A WXR file from Tools > Export in WordPress."}, Status: http.StatusOK, Response: WordPressImportReport{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.RequestTooLarge}},
+	{Method: http.MethodPost, Path: "/admin/seed", Tag: "admin", Summary: "Add the posts of a fixture file", Scope: ScopeAdmin,
+		Request: rawBody{ContentType: "application/yaml", Description: "A fixture file, as YAML or as application/json."}, Status: http.StatusOK, Response: SeedReport{},
+		Errors: []apperr.Code{apperr.ValidationFailed, apperr.RequestTooLarge}},
+	{Method: http.MethodGet, Path: "/admin/loglevel", Tag: "admin", Summary: "Current log levels", Scope: ScopeAdmin, Status: http.StatusOK, Response: LogLevelResp{}},
+	{Method: http.MethodPut, Path: "/admin/loglevel", Tag: "admin", Summary: "Set the base or a component log level", Scope: ScopeAdmin, Request: SetLogLevelReq{}, Status: http.StatusOK, Response: LogLevelResp{},
+		Errors: []apperr.Code{apperr.ValidationFailed}},
+	{Method: http.MethodDelete, Path: "/admin/loglevel/:component", Tag: "admin", Summary: "Reset a component to the base log level", Scope: ScopeAdmin, Status: http.StatusOK, Response: LogLevelResp{}},
+	{Method: http.MethodPost, Path: "/admin/encryption/rotate", Tag: "admin", Summary: "Re-encrypt every post with the current key", Scope: ScopeAdmin, Status: http.StatusOK, Response: RotateKeysResp{}},
+}
+
+// undocumentedPrefixes are routes left out of the spec on purpose.
+// The /v1/ gateway is described by post.proto instead, /blog is HTML for
+// browsers and the ActivityPub routes follow their own specs.
+var undocumentedPrefixes = []string{"/.well-known/", "/admin/debug/", "/ap/", "/blog", "/docs/", "/openapi.json", "/v1/"}
+
+type openAPISpec struct {
+	schemas map[string]any
+	// request is set while describing a request body: only fields with a
+	// binding:"required" tag are required there, while response fields are
+	// required unless they are pointers or omitempty.
+	request bool
+}
+
+// NewOpenAPI builds an OpenAPI 3 document for the routes that are actually
+// registered, so optional routes only show up when enabled. Registered routes
+// missing from apiOperations are logged at startup.
+func NewOpenAPI(routes gin.RoutesInfo) map[string]any {
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = true
+	}
+	documented := make(map[string]bool, len(apiOperations))
+
+	spec := &openAPISpec{schemas: map[string]any{}}
+	spec.schema(reflect.TypeOf(httpapi.Problem{}))
+	spec.schema(reflect.TypeOf(PostEvent{}))
+
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+		if !registered[op.Method+" "+op.Path] {
+			continue
+		}
+		path := openAPIPath(op.Path)
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(op.Method)] = spec.operation(op)
+	}
+
+	for _, route := range routes {
+		if documented[route.Method+" "+route.Path] || slices.ContainsFunc(undocumentedPrefixes, func(p string) bool { return strings.HasPrefix(route.Path, p) }) {
+			continue
+		}
+		slog.Warn("route missing from the OpenAPI spec", "method", route.Method, "path", route.Path)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "gosolid",
+			"version": BuildVersion().Version,
+			"description": "Errors are RFC 9457 problem details with a code from GET /errors. " +
+				"Admin routes are also limited to the admin IP allow list. " +
+				"The /posts endpoints also speak JSON:API when sent Accept: application/vnd.api+json.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": spec.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// openAPIPath turns /posts/:id into /posts/{id}.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if name, ok := strings.CutPrefix(s, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (s *openAPISpec) operation(op apiOperation) map[string]any {
+	out := map[string]any{
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"operationId": strings.ToLower(op.Method) + strings.NewReplacer("/", "_", ":", "").Replace(op.Path),
+	}
+
+	var params []any
+	for _, segment := range strings.Split(op.Path, "/") {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			typ := "string"
+			if name == "id" {
+				typ = "integer"
+			}
+			params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": map[string]any{"type": typ}})
+		}
+	}
+	for _, p := range op.Params {
+		params = append(params, map[string]any{"name": p.Name, "in": p.In, "description": p.Description, "schema": map[string]any{"type": p.Type}})
+	}
+	if params != nil {
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		s.request = true
+		out["requestBody"] = map[string]any{"required": true, "content": s.content(op.Request)}
+		s.request = false
+	}
+
+	responses := map[string]any{}
+	success := map[string]any{"description": http.StatusText(op.Status)}
+	if raw, ok := op.Response.(rawBody); ok && raw.Description != "" {
+		success["description"] = raw.Description
+	}
+	if op.Response != nil {
+		if content := s.content(op.Response); content != nil {
+			success["content"] = content
+		}
+	}
+	responses[strconv.Itoa(op.Status)] = success
+
+	codes := slices.Clone(op.Errors)
+	if !op.Public {
+		out["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+		if op.Scope == "" {
+			out["description"] = "Requires a token with any scope."
+			codes = append(codes, apperr.Unauthenticated)
+		} else {
+			out["description"] = "Requires scope " + string(op.Scope) + "."
+			codes = append(codes, apperr.Unauthenticated, apperr.InsufficientScope)
+		}
+	}
+	codes = append(codes, apperr.Internal)
+	if !op.Public && !strings.HasPrefix(op.Path, "/admin/") {
+		codes = append(codes, apperr.Overloaded, apperr.Timeout)
+	}
+	byStatus := map[int][]string{}
+	for _, code := range codes {
+		byStatus[code.Status()] = append(byStatus[code.Status()], string(code))
+	}
+	for status, names := range byStatus {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": "Problem with code " + strings.Join(names, " or "),
+			"content": map[string]any{
+				"application/problem+json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Problem"}},
+			},
+		}
+	}
+	out["responses"] = responses
+	return out
+}
+
+func (s *openAPISpec) content(body any) map[string]any {
+	if raw, ok := body.(rawBody); ok {
+		if raw.ContentType == "" {
+			return nil
+		}
+		return map[string]any{raw.ContentType: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}
+	}
+	return map[string]any{"application/json": map[string]any{"schema": s.schema(reflect.TypeOf(body))}}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	codeType = reflect.TypeOf(apperr.Code(""))
+)
+
+// schema describes t, registering named structs under components/schemas.
+func (s *openAPISpec) schema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case codeType:
+		var codes []string
+		for _, def := range apperr.Catalog() {
+			codes = append(codes, string(def.Code))
+		}
+		return map[string]any{"type": "string", "enum": codes}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		if _, ok := s.schemas[t.Name()]; !ok {
+			s.schemas[t.Name()] = nil
+			s.schemas[t.Name()] = s.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (s *openAPISpec) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+
+		binding := field.Tag.Get("binding")
+		if strings.Contains(binding, "required") ||
+			(!s.request && field.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty")) {
+			required = append(required, name)
+		}
+		for _, rule := range strings.Split(binding, ",") {
+			if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+				properties[name].(map[string]any)["enum"] = strings.Fields(values)
+			}
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func OpenAPIHandler(spec map[string]any) func(*gin.Context) {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	}
+}
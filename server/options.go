@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Option configures a server built by NewServer.
+type Option func(*App)
+
+// WithConfig starts from cfg instead of DefaultConfig. It replaces the whole
+// config, so it goes before the options that change parts of it.
+func WithConfig(cfg Config) Option {
+	return func(a *App) { a.cfg = cfg }
+}
+
+// WithPort serves HTTP on port on all interfaces; 0 picks a free one, which
+// Addr reports after Start.
+func WithPort(port int) Option {
+	return func(a *App) { a.cfg.Addr = net.JoinHostPort("", strconv.Itoa(port)) }
+}
+
+// WithStorage serves posts from db instead of the configured backend. The
+// configured layers and encryption still wrap it, and the caller closes it.
+func WithStorage(db PostRepository) Option {
+	return func(a *App) { a.store = db }
+}
+
+// WithNotifiers adds notifiers told of every post change, alongside the
+// configured ones.
+func WithNotifiers(notifiers ...PostUpdateNotifier) Option {
+	return func(a *App) { a.extra = append(a.extra, notifiers...) }
+}
+
+// WithAuth authenticates API requests against tokens instead of a store
+// holding just ADMIN_TOKEN.
+func WithAuth(tokens *TokenStore) Option {
+	return func(a *App) { a.tokens = tokens }
+}
+
+// WithMiddleware runs middleware on every request, after the global
+// middleware the config lists.
+func WithMiddleware(middleware ...gin.HandlerFunc) Option {
+	return func(a *App) { a.middleware = append(a.middleware, middleware...) }
+}
+
+// NewServer assembles the server for embedding in another program: a few
+// options over DefaultConfig, then Start and Shutdown.
+//
+//	srv, err := server.NewServer(server.WithPort(8080), server.WithStorage(db))
+//	if err != nil { ... }
+//	if err := srv.Start(); err != nil { ... }
+//	defer srv.Shutdown(ctx)
+//
+// Reloads reapply the config it was built with, and unlike Run, it leaves
+// the process's signals alone.
+func NewServer(opts ...Option) (*App, error) {
+	a := &App{cfg: DefaultConfig(), startedAt: time.Now()}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if err := a.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg := a.cfg
+	a.load = func() (Config, error) { return cfg, nil }
+	if err := a.assemble(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/internal/clock"
+	"gosolid/internal/notify"
+)
+
+// PasswordResetMailer lets whoever holds the mailbox a principal is named
+// after replace its tokens when they are lost or leaked. There are no
+// passwords: the credentials are API tokens, and a reset revokes all of
+// them and issues one new token. Reset links are single use, expire after 30
+// minutes and are only kept hashed.
+//
+// Requests are throttled per client and per address, so the endpoint can't
+// be used to flood a mailbox or the mail provider.
+type PasswordResetMailer struct {
+	emailService notify.EmailService
+	tokens       *OneTimeTokens
+	accounts     *TokenStore
+	sender       string
+	resetURL     string
+	clock        clock.Clock
+	perClient    *Throttle
+	perEmail     *Throttle
+}
+
+// Reset requests let through per client address and per email address each
+// hour. The rest are answered like any other but send nothing.
+const (
+	resetsPerClient = 10
+	resetsPerEmail  = 3
+)
+
+func NewPasswordResetMailer(emailService notify.EmailService, accounts *TokenStore, sender, resetURL string) *PasswordResetMailer {
+	return &PasswordResetMailer{
+		emailService: emailService,
+		tokens:       NewOneTimeTokens(30 * time.Minute),
+		accounts:     accounts,
+		sender:       sender,
+		resetURL:     resetURL,
+		clock:        clock.System,
+		perClient:    NewThrottle(resetsPerClient, time.Hour),
+		perEmail:     NewThrottle(resetsPerEmail, time.Hour),
+	}
+}
+
+// SendReset mails a reset link when a principal is named email, and does
+// nothing otherwise so callers can't tell which addresses have tokens.
+// client is the requester's address; requests over either throttle do
+// nothing too.
+func (m *PasswordResetMailer) SendReset(ctx context.Context, email, client string) error {
+	now := m.clock.Now()
+	if !m.perClient.Allow(client, now) || !m.perEmail.Allow(email, now) {
+		return nil
+	}
+	if !m.accounts.Has(email) {
+		return nil
+	}
+	token, err := m.tokens.Issue(email, now)
+	if err != nil {
+		return err
+	}
+
+	link := m.resetURL + "?token=" + url.QueryEscape(token)
+	subject := "Reset your API tokens"
+	body := "Someone asked to reset the API tokens for this account.\n" +
+		"Use the link below within 30 minutes to get a new token; every\n" +
+		"token you have now will stop working:\n" +
+		link + "\n\n" +
+		"If this wasn't you, you can ignore this email."
+	return m.emailService.SendEmail(ctx, m.sender, email, subject, body)
+}
+
+// Reset consumes token, revokes every API token of the principal it was
+// sent to and issues one new token with the same scopes. The principal's
+// other reset links stop working too.
+func (m *PasswordResetMailer) Reset(token string) (IssueTokenResp, error) {
+	email, err := m.tokens.Consume(token, m.clock.Now())
+	if err != nil {
+		return IssueTokenResp{}, err
+	}
+	m.tokens.RevokeSubject(email)
+
+	// An admin may have revoked the principal since the link was sent.
+	principal, ok := m.accounts.RevokeName(email)
+	if !ok {
+		return IssueTokenResp{}, ErrOneTimeTokenInvalid
+	}
+	issued, err := m.accounts.Issue(principal)
+	if err != nil {
+		return IssueTokenResp{}, err
+	}
+	resp := IssueTokenResp{Name: principal.Name, Token: issued}
+	for _, scope := range principal.Scopes {
+		resp.Scopes = append(resp.Scopes, string(scope))
+	}
+	slices.Sort(resp.Scopes)
+	return resp, nil
+}
+
+type RequestResetReq struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ConfirmResetReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RequestResetHandler answers 202 whether or not a link was sent, throttled
+// or not.
+func RequestResetHandler(m *PasswordResetMailer) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var req RequestResetReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		if err := m.SendReset(c.Request.Context(), req.Email, c.ClientIP()); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func ConfirmResetHandler(m *PasswordResetMailer) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var req ConfirmResetReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		resp, err := m.Reset(req.Token)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/clock"
+)
+
+// outbox is an EmailService that keeps what it sends.
+type outbox struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+type sentEmail struct {
+	recipient, subject, body string
+}
+
+func (o *outbox) SendEmail(ctx context.Context, sender, recipient, subject, body string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, sentEmail{recipient, subject, body})
+	return nil
+}
+
+// linkToken is the token parameter of the last link mailed.
+func (o *outbox) linkToken(t *testing.T) string {
+	t.Helper()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	for _, field := range strings.Fields(o.sent[len(o.sent)-1].body) {
+		if u, err := url.Parse(field); err == nil && u.Query().Has("token") {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatal("no link in the email")
+	return ""
+}
+
+const (
+	resetEmail = "ada@example.com"
+	testClient = "192.0.2.1"
+)
+
+func newTestResetMailer(t *testing.T) (*PasswordResetMailer, *TokenStore, *outbox, *clock.Fake) {
+	t.Helper()
+	tokens := NewTokenStore()
+	tokens.Add("laptop", Principal{Name: resetEmail, Scopes: []Scope{ScopePostsRead}})
+	tokens.Add("ci", Principal{Name: resetEmail, Scopes: []Scope{ScopePostsWrite}})
+	tokens.Add("other", Principal{Name: "grace@example.com", Scopes: []Scope{ScopePostsRead}})
+	mail := &outbox{}
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := NewPasswordResetMailer(mail, tokens, "noreply@example.com", "https://example.com/reset")
+	m.clock = fake
+	return m, tokens, mail, fake
+}
+
+func TestPasswordResetRevokesTokens(t *testing.T) {
+	m, tokens, mail, _ := newTestResetMailer(t)
+	if err := m.SendReset(context.Background(), resetEmail, testClient); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := m.Reset(mail.linkToken(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, old := range []string{"laptop", "ci"} {
+		if _, ok := tokens.Lookup(old); ok {
+			t.Errorf("token %q still works after the reset", old)
+		}
+	}
+	if _, ok := tokens.Lookup("other"); !ok {
+		t.Error("another principal's token was revoked")
+	}
+	principal, ok := tokens.Lookup(resp.Token)
+	if !ok {
+		t.Fatal("the new token doesn't work")
+	}
+	if !principal.HasScope(ScopePostsRead) || !principal.HasScope(ScopePostsWrite) || principal.Name != resetEmail {
+		t.Errorf("new token principal = %+v, want %s with both scopes", principal, resetEmail)
+	}
+}
+
+func TestPasswordResetSingleUse(t *testing.T) {
+	m, _, mail, _ := newTestResetMailer(t)
+	ctx := context.Background()
+	if err := m.SendReset(ctx, resetEmail, testClient); err != nil {
+		t.Fatal(err)
+	}
+	first := mail.linkToken(t)
+	if err := m.SendReset(ctx, resetEmail, testClient); err != nil {
+		t.Fatal(err)
+	}
+	second := mail.linkToken(t)
+
+	if _, err := m.Reset(second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reset(second); !errors.Is(err, ErrOneTimeTokenInvalid) {
+		t.Errorf("reusing a link: err = %v, want %v", err, ErrOneTimeTokenInvalid)
+	}
+	if _, err := m.Reset(first); !errors.Is(err, ErrOneTimeTokenInvalid) {
+		t.Errorf("an older link after a reset: err = %v, want %v", err, ErrOneTimeTokenInvalid)
+	}
+}
+
+func TestPasswordResetExpires(t *testing.T) {
+	m, tokens, mail, fake := newTestResetMailer(t)
+	if err := m.SendReset(context.Background(), resetEmail, testClient); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(30*time.Minute + time.Second)
+	if _, err := m.Reset(mail.linkToken(t)); !errors.Is(err, ErrOneTimeTokenExpired) {
+		t.Errorf("expired link: err = %v, want %v", err, ErrOneTimeTokenExpired)
+	}
+	if _, ok := tokens.Lookup("laptop"); !ok {
+		t.Error("an expired link revoked the tokens")
+	}
+}
+
+func TestPasswordResetRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, tokens, mail, _ := newTestResetMailer(t)
+	e := gin.New()
+	e.POST("/auth/reset", RequestResetHandler(m))
+	e.POST("/auth/reset/confirm", ConfirmResetHandler(m))
+	post := func(path string, body any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// Unknown addresses get the same answer and no email.
+	if w := post("/auth/reset", RequestResetReq{Email: "nobody@example.com"}); w.Code != http.StatusAccepted {
+		t.Fatalf("unknown address: status %d, want 202", w.Code)
+	}
+	if len(mail.sent) != 0 {
+		t.Fatalf("sent %d emails for an unknown address", len(mail.sent))
+	}
+
+	if w := post("/auth/reset", RequestResetReq{Email: resetEmail}); w.Code != http.StatusAccepted {
+		t.Fatalf("request: status %d, want 202", w.Code)
+	}
+	token := mail.linkToken(t)
+	w := post("/auth/reset/confirm", ConfirmResetReq{Token: token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirm: status %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp IssueTokenResp
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tokens.Lookup(resp.Token); !ok {
+		t.Error("the token from confirm doesn't work")
+	}
+	if w := post("/auth/reset/confirm", ConfirmResetReq{Token: token}); w.Code != http.StatusGone {
+		t.Errorf("second confirm: status %d, want 410", w.Code)
+	}
+}
+
+func TestPasswordResetThrottles(t *testing.T) {
+	m, _, mail, fake := newTestResetMailer(t)
+	ctx := context.Background()
+	sent := func() int {
+		mail.mu.Lock()
+		defer mail.mu.Unlock()
+		return len(mail.sent)
+	}
+
+	for range resetsPerEmail + 2 {
+		if err := m.SendReset(ctx, resetEmail, testClient); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := sent(); got != resetsPerEmail {
+		t.Fatalf("sent %d emails, want %d for one address", got, resetsPerEmail)
+	}
+	// Another client doesn't get past the address's limit either.
+	if err := m.SendReset(ctx, resetEmail, "198.51.100.7"); err != nil || sent() != resetsPerEmail {
+		t.Errorf("another client: err %v with %d emails, want no new email", err, sent())
+	}
+
+	fake.Advance(time.Hour)
+	// Unknown addresses count towards the client's limit, so one client
+	// can't probe or mail without end.
+	for i := range resetsPerClient {
+		if err := m.SendReset(ctx, fmt.Sprintf("nobody%d@example.com", i), testClient); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.SendReset(ctx, resetEmail, testClient); err != nil || sent() != resetsPerEmail {
+		t.Errorf("client over its limit: err %v with %d emails, want no new email", err, sent())
+	}
+	if err := m.SendReset(ctx, resetEmail, "198.51.100.7"); err != nil || sent() != resetsPerEmail+1 {
+		t.Errorf("another client after an hour: err %v with %d emails, want one new email", err, sent())
+	}
+}
+
+func TestOneTimeTokensSweepExpired(t *testing.T) {
+	tokens := NewOneTimeTokens(time.Minute)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 100 {
+		if _, err := tokens.Issue(resetEmail, now.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokens.mu.Lock()
+	defer tokens.mu.Unlock()
+	if n := len(tokens.tokens); n > 2 {
+		t.Errorf("%d tokens kept, want only the unexpired ones", n)
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+)
+
+// NotifierConstructor builds a notifier notifiers.plugins turns on, from
+// the settings it lists there. A notifier that is also an io.Closer or has
+// Close(ctx) is closed on shutdown.
+type NotifierConstructor func(settings map[string]string, secrets SecretsProvider) (PostUpdateNotifier, error)
+
+// notifierPlugins is filled by RegisterNotifier.
+var notifierPlugins = map[string]NotifierConstructor{}
+
+// RegisterNotifier makes a notifier available to notifiers.plugins under
+// name, with the same rules as storage.Register.
+func RegisterNotifier(name string, constructor NotifierConstructor) {
+	if _, ok := notifierPlugins[name]; ok {
+		panic(fmt.Sprintf("notifier %q registered twice", name))
+	}
+	notifierPlugins[name] = constructor
+}
+
+func notifierPluginNames() []string {
+	names := make([]string, 0, len(notifierPlugins))
+	for name := range notifierPlugins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+
+	"gosolid/internal/httpapi"
+	"gosolid/internal/storage"
+)
+
+// TestPostRoutesOnEachRouter runs the post CRUD routes on every router
+// httpapi mounts on.
+func TestPostRoutesOnEachRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routers := map[string]func([]httpapi.Route) http.Handler{
+		"gin": func(rs []httpapi.Route) http.Handler {
+			e := gin.New()
+			httpapi.MountGin(e, rs)
+			return e
+		},
+		"chi": func(rs []httpapi.Route) http.Handler {
+			r := chi.NewRouter()
+			httpapi.MountChi(r, rs)
+			return r
+		},
+		"servemux": func(rs []httpapi.Route) http.Handler {
+			mux := http.NewServeMux()
+			httpapi.MountServeMux(mux, rs)
+			return mux
+		},
+	}
+	for name, mount := range routers {
+		t.Run(name, func(t *testing.T) {
+			h := mount(PostRoutes(NewPostService(storage.NewDB(), NewFeatureFlags(nil))))
+			do := func(method, target, body string) *httptest.ResponseRecorder {
+				t.Helper()
+				req := httptest.NewRequest(method, target, strings.NewReader(body))
+				if body != "" {
+					req.Header.Set("Content-Type", "application/json")
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, req)
+				return w
+			}
+
+			for _, step := range []struct {
+				method, target, body string
+				status               int
+				want                 string
+			}{
+				{http.MethodPost, "/posts", `{"title":"first","body":"hello"}`, http.StatusOK, `"id":1`},
+				{http.MethodPost, "/posts", `{"title":`, http.StatusBadRequest, "VALIDATION_FAILED"},
+				{http.MethodGet, "/posts/1", "", http.StatusOK, `"title":"first"`},
+				{http.MethodGet, "/posts?view=summary", "", http.StatusOK, `"excerpt":"hello"`},
+				{http.MethodGet, "/posts/one", "", http.StatusBadRequest, "post id must be an integer"},
+				{http.MethodPatch, "/posts/1", `{"title":"renamed"}`, http.StatusOK, `"title":"renamed"`},
+				{http.MethodGet, "/posts?limit=10", "", http.StatusOK, `[{"id":1,"title":"renamed"`},
+				{http.MethodDelete, "/posts/1", "", http.StatusNoContent, ""},
+				{http.MethodGet, "/posts/1", "", http.StatusNotFound, "POST_NOT_FOUND"},
+			} {
+				w := do(step.method, step.target, step.body)
+				if w.Code != step.status || !strings.Contains(w.Body.String(), step.want) {
+					t.Errorf("%s %s: status %d, body %s; want %d with %s", step.method, step.target, w.Code, w.Body, step.status, step.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package server is the gosolid API server: the HTTP and gRPC APIs over the
+// configured storage backend, and the notifiers, mailers and background jobs
+// around them. cmd/server runs it from the config; NewServer embeds it in
+// another program.
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/client"
+	"gosolid/internal/httpapi"
+	"gosolid/internal/mapper"
+)
+
+func postIDParam(c *gin.Context) (int, bool) {
+	return idParam(c, "post")
+}
+
+// pageParams reads ?limit=&offset=, or JSON:API's page[limit] and
+// page[offset]. A missing limit means no limit.
+func pageParams(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	query := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &limit}, {"offset", &offset}} {
+		v := query.Get(p.name)
+		if v == "" {
+			v = query.Get("page[" + p.name + "]")
+		}
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeProblem(w, r, apperr.ValidationFailed, p.name+" must be a non-negative integer")
+			return 0, 0, false
+		}
+		*p.dst = n
+	}
+	return limit, offset, true
+}
+
+// summaryExcerptLen is how many runes of each body view=summary keeps.
+const summaryExcerptLen = 280
+
+// listOptionsParams reads a list's paging, ?sort=, ?after=, ?view= and
+// filters into ListOptions, as GET /posts takes them.
+func listOptionsParams(w http.ResponseWriter, r *http.Request) (ListOptions, bool) {
+	limit, offset, ok := pageParams(w, r)
+	if !ok {
+		return ListOptions{}, false
+	}
+	query := r.URL.Query()
+	opts := ListOptions{Limit: limit, Offset: offset, Sort: ListSort(query.Get("sort")), Query: query.Get("q")}
+	switch query.Get("view") {
+	case "", "full":
+	case "summary":
+		opts.Excerpt = summaryExcerptLen
+	default:
+		writeProblem(w, r, apperr.ValidationFailed, "view must be full or summary")
+		return ListOptions{}, false
+	}
+	if !opts.Sort.Valid() {
+		writeProblem(w, r, apperr.ValidationFailed, "sort must be one of id, created_at, updated_at, title, optionally prefixed with -")
+		return ListOptions{}, false
+	}
+	if raw := query.Get("after"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeProblem(w, r, apperr.ValidationFailed, "after must be a post id")
+			return ListOptions{}, false
+		}
+		if !opts.Sort.ByID() {
+			writeProblem(w, r, apperr.ValidationFailed, "after only works with sort=id or sort=-id")
+			return ListOptions{}, false
+		}
+		opts.After = n
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"created_after", &opts.CreatedAfter}, {"updated_after", &opts.UpdatedAfter}} {
+		if raw := query.Get(p.name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeProblem(w, r, apperr.ValidationFailed, p.name+" must be an RFC 3339 timestamp")
+				return ListOptions{}, false
+			}
+			*p.dst = t
+		}
+	}
+	return opts, true
+}
+
+func valueOrZero[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
+}
+
+// PostResource is the post endpoints as a Resource. Plain JSON reads come
+// from the serialized bodies and lists stream; the other formats are
+// rendered from posts.
+func PostResource(posts interface {
+	CreatePost(ctx context.Context, title, body string) (Post, error)
+	GetPost(ctx context.Context, id int) (Post, error)
+	GetPostBody(ctx context.Context, id int) (PostBody, error)
+	ListPostPage(ctx context.Context, opts ListOptions) ([]Post, int, error)
+	UpdatePost(ctx context.Context, id int, title, body *string) (Post, error)
+	DeletePost(ctx context.Context, id int) error
+}) Resource[Post, client.NewPostReq, client.UpdatePostReq, client.GetPostResp] {
+	return Resource[Post, client.NewPostReq, client.UpdatePostReq, client.GetPostResp]{
+		Name: "post",
+		Create: func(ctx context.Context, req client.NewPostReq) (Post, error) {
+			post := mapper.FromNewPostReq(req)
+			return posts.CreatePost(ctx, post.Title, post.Body)
+		},
+		Get: posts.GetPost,
+		Stored: func(ctx context.Context, id int) (StoredJSON, error) {
+			body, err := posts.GetPostBody(ctx, id)
+			return StoredJSON{JSON: body.JSON, ETag: body.ETag, ModTime: body.Post.UpdatedAt}, err
+		},
+		List: posts.ListPostPage,
+		Update: func(ctx context.Context, id int, req client.UpdatePostReq) (Post, error) {
+			return posts.UpdatePost(ctx, id, req.Title, req.Body)
+		},
+		Delete:    posts.DeletePost,
+		ToResp:    mapper.ToPostResp[client.GetPostResp],
+		ToSummary: func(post Post) any { return mapper.ToPostSummary(post) },
+		ID:        func(post Post) int { return post.ID },
+		Render:    renderPost,
+		RenderList: func(w http.ResponseWriter, r *http.Request, page []Post, total int, opts ListOptions) {
+			if opts.Excerpt > 0 {
+				renderPostSummaries(w, r, page, total, opts.Limit, opts.Offset)
+			} else {
+				renderPostList(w, r, page, total, opts.Limit, opts.Offset)
+			}
+		},
+	}
+}
+
+// PostRoutes are the post CRUD endpoints as httpapi routes, so any router
+// httpapi mounts on can serve them. Scopes, UIDs in place of IDs and the
+// formats other than plain JSON come from gin middleware, which the server
+// mounts each route behind.
+func PostRoutes(posts PostUseCases) []httpapi.Route {
+	return PostResource(posts).Routes("/posts")
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+
+	"gosolid/internal/storage"
+)
+
+// PostQueries are the post use cases that only read. Caches and the body
+// cache are layers of the repository they read, and projections such as
+// excerpts are ListOptions the repository answers, so reads can be tuned
+// without touching the write path.
+type PostQueries struct {
+	db     PostRepository
+	search PostSearcher
+}
+
+// NewPostQueries reads db and searches with search, or with db's Query
+// filter when search is nil.
+func NewPostQueries(db PostRepository, search PostSearcher) *PostQueries {
+	q := &PostQueries{db: db, search: search}
+	if q.search == nil {
+		q.search = NewBuiltinSearch(q)
+	}
+	return q
+}
+
+func (s *PostQueries) GetPost(ctx context.Context, id int) (Post, error) {
+	return s.db.GetPostByID(ctx, id)
+}
+
+// PostIDByUID finds the post a UUIDv7 or ULID generator gave uid.
+func (s *PostQueries) PostIDByUID(ctx context.Context, uid string) (int, error) {
+	resolver, ok := storage.Unwrap[storage.PostUIDResolver](s.db)
+	if !ok {
+		return 0, storage.ErrUIDsUnsupported
+	}
+	return resolver.PostIDByUID(ctx, uid)
+}
+
+// GetPostBody returns post id serialized as plain JSON, from the body cache
+// when the repository has one.
+func (s *PostQueries) GetPostBody(ctx context.Context, id int) (PostBody, error) {
+	if bodies, ok := storage.Unwrap[*PostBodyCache](s.db); ok {
+		return bodies.GetPostBody(ctx, id)
+	}
+	post, err := s.db.GetPostByID(ctx, id)
+	if err != nil {
+		return PostBody{}, err
+	}
+	return newPostBody(post)
+}
+
+func (s *PostQueries) ListPosts(ctx context.Context) ([]Post, error) {
+	posts, _, err := s.db.ListPosts(ctx, ListOptions{})
+	return posts, err
+}
+
+// ListPostPage returns the page opts selects and the number of posts
+// matching its filters, leaving the paging to the repository.
+func (s *PostQueries) ListPostPage(ctx context.Context, opts ListOptions) ([]Post, int, error) {
+	return s.db.ListPosts(ctx, opts)
+}
+
+// EachPost calls fn for every post in ID order without loading them all.
+func (s *PostQueries) EachPost(ctx context.Context, fn func(Post) error) error {
+	return s.db.EachPost(ctx, fn)
+}
+
+// SearchPosts returns one page of the posts matching q and the number of
+// matches.
+func (s *PostQueries) SearchPosts(ctx context.Context, q string, limit, offset int) ([]Post, int, error) {
+	return s.search.SearchPosts(ctx, q, limit, offset)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+
+	"gosolid/apperr"
+	"gosolid/internal/httpapi"
+)
+
+type ErrorReport struct {
+	Error     string    `json:"error"`
+	Panic     bool      `json:"panic"`
+	Stack     string    `json:"stack,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Route     string    `json:"route"`
+	Principal string    `json:"principal,omitempty"`
+	Status    int       `json:"status"`
+	Time      time.Time `json:"time"`
+}
+
+type ErrorReporter interface {
+	Report(ctx context.Context, report ErrorReport)
+}
+
+type LogErrorReporter struct{}
+
+func (LogErrorReporter) Report(ctx context.Context, report ErrorReport) {
+	slog.ErrorContext(ctx, "unhandled error",
+		"error", report.Error,
+		"panic", report.Panic,
+		"route", report.Route,
+		"status", report.Status,
+		"stack", report.Stack,
+	)
+}
+
+// WebhookErrorReporter posts reports as JSON to an external collector. It
+// sends in the background so a slow collector can't hold up the response.
+type WebhookErrorReporter struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookErrorReporter(url string) *WebhookErrorReporter {
+	return &WebhookErrorReporter{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (r *WebhookErrorReporter) Report(ctx context.Context, report ErrorReport) {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	go func() {
+		resp, err := r.client.Post(r.url, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			slog.Warn("send error report", "error", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+type MultiErrorReporter []ErrorReporter
+
+func (m MultiErrorReporter) Report(ctx context.Context, report ErrorReport) {
+	for _, r := range m {
+		r.Report(ctx, report)
+	}
+}
+
+func abortWithProblem(c *gin.Context, code apperr.Code, detail string) {
+	c.Abort()
+	writeProblem(c.Writer, c.Request, code, detail)
+}
+
+// writeProblem is abortWithProblem for handlers written against net/http:
+// a JSON:API error when that was negotiated, problem+json otherwise.
+func writeProblem(w http.ResponseWriter, r *http.Request, code apperr.Code, detail string) {
+	if wantsJSONAPI(r) {
+		writeJSONAPIError(w, r, code, detail)
+		return
+	}
+	httpapi.WriteProblem(w, r, code, detail)
+}
+
+// writeError is abortWithError for handlers written against net/http.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	httpapi.ReportError(r, err)
+	appErr := apperr.From(err)
+	writeProblem(w, r, appErr.Code, appErr.Detail)
+}
+
+// writeRender is c.Render for handlers written against net/http.
+func writeRender(w http.ResponseWriter, r *http.Request, status int, v render.Render) {
+	v.WriteContentType(w)
+	w.WriteHeader(status)
+	if err := v.Render(w); err != nil {
+		httpapi.ReportError(r, err)
+	}
+}
+
+// abortWithError answers with the problem apperr.From derives from err and
+// keeps err on the context for logging and error reporting.
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	answerError(c, err)
+}
+
+func answerError(c *gin.Context, err error) {
+	appErr := apperr.From(err)
+	abortWithProblem(c, appErr.Code, appErr.Detail)
+}
+
+// ProblemMiddleware answers requests that end with an error on the context
+// and nothing written, so a handler or middleware can c.Error(err) and
+// return, leaving the status and body to apperr.From. Anything behind it
+// that aborts this way gets the same problem+json or JSON:API error as
+// abortWithError gives.
+func ProblemMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if err := c.Errors.Last(); err != nil && !c.Writer.Written() {
+			answerError(c, err.Err)
+		}
+	}
+}
+
+func ErrorCatalogHandler() http.Handler {
+	catalog := apperr.Catalog()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpapi.WriteJSON(w, http.StatusOK, catalog)
+	})
+}
+
+func newErrorReport(c *gin.Context, err string, status int) ErrorReport {
+	report := ErrorReport{
+		Error:     err,
+		RequestID: httpapi.RequestIDFromContext(c.Request.Context()),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Route:     c.FullPath(),
+		Status:    status,
+		Time:      time.Now(),
+	}
+	if principal, ok := PrincipalFromContext(c); ok {
+		report.Principal = principal.Name
+	}
+	return report
+}
+
+// RecoveryMiddleware turns panics into a problem+json 500 and reports both
+// panics and errors that handlers attached to 5xx responses.
+func RecoveryMiddleware(reporter ErrorReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			report := newErrorReport(c, fmt.Sprint(rec), http.StatusInternalServerError)
+			report.Panic = true
+			report.Stack = string(debug.Stack())
+			reporter.Report(c.Request.Context(), report)
+
+			if !c.Writer.Written() {
+				abortWithProblem(c, apperr.Internal, "")
+			}
+		}()
+
+		c.Next()
+
+		if status := c.Writer.Status(); status >= 500 {
+			for _, err := range c.Errors {
+				reporter.Report(c.Request.Context(), newErrorReport(c, err.Error(), status))
+			}
+		}
+	}
+}
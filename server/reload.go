@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+type configApplier struct {
+	name  string
+	apply func(Config) error
+}
+
+// ConfigReloader re-reads the configuration with load and hands it to the
+// registered appliers. A config that fails validation is
+// rejected outright; if an applier fails, the ones that already ran get the
+// previous config back. Settings without an applier need a restart.
+type ConfigReloader struct {
+	load func() (Config, error)
+
+	mu       sync.Mutex
+	current  Config
+	appliers []configApplier
+}
+
+func NewConfigReloader(load func() (Config, error), current Config) *ConfigReloader {
+	return &ConfigReloader{load: load, current: current}
+}
+
+func (r *ConfigReloader) OnReload(name string, apply func(Config) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.appliers = append(r.appliers, configApplier{name: name, apply: apply})
+}
+
+func (r *ConfigReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	for i, a := range r.appliers {
+		if err := a.apply(cfg); err != nil {
+			for _, applied := range r.appliers[:i] {
+				if err := applied.apply(r.current); err != nil {
+					slog.With("component", "config").Error("roll back config", "setting", applied.name, "error", err)
+				}
+			}
+			return fmt.Errorf("apply %s config: %w", a.name, err)
+		}
+	}
+	r.current = cfg
+	slog.With("component", "config").Info("config reloaded", "config", cfg)
+	return nil
+}
+
+// WatchSignals reloads on every SIGHUP until ctx is done.
+func (r *ConfigReloader) WatchSignals(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			if err := r.Reload(); err != nil {
+				slog.With("component", "config").Error("reload config", "error", err)
+			}
+		}
+	}
+}
+
+func ReloadConfigHandler(reloader *ConfigReloader) func(*gin.Context) {
+	return func(c *gin.Context) {
+		if err := reloader.Reload(); err != nil {
+			abortWithError(c, apperr.Wrap(apperr.InvalidConfig, err))
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
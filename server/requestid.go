@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"gosolid/internal/httpapi"
+)
+
+// requestIDLogHandler adds request_id to every record logged with a context
+// that carries one.
+type requestIDLogHandler struct {
+	slog.Handler
+}
+
+func (h requestIDLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := httpapi.RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDLogHandler) WithGroup(name string) slog.Handler {
+	return requestIDLogHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"gosolid/internal/clock"
+)
+
+var responseCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_response_cache_requests_total",
+	Help: "Cacheable GET requests by result: hit or miss.",
+}, []string{"route", "result"})
+
+// responseCacheMaxBody keeps a huge list from being copied into the cache.
+const responseCacheMaxBody = 1 << 20
+
+// responseCacheHeaders are the response headers worth replaying; the rest,
+// like X-Request-Id, belong to the request that filled the entry.
+var responseCacheHeaders = []string{"Content-Type", "X-Total-Count", "Link", "Last-Modified", "ETag"}
+
+// responseCacheRoutes are the cacheable routes and the scope each requires.
+// The cache is in front of the route's own RequireScope, so it checks the
+// scope itself before serving a hit.
+var responseCacheRoutes = map[string]Scope{"/posts": ScopePostsRead, "/posts/:id": ScopePostsRead}
+
+type cachedResponse struct {
+	key    string
+	postID string // "" for lists, which any write invalidates
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+// ResponseCache caches the rendered bodies of GET /posts and GET
+// /posts/:id, keyed by URL and Accept, the only header the renderers vary
+// on. Entries are dropped when an event for their post arrives on the event
+// bus, which also covers writes that don't come through HTTP, and
+// after any write request through the API, so its client can read its own
+// write. Responses are marked private because they need a token.
+type ResponseCache struct {
+	size   int
+	ttl    time.Duration
+	maxAge int
+	events *EventBus
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used; values are *cachedResponse
+	entries map[string]*list.Element
+	gen     uint64
+}
+
+func NewResponseCache(cfg HTTPCacheConfig, events *EventBus) *ResponseCache {
+	return &ResponseCache{
+		size:    cfg.Size,
+		ttl:     cfg.TTL.Duration,
+		maxAge:  int(cfg.MaxAge.Seconds()),
+		events:  events,
+		clock:   clock.System,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Run applies invalidations from the event bus until ctx is done. If the
+// bus drops the subscription for falling behind, everything is purged,
+// since events were missed, and it subscribes again.
+func (rc *ResponseCache) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		_, ch, unsubscribe := rc.events.Subscribe(EventFilter{}, 0)
+		rc.purge("")
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				unsubscribe()
+				return
+			case event, ok := <-ch:
+				if !ok {
+					break loop
+				}
+				rc.purge(strconv.Itoa(event.Post.ID))
+			}
+		}
+	}
+}
+
+// purge drops the lists and the entries of postID, or everything when
+// postID is "".
+func (rc *ResponseCache) purge(postID string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.gen++
+	for key, elem := range rc.entries {
+		entry := elem.Value.(*cachedResponse)
+		if postID == "" || entry.postID == "" || entry.postID == postID {
+			rc.order.Remove(elem)
+			delete(rc.entries, key)
+		}
+	}
+}
+
+func (rc *ResponseCache) get(key string) (*cachedResponse, uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	elem, ok := rc.entries[key]
+	if !ok {
+		return nil, rc.gen
+	}
+	entry := elem.Value.(*cachedResponse)
+	if rc.clock.Now().Sub(entry.stored) > rc.ttl {
+		rc.order.Remove(elem)
+		delete(rc.entries, key)
+		return nil, rc.gen
+	}
+	rc.order.MoveToFront(elem)
+	return entry, rc.gen
+}
+
+// put stores entry unless something was purged since gen was read, in
+// which case the response may predate the write that caused it.
+func (rc *ResponseCache) put(entry *cachedResponse, gen uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.gen != gen {
+		return
+	}
+	if elem, ok := rc.entries[entry.key]; ok {
+		rc.order.Remove(elem)
+	}
+	rc.entries[entry.key] = rc.order.PushFront(entry)
+	for rc.order.Len() > rc.size {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+type cacheRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *cacheRecorder) Write(b []byte) (int, error) {
+	if w.body.Len() <= responseCacheMaxBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheRecorder) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Middleware serves and fills the cache for the cacheable routes and
+// purges after writes. Hits honour conditional requests; requests for a
+// range bypass the cache. It belongs after authentication: it runs before the
+// route's handlers, scope check included, so it only serves callers whose
+// principal has the route's scope and leaves everyone else to the route.
+func (rc *ResponseCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			if c.Writer.Status() < 300 && strings.HasPrefix(route, "/posts") {
+				rc.purge(c.Param("id"))
+			}
+			return
+		}
+		scope, ok := responseCacheRoutes[route]
+		if !ok {
+			c.Next()
+			return
+		}
+		if principal, ok := PrincipalFromContext(c); !ok || !principal.HasScope(scope) {
+			c.Next()
+			return
+		}
+		// A range is left to the route, which knows whether it serves one.
+		if c.GetHeader("Range") != "" {
+			c.Next()
+			return
+		}
+
+		c.Header("Cache-Control", "private, max-age="+strconv.Itoa(rc.maxAge))
+		c.Header("Vary", "Accept")
+		key := c.Request.URL.RequestURI() + "\x00" + c.GetHeader("Accept")
+		entry, gen := rc.get(key)
+		if entry != nil {
+			responseCacheRequestsTotal.WithLabelValues(route, "hit").Inc()
+			for name, values := range entry.header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Age", strconv.Itoa(int(rc.clock.Now().Sub(entry.stored).Seconds())))
+			c.Header("X-Cache", "HIT")
+			// ServeContent answers If-None-Match against the cached ETag and
+			// If-Modified-Since against Last-Modified with a 304, as the
+			// route would have.
+			modTime, _ := http.ParseTime(entry.header.Get("Last-Modified"))
+			http.ServeContent(c.Writer, c.Request, "", modTime, bytes.NewReader(entry.body))
+			c.Abort()
+			return
+		}
+		responseCacheRequestsTotal.WithLabelValues(route, "miss").Inc()
+		c.Header("X-Cache", "MISS")
+
+		recorder := &cacheRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		if !c.Writer.Written() || c.Writer.Status() != http.StatusOK || recorder.body.Len() > responseCacheMaxBody {
+			return
+		}
+		header := http.Header{}
+		for _, name := range responseCacheHeaders {
+			if values := c.Writer.Header().Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		rc.put(&cachedResponse{
+			key:    key,
+			postID: c.Param("id"),
+			header: header,
+			body:   recorder.body.Bytes(),
+			stored: rc.clock.Now(),
+		}, gen)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/clock"
+)
+
+func TestResponseCacheChecksScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := NewTokenStore()
+	tokens.Add("reader", Principal{Name: "reader", Scopes: []Scope{ScopePostsRead}})
+	tokens.Add("writer", Principal{Name: "writer", Scopes: []Scope{ScopePostsWrite}})
+	rc := NewResponseCache(HTTPCacheConfig{Size: 10, TTL: Duration{Duration: time.Minute}}, nil)
+
+	e := gin.New()
+	api := e.Group("", AuthMiddleware(tokens), rc.Middleware())
+	api.GET("/posts/:id", RequireScope(ScopePostsRead), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	for _, want := range []string{"MISS", "HIT"} {
+		w := serve(e, http.MethodGet, "/posts/1", "reader", nil)
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != want {
+			t.Fatalf("reader: status %d, X-Cache %q; want 200 and %s", w.Code, w.Header().Get("X-Cache"), want)
+		}
+	}
+	w := serve(e, http.MethodGet, "/posts/1", "writer", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("write-only token on a cached post: status %d, want 403", w.Code)
+	}
+	if got := w.Header().Get("X-Cache"); got != "" {
+		t.Errorf("write-only token: X-Cache %q, want none", got)
+	}
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := NewTokenStore()
+	tokens.Add("reader", Principal{Name: "reader", Scopes: []Scope{ScopePostsRead}})
+	rc := NewResponseCache(HTTPCacheConfig{Size: 10, TTL: Duration{Duration: time.Minute}}, nil)
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	rc.clock = fake
+
+	e := gin.New()
+	api := e.Group("", AuthMiddleware(tokens), rc.Middleware())
+	api.GET("/posts/:id", RequireScope(ScopePostsRead), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	steps := []struct {
+		advance time.Duration
+		cache   string
+		age     string
+	}{
+		{0, "MISS", ""},
+		{40 * time.Second, "HIT", "40"},
+		{20 * time.Second, "HIT", "60"},
+		{time.Second, "MISS", ""},
+	}
+	for i, step := range steps {
+		fake.Advance(step.advance)
+		w := serve(e, http.MethodGet, "/posts/1", "reader", nil)
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != step.cache || w.Header().Get("Age") != step.age {
+			t.Errorf("step %d: status %d, X-Cache %q, Age %q; want 200, %s and %q",
+				i, w.Code, w.Header().Get("X-Cache"), w.Header().Get("Age"), step.cache, step.age)
+		}
+	}
+}
+
+func TestResponseCacheConditionalHit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := NewTokenStore()
+	tokens.Add("reader", Principal{Name: "reader", Scopes: []Scope{ScopePostsRead}})
+	rc := NewResponseCache(HTTPCacheConfig{Size: 10, TTL: Duration{Duration: time.Minute}}, nil)
+
+	e := gin.New()
+	api := e.Group("", AuthMiddleware(tokens), rc.Middleware())
+	api.GET("/posts/:id", RequireScope(ScopePostsRead), func(c *gin.Context) {
+		c.Header("ETag", `"v1"`)
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+		req.Header.Set("Authorization", "Bearer reader")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+	if w := get("", ""); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first read: X-Cache %q, want MISS", w.Header().Get("X-Cache"))
+	}
+
+	w := get("If-None-Match", `"v1"`)
+	if w.Code != http.StatusNotModified || w.Header().Get("X-Cache") != "HIT" || w.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match: status %d, X-Cache %q, body %q; want an empty 304 HIT",
+			w.Code, w.Header().Get("X-Cache"), w.Body)
+	}
+	w = get("If-None-Match", `"v0"`)
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "HIT" || w.Body.String() != `{"id":"1"}` {
+		t.Errorf("stale If-None-Match: status %d, X-Cache %q, body %q; want the cached 200",
+			w.Code, w.Header().Get("X-Cache"), w.Body)
+	}
+	if w := get("Range", "bytes=0-1"); w.Header().Get("X-Cache") == "HIT" {
+		t.Errorf("range request was served from the cache")
+	}
+}
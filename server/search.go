@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+// PostSearcher returns one page of the posts matching q and the total number
+// of matches. A zero limit returns every match.
+type PostSearcher interface {
+	SearchPosts(ctx context.Context, q string, limit, offset int) ([]Post, int, error)
+}
+
+// BuiltinSearch is the search used without a search index: the repository's
+// Query filter, in ID order.
+type BuiltinSearch struct {
+	posts postPageLister
+}
+
+func NewBuiltinSearch(posts postPageLister) *BuiltinSearch {
+	return &BuiltinSearch{posts: posts}
+}
+
+func (s *BuiltinSearch) SearchPosts(ctx context.Context, q string, limit, offset int) ([]Post, int, error) {
+	return s.posts.ListPostPage(ctx, ListOptions{Query: q, Limit: limit, Offset: offset})
+}
+
+// SearchPostsHandler answers GET /posts/search?q= with the same body and
+// paging as GET /posts.
+func SearchPostsHandler(search PostSearcher) func(*gin.Context) {
+	return func(c *gin.Context) {
+		q := c.Query("q")
+		if q == "" {
+			abortWithProblem(c, apperr.ValidationFailed, "q is required")
+			return
+		}
+		limit, offset, ok := pageParams(c.Writer, c.Request)
+		if !ok {
+			return
+		}
+
+		posts, total, err := search.SearchPosts(c.Request.Context(), q, limit, offset)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Header("X-Total-Count", strconv.Itoa(total))
+		renderPostList(c.Writer, c.Request, posts, total, limit, offset)
+	}
+}
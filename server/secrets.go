@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gosolid/internal/clock"
+	"gosolid/internal/httpapi"
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+type SecretsProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+type EnvFileSecrets struct {
+	values map[string]string
+}
+
+func NewEnvFileSecrets(path string) (*EnvFileSecrets, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &EnvFileSecrets{values: values}, nil
+}
+
+func (s *EnvFileSecrets) GetSecret(ctx context.Context, name string) (string, error) {
+	value, ok := s.values[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// VaultSecrets reads key/value pairs from a single secret in a Vault KV v2
+// engine. The secret is read whole and answers lookups for ttl, so the
+// secrets read at startup and on reloads take one request between them.
+type VaultSecrets struct {
+	endpoint string
+	token    string
+	ttl      time.Duration
+	client   *http.Client
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	values  map[string]string
+	fetched time.Time
+}
+
+func NewVaultSecrets(addr, token, mount, path string, ttl time.Duration) *VaultSecrets {
+	return &VaultSecrets{
+		endpoint: strings.TrimSuffix(addr, "/") + "/v1/" + escapeSegments(mount) + "/data/" + escapeSegments(path),
+		token:    token,
+		ttl:      ttl,
+		client:   &http.Client{Timeout: 10 * time.Second, Transport: &httpapi.RequestIDTransport{}},
+		clock:    clock.System,
+	}
+}
+
+// escapeSegments escapes each segment of a slash-separated Vault path, so a
+// name such as "apps/blog prod" stays two segments.
+func escapeSegments(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+type vaultKVResp struct {
+	Data struct {
+		Data map[string]string `json:"data"`
+	} `json:"data"`
+}
+
+func (s *VaultSecrets) GetSecret(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	if s.values == nil || now.Sub(s.fetched) >= s.ttl {
+		values, err := s.fetch(ctx)
+		if err != nil {
+			return "", err
+		}
+		s.values, s.fetched = values, now
+	}
+
+	value, ok := s.values[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// fetch reads every key of the secret.
+func (s *VaultSecrets) fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrSecretNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: unexpected status %s", resp.Status)
+	}
+
+	var kvResp vaultKVResp
+	if err := json.NewDecoder(resp.Body).Decode(&kvResp); err != nil {
+		return nil, err
+	}
+	if kvResp.Data.Data == nil {
+		return map[string]string{}, nil
+	}
+	return kvResp.Data.Data, nil
+}
+
+func NewSecretsProvider(cfg SecretsConfig) (SecretsProvider, error) {
+	switch cfg.Provider {
+	case "vault":
+		token := os.Getenv("VAULT_TOKEN")
+		if token == "" {
+			return nil, errors.New("secrets: VAULT_TOKEN is required for the vault provider")
+		}
+		return NewVaultSecrets(cfg.VaultAddr, token, cfg.VaultMount, cfg.VaultPath, cfg.VaultCacheTTL.Duration), nil
+	case "envfile":
+		if cfg.File == "" {
+			secrets, err := NewEnvFileSecrets(".env")
+			if errors.Is(err, os.ErrNotExist) {
+				return &EnvFileSecrets{values: map[string]string{}}, nil
+			}
+			return secrets, err
+		}
+		return NewEnvFileSecrets(cfg.File)
+	default:
+		return nil, fmt.Errorf("secrets: unknown provider %q", cfg.Provider)
+	}
+}
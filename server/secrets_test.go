@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gosolid/internal/clock"
+)
+
+func TestVaultSecretsCachesAndEscapes(t *testing.T) {
+	var requests int
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.EscapedPath() != "/v1/secret/data/apps/blog%20prod" {
+			t.Errorf("requested %s", r.URL.EscapedPath())
+		}
+		if r.Header.Get("X-Vault-Token") != "root" {
+			t.Errorf("X-Vault-Token %q, want root", r.Header.Get("X-Vault-Token"))
+		}
+		w.Write([]byte(`{"data":{"data":{"ADMIN_TOKEN":"admin","SMTP_PASSWORD":"smtp"}}}`))
+	}))
+	defer vault.Close()
+
+	secrets := NewVaultSecrets(vault.URL, "root", "secret", "apps/blog prod", time.Minute)
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	secrets.clock = fake
+	ctx := context.Background()
+
+	for name, want := range map[string]string{"ADMIN_TOKEN": "admin", "SMTP_PASSWORD": "smtp"} {
+		if got, err := secrets.GetSecret(ctx, name); err != nil || got != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := secrets.GetSecret(ctx, "MISSING"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("missing key: %v, want ErrSecretNotFound", err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests within the TTL, want 1", requests)
+	}
+
+	fake.Advance(time.Minute)
+	if _, err := secrets.GetSecret(ctx, "ADMIN_TOKEN"); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("%d requests once the TTL passed, want 2", requests)
+	}
+}
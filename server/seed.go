@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/internal/fixtures"
+)
+
+// SeedReport is what POST /admin/seed added; authors and tags are counted
+// but not stored.
+type SeedReport fixtures.Report
+
+// SeedHandler takes a fixture file as the request body, JSON or YAML by its
+// Content-Type, and adds its posts to whatever the store already holds.
+func SeedHandler(svc interface {
+	Seed(ctx context.Context, f fixtures.File) (SeedReport, error)
+}) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var format string
+		switch mediaType, _, _ := mime.ParseMediaType(c.ContentType()); mediaType {
+		case "application/json":
+			format = "json"
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			format = "yaml"
+		default:
+			abortWithProblem(c, apperr.ValidationFailed, "fixtures must be application/json or application/yaml")
+			return
+		}
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		f, err := fixtures.Parse(data, format)
+		if err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+
+		report, err := svc.Seed(c.Request.Context(), f)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// seed loads the fixtures at cfg.Seed into a store that has no posts yet, so
+// restarting on a persistent backend doesn't add them again.
+func (a *App) seed(ctx context.Context) error {
+	f, err := fixtures.Load(a.cfg.Seed)
+	if err != nil {
+		return err
+	}
+	_, total, err := a.repos.Top.ListPosts(ctx, ListOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		slog.Info("seed skipped: store is not empty", "fixtures", a.cfg.Seed, "posts", total)
+		return nil
+	}
+	report, err := a.posts.Seed(ctx, f)
+	if err != nil {
+		return err
+	}
+	slog.Info("seeded", "fixtures", a.cfg.Seed, "posts", report.Created, "authors_skipped", report.AuthorsSkipped, "tags_skipped", report.TagsSkipped)
+	return nil
+}
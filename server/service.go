@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"io"
+
+	"gosolid/internal/fixtures"
+)
+
+// PostUseCases is what can be done with posts, whichever API it's done
+// through. The handlers only translate HTTP to and from it, each depending
+// on the methods it calls; the gRPC server takes all of it.
+type PostUseCases interface {
+	CreatePost(ctx context.Context, title, body string) (Post, error)
+	CreatePosts(ctx context.Context, posts []Post) ([]Post, error)
+	ImportPosts(ctx context.Context, rows []Post) (ImportResp, error)
+	ImportWordPress(ctx context.Context, r io.Reader, dryRun bool) (WordPressImportReport, error)
+	Seed(ctx context.Context, f fixtures.File) (SeedReport, error)
+	GetPost(ctx context.Context, id int) (Post, error)
+	GetPostBody(ctx context.Context, id int) (PostBody, error)
+	PostIDByUID(ctx context.Context, uid string) (int, error)
+	ListPosts(ctx context.Context) ([]Post, error)
+	ListPostPage(ctx context.Context, opts ListOptions) ([]Post, int, error)
+	EachPost(ctx context.Context, fn func(Post) error) error
+	SearchPosts(ctx context.Context, q string, limit, offset int) ([]Post, int, error)
+	UpdatePost(ctx context.Context, id int, title, body *string) (Post, error)
+	DeletePost(ctx context.Context, id int) error
+	InUnitOfWork(ctx context.Context, fn func(uow *UnitOfWork) error) ([]Post, error)
+}
+
+var _ PostUseCases = (*PostService)(nil)
+
+// PostService holds the post use cases so the HTTP handlers and the gRPC
+// server behave the same way. Writes are PostCommands and reads are
+// PostQueries, each given its own repository and tuned on its own.
+type PostService struct {
+	*PostCommands
+	*PostQueries
+}
+
+// NewPostService writes and reads through db, searching it with its Query
+// filter.
+func NewPostService(db PostRepository, features interface {
+	Enabled(name string) bool
+}) *PostService {
+	return &PostService{NewPostCommands(db, features), NewPostQueries(db, nil)}
+}
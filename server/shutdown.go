@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"gosolid/internal/storage"
+)
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// ShutdownHooks run last-registered first, like defers, so components are torn
+// down before the things they depend on.
+type ShutdownHooks struct {
+	hooks []shutdownHook
+}
+
+func (s *ShutdownHooks) Add(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+func (s *ShutdownHooks) Run(ctx context.Context) error {
+	var errs []error
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		hook := s.hooks[i]
+		if err := hook.fn(ctx); err != nil {
+			slog.Error("shutdown hook failed", "hook", hook.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func CloseRepository(db PostRepository) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if closer, ok := storage.Unwrap[interface{ Close(ctx context.Context) error }](db); ok {
+			return closer.Close(ctx)
+		}
+		if closer, ok := storage.Unwrap[io.Closer](db); ok {
+			return closer.Close()
+		}
+		return nil
+	}
+}
+
+// closeNotifier is CloseRepository for a notifier.
+func closeNotifier(notifier PostUpdateNotifier) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		switch closer := notifier.(type) {
+		case interface {
+			Close(ctx context.Context) error
+		}:
+			return closer.Close(ctx)
+		case io.Closer:
+			return closer.Close()
+		}
+		return nil
+	}
+}
+
+// NewHTTPServer returns the server for handler on addr, tuned by cfg.
+func NewHTTPServer(addr string, handler http.Handler, cfg ServerConfig) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if cfg.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return srv
+}
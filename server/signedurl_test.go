@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/clock"
+)
+
+func newTestSigner(now time.Time) (*URLSigner, *clock.Fake) {
+	fake := clock.NewFake(now)
+	signer := NewURLSigner([]byte("test key"))
+	signer.clock = fake
+	return signer, fake
+}
+
+func TestURLSigner(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	signer, _ := newTestSigner(now)
+	signed := signer.Sign("/files/1/my notes.txt", now.Add(time.Minute))
+
+	tamper := func(fn func(u *url.URL)) *url.URL {
+		u, err := url.Parse(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(u)
+		return u
+	}
+	setQuery := func(key, value string) func(u *url.URL) {
+		return func(u *url.URL) {
+			q := u.Query()
+			q.Set(key, value)
+			u.RawQuery = q.Encode()
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		u    *url.URL
+		at   time.Time
+		want error
+	}{
+		{"valid", tamper(func(*url.URL) {}), now, nil},
+		{"at expiry", tamper(func(*url.URL) {}), now.Add(time.Minute), nil},
+		{"expired", tamper(func(*url.URL) {}), now.Add(time.Minute + time.Second), ErrURLExpired},
+		{"other path", tamper(func(u *url.URL) { u.Path = "/files/2/my notes.txt" }), now, ErrURLSignatureInvalid},
+		{"extended expiry", tamper(setQuery("expires", "4102444800")), now, ErrURLSignatureInvalid},
+		{"tampered signature", tamper(setQuery("signature", "AAAA")), now, ErrURLSignatureInvalid},
+		{"unsigned", tamper(func(u *url.URL) { u.RawQuery = "" }), now, ErrURLSignatureInvalid},
+		{"expired and tampered", tamper(setQuery("signature", "AAAA")), now.Add(time.Hour), ErrURLSignatureInvalid},
+	} {
+		if err := signer.Verify(tc.u, tc.at); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Verify = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestSignedFileRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	signer, fake := newTestSigner(now)
+	blobs := NewLocalBlobStore(t.TempDir())
+	links := NewAttachmentLinks(blobs, signer, 5*time.Minute)
+	blob, err := blobs.Put(context.Background(), attachmentKey(1, "notes.txt"), strings.NewReader("hello"), 5, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := gin.New()
+	e.GET("/files/:id/:name", RequireSignedURL(signer), SignedFileHandler(blobs))
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	signed, err := links.URL(context.Background(), 1, blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := get(signed)
+	if body, _ := io.ReadAll(w.Body); w.Code != http.StatusOK || string(body) != "hello" {
+		t.Errorf("signed URL: %d %q, want 200 with the file", w.Code, body)
+	}
+
+	if w := get(strings.Replace(signed, "/files/1/", "/files/2/", 1)); w.Code != http.StatusForbidden {
+		t.Errorf("URL for another post: status %d, want 403", w.Code)
+	}
+	if w := get("/files/1/notes.txt"); w.Code != http.StatusForbidden {
+		t.Errorf("unsigned URL: status %d, want 403", w.Code)
+	}
+
+	fake.Advance(5*time.Minute + time.Second)
+	if w := get(signed); w.Code != http.StatusGone {
+		t.Errorf("expired URL: status %d, want 410", w.Code)
+	}
+}
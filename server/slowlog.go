@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	slowRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_slow_requests_total",
+		Help: "HTTP requests that took longer than the slow request threshold.",
+	}, []string{"method", "route"})
+
+	slowRepositoryOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "repository_slow_operations_total",
+		Help: "Post repository operations that took longer than the slow query threshold.",
+	}, []string{"backend", "operation"})
+)
+
+type routeKey struct{}
+
+func routeFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// SlowRequestMiddleware warns about requests slower than threshold. It also
+// puts the matched route on the request context so slow repository calls can
+// say which endpoint they were serving.
+func SlowRequestMiddleware(threshold time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), routeKey{}, route))
+
+		start := time.Now()
+		c.Next()
+
+		elapsed := time.Since(start)
+		if elapsed < threshold || c.GetBool(streamingKey) {
+			return
+		}
+		slowRequestsTotal.WithLabelValues(c.Request.Method, route).Inc()
+		slog.With("component", "http").WarnContext(c.Request.Context(), "slow request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", route,
+			"status", c.Writer.Status(),
+			"duration", elapsed,
+			"threshold", threshold,
+		)
+	}
+}
+
+type SlowQueryPostRepository struct {
+	next      PostRepository
+	backend   string
+	threshold time.Duration
+}
+
+func NewSlowQueryPostRepository(next PostRepository, backend string, threshold time.Duration) *SlowQueryPostRepository {
+	return &SlowQueryPostRepository{next: next, backend: backend, threshold: threshold}
+}
+
+func (r *SlowQueryPostRepository) Unwrap() PostRepository { return r.next }
+
+func (r *SlowQueryPostRepository) observe(ctx context.Context, operation string, id int, start time.Time, err error) {
+	elapsed := time.Since(start)
+	if elapsed < r.threshold {
+		return
+	}
+	slowRepositoryOperationsTotal.WithLabelValues(r.backend, operation).Inc()
+	attrs := []any{
+		"operation", operation,
+		"backend", r.backend,
+		"route", routeFromContext(ctx),
+		"duration", elapsed,
+		"threshold", r.threshold,
+	}
+	if id != 0 {
+		attrs = append(attrs, "post_id", id)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.With("component", "repository").WarnContext(ctx, "slow repository operation", attrs...)
+}
+
+func (r *SlowQueryPostRepository) AddPost(ctx context.Context, newPost Post) (post Post, err error) {
+	defer func(start time.Time) { r.observe(ctx, "AddPost", post.ID, start, err) }(time.Now())
+	return r.next.AddPost(ctx, newPost)
+}
+
+func (r *SlowQueryPostRepository) AddPosts(ctx context.Context, newPosts []Post) (posts []Post, err error) {
+	defer func(start time.Time) { r.observe(ctx, "AddPosts", 0, start, err) }(time.Now())
+	return r.next.AddPosts(ctx, newPosts)
+}
+
+func (r *SlowQueryPostRepository) GetPostByID(ctx context.Context, id int) (post Post, err error) {
+	defer func(start time.Time) { r.observe(ctx, "GetPostByID", id, start, err) }(time.Now())
+	return r.next.GetPostByID(ctx, id)
+}
+
+func (r *SlowQueryPostRepository) ListPosts(ctx context.Context, opts ListOptions) (posts []Post, total int, err error) {
+	defer func(start time.Time) { r.observe(ctx, "ListPosts", 0, start, err) }(time.Now())
+	return r.next.ListPosts(ctx, opts)
+}
+
+// EachPost isn't timed: most of its time is spent in fn, not the backend.
+func (r *SlowQueryPostRepository) EachPost(ctx context.Context, fn func(Post) error) error {
+	return r.next.EachPost(ctx, fn)
+}
+
+func (r *SlowQueryPostRepository) UpdatePost(ctx context.Context, updatePost Post) (post Post, err error) {
+	defer func(start time.Time) { r.observe(ctx, "UpdatePost", updatePost.ID, start, err) }(time.Now())
+	return r.next.UpdatePost(ctx, updatePost)
+}
+
+func (r *SlowQueryPostRepository) DeletePostByID(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) { r.observe(ctx, "DeletePostByID", id, start, err) }(time.Now())
+	return r.next.DeletePostByID(ctx, id)
+}
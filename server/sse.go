@@ -0,0 +1,78 @@
+package server
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+const sseKeepAlive = 30 * time.Second
+
+// SSEHandler streams post events as Server-Sent Events, one event per post
+// write named after its type. A client reconnecting with Last-Event-ID (or
+// ?last_event_id, since EventSource can't set headers on its first request)
+// first gets the retained events it missed.
+func SSEHandler(bus *EventBus) func(*gin.Context) {
+	return func(c *gin.Context) {
+		filter, err := EventFilterFromQuery(c)
+		if err != nil {
+			abortWithProblem(c, apperr.ValidationFailed, "post_id must be an integer")
+			return
+		}
+
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("last_event_id")
+		}
+		var afterID uint64
+		if lastEventID != "" {
+			if afterID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+				abortWithProblem(c, apperr.ValidationFailed, "Last-Event-ID must be an event id")
+				return
+			}
+		}
+
+		replay, events, unsubscribe := bus.Subscribe(filter, afterID)
+		defer unsubscribe()
+		markStreaming(c)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		for _, event := range replay {
+			renderSSE(c, event)
+		}
+		c.Writer.Flush()
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+				renderSSE(c, event)
+				return true
+			case <-ticker.C:
+				_, err := io.WriteString(w, ": keepalive\n\n")
+				return err == nil
+			}
+		})
+	}
+}
+
+func renderSSE(c *gin.Context, event PostEvent) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RuntimeStats struct {
+	GoVersion      string `json:"go_version"`
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapSysBytes   uint64 `json:"heap_sys_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+type StatsResp struct {
+	Posts          int            `json:"posts"`
+	StorageBackend string         `json:"storage_backend"`
+	Queues         map[string]int `json:"queues"`
+	Runtime        RuntimeStats   `json:"runtime"`
+	StartedAt      time.Time      `json:"started_at"`
+	UptimeSeconds  float64        `json:"uptime_seconds"`
+}
+
+type Stats struct {
+	storageBackend string
+	startedAt      time.Time
+
+	mu     sync.RWMutex
+	queues map[string]func() int
+}
+
+func NewStats(storageBackend string, startedAt time.Time) *Stats {
+	return &Stats{
+		storageBackend: storageBackend,
+		startedAt:      startedAt,
+		queues:         make(map[string]func() int),
+	}
+}
+
+// RegisterQueue lets background workers report how much work is pending.
+func (s *Stats) RegisterQueue(name string, depth func() int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues[name] = depth
+}
+
+func (s *Stats) queueDepths() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	depths := make(map[string]int, len(s.queues))
+	for name, depth := range s.queues {
+		depths[name] = depth()
+	}
+	return depths
+}
+
+func readRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return RuntimeStats{
+		GoVersion:      runtime.Version(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: m.HeapAlloc,
+		HeapSysBytes:   m.HeapSys,
+		HeapObjects:    m.HeapObjects,
+		NumGC:          m.NumGC,
+	}
+}
+
+func StatsHandler(db PostReader, stats *Stats) func(*gin.Context) {
+	return func(c *gin.Context) {
+		// Only the total is needed; the one post is just the smallest page.
+		_, total, err := db.ListPosts(c.Request.Context(), ListOptions{Limit: 1})
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, StatsResp{
+			Posts:          total,
+			StorageBackend: stats.storageBackend,
+			Queues:         stats.queueDepths(),
+			Runtime:        readRuntimeStats(),
+			StartedAt:      stats.startedAt,
+			UptimeSeconds:  time.Since(stats.startedAt).Seconds(),
+		})
+	}
+}
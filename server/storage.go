@@ -0,0 +1,21 @@
+package server
+
+import "gosolid/internal/storage"
+
+// NewPostStore returns the configured backend, built in or registered with
+// storage.Register. storage.layers wraps it in the same layers whichever
+// backend it is; see RepositoryDecorators.
+func NewPostStore(cfg StorageConfig) (PostRepository, error) {
+	return storage.NewPostStore(cfg.backend())
+}
+
+// backend is the part of cfg the backend itself is built from.
+func (cfg StorageConfig) backend() storage.Config {
+	return storage.Config{
+		Backend:       cfg.Backend,
+		EventLog:      cfg.EventLog,
+		IDs:           cfg.IDs,
+		SnowflakeNode: cfg.SnowflakeNode,
+		Settings:      cfg.Settings,
+	}
+}
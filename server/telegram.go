@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gosolid/apperr"
+	"gosolid/internal/domain"
+)
+
+const (
+	telegramListSize   = 10
+	telegramMaxMessage = 4096
+)
+
+const telegramHelp = `Commands:
+/login <token> – link your chat account to an API token (private chat only)
+/logout – unlink it
+/new <title>, then the body on the following lines – create and publish a post
+/list [page] – newest posts
+/show <id> – one post
+/delete <id> – delete a post`
+
+type telegramPosts interface {
+	CreatePost(ctx context.Context, title, body string) (Post, error)
+	GetPost(ctx context.Context, id int) (Post, error)
+	ListPostPage(ctx context.Context, opts ListOptions) ([]Post, int, error)
+	DeletePost(ctx context.Context, id int) error
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	From      *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// TelegramBot lets Telegram users manage posts through chat commands. A
+// user links their account by sending /login with an API token, and from
+// then on acts as that token's principal, with its scopes. Links are kept in
+// memory, like the tokens themselves.
+type TelegramBot struct {
+	api         string
+	client      *http.Client
+	pollTimeout time.Duration
+	tokens      *TokenStore
+	posts       telegramPosts
+	blogBase    string
+	logger      *slog.Logger
+
+	mu       sync.RWMutex
+	sessions map[int64]Principal
+}
+
+func NewTelegramBot(cfg TelegramConfig, botToken string, tokens *TokenStore, posts telegramPosts, blogBase string) *TelegramBot {
+	return &TelegramBot{
+		api:         strings.TrimSuffix(cfg.APIURL, "/") + "/bot" + botToken + "/",
+		client:      &http.Client{Timeout: cfg.PollTimeout.Duration + 10*time.Second},
+		pollTimeout: cfg.PollTimeout.Duration,
+		tokens:      tokens,
+		posts:       posts,
+		blogBase:    strings.TrimSuffix(blogBase, "/"),
+		logger:      slog.With("component", "telegram"),
+		sessions:    map[int64]Principal{},
+	}
+}
+
+// call posts a Bot API method. Transport errors are unwrapped from their
+// *url.Error because its URL contains the bot token.
+func (b *TelegramBot) call(ctx context.Context, method string, params, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.api+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram %s: build request", method)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("telegram %s: %s: %w", method, resp.Status, err)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("telegram %s: %s", method, envelope.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// Run long-polls for updates until ctx is done.
+func (b *TelegramBot) Run(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		var updates []telegramUpdate
+		err := b.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         int(b.pollTimeout.Seconds()),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() == nil {
+				b.logger.ErrorContext(ctx, "get updates", "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+			}
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if msg := update.Message; msg != nil && msg.From != nil && strings.HasPrefix(msg.Text, "/") {
+				b.handle(ctx, msg)
+			}
+		}
+	}
+}
+
+func (b *TelegramBot) reply(ctx context.Context, msg *telegramMessage, text string) {
+	err := b.call(ctx, "sendMessage", map[string]any{
+		"chat_id":             msg.Chat.ID,
+		"text":                domain.TruncateRunes(text, telegramMaxMessage),
+		"reply_to_message_id": msg.MessageID,
+		// The /login message is deleted before the reply goes out.
+		"allow_sending_without_reply": true,
+	}, nil)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "send message", "chat_id", msg.Chat.ID, "error", err)
+	}
+}
+
+func (b *TelegramBot) principal(userID int64) (Principal, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	principal, ok := b.sessions[userID]
+	return principal, ok
+}
+
+func (b *TelegramBot) handle(ctx context.Context, msg *telegramMessage) {
+	line, rest, _ := strings.Cut(msg.Text, "\n")
+	command, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	// In groups commands may be addressed as /new@SomeBot.
+	command, _, _ = strings.Cut(command, "@")
+	args = strings.TrimSpace(args)
+	logger := b.logger.With("user_id", msg.From.ID, "command", command)
+
+	switch command {
+	case "/start", "/help":
+		b.reply(ctx, msg, telegramHelp)
+		return
+	case "/login":
+		b.login(ctx, msg, args)
+		return
+	case "/logout":
+		b.mu.Lock()
+		delete(b.sessions, msg.From.ID)
+		b.mu.Unlock()
+		b.reply(ctx, msg, "Logged out.")
+		return
+	}
+
+	scope, ok := map[string]Scope{"/new": ScopePostsWrite, "/delete": ScopePostsWrite, "/list": ScopePostsRead, "/show": ScopePostsRead}[command]
+	if !ok {
+		b.reply(ctx, msg, "Unknown command. "+telegramHelp)
+		return
+	}
+	principal, ok := b.principal(msg.From.ID)
+	if !ok {
+		b.reply(ctx, msg, "Send /login <token> in a private chat first.")
+		return
+	}
+	if !principal.HasScope(scope) {
+		b.reply(ctx, msg, "Your token lacks the "+string(scope)+" scope.")
+		return
+	}
+
+	text, err := b.run(ctx, command, args, rest)
+	if err != nil {
+		appErr := apperr.From(err)
+		if appErr.Code == apperr.Internal {
+			logger.ErrorContext(ctx, "command failed", "principal", principal.Name, "error", err)
+			text = "Something went wrong."
+		} else {
+			text = appErr.Detail
+			if text == "" {
+				text = string(appErr.Code)
+			}
+		}
+	} else {
+		logger.InfoContext(ctx, "command", "principal", principal.Name)
+	}
+	b.reply(ctx, msg, text)
+}
+
+// login links the sender to the token's principal. The message is deleted
+// first, in any chat, so the token doesn't stay in the history.
+func (b *TelegramBot) login(ctx context.Context, msg *telegramMessage, token string) {
+	if err := b.call(ctx, "deleteMessage", map[string]any{"chat_id": msg.Chat.ID, "message_id": msg.MessageID}, nil); err != nil {
+		b.logger.WarnContext(ctx, "delete login message", "error", err)
+	}
+	if msg.Chat.Type != "private" {
+		b.reply(ctx, msg, "Send /login in a private chat with the bot; treat a token sent here as leaked.")
+		return
+	}
+	principal, ok := b.tokens.Lookup(token)
+	if token == "" || !ok {
+		b.reply(ctx, msg, "Invalid token.")
+		return
+	}
+	b.mu.Lock()
+	b.sessions[msg.From.ID] = principal
+	b.mu.Unlock()
+	b.logger.InfoContext(ctx, "linked user", "user_id", msg.From.ID, "principal", principal.Name)
+	b.reply(ctx, msg, "Logged in as "+principal.Name+".")
+}
+
+func (b *TelegramBot) run(ctx context.Context, command, args, rest string) (string, error) {
+	switch command {
+	case "/new":
+		if args == "" {
+			return "", apperr.New(apperr.ValidationFailed, "Usage: /new <title>, then the body on the following lines.")
+		}
+		post, err := b.posts.CreatePost(ctx, args, strings.TrimSpace(rest))
+		if err != nil {
+			return "", err
+		}
+		return "Published #" + strconv.Itoa(post.ID) + ": " + post.Title + b.link(post), nil
+
+	case "/list":
+		page := 1
+		if args != "" {
+			n, err := strconv.Atoi(args)
+			if err != nil || n < 1 {
+				return "", apperr.New(apperr.ValidationFailed, "Usage: /list [page]")
+			}
+			page = n
+		}
+		start := (page - 1) * telegramListSize
+		newest, total, err := b.posts.ListPostPage(ctx, ListOptions{Sort: SortCreatedDesc, Offset: start, Limit: telegramListSize})
+		if err != nil {
+			return "", err
+		}
+		if len(newest) == 0 {
+			return "No posts.", nil
+		}
+		var out strings.Builder
+		for _, post := range newest {
+			fmt.Fprintf(&out, "#%d %s\n", post.ID, post.Title)
+		}
+		if start+len(newest) < total {
+			fmt.Fprintf(&out, "More: /list %d", page+1)
+		}
+		return strings.TrimSpace(out.String()), nil
+
+	case "/show", "/delete":
+		id, err := strconv.Atoi(strings.TrimPrefix(args, "#"))
+		if err != nil {
+			return "", apperr.New(apperr.ValidationFailed, "Usage: "+command+" <id>")
+		}
+		if command == "/delete" {
+			if err := b.posts.DeletePost(ctx, id); err != nil {
+				return "", err
+			}
+			return "Deleted #" + strconv.Itoa(id) + ".", nil
+		}
+		post, err := b.posts.GetPost(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		return "#" + strconv.Itoa(post.ID) + " " + post.Title + "\n\n" + post.Body + b.link(post), nil
+	}
+	return "", fmt.Errorf("telegram: unhandled command %s", command)
+}
+
+func (b *TelegramBot) link(post Post) string {
+	if b.blogBase == "" {
+		return ""
+	}
+	return "\n" + b.blogBase + "/blog/" + blogSlug(post)
+}
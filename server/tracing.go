@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("gosolid")
+
+// SetupTracing installs an OTLP/HTTP exporter when one is configured through
+// the standard OTEL_EXPORTER_OTLP_* variables. Without one, spans are dropped by
+// the global no-op provider but context propagation still works.
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("gosolid"),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type TracingNotifier struct {
+	name string
+	next PostUpdateNotifier
+}
+
+func NewTracingNotifier(name string, next PostUpdateNotifier) *TracingNotifier {
+	return &TracingNotifier{name: name, next: next}
+}
+
+func (n *TracingNotifier) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	ctx, span := tracer.Start(ctx, "PostUpdateNotifier.NotifyPostUpdated", trace.WithAttributes(
+		attribute.String("notifier", n.name),
+		attribute.Int("post.id", post.ID),
+		attribute.String("action", string(action)),
+	))
+	err := n.next.NotifyPostUpdated(ctx, post, action)
+	endSpan(span, err)
+	return err
+}
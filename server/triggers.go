@@ -0,0 +1,333 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+	"gosolid/internal/httpapi"
+)
+
+// Triggers for automation platforms such as Zapier and IFTTT: polling
+// endpoints returning the newest items first, each with an id the platform
+// deduplicates on, and REST hooks, subscriptions that get the same items
+// pushed to them.
+
+const (
+	triggerDefaultLimit = 50
+	triggerMaxLimit     = 100
+)
+
+const (
+	HookEventNewPost     = "new_post"
+	HookEventUpdatedPost = "updated_post"
+	HookEventDeletedPost = "deleted_post"
+)
+
+var hookEvents = []string{HookEventNewPost, HookEventUpdatedPost, HookEventDeletedPost}
+
+var ErrHookNotFound = apperr.New(apperr.HookNotFound, "hook not found")
+
+// TriggerItem is one post as a trigger sees it. ID is unique per event, so a
+// post updated twice yields two items: for updates it is the post ID plus
+// the update time.
+type TriggerItem struct {
+	ID        string `json:"id"`
+	PostID    int    `json:"post_id"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func triggerItem(post Post, event string) TriggerItem {
+	id := strconv.Itoa(post.ID)
+	switch event {
+	case HookEventUpdatedPost:
+		id += "-" + strconv.FormatInt(post.UpdatedAt.UnixMilli(), 10)
+	case HookEventDeletedPost:
+		id += "-deleted"
+	}
+	return TriggerItem{
+		ID:        id,
+		PostID:    post.ID,
+		Title:     post.Title,
+		Body:      post.Body,
+		CreatedAt: formatTimestamp(post.CreatedAt),
+		UpdatedAt: formatTimestamp(post.UpdatedAt),
+	}
+}
+
+// PostTriggerHandler answers the polling trigger for event, new_post or
+// updated_post: posts created (or updated after creation) after ?since=,
+// newest first, up to ?limit=. Platforms that poll without since rely on
+// the item IDs to skip what they already saw.
+func PostTriggerHandler(posts postPageLister, event string) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var since time.Time
+		if raw := c.Query("since"); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				abortWithProblem(c, apperr.ValidationFailed, "since must be an RFC 3339 timestamp")
+				return
+			}
+			since = t
+		}
+		limit := triggerDefaultLimit
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > triggerMaxLimit {
+				abortWithProblem(c, apperr.ValidationFailed, fmt.Sprintf("limit must be between 1 and %d", triggerMaxLimit))
+				return
+			}
+			limit = n
+		}
+
+		if event == HookEventUpdatedPost {
+			items, err := updatedTriggerItems(c.Request.Context(), posts, since, limit)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, items)
+			return
+		}
+
+		matches, _, err := posts.ListPostPage(c.Request.Context(), ListOptions{CreatedAfter: since, Sort: SortCreatedDesc, Limit: limit})
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		items := make([]TriggerItem, 0, len(matches))
+		for _, post := range matches {
+			items = append(items, triggerItem(post, event))
+		}
+		c.JSON(http.StatusOK, items)
+	}
+}
+
+// updatedTriggerItems pages through the posts updated after since, newest
+// first, until it has limit of them that were updated after creation. The
+// others can't be filtered out by the repository, so a page may yield
+// fewer items than it holds; the cursor keeps each page at triggerMaxLimit
+// posts however many are skipped.
+func updatedTriggerItems(ctx context.Context, posts postPageLister, since time.Time, limit int) ([]TriggerItem, error) {
+	items := make([]TriggerItem, 0, limit)
+	opts := ListOptions{UpdatedAfter: since, Sort: SortUpdatedDesc, Limit: triggerMaxLimit}
+	for {
+		page, _, err := posts.ListPostPage(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range page {
+			if !post.UpdatedAt.After(post.CreatedAt) {
+				continue
+			}
+			items = append(items, triggerItem(post, HookEventUpdatedPost))
+			if len(items) == limit {
+				return items, nil
+			}
+		}
+		if len(page) < opts.Limit {
+			return items, nil
+		}
+		last := page[len(page)-1]
+		opts.After, opts.AfterUpdatedAt = last.ID, last.UpdatedAt
+	}
+}
+
+type HookSubscribeReq struct {
+	TargetURL string `json:"target_url"`
+	Event     string `json:"event"`
+}
+
+type HookResp struct {
+	ID        string `json:"id"`
+	TargetURL string `json:"target_url"`
+	Event     string `json:"event"`
+}
+
+type hookSubscription struct {
+	HookResp
+	principal string
+}
+
+// RESTHooks keeps REST hook subscriptions and delivers post events to them.
+// A target answering 410 Gone is unsubscribed, as REST hooks specify.
+// Subscriptions live in memory, so a restart drops them until the platform
+// subscribes again.
+type RESTHooks struct {
+	cfg    TriggersConfig
+	client *http.Client
+	logger *slog.Logger
+
+	mu   sync.RWMutex
+	subs map[string]hookSubscription
+}
+
+func NewRESTHooks(cfg TriggersConfig, timeout time.Duration) *RESTHooks {
+	return &RESTHooks{
+		cfg:    cfg,
+		client: outboundClient(timeout, cfg.AllowPrivateTargets),
+		logger: slog.With("component", "resthooks"),
+		subs:   map[string]hookSubscription{},
+	}
+}
+
+// outboundClient is for requests to URLs that callers, not the operator,
+// chose. Unless allowPrivate is set it only connects to public addresses,
+// and it doesn't follow redirects, which could lead anywhere.
+func outboundClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = publicAddressOnly
+	}
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     &httpapi.RequestIDTransport{Base: &http.Transport{DialContext: dialer.DialContext}},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// publicAddressOnly refuses connections to loopback, private and link-local
+// addresses. It runs after DNS resolution, so a public name resolving to an
+// internal address is refused too.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("target address %s is not public", host)
+	}
+	return nil
+}
+
+func (h *RESTHooks) NotifyPostUpdated(ctx context.Context, post Post, action Action) error {
+	event := map[Action]string{ActionCreate: HookEventNewPost, ActionUpdate: HookEventUpdatedPost, ActionDelete: HookEventDeletedPost}[action]
+	payload, err := json.Marshal(triggerItem(post, event))
+	if err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	var targets []hookSubscription
+	for _, sub := range h.subs {
+		if sub.Event == event {
+			targets = append(targets, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range targets {
+		gone, err := h.deliver(ctx, sub.TargetURL, payload)
+		if gone {
+			h.mu.Lock()
+			delete(h.subs, sub.ID)
+			h.mu.Unlock()
+			h.logger.InfoContext(ctx, "unsubscribed gone target", "hook_id", sub.ID, "principal", sub.principal)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (h *RESTHooks) deliver(ctx context.Context, target string, payload []byte) (gone bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return true, nil
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("rest hook %s: unexpected status %s", target, resp.Status)
+	}
+	return false, nil
+}
+
+// SubscribeHandler answers POST /hooks. The response's id is what the
+// platform sends back to unsubscribe.
+func (h *RESTHooks) SubscribeHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		var req HookSubscribeReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		if !slices.Contains(hookEvents, req.Event) {
+			abortWithProblem(c, apperr.ValidationFailed, "event must be one of new_post, updated_post, deleted_post")
+			return
+		}
+		if u, err := url.Parse(req.TargetURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			abortWithProblem(c, apperr.ValidationFailed, "target_url must be an http or https URL")
+			return
+		}
+		principal, _ := PrincipalFromContext(c)
+
+		h.mu.Lock()
+		owned := 0
+		for _, sub := range h.subs {
+			if sub.principal == principal.Name {
+				owned++
+			}
+		}
+		if owned >= h.cfg.MaxHooksPerToken {
+			h.mu.Unlock()
+			abortWithProblem(c, apperr.Conflict, fmt.Sprintf("at most %d hooks per token", h.cfg.MaxHooksPerToken))
+			return
+		}
+		sub := hookSubscription{HookResp: HookResp{ID: rand.Text(), TargetURL: req.TargetURL, Event: req.Event}, principal: principal.Name}
+		h.subs[sub.ID] = sub
+		h.mu.Unlock()
+
+		h.logger.InfoContext(c.Request.Context(), "subscribed", "hook_id", sub.ID, "event", sub.Event, "principal", principal.Name)
+		c.JSON(http.StatusCreated, sub.HookResp)
+	}
+}
+
+// UnsubscribeHandler answers DELETE /hooks/:id for hooks the caller's token
+// created.
+func (h *RESTHooks) UnsubscribeHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		principal, _ := PrincipalFromContext(c)
+		id := c.Param("id")
+
+		h.mu.Lock()
+		sub, ok := h.subs[id]
+		if ok && sub.principal == principal.Name {
+			delete(h.subs, id)
+		}
+		h.mu.Unlock()
+
+		if !ok || sub.principal != principal.Name {
+			abortWithError(c, ErrHookNotFound)
+			return
+		}
+		h.logger.InfoContext(c.Request.Context(), "unsubscribed", "hook_id", id, "principal", principal.Name)
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/storage"
+)
+
+// pageRecorder lists posts from a store and keeps the options it was asked
+// for.
+type pageRecorder struct {
+	db    *storage.DB
+	pages []ListOptions
+}
+
+func (r *pageRecorder) ListPostPage(ctx context.Context, opts ListOptions) ([]Post, int, error) {
+	r.pages = append(r.pages, opts)
+	return r.db.ListPosts(ctx, opts)
+}
+
+func TestUpdatedPostTriggerPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db := storage.NewDB()
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var updated []int
+	for range 3 {
+		post, err := db.AddPost(ctx, Post{Title: "edited", CreatedAt: at, UpdatedAt: at.Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated = append(updated, post.ID)
+	}
+	// Newer posts never updated since creation come first in the order and
+	// fill more than a page.
+	for i := range triggerMaxLimit + 50 {
+		created := at.Add(2*time.Hour + time.Duration(i)*time.Minute)
+		if _, err := db.AddPost(ctx, Post{Title: "new", CreatedAt: created, UpdatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lister := &pageRecorder{db: db}
+	e := gin.New()
+	e.GET("/triggers/updated_posts", PostTriggerHandler(lister, HookEventUpdatedPost))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/triggers/updated_posts?limit=10", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var items []TriggerItem
+	if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ties in UpdatedAt come newest ID first.
+	want := []int{updated[2], updated[1], updated[0]}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		if item.PostID != want[i] {
+			t.Errorf("item %d is post %d, want %d", i, item.PostID, want[i])
+		}
+	}
+	if len(lister.pages) != 2 {
+		t.Errorf("listed %d pages, want 2", len(lister.pages))
+	}
+	for _, opts := range lister.pages {
+		if opts.Limit == 0 || opts.Limit > triggerMaxLimit {
+			t.Errorf("listed a page of %d posts, want at most %d", opts.Limit, triggerMaxLimit)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/internal/storage"
+)
+
+// PostUIDParam lets a route's :id be a post's UID: it is replaced by the
+// post's ID before the handler reads it. Numeric IDs, and UIDs when the
+// store has none, are left for the handler.
+func PostUIDParam(resolver storage.PostUIDResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := c.Param("id")
+		if _, err := strconv.Atoi(uid); err == nil || uid == "" {
+			c.Next()
+			return
+		}
+		id, err := resolver.PostIDByUID(c.Request.Context(), uid)
+		if errors.Is(err, storage.ErrUIDsUnsupported) {
+			c.Next()
+			return
+		}
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		for i, param := range c.Params {
+			if param.Key == "id" {
+				c.Params[i].Value = strconv.Itoa(id)
+			}
+		}
+		c.Next()
+	}
+}
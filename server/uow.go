@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+
+	"gosolid/internal/storage"
+)
+
+// UnitOfWork stages the writes of a PostCommands.InUnitOfWork call. Nothing
+// is written until it commits, so reads through the service don't see the
+// staged writes; UpdatePost starts from an earlier staged update of the
+// same post, though.
+type UnitOfWork struct {
+	svc    *PostCommands
+	writes []storage.PostWrite
+}
+
+func (u *UnitOfWork) CreatePost(title, body string) {
+	now := u.svc.clock.Now().UTC()
+	u.writes = append(u.writes, storage.PostWrite{Op: storage.PostWriteAdd, Post: Post{Title: title, Body: body, CreatedAt: now, UpdatedAt: now}})
+}
+
+// UpdatePost merges title and body into the post like PostCommands.UpdatePost.
+func (u *UnitOfWork) UpdatePost(ctx context.Context, id int, title, body *string) error {
+	post, err := u.staged(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.svc.mergeUpdate(&post, title, body)
+	u.writes = append(u.writes, storage.PostWrite{Op: storage.PostWriteUpdate, Post: post})
+	return nil
+}
+
+func (u *UnitOfWork) DeletePost(id int) {
+	u.writes = append(u.writes, storage.PostWrite{Op: storage.PostWriteDelete, Post: Post{ID: id}})
+}
+
+// staged returns post id as the unit of work has left it so far.
+func (u *UnitOfWork) staged(ctx context.Context, id int) (Post, error) {
+	for i := len(u.writes) - 1; i >= 0; i-- {
+		if w := u.writes[i]; w.Post.ID == id && w.Op != storage.PostWriteAdd {
+			if w.Op == storage.PostWriteDelete {
+				return Post{}, ErrNotFound
+			}
+			return w.Post, nil
+		}
+	}
+	return u.svc.db.GetPostByID(ctx, id)
+}
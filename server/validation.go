@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+
+	"gosolid/apperr"
+)
+
+// Validator checks a post before the commands write it. A post it rejects
+// comes back as a validation failure whose message says why, which imports
+// report per row.
+type Validator interface {
+	ValidatePost(ctx context.Context, post Post) error
+}
+
+// PostRules are the limits strict validation holds posts to, as binding
+// tags checked the way gin checks request bodies.
+type PostRules struct {
+	Title string `binding:"required,max=200"`
+	Body  string `binding:"max=100000"`
+}
+
+// TagValidator checks posts against the tags of PostRules.
+type TagValidator struct{}
+
+func (TagValidator) ValidatePost(ctx context.Context, post Post) error {
+	if err := binding.Validator.ValidateStruct(PostRules{Title: post.Title, Body: post.Body}); err != nil {
+		return apperr.Wrap(apperr.ValidationFailed, err)
+	}
+	return nil
+}
+
+// PostRule checks one thing about a post, returning why it fails or "".
+type PostRule func(post Post) string
+
+// Rules is a Validator made of rules in code, reporting the first that
+// fails. The zero Rules accepts everything.
+type Rules []PostRule
+
+func (r Rules) ValidatePost(ctx context.Context, post Post) error {
+	for _, rule := range r {
+		if msg := rule(post); msg != "" {
+			return apperr.New(apperr.ValidationFailed, msg)
+		}
+	}
+	return nil
+}
+
+// TitleRequired rejects posts whose title is blank.
+func TitleRequired(post Post) string {
+	if strings.TrimSpace(post.Title) == "" {
+		return "title is required"
+	}
+	return ""
+}
+
+// ValidationPolicies are the policies NewValidator knows.
+var ValidationPolicies = []string{"lenient", "strict"}
+
+// NewValidator returns the validator policy names: strict is TagValidator
+// and lenient is lenient, the rules a caller holds posts to anyway.
+func NewValidator(policy string, lenient Rules) (Validator, error) {
+	switch policy {
+	case "lenient":
+		return lenient, nil
+	case "strict":
+		return TagValidator{}, nil
+	}
+	return nil, fmt.Errorf("validation: unknown policy %q", policy)
+}
+
+// validationMessage is the reason err gives for rejecting a post.
+func validationMessage(err error) (string, bool) {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) && appErr.Code == apperr.ValidationFailed {
+		return appErr.Error(), true
+	}
+	return "", false
+}
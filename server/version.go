@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"gosolid/internal/httpapi"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X gosolid/server.version=v1.2.3 -X gosolid/server.commit=$(git rev-parse HEAD) -X gosolid/server.buildDate=$(date -u +%FT%TZ)"
+//
+// When they are left unset, commit and buildDate fall back to the VCS stamp
+// the Go toolchain embeds.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+type VersionResp struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+func BuildVersion() VersionResp {
+	resp := VersionResp{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if resp.Commit == "" {
+					resp.Commit = setting.Value
+				}
+			case "vcs.time":
+				if resp.BuildDate == "" {
+					resp.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				resp.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return resp
+}
+
+func VersionHandler() http.Handler {
+	resp := BuildVersion()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpapi.WriteJSON(w, http.StatusOK, resp)
+	})
+}
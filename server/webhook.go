@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net/http"
+
+	"gosolid/internal/httpapi"
+	"gosolid/internal/notify"
+)
+
+// NewWebhookNotifiers builds the instrumented notifier chain for every
+// configured webhook.
+func NewWebhookNotifiers(cfg NotifiersConfig) []PostUpdateNotifier {
+	var notifiers []PostUpdateNotifier
+	for _, hook := range cfg.Webhooks {
+		client := &http.Client{Timeout: cfg.Timeout.Duration, Transport: &httpapi.RequestIDTransport{}}
+		var notifier PostUpdateNotifier = notify.NewWebhookNotifier(hook, client)
+		notifier = notify.NewRetryingNotifier(NewMetricsNotifier("webhook", notifier), cfg.Retries, cfg.RetryBackoff.Duration,
+			notifierRetriesTotal.WithLabelValues("webhook").Inc)
+		notifiers = append(notifiers, NewTracingNotifier("webhook", notifier))
+	}
+	return notifiers
+}
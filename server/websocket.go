@@ -0,0 +1,88 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"gosolid/apperr"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// WebSocketHandler streams post events as JSON text messages. Clients only
+// need to answer pings; anything they send is ignored. A client that misses
+// a pong for wsPongWait is disconnected.
+func WebSocketHandler(bus *EventBus) func(*gin.Context) {
+	return func(c *gin.Context) {
+		filter, err := EventFilterFromQuery(c)
+		if err != nil {
+			abortWithProblem(c, apperr.ValidationFailed, "post_id must be an integer")
+			return
+		}
+
+		conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// Upgrade has already answered the client.
+			c.Error(err)
+			return
+		}
+		markStreaming(c)
+		defer conn.Close()
+
+		_, events, unsubscribe := bus.Subscribe(filter, 0)
+		defer unsubscribe()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			conn.SetReadLimit(512)
+			conn.SetReadDeadline(time.Now().Add(wsPongWait))
+			conn.SetPongHandler(func(string) error {
+				return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+			})
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-c.Request.Context().Done():
+				return
+			case event, ok := <-events:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				if !ok {
+					conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscription ended"))
+					return
+				}
+				if err := conn.WriteJSON(event); err != nil {
+					slog.With("component", "events").DebugContext(c.Request.Context(), "websocket write", "error", err)
+					return
+				}
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
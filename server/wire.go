@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"gosolid/apperr"
+	"gosolid/internal/mapper"
+	"gosolid/postpb"
+)
+
+const (
+	protobufMediaType = "application/x-protobuf"
+	msgpackMediaType  = "application/msgpack"
+)
+
+type wireFormatKey struct{}
+
+// protobufRequests are the messages a protobuf request body is decoded as,
+// by route.
+var protobufRequests = map[string]func() proto.Message{
+	"POST /posts":      func() proto.Message { return &postpb.CreatePostRequest{} },
+	"PATCH /posts/:id": func() proto.Message { return &postpb.UpdatePostRequest{} },
+}
+
+// msgpackHandle decodes into the types encoding/json can marshal.
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	return h
+}()
+
+// wireFormat returns the binary media type negotiated for the response, or
+// "" for JSON.
+func wireFormat(r *http.Request) string {
+	format, _ := r.Context().Value(wireFormatKey{}).(string)
+	return format
+}
+
+func wireMediaType(mediaType string) string {
+	switch mediaType {
+	case protobufMediaType, "application/protobuf":
+		return protobufMediaType
+	case msgpackMediaType, "application/x-msgpack":
+		return msgpackMediaType
+	}
+	return ""
+}
+
+// WireFormatMiddleware lets the post endpoints speak protobuf (the postpb
+// messages) and MessagePack (the JSON DTOs under the same field names). Like
+// JSONAPIMiddleware, it turns binary request bodies into the JSON the
+// handlers bind. Errors are still problem+json.
+func WireFormatMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+			if mediaType, _, err := mime.ParseMediaType(accept); err == nil && wireMediaType(mediaType) != "" {
+				c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), wireFormatKey{}, wireMediaType(mediaType)))
+				break
+			}
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+		format := wireMediaType(mediaType)
+		if format == "" || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, apperr.Invalid(err))
+			return
+		}
+		if format == protobufMediaType {
+			body, err = protobufToJSON(c.Request.Method+" "+c.FullPath(), body)
+		} else {
+			body, err = msgpackToJSON(body)
+		}
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Next()
+	}
+}
+
+func protobufToJSON(route string, body []byte) ([]byte, error) {
+	newMessage, ok := protobufRequests[route]
+	if !ok {
+		return nil, apperr.New(apperr.ValidationFailed, "this endpoint doesn't accept "+protobufMediaType)
+	}
+	msg := newMessage()
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, apperr.Wrap(apperr.ValidationFailed, err)
+	}
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+}
+
+func msgpackToJSON(body []byte) ([]byte, error) {
+	var v any
+	if err := codec.NewDecoderBytes(body, msgpackHandle).Decode(&v); err != nil {
+		return nil, apperr.Wrap(apperr.ValidationFailed, err)
+	}
+	return json.Marshal(v)
+}
+
+// renderWirePost renders post in the negotiated binary format and reports
+// whether it did.
+func renderWirePost(w http.ResponseWriter, r *http.Request, status int, post Post, plain any) bool {
+	switch wireFormat(r) {
+	case protobufMediaType:
+		writeRender(w, r, status, render.ProtoBuf{Data: mapper.ToPostpb(post)})
+	case msgpackMediaType:
+		writeRender(w, r, status, render.MsgPack{Data: plain})
+	default:
+		return false
+	}
+	return true
+}
+
+// renderWirePostSummaries answers protobuf requests with 501, since
+// postpb has no summary message.
+func renderWirePostSummaries(w http.ResponseWriter, r *http.Request, page []Post) bool {
+	switch wireFormat(r) {
+	case protobufMediaType:
+		writeProblem(w, r, apperr.UnsupportedFormat, "view=summary is not available as protobuf")
+	case msgpackMediaType:
+		writeRender(w, r, http.StatusOK, render.MsgPack{Data: postSummaryData(page)})
+	default:
+		return false
+	}
+	return true
+}
+
+func renderWirePostList(w http.ResponseWriter, r *http.Request, page []Post, total int) bool {
+	switch wireFormat(r) {
+	case protobufMediaType:
+		list := &postpb.PostList{Posts: mapper.Slice(page, mapper.ToPostpb), Total: int64(total)}
+		writeRender(w, r, http.StatusOK, render.ProtoBuf{Data: list})
+	case msgpackMediaType:
+		writeRender(w, r, http.StatusOK, render.MsgPack{Data: postListData(page)})
+	default:
+		return false
+	}
+	return true
+}
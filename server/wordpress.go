@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gosolid/apperr"
+)
+
+// wxrItem is the part of a WordPress eXtended RSS <item> we read. Fields
+// without a namespace match whichever WXR version (1.0 to 1.2) wrote them.
+type wxrItem struct {
+	Title       string `xml:"title"`
+	Creator     string `xml:"creator"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID      int    `xml:"post_id"`
+	PostDateGMT string `xml:"post_date_gmt"`
+	ModifiedGMT string `xml:"post_modified_gmt"`
+	Status      string `xml:"status"`
+	PostType    string `xml:"post_type"`
+	Categories  []struct {
+		Domain string `xml:"domain,attr"`
+	} `xml:"category"`
+	Comments []struct{} `xml:"comment"`
+}
+
+type WordPressSkip struct {
+	WordPressID int    `json:"wordpress_id"`
+	Title       string `json:"title"`
+	Reason      string `json:"reason"`
+}
+
+// WordPressImportReport lists what was imported and what wasn't. Posts are the
+// only entity here so far: authors, categories, tags and comments are counted
+// but not imported.
+type WordPressImportReport struct {
+	DryRun            bool            `json:"dry_run"`
+	Imported          int             `json:"imported"`
+	Skipped           []WordPressSkip `json:"skipped"`
+	AuthorsSkipped    int             `json:"authors_skipped"`
+	CategoriesSkipped int             `json:"categories_skipped"`
+	TagsSkipped       int             `json:"tags_skipped"`
+	CommentsSkipped   int             `json:"comments_skipped"`
+}
+
+func parseWXRTime(s string) time.Time {
+	t, err := time.Parse(time.DateTime, s)
+	if err != nil {
+		// Drafts carry 0000-00-00 00:00:00.
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// importWordPress reads a WXR export item by item. Published, draft, pending
+// and private posts become posts with their original dates; pages,
+// attachments, trashed posts and posts validator rejects are skipped. With
+// dryRun nothing is written.
+func importWordPress(ctx context.Context, r io.Reader, db PostWriter, validator Validator, dryRun bool) (WordPressImportReport, error) {
+	report := WordPressImportReport{DryRun: dryRun, Skipped: []WordPressSkip{}}
+	authors := map[string]bool{}
+	sawChannel := false
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, apperr.Invalid(fmt.Errorf("wordpress export: %w", err))
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "channel":
+			sawChannel = true
+		case "author":
+			if err := dec.Skip(); err != nil {
+				return report, apperr.Invalid(fmt.Errorf("wordpress export: %w", err))
+			}
+			report.AuthorsSkipped++
+		case "item":
+			var item wxrItem
+			if err := dec.DecodeElement(&item, &start); err != nil {
+				return report, apperr.Invalid(fmt.Errorf("wordpress export: %w", err))
+			}
+			if item.PostType != "post" {
+				report.Skipped = append(report.Skipped, WordPressSkip{item.PostID, item.Title, "post type " + strconv.Quote(item.PostType)})
+				continue
+			}
+			if item.Status == "trash" || item.Status == "auto-draft" {
+				report.Skipped = append(report.Skipped, WordPressSkip{item.PostID, item.Title, "status " + item.Status})
+				continue
+			}
+
+			post := Post{
+				Title:     strings.TrimSpace(item.Title),
+				Body:      item.Content,
+				CreatedAt: parseWXRTime(item.PostDateGMT),
+				UpdatedAt: parseWXRTime(item.ModifiedGMT),
+			}
+			if post.UpdatedAt.IsZero() {
+				post.UpdatedAt = post.CreatedAt
+			}
+			if err := validator.ValidatePost(ctx, post); err != nil {
+				msg, invalid := validationMessage(err)
+				if !invalid {
+					return report, err
+				}
+				report.Skipped = append(report.Skipped, WordPressSkip{item.PostID, item.Title, msg})
+				continue
+			}
+
+			if item.Creator != "" {
+				authors[item.Creator] = true
+			}
+			for _, category := range item.Categories {
+				if category.Domain == "post_tag" {
+					report.TagsSkipped++
+				} else {
+					report.CategoriesSkipped++
+				}
+			}
+			report.CommentsSkipped += len(item.Comments)
+
+			if !dryRun {
+				if _, err := db.AddPost(ctx, post); err != nil {
+					return report, err
+				}
+			}
+			report.Imported++
+		}
+	}
+	if !sawChannel {
+		return report, apperr.New(apperr.ValidationFailed, "wordpress export: no <channel> element")
+	}
+	// Exports without a <wp:author> list still name authors on each item.
+	report.AuthorsSkipped = max(report.AuthorsSkipped, len(authors))
+	return report, nil
+}
+
+// ImportWordPressHandler takes the export file as the request body. Posts are
+// written as they are read, so an error part way leaves the earlier ones in
+// place; run with ?dry_run=true first to check the file.
+func ImportWordPressHandler(svc interface {
+	ImportWordPress(ctx context.Context, r io.Reader, dryRun bool) (WordPressImportReport, error)
+}) func(*gin.Context) {
+	return func(c *gin.Context) {
+		dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+		if err != nil {
+			abortWithProblem(c, apperr.ValidationFailed, "dry_run must be a boolean")
+			return
+		}
+
+		report, err := svc.ImportWordPress(c.Request.Context(), c.Request.Body, dryRun)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -1,3 +1,3 @@
 #!/usr/bin/env bash
 
-curl -v -XGET "localhost:8080/posts"
+curl -H "Authorization: Bearer $API_TOKEN" -v -XGET "localhost:8080/posts"
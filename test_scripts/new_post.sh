@@ -1,6 +1,6 @@
 #!/usr/bin/env bash
 
-curl -XPOST -H "Content-Type:application/json" "localhost:8080/posts"  -d '{
+curl -H "Authorization: Bearer $API_TOKEN" -XPOST -H "Content-Type:application/json" "localhost:8080/posts"  -d '{
   "title": "'"$1"'",
   "body": "'"$2"'"
 }'